@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"iconrepo/internal/app"
 	"iconrepo/internal/config"
@@ -11,12 +12,118 @@ import (
 	"math/rand"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
 )
 
+const (
+	checkConsistencyCommand = "check-consistency"
+	reindexCommand          = "reindex"
+	migrateIndexCommand     = "migrate-index"
+)
+
+// checkConsistency prints the consistency report as JSON and returns the exit code: 1 if inconsistencies are left
+func checkConsistency(ctx context.Context, args []string) int {
+	repair := false
+	for _, value := range args {
+		if value == "--repair" {
+			repair = true
+		}
+	}
+
+	conf, confErr := config.ReadConfiguration(config.GetConfigFilePath(), args)
+	if confErr != nil {
+		panic(confErr)
+	}
+
+	report, checkErr := app.CheckConsistency(ctx, conf, repair)
+	if checkErr != nil {
+		fmt.Fprintf(os.Stderr, "Consistency check failed: %v\n", checkErr)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+
+	if report.IsConsistent() || report.Repaired {
+		return 0
+	}
+	return 1
+}
+
+// reindex rebuilds the index from the blobstore, prints the summary as JSON and returns the exit code
+func reindex(ctx context.Context, args []string) int {
+	conf, confErr := config.ReadConfiguration(config.GetConfigFilePath(), args)
+	if confErr != nil {
+		panic(confErr)
+	}
+
+	report, reindexErr := app.Reindex(ctx, conf)
+	if reindexErr != nil {
+		fmt.Fprintf(os.Stderr, "Reindexing failed: %v\n", reindexErr)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+	return 0
+}
+
+// flagValue returns the value following the flag in args or the empty string if the flag is missing
+func flagValue(args []string, flag string) string {
+	for index, value := range args {
+		if value == flag && index+1 < len(args) {
+			return args[index+1]
+		}
+	}
+	return ""
+}
+
+// migrateIndex copies the index between the backends given as --from and --to, prints the report as JSON and
+// returns the exit code: 1 if the target couldn't be verified to hold the same as the source
+func migrateIndex(ctx context.Context, args []string) int {
+	from := flagValue(args, "--from")
+	to := flagValue(args, "--to")
+	if len(from) == 0 || len(to) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s --from <%s|%s|%s> --to <%s|%s|%s> [--batch-size <n>]\n", migrateIndexCommand, app.IndexBackendPg, app.IndexBackendDynamodb, app.IndexBackendSQLite, app.IndexBackendPg, app.IndexBackendDynamodb, app.IndexBackendSQLite)
+		return 2
+	}
+	batchSize := 0
+	if batchSizeArg := flagValue(args, "--batch-size"); len(batchSizeArg) > 0 {
+		var parseErr error
+		batchSize, parseErr = strconv.Atoi(batchSizeArg)
+		if parseErr != nil {
+			fmt.Fprintf(os.Stderr, "Invalid batch size %s: %v\n", batchSizeArg, parseErr)
+			return 2
+		}
+	}
+
+	conf, confErr := config.ReadConfiguration(config.GetConfigFilePath(), args)
+	if confErr != nil {
+		panic(confErr)
+	}
+
+	report, migrateErr := app.MigrateIndex(ctx, conf, from, to, batchSize)
+	if migrateErr != nil {
+		fmt.Fprintf(os.Stderr, "Index migration failed: %v\n", migrateErr)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(report)
+
+	if report.Verified {
+		return 0
+	}
+	return 1
+}
+
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
@@ -32,6 +139,16 @@ func main() {
 		}
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == checkConsistencyCommand {
+		os.Exit(checkConsistency(ctx, os.Args))
+	}
+	if len(os.Args) > 1 && os.Args[1] == reindexCommand {
+		os.Exit(reindex(ctx, os.Args))
+	}
+	if len(os.Args) > 1 && os.Args[1] == migrateIndexCommand {
+		os.Exit(migrateIndex(ctx, os.Args))
+	}
+
 	if serverWanted {
 		var confErr error
 
@@ -25,29 +25,94 @@ resource "aws_dynamodb_table" "icon_tags" {
   }
 }
 
-resource "aws_dynamodb_table" "icons_locks" {
-  name           = "icons_locks"
+resource "aws_dynamodb_table" "icon_tag_members" {
+  name           = "icon_tag_members"
   billing_mode   = "PROVISIONED"
   read_capacity  = 5
   write_capacity = 5
-  hash_key       = "key"
+  hash_key       = "Tag"
+  range_key      = "IconName"
+
+  attribute {
+    name = "Tag"
+    type = "S"
+  }
+
+  attribute {
+    name = "IconName"
+    type = "S"
+  }
+}
+
+resource "aws_dynamodb_table" "icon_audit" {
+  name           = "icon_audit"
+  billing_mode   = "PROVISIONED"
+  read_capacity  = 5
+  write_capacity = 5
+  hash_key       = "IconName"
+  range_key      = "EntryID"
+
+  attribute {
+    name = "IconName"
+    type = "S"
+  }
 
   attribute {
-    name = "key"
+    name = "EntryID" # <timestamp>#<xid>
     type = "S"
   }
+
+  attribute {
+    name = "Actor"
+    type = "S"
+  }
+
+  attribute {
+    name = "AuditLog" # "all", for a single partition of all the entries
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = "icon_audit_by_actor"
+    hash_key        = "Actor"
+    range_key       = "EntryID"
+    read_capacity   = 5
+    write_capacity  = 5
+    projection_type = "ALL"
+  }
+
+  global_secondary_index {
+    name            = "icon_audit_by_time"
+    hash_key        = "AuditLog"
+    range_key       = "EntryID"
+    read_capacity   = 5
+    write_capacity  = 5
+    projection_type = "ALL"
+  }
 }
 
-resource "aws_dynamodb_table" "icon_tags_locks" {
-  name           = "icon_tags_locks"
+resource "aws_dynamodb_table" "icon_outbox" {
+  name           = "icon_outbox"
   billing_mode   = "PROVISIONED"
   read_capacity  = 5
   write_capacity = 5
-  hash_key       = "key"
+  hash_key       = "EntryID"
+
+  attribute {
+    name = "EntryID" # <timestamp>#<xid>
+    type = "S"
+  }
+}
 
+resource "aws_dynamodb_table" "icon_meta" {
+  name           = "icon_meta"
+  billing_mode   = "PROVISIONED"
+  read_capacity  = 5
+  write_capacity = 5
+  hash_key       = "Version"
 
   attribute {
-    name = "key"
+    name = "Version" # <date>/<n> - <description>
     type = "S"
   }
 }
@@ -37,7 +37,9 @@ data "aws_iam_policy_document" "user_policy" {
   statement {
     actions = [
       "dynamodb:GetItem",
+      "dynamodb:BatchGetItem",
       "dynamodb:Scan",
+      "dynamodb:Query",
       "dynamodb:PutItem",
       "dynamodb:UpdateItem",
       "dynamodb:DeleteItem",
@@ -46,8 +48,11 @@ data "aws_iam_policy_document" "user_policy" {
     resources = [
       aws_dynamodb_table.icons.arn,
       aws_dynamodb_table.icon_tags.arn,
-      aws_dynamodb_table.icons_locks.arn,
-      aws_dynamodb_table.icon_tags_locks.arn,
+      aws_dynamodb_table.icon_tag_members.arn,
+      aws_dynamodb_table.icon_audit.arn,
+      "${aws_dynamodb_table.icon_audit.arn}/index/*",
+      aws_dynamodb_table.icon_outbox.arn,
+      aws_dynamodb_table.icon_meta.arn,
     ]
   }
 }
@@ -0,0 +1,8 @@
+terraform {
+  backend "s3" {
+    bucket  = "bitkitchen-tf-state"
+    key     = "iconrepo/blobstore/s3"
+    region  = "eu-west-1"
+    encrypt = true
+  }
+}
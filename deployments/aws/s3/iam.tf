@@ -0,0 +1,61 @@
+locals {
+  service_user = "iconrepo_s3"
+}
+
+resource "aws_iam_user" "user" {
+  name = local.service_user
+}
+
+# https://registry.terraform.io/providers/hashicorp/aws/latest/docs/resources/iam_access_key
+resource "aws_iam_access_key" "user_key" {
+  user = aws_iam_user.user.name
+  pgp_key = "keybase:${var.pgp_key_owner}"
+}
+
+# https://registry.terraform.io/providers/hashicorp/aws/latest/docs/resources/iam_policy
+resource "aws_iam_policy" "policy" {
+  name   = local.service_user
+  policy = data.aws_iam_policy_document.user_policy.json
+}
+
+# https://registry.terraform.io/providers/hashicorp/aws/latest/docs/resources/iam_user_policy_attachment
+resource "aws_iam_user_policy_attachment" "user_policy_attachment" {
+  user       = aws_iam_user.user.name
+  policy_arn = aws_iam_policy.policy.arn
+}
+
+# https://registry.terraform.io/providers/hashicorp/aws/latest/docs/data-sources/iam_policy_document
+data "aws_iam_policy_document" "user_policy" {
+  statement {
+    actions = [
+      "s3:ListBucket",
+      "s3:ListBucketVersions",
+      "s3:GetBucketVersioning",
+    ]
+
+    resources = [
+      aws_s3_bucket.iconfiles.arn,
+    ]
+  }
+
+  statement {
+    actions = [
+      "s3:GetObject",
+      "s3:GetObjectVersion",
+      "s3:PutObject",
+      "s3:DeleteObject",
+    ]
+
+    resources = [
+      "${aws_s3_bucket.iconfiles.arn}/*",
+    ]
+  }
+}
+
+output "access_key_id" {
+  value = aws_iam_access_key.user_key.id
+}
+
+output "encrypted_access_key_secret" {
+  value = aws_iam_access_key.user_key.encrypted_secret
+}
@@ -0,0 +1,24 @@
+variable bucket_name {
+  type    = string
+  default = "iconrepo"
+}
+
+resource "aws_s3_bucket" "iconfiles" {
+  bucket = var.bucket_name
+}
+
+# Every write keeps the previous version of the iconfile: this is the history of the blobstore
+resource "aws_s3_bucket_versioning" "iconfiles" {
+  bucket = aws_s3_bucket.iconfiles.id
+  versioning_configuration {
+    status = "Enabled"
+  }
+}
+
+resource "aws_s3_bucket_public_access_block" "iconfiles" {
+  bucket                  = aws_s3_bucket.iconfiles.id
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
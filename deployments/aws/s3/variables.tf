@@ -0,0 +1,3 @@
+variable pgp_key_owner {
+  type = string
+}
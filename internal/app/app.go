@@ -2,61 +2,196 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/app/security/authr"
 	"iconrepo/internal/app/services"
 	"iconrepo/internal/config"
 	"iconrepo/internal/httpadapter"
 	"iconrepo/internal/repositories"
+	"iconrepo/internal/repositories/blobstore/cache"
+	"iconrepo/internal/repositories/blobstore/filesystem"
 	"iconrepo/internal/repositories/blobstore/git"
+	memory_blobstore "iconrepo/internal/repositories/blobstore/memory"
+	"iconrepo/internal/repositories/blobstore/postgres"
+	"iconrepo/internal/repositories/blobstore/s3"
+	"iconrepo/internal/repositories/blobstore/scheduler"
+	"iconrepo/internal/repositories/indexing/catalog"
 	"iconrepo/internal/repositories/indexing/dynamodb"
+	memory_index "iconrepo/internal/repositories/indexing/memory"
 	"iconrepo/internal/repositories/indexing/pgdb"
+	"iconrepo/internal/repositories/indexing/sqlite"
+	"time"
 
 	"github.com/rs/zerolog"
 )
 
-func Start(ctx context.Context, conf config.Options, ready func(port int, stop func())) error {
-	logger := zerolog.Ctx(ctx)
-	var dbSchemaAlreadyThere bool
-	var db repositories.IndexRepository
-	if conf.DynamodbURL == "" {
+// CLIUserName is the user changes made from the command line are attributed to
+const CLIUserName = "iconrepo-cli"
+
+// Index backends
+const (
+	IndexBackendPg       = "pg"
+	IndexBackendDynamodb = "dynamodb"
+	IndexBackendMemory   = "memory"
+	IndexBackendSQLite   = "sqlite"
+)
+
+// configuredIndexBackend tells which index backend the configuration is for
+func configuredIndexBackend(conf config.Options) string {
+	if conf.Storage == config.StorageMemory {
+		return IndexBackendMemory
+	}
+	if len(conf.SQLiteFile) > 0 {
+		return IndexBackendSQLite
+	}
+	if len(conf.DynamodbURL) > 0 {
+		return IndexBackendDynamodb
+	}
+	return IndexBackendPg
+}
+
+// createIndexRepository connects to the index backend specified. It also tells whether the index schema has already been there.
+func createIndexRepository(conf config.Options, backend string) (repositories.IndexRepository, repositories.AuditRepository, bool, error) {
+	switch backend {
+	case IndexBackendPg:
 		connection, dbErr := pgdb.NewDBConnection(conf)
 		if dbErr != nil {
-			return dbErr
+			return nil, nil, false, dbErr
 		}
 
-		var schemaErr error
-		dbSchemaAlreadyThere, schemaErr = pgdb.OpenSchema(conf, connection)
+		dbSchemaAlreadyThere, schemaErr := pgdb.OpenSchema(conf, connection)
 		if schemaErr != nil {
-			return schemaErr
+			return nil, nil, false, schemaErr
 		}
 
-		db = pgdb.NewPgRepository(connection)
-	}
-
-	if len(conf.DynamodbURL) > 0 {
+		pgRepo := pgdb.NewPgRepository(connection)
+		return pgRepo, pgRepo, dbSchemaAlreadyThere, nil
+	case IndexBackendDynamodb:
 		dyndb, createDyndbErr := dynamodb.NewDynamodbRepository(&conf)
 		if createDyndbErr != nil {
-			return createDyndbErr
+			return nil, nil, false, createDyndbErr
+		}
+		return dyndb, dyndb, false, nil
+	case IndexBackendMemory:
+		// The schema name keeps apart the data of servers started in the same process, as it does in Postgres
+		index := memory_index.Shared(conf.DBSchemaName)
+		return index, index, false, nil
+	case IndexBackendSQLite:
+		connection, dbErr := sqlite.NewDBConnection(conf.SQLiteFile)
+		if dbErr != nil {
+			return nil, nil, false, dbErr
+		}
+
+		dbSchemaAlreadyThere, schemaErr := sqlite.OpenSchema(connection)
+		if schemaErr != nil {
+			connection.Close()
+			return nil, nil, false, schemaErr
 		}
-		db = dyndb
+
+		sqliteRepo := sqlite.NewSQLiteRepository(connection)
+		return sqliteRepo, sqliteRepo, dbSchemaAlreadyThere, nil
+	default:
+		return nil, nil, false, fmt.Errorf("unknown index backend: %s", backend)
 	}
-	defer db.Close()
+}
+
+// createPostgresBlobstore shares the connection of the index if the index is in Postgres too.
+// The iconfiles are then written in the transactions of the index.
+func createPostgresBlobstore(conf config.Options, index repositories.IndexRepository) (*postgres.Postgres, error) {
+	if pgIndex, ok := index.(pgdb.PgRepository); ok {
+		return postgres.NewPostgresBlobstore(pgIndex.Conn.Pool), nil
+	}
+	connection, dbErr := pgdb.NewDBConnection(conf)
+	if dbErr != nil {
+		return nil, dbErr
+	}
+	if _, schemaErr := pgdb.OpenSchema(conf, connection); schemaErr != nil {
+		return nil, schemaErr
+	}
+	return postgres.NewPostgresBlobstore(connection.Pool), nil
+}
+
+func gitlabClientOptions(conf config.Options) git.GitlabClientOptions {
+	return git.GitlabClientOptions{
+		RequestTimeout:   time.Duration(conf.GitlabRequestTimeout) * time.Second,
+		MaxRetries:       conf.GitlabMaxRetries,
+		RetryDelay:       time.Duration(conf.GitlabRetryDelayMs) * time.Millisecond,
+		MaxRetryDelay:    time.Duration(conf.GitlabMaxRetryDelay) * time.Second,
+		BreakerThreshold: conf.GitlabBreakerThreshold,
+		BreakerCooldown:  time.Duration(conf.GitlabBreakerCooldown) * time.Second,
+	}
+}
+
+// CreateRepositories connects to the index and the blobstore configured. The blobstore is initialized
+// along with a new index schema.
+func CreateRepositories(ctx context.Context, conf config.Options) (*repositories.RepoCombo, error) {
+	logger := zerolog.Ctx(ctx)
+	db, audit, dbSchemaAlreadyThere, createIndexErr := createIndexRepository(conf, configuredIndexBackend(conf))
+	if createIndexErr != nil {
+		return nil, createIndexErr
+	}
+
+	writeTimeout := time.Duration(conf.BlobstoreWriteTimeout) * time.Second
 
 	var blobstore repositories.BlobstoreRepository
-	if len(conf.GitlabNamespacePath) == 0 && len(conf.LocalGitRepo) > 0 {
+	if conf.Storage == config.StorageMemory {
+		blobstore = memory_blobstore.Shared(conf.DBSchemaName)
+		logger.Info().Msg("Keeping the index and the iconfiles in memory...")
+	} else if conf.BlobstoreType == config.BlobstoreTypeGitea {
+		giteaClient, giteaErr := git.NewGiteaRepositoryClient(conf.GiteaAPIURL, conf.GiteaOwner, conf.GiteaRepository, conf.GiteaMainBranch, conf.GiteaAccessToken)
+		if giteaErr != nil {
+			db.Close()
+			return nil, giteaErr
+		}
+		blobstore = giteaClient
+		logger.Info().Str("giteaApiUrl", conf.GiteaAPIURL).Str("repository", giteaClient.String()).Msg("Connecting to Gitea repo...")
+	} else if conf.BlobstoreType == config.BlobstoreTypeFilesystem {
+		fsBlobstore := filesystem.NewFilesystemBlobstore(conf.FilesystemBlobstoreRoot)
+		fsBlobstore.Writes = scheduler.NewScheduler(fsBlobstore.String(), writeTimeout)
+		blobstore = fsBlobstore
+		logger.Info().Str("location", conf.FilesystemBlobstoreRoot).Msg("Using filesystem blobstore...")
+	} else if conf.BlobstoreType == config.BlobstoreTypeS3 {
+		s3Blobstore, s3Err := s3.NewS3Blobstore(ctx, &conf)
+		if s3Err != nil {
+			db.Close()
+			return nil, s3Err
+		}
+		blobstore = s3Blobstore
+		logger.Info().Str("bucket", conf.S3Bucket).Str("s3Url", conf.S3URL).Msg("Connecting to S3 bucket...")
+	} else if conf.BlobstoreType == config.BlobstoreTypePostgres {
+		pgBlobstore, pgErr := createPostgresBlobstore(conf, db)
+		if pgErr != nil {
+			db.Close()
+			return nil, pgErr
+		}
+		blobstore = pgBlobstore
+		logger.Info().Msg("Storing iconfiles in Postgres...")
+	} else if len(conf.GitlabNamespacePath) == 0 && len(conf.LocalGitRepo) > 0 {
 		localGit := git.NewLocalGitRepository(conf.LocalGitRepo)
+		localGit.Writes = scheduler.NewScheduler(localGit.String(), writeTimeout)
+		if remotes := git.ParseMirrorRemotes(conf.GitMirrorRemotes); len(remotes) > 0 {
+			mirrorOptions := git.DefaultMirrorOptions
+			mirrorOptions.Interval = time.Duration(conf.GitMirrorInterval) * time.Second
+			localGit.Mirror = git.NewMirror(conf.LocalGitRepo, remotes, mirrorOptions)
+		}
 		blobstore = &localGit
 		logger.Info().Str("location", conf.LocalGitRepo).Msg("Connecting local git repo...")
-	}
-	if len(conf.GitlabNamespacePath) > 0 {
+	} else if len(conf.GitlabNamespacePath) > 0 {
 		gitlabClient, gitlabRepoErr := git.NewGitlabRepositoryClient(
 			ctx,
+			conf.GitlabAPIURL,
 			conf.GitlabNamespacePath,
 			conf.GitlabProjectPath,
 			conf.GitlabMainBranch,
 			conf.GitlabAccessToken,
+			conf.GitlabMergeRequests,
+			gitlabClientOptions(conf),
 		)
 		if gitlabRepoErr != nil {
-			return gitlabRepoErr
+			db.Close()
+			return nil, gitlabRepoErr
 		}
 		blobstore = gitlabClient
 		logger.Info().
@@ -64,21 +199,60 @@ func Start(ctx context.Context, conf config.Options, ready func(port int, stop f
 			Str("gitlabProjectPath,", conf.GitlabProjectPath).
 			Str("gitlabMainBranch,", conf.GitlabMainBranch).
 			Str("gitlabAccessToken,", conf.GitlabAccessToken).
+			Bool("gitlabMergeRequests", conf.GitlabMergeRequests).
 			Msg("Connecting to Gitlab repo...")
 	}
 
 	if !dbSchemaAlreadyThere {
 		gitErr := blobstore.CreateRepository(ctx)
 		if gitErr != nil {
-			return gitErr
+			db.Close()
+			return nil, gitErr
 		}
 	}
 
-	combinedRepo := repositories.RepoCombo{Index: db, Blobstore: blobstore}
+	combo := &repositories.RepoCombo{Index: db, Blobstore: blobstore, Audit: audit}
+	if conf.IconfileCacheSize > 0 {
+		iconfileCache, cacheErr := cache.NewIconfileCache(int64(conf.IconfileCacheSize)<<20, conf.IconfileCacheDir, int64(conf.IconfileCacheDiskSize)<<20)
+		if cacheErr != nil {
+			db.Close()
+			return nil, cacheErr
+		}
+		combo.IconfileCache = iconfileCache
+	}
+	if conf.IconCatalogMaxAge > 0 {
+		combo.Catalog = catalog.NewSnapshot(time.Duration(conf.IconCatalogMaxAge) * time.Second)
+	}
+	// Every index backend keeps the outbox next to the index
+	if outbox, ok := db.(repositories.OutboxRepository); ok {
+		combo.Outbox = outbox
+	}
+	return combo, nil
+}
+
+func Start(ctx context.Context, conf config.Options, ready func(port int, stop func())) error {
+	combinedRepo, createReposErr := CreateRepositories(ctx, conf)
+	if createReposErr != nil {
+		return createReposErr
+	}
+	db := combinedRepo.Index
+	defer db.Close()
+
+	// Only the server pushes to the mirrors: the commits made from the command line are pushed when the server starts
+	if localGit, ok := combinedRepo.Blobstore.(*git.Local); ok && localGit.Mirror != nil {
+		localGit.Mirror.Start()
+		defer localGit.Mirror.Stop()
+	}
+
+	if conf.OutboxRetryDelay > 0 && combinedRepo.Outbox != nil {
+		outboxWorker := repositories.NewOutboxWorker(combinedRepo, time.Duration(conf.OutboxRetryDelay)*time.Second)
+		outboxWorker.Start()
+		defer outboxWorker.Stop()
+	}
 
 	server := httpadapter.CreateServer(
 		conf,
-		*services.NewIconService(&combinedRepo),
+		*services.NewIconService(combinedRepo, conf.EnableReviewWorkflow),
 	)
 
 	server.SetupAndStart(conf, func(port int, stop func()) {
@@ -90,3 +264,51 @@ func Start(ctx context.Context, conf config.Options, ready func(port int, stop f
 
 	return nil
 }
+
+// CheckConsistency runs the consistency check between the index and the blobstore from the command line
+func CheckConsistency(ctx context.Context, conf config.Options, repair bool) (domain.ConsistencyReport, error) {
+	combinedRepo, createReposErr := CreateRepositories(ctx, conf)
+	if createReposErr != nil {
+		return domain.ConsistencyReport{}, createReposErr
+	}
+	defer combinedRepo.Index.Close()
+
+	return services.NewIconService(combinedRepo, conf.EnableReviewWorkflow).CheckConsistency(ctx, repair, cliUser())
+}
+
+// Reindex rebuilds the index from the blobstore from the command line
+func Reindex(ctx context.Context, conf config.Options) (domain.ReindexReport, error) {
+	combinedRepo, createReposErr := CreateRepositories(ctx, conf)
+	if createReposErr != nil {
+		return domain.ReindexReport{}, createReposErr
+	}
+	defer combinedRepo.Index.Close()
+
+	return services.NewIconService(combinedRepo, conf.EnableReviewWorkflow).Reindex(ctx, cliUser())
+}
+
+// MigrateIndex copies the index from one backend to the other. Both are to be configured in conf.
+func MigrateIndex(ctx context.Context, conf config.Options, from string, to string, batchSize int) (domain.IndexMigrationReport, error) {
+	if from == to {
+		return domain.IndexMigrationReport{}, fmt.Errorf("the source and the target index backends are the same: %s", from)
+	}
+	source, _, _, sourceErr := createIndexRepository(conf, from)
+	if sourceErr != nil {
+		return domain.IndexMigrationReport{}, fmt.Errorf("failed to connect to source index: %w", sourceErr)
+	}
+	defer source.Close()
+	target, _, _, targetErr := createIndexRepository(conf, to)
+	if targetErr != nil {
+		return domain.IndexMigrationReport{}, fmt.Errorf("failed to connect to target index: %w", targetErr)
+	}
+	defer target.Close()
+
+	return repositories.MigrateIndex(ctx, source, target, batchSize)
+}
+
+func cliUser() authr.UserInfo {
+	return authr.UserInfo{
+		UserId:      authn.LocalDomain.CreateUserID(CLIUserName),
+		Permissions: authr.GetPermissionsForGroup(authr.REPO_ADMIN),
+	}
+}
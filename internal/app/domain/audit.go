@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionCreateIcon     AuditAction = "createIcon"
+	AuditActionDeleteIcon     AuditAction = "deleteIcon"
+	AuditActionAddIconfile    AuditAction = "addIconfile"
+	AuditActionDeleteIconfile AuditAction = "deleteIconfile"
+	AuditActionAddTag         AuditAction = "addTag"
+	AuditActionRemoveTag      AuditAction = "removeTag"
+	AuditActionSetDescription AuditAction = "setDescription"
+	AuditActionSubmitReview   AuditAction = "submitForReview"
+	AuditActionPublish        AuditAction = "publish"
+	AuditActionReject         AuditAction = "reject"
+)
+
+// AuditEntry records a single change made to the repository
+type AuditEntry struct {
+	ID        string              `json:"id"`
+	Timestamp time.Time           `json:"timestamp"`
+	Actor     string              `json:"actor"`
+	Action    AuditAction         `json:"action"`
+	IconName  string              `json:"iconName"`
+	Iconfile  *IconfileDescriptor `json:"iconfile,omitempty"`
+	Tag       string              `json:"tag,omitempty"`
+	Before    *IconDescriptor     `json:"before,omitempty"`
+	After     *IconDescriptor     `json:"after,omitempty"`
+	RequestID string              `json:"requestId,omitempty"`
+	Comment   string              `json:"comment,omitempty"`
+}
+
+func (e AuditEntry) String() string {
+	return fmt.Sprintf("%s %s by %s at %v", e.Action, e.IconName, e.Actor, e.Timestamp)
+}
+
+// AuditQuery selects audit entries. Zero values mean "no restriction".
+// Cursor is the opaque value returned in AuditPage.NextCursor of the previous page.
+type AuditQuery struct {
+	IconName string
+	Actor    string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+	Cursor   string
+}
+
+const DefaultAuditPageSize = 50
+
+// AuditPage holds one page of audit entries, the most recent first. NextCursor is empty on the last page.
+type AuditPage struct {
+	Entries    []AuditEntry `json:"entries"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
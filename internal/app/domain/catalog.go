@@ -0,0 +1,14 @@
+package domain
+
+// IconCatalog lists the icons as of a revision of the catalog, either all of them or those changed since an earlier revision
+type IconCatalog struct {
+	// Revision is empty if the catalog isn't kept track of
+	Revision string
+	// Full tells whether Icons lists all the icons, rather than only those changed since the revision asked for
+	Full  bool
+	Icons []IconDescriptor
+	// Deleted lists the icons deleted since the revision asked for
+	Deleted []string
+	// PublishedOnly tells whether only the published iconfiles are listed
+	PublishedOnly bool
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ChangesetOperation is one of the changes applied together in a changeset. The action is one of
+// createIcon, addIconfile, deleteIcon, deleteIconfile, addTag and removeTag; the iconfile is used by the
+// iconfile operations (with its content when it is added), the tag by the tag operations.
+type ChangesetOperation struct {
+	Action   AuditAction
+	IconName string
+	Iconfile Iconfile
+	Tag      string
+}
+
+func (op ChangesetOperation) String() string {
+	switch op.Action {
+	case AuditActionCreateIcon, AuditActionAddIconfile, AuditActionDeleteIconfile:
+		return fmt.Sprintf("%s %s (%v)", op.Action, op.IconName, op.Iconfile.IconfileDescriptor)
+	case AuditActionAddTag, AuditActionRemoveTag:
+		return fmt.Sprintf("%s %s (%s)", op.Action, op.IconName, op.Tag)
+	default:
+		return fmt.Sprintf("%s %s", op.Action, op.IconName)
+	}
+}
+
+func compareIconfiles(iconfile1 IconfileDescriptor, iconfile2 IconfileDescriptor) int {
+	if formatOrder := strings.Compare(iconfile1.Format, iconfile2.Format); formatOrder != 0 {
+		return formatOrder
+	}
+	return strings.Compare(iconfile1.Size, iconfile2.Size)
+}
+
+// ApplyTo returns the icon as changed by the operation. A nil icon stands for an icon which doesn't exist:
+// it is passed to create the icon and returned once the icon is deleted along with its last iconfile.
+// The icon passed in is left unchanged.
+func (op ChangesetOperation) ApplyTo(icon *IconDescriptor, modifiedBy string) (*IconDescriptor, error) {
+	if op.Action == AuditActionCreateIcon {
+		if icon != nil {
+			return nil, fmt.Errorf("failed to create icon %s: %w", op.IconName, ErrIconAlreadyExists)
+		}
+		return &IconDescriptor{
+			IconAttributes: IconAttributes{Name: op.IconName, ModifiedBy: modifiedBy, Tags: []string{}},
+			Iconfiles:      []IconfileDescriptor{op.Iconfile.IconfileDescriptor},
+		}, nil
+	}
+
+	if icon == nil {
+		return nil, fmt.Errorf("failed to %s: icon %s not found: %w", op, op.IconName, ErrIconNotFound)
+	}
+	changed := *icon
+	changed.Tags = append([]string{}, icon.Tags...)
+	changed.Iconfiles = append([]IconfileDescriptor{}, icon.Iconfiles...)
+
+	switch op.Action {
+	case AuditActionAddIconfile:
+		if slices.ContainsFunc(changed.Iconfiles, op.Iconfile.Equals) {
+			return nil, fmt.Errorf("failed to %s: %w", op, ErrIconfileAlreadyExists)
+		}
+		changed.Iconfiles = append(changed.Iconfiles, op.Iconfile.IconfileDescriptor)
+		slices.SortFunc(changed.Iconfiles, compareIconfiles)
+	case AuditActionDeleteIcon:
+		return nil, nil
+	case AuditActionDeleteIconfile:
+		position := slices.IndexFunc(changed.Iconfiles, op.Iconfile.Equals)
+		if position < 0 {
+			return nil, fmt.Errorf("failed to %s: %w", op, ErrIconfileNotFound)
+		}
+		changed.Iconfiles = slices.Delete(changed.Iconfiles, position, position+1)
+		if len(changed.Iconfiles) == 0 {
+			return nil, nil
+		}
+	case AuditActionAddTag:
+		if slices.Contains(changed.Tags, op.Tag) {
+			return &changed, nil
+		}
+		changed.Tags = append(changed.Tags, op.Tag)
+	case AuditActionRemoveTag:
+		if !slices.Contains(changed.Tags, op.Tag) {
+			return &changed, nil
+		}
+		changed.Tags = slices.DeleteFunc(changed.Tags, func(tag string) bool { return tag == op.Tag })
+	default:
+		return nil, fmt.Errorf("%s is not a changeset operation: %w", op.Action, ErrInvalidChangeset)
+	}
+	changed.ModifiedBy = modifiedBy
+	return &changed, nil
+}
+
+type BlobstoreChangeKind string
+
+const (
+	BlobstoreChangeAddIconfile    BlobstoreChangeKind = "addIconfile"
+	BlobstoreChangeDeleteIconfile BlobstoreChangeKind = "deleteIconfile"
+	BlobstoreChangeUpdateMetadata BlobstoreChangeKind = "updateMetadata"
+	BlobstoreChangeDeleteIcon     BlobstoreChangeKind = "deleteIcon"
+)
+
+// BlobstoreChange is one of the changes a changeset makes to the blobstore. The changes are the net result
+// of the operations of the changeset: an iconfile deleted and then added again is replaced by a single change.
+type BlobstoreChange struct {
+	Kind     BlobstoreChangeKind
+	IconName string
+	// Iconfile is the iconfile added or deleted
+	Iconfile Iconfile
+	// Replace tells that the iconfile added takes the place of one already in the blobstore
+	Replace bool
+	// Metadata is the new metadata of the icon
+	Metadata IconMetadata
+	// Icon is the icon deleted, with the iconfiles to remove from the blobstore
+	Icon IconDescriptor
+}
+
+func (change BlobstoreChange) String() string {
+	switch change.Kind {
+	case BlobstoreChangeAddIconfile, BlobstoreChangeDeleteIconfile:
+		return fmt.Sprintf("%s %s (%v)", change.Kind, change.IconName, change.Iconfile.IconfileDescriptor)
+	default:
+		return fmt.Sprintf("%s %s", change.Kind, change.IconName)
+	}
+}
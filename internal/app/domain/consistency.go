@@ -0,0 +1,38 @@
+package domain
+
+import "fmt"
+
+// IconfileRef identifies an iconfile across icons
+type IconfileRef struct {
+	IconName string             `json:"iconName"`
+	Iconfile IconfileDescriptor `json:"iconfile"`
+}
+
+func (ref IconfileRef) String() string {
+	return fmt.Sprintf("%s::%s", ref.IconName, ref.Iconfile.String())
+}
+
+// ConsistencyReport lists the differences found between the index and the blobstore
+type ConsistencyReport struct {
+	// MissingFromIndex lists the iconfiles in the blobstore the index doesn't know about
+	MissingFromIndex []IconfileRef `json:"missingFromIndex"`
+	// MissingFromBlobstore lists the iconfiles in the index without content in the blobstore
+	MissingFromBlobstore []IconfileRef `json:"missingFromBlobstore"`
+	// UnrecognizedFiles lists the files in the blobstore which are not iconfiles
+	UnrecognizedFiles []string `json:"unrecognizedFiles"`
+	// Repaired tells whether the differences have been repaired
+	Repaired bool `json:"repaired"`
+}
+
+func (report ConsistencyReport) IsConsistent() bool {
+	return len(report.MissingFromIndex) == 0 && len(report.MissingFromBlobstore) == 0
+}
+
+// ReindexReport summarizes the index rebuilt from the blobstore
+type ReindexReport struct {
+	Icons     int `json:"icons"`
+	Iconfiles int `json:"iconfiles"`
+	Tags      int `json:"tags"`
+	// UnrecognizedFiles lists the files in the blobstore which are not iconfiles
+	UnrecognizedFiles []string `json:"unrecognizedFiles"`
+}
@@ -8,4 +8,12 @@ var (
 	ErrTooManyIconsFound     = errors.New("too many icons found")
 	ErrIconAlreadyExists     = errors.New("icon already exists")
 	ErrIconfileAlreadyExists = errors.New("iconfile already exists")
+	ErrInvalidAuditCursor    = errors.New("invalid audit cursor")
+	ErrInvalidReviewStatus   = errors.New("invalid review status")
+	ErrInvalidReviewChange   = errors.New("invalid review status change")
+	ErrUnknownChangeBranch   = errors.New("not a change branch of the repository")
+	ErrNoMergeRequests       = errors.New("changes are not reviewed in merge requests")
+	ErrIndexNotEmpty         = errors.New("index is not empty")
+	ErrIconMetadataNotFound  = errors.New("icon metadata not found")
+	ErrInvalidChangeset      = errors.New("invalid changeset")
 )
@@ -3,8 +3,9 @@ package domain
 import "fmt"
 
 type IconfileDescriptor struct {
-	Format string `json:"format"`
-	Size   string `json:"size"`
+	Format       string       `json:"format"`
+	Size         string       `json:"size"`
+	ReviewStatus ReviewStatus `json:"reviewStatus,omitempty"`
 }
 
 func (i IconfileDescriptor) Equals(other IconfileDescriptor) bool {
@@ -26,9 +27,10 @@ func (i Iconfile) String() string {
 }
 
 type IconAttributes struct {
-	Name       string
-	ModifiedBy string
-	Tags       []string
+	Name        string
+	ModifiedBy  string
+	Description string
+	Tags        []string
 }
 
 type IconDescriptor struct {
@@ -41,3 +43,34 @@ type Icon struct {
 	IconAttributes
 	Iconfiles []Iconfile
 }
+
+// IconMetadata holds the attributes of an icon kept next to its iconfiles in the blobstore,
+// so that the icon can be fully restored from the blobstore
+type IconMetadata struct {
+	ModifiedBy  string   `json:"modifiedBy"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags"`
+	// Iconfiles records the review status of the iconfiles, which isn't apparent from the iconfiles themselves
+	Iconfiles []IconfileDescriptor `json:"iconfiles,omitempty"`
+}
+
+// Metadata returns the attributes of the icon to be kept next to its iconfiles
+func (icon IconDescriptor) Metadata() IconMetadata {
+	return IconMetadata{
+		ModifiedBy:  icon.ModifiedBy,
+		Description: icon.Description,
+		Tags:        append([]string{}, icon.Tags...),
+		Iconfiles:   append([]IconfileDescriptor{}, icon.Iconfiles...),
+	}
+}
+
+// ReviewStatusOf returns the review status recorded for the iconfile; iconfiles not recorded, as is the case
+// with metadata written before review statuses were recorded, are taken to be published
+func (metadata IconMetadata) ReviewStatusOf(iconfile IconfileDescriptor) ReviewStatus {
+	for _, recorded := range metadata.Iconfiles {
+		if recorded.Equals(iconfile) {
+			return recorded.ReviewStatus
+		}
+	}
+	return ReviewStatusPublished
+}
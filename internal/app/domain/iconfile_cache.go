@@ -0,0 +1,29 @@
+package domain
+
+// IconfileCacheStats reports on the cache of the content of iconfiles read from the blobstore
+type IconfileCacheStats struct {
+	// Enabled tells whether iconfiles are cached at all
+	Enabled bool `json:"enabled"`
+	// Hits counts the iconfiles found in memory
+	Hits uint64 `json:"hits"`
+	// DiskHits counts the iconfiles found on disk, having been evicted from memory
+	DiskHits uint64 `json:"diskHits"`
+	// Misses counts the iconfiles read from the blobstore
+	Misses uint64 `json:"misses"`
+	// Evictions counts the iconfiles evicted from memory to make room for others
+	Evictions uint64 `json:"evictions"`
+	// Invalidations counts the icons whose iconfiles were dropped from the cache as they were changed
+	Invalidations uint64 `json:"invalidations"`
+	// Entries is the number of iconfiles in memory
+	Entries int `json:"entries"`
+	// Bytes is the size of the iconfiles in memory
+	Bytes int64 `json:"bytes"`
+	// MaxBytes is the size the iconfiles in memory are kept under
+	MaxBytes int64 `json:"maxBytes"`
+	// DiskEntries is the number of iconfiles on disk
+	DiskEntries int `json:"diskEntries"`
+	// DiskBytes is the size of the iconfiles on disk
+	DiskBytes int64 `json:"diskBytes"`
+	// DiskMaxBytes is the size the iconfiles on disk are kept under; zero if there is no disk tier
+	DiskMaxBytes int64 `json:"diskMaxBytes"`
+}
@@ -0,0 +1,21 @@
+package domain
+
+// IndexSummary describes the content of an index in a way which can be compared across index backends
+type IndexSummary struct {
+	Icons     int `json:"icons"`
+	Iconfiles int `json:"iconfiles"`
+	// Tags counts the tags of the icons: a tag is counted as many times as many icons it is attached to
+	Tags int `json:"tags"`
+	// Checksum is calculated over the names, modifiers, iconfiles and tags of the icons
+	Checksum string `json:"checksum"`
+}
+
+// IndexMigrationReport summarizes the copy of an index into another
+type IndexMigrationReport struct {
+	Source IndexSummary `json:"source"`
+	Target IndexSummary `json:"target"`
+	// Copied counts the icons created or completed in the target; the others had been copied by an earlier run
+	Copied int `json:"copied"`
+	// Verified tells whether the target has been found to hold the same as the source
+	Verified bool `json:"verified"`
+}
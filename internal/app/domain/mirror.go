@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// MirrorStatus reports how pushing the blobstore to a remote mirror has been going
+type MirrorStatus struct {
+	// Remote is the URL of the mirror, without credentials
+	Remote string `json:"remote"`
+	// Pending tells whether there are commits not pushed to the mirror yet
+	Pending bool `json:"pending"`
+	// LastAttempt is the time of the last push, successful or not
+	LastAttempt *time.Time `json:"lastAttempt,omitempty"`
+	// LastSuccess is the time of the last successful push
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	// LastPushedCommit is the commit the main branch was at when last pushed successfully
+	LastPushedCommit string `json:"lastPushedCommit,omitempty"`
+	// LastError is the error of the last push, if it failed
+	LastError string `json:"lastError,omitempty"`
+	// ConsecutiveFailures counts the pushes failed since the last successful one
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// OutboxEntry records the changes to the blobstore which go along with a change to the index. It is recorded
+// before the index is changed and removed once the change has gone through: an entry left behind by a failed
+// change, or by a server stopped halfway, tells which icons the index and the blobstore may disagree on.
+type OutboxEntry struct {
+	ID         string
+	RecordedAt time.Time
+	// HeldUntil is the deadline of the change the entry goes along with: the change may be in progress until then,
+	// and the entry is not to be settled before. The entry is held until RecordedAt if it is zero.
+	HeldUntil time.Time
+	Actor     string
+	Changes   []BlobstoreChange
+	// Attempts counts the failed attempts at bringing the blobstore in line with the index
+	Attempts  int
+	LastError string
+}
+
+// OutboxReport summarizes a pass over the entries left in the outbox
+type OutboxReport struct {
+	// Settled counts the entries whose icons are now the same in the index and the blobstore
+	Settled int `json:"settled"`
+	// Failed counts the entries to be retried later
+	Failed int `json:"failed"`
+}
@@ -0,0 +1,73 @@
+package domain
+
+// ReviewStatus is the stage of an iconfile in the review workflow: draft -> in review -> published (or rejected)
+type ReviewStatus string
+
+const (
+	// ReviewStatusPublished is the zero value, so that iconfiles created before the review workflow
+	// (or with the workflow disabled) are published
+	ReviewStatusPublished ReviewStatus = ""
+	ReviewStatusDraft     ReviewStatus = "draft"
+	ReviewStatusInReview  ReviewStatus = "in-review"
+	ReviewStatusRejected  ReviewStatus = "rejected"
+)
+
+func (status ReviewStatus) IsValid() bool {
+	switch status {
+	case ReviewStatusPublished, ReviewStatusDraft, ReviewStatusInReview, ReviewStatusRejected:
+		return true
+	}
+	return false
+}
+
+func (status ReviewStatus) String() string {
+	if status == ReviewStatusPublished {
+		return "published"
+	}
+	return string(status)
+}
+
+// CanChangeTo tells whether the review workflow allows for moving from this status to the next one
+func (status ReviewStatus) CanChangeTo(next ReviewStatus) bool {
+	switch next {
+	case ReviewStatusInReview:
+		return status == ReviewStatusDraft || status == ReviewStatusRejected
+	case ReviewStatusPublished, ReviewStatusRejected:
+		return status == ReviewStatusInReview
+	}
+	return false
+}
+
+// ParseReviewStatus is the inverse of ReviewStatus.String
+func ParseReviewStatus(value string) (ReviewStatus, error) {
+	if value == "published" {
+		return ReviewStatusPublished, nil
+	}
+	status := ReviewStatus(value)
+	if value == "" || !status.IsValid() {
+		return "", ErrInvalidReviewStatus
+	}
+	return status, nil
+}
+
+// OnlyPublished returns the icon with its unpublished iconfiles removed.
+// The second return value is false if the icon has no published iconfiles.
+func (icon IconDescriptor) OnlyPublished() (IconDescriptor, bool) {
+	published := []IconfileDescriptor{}
+	for _, iconfile := range icon.Iconfiles {
+		if iconfile.ReviewStatus == ReviewStatusPublished {
+			published = append(published, iconfile)
+		}
+	}
+	return IconDescriptor{IconAttributes: icon.IconAttributes, Iconfiles: published}, len(published) > 0
+}
+
+// FindIconfile returns the descriptor of the iconfile with the same format and size as the one specified
+func (icon IconDescriptor) FindIconfile(iconfile IconfileDescriptor) (IconfileDescriptor, error) {
+	for _, candidate := range icon.Iconfiles {
+		if candidate.Equals(iconfile) {
+			return candidate, nil
+		}
+	}
+	return IconfileDescriptor{}, ErrIconfileNotFound
+}
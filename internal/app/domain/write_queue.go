@@ -0,0 +1,21 @@
+package domain
+
+// WriteQueueStats reports on the writes to a blobstore
+type WriteQueueStats struct {
+	// Blobstore the writes go to
+	Blobstore string `json:"blobstore"`
+	// Queued is the number of writes waiting for their turn
+	Queued int `json:"queued"`
+	// Running is the number of writes in progress
+	Running int `json:"running"`
+	// MaxQueued is the largest number of writes waiting at the same time
+	MaxQueued int `json:"maxQueued"`
+	// Completed counts the writes done successfully
+	Completed uint64 `json:"completed"`
+	// Failed counts the writes failed
+	Failed uint64 `json:"failed"`
+	// Cancelled counts the writes given up by the caller while waiting for their turn
+	Cancelled uint64 `json:"cancelled"`
+	// TimedOut counts the writes which ran out of time, waiting or running
+	TimedOut uint64 `json:"timedOut"`
+}
@@ -15,6 +15,9 @@ const (
 	REMOVE_ICON     PermissionID = "REMOVE_ICON"
 	ADD_TAG         PermissionID = "ADD_TAG"
 	REMOVE_TAG      PermissionID = "REMOVE_TAG"
+	APPROVE_ICON    PermissionID = "APPROVE_ICON"
+	// ADMINISTER_REPO allows for maintenance operations on the repository as a whole
+	ADMINISTER_REPO PermissionID = "ADMINISTER_REPO"
 )
 
 func GetPrivilegeString(id PermissionID) string {
@@ -24,7 +27,9 @@ func GetPrivilegeString(id PermissionID) string {
 type GroupID string
 
 const (
-	ICON_EDITOR GroupID = "ICON_EDITOR"
+	ICON_EDITOR   GroupID = "ICON_EDITOR"
+	ICON_APPROVER GroupID = "ICON_APPROVER"
+	REPO_ADMIN    GroupID = "REPO_ADMIN"
 )
 
 var permissionsByGroup = map[GroupID][]PermissionID{
@@ -37,6 +42,12 @@ var permissionsByGroup = map[GroupID][]PermissionID{
 		ADD_TAG,
 		REMOVE_TAG,
 	},
+	ICON_APPROVER: {
+		APPROVE_ICON,
+	},
+	REPO_ADMIN: {
+		ADMINISTER_REPO,
+	},
 }
 
 func GetPermissionsForGroup(group GroupID) []PermissionID {
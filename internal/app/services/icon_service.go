@@ -3,17 +3,20 @@ package services
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"iconrepo/internal/app/domain"
 	"iconrepo/internal/app/security/authr"
 	"iconrepo/internal/logging"
 	"image"
+	"slices"
 
 	"github.com/rs/zerolog"
 )
 
 type Repository interface {
 	DescribeAllIcons(ctx context.Context) ([]domain.IconDescriptor, error)
+	GetIconCatalog(ctx context.Context, since string) (domain.IconCatalog, error)
 	DescribeIcon(ctx context.Context, iconName string) (domain.IconDescriptor, error)
 	CreateIcon(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy authr.UserInfo) error
 	DeleteIcon(ctx context.Context, iconName string, modifiedBy authr.UserInfo) error
@@ -23,37 +26,114 @@ type Repository interface {
 	DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy authr.UserInfo) error
 
 	GetTags(ctx context.Context) ([]string, error)
+	GetIconsWithTag(ctx context.Context, tag string) ([]domain.IconDescriptor, error)
 	AddTag(ctx context.Context, iconName string, tag string, modifiedBy authr.UserInfo) error
 	RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy authr.UserInfo) error
+	SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy authr.UserInfo) error
+
+	ApplyChangeset(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy authr.UserInfo) ([]domain.IconDescriptor, error)
+
+	GetAuditEntries(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error)
+
+	SetIconfileReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, comment string, modifiedBy authr.UserInfo) error
+	CompleteMergeRequest(ctx context.Context, sourceBranch string, merged bool, modifiedBy authr.UserInfo) error
+
+	CheckConsistency(ctx context.Context, repair bool, modifiedBy authr.UserInfo) (domain.ConsistencyReport, error)
+	Reindex(ctx context.Context, modifiedBy authr.UserInfo) (domain.ReindexReport, error)
+	GetMirrorStatus(ctx context.Context) ([]domain.MirrorStatus, error)
+	GetWriteQueueStats(ctx context.Context) (domain.WriteQueueStats, error)
+	GetIconfileCacheStats(ctx context.Context) (domain.IconfileCacheStats, error)
 }
 
 type IconService struct {
 	Repository Repository
-	logger     zerolog.Logger
+	// With the review workflow enabled, new iconfiles are drafts, which need to be approved before they are published
+	reviewWorkflow bool
+	logger         zerolog.Logger
 }
 
-func NewIconService(repo Repository) *IconService {
+func NewIconService(repo Repository, reviewWorkflow bool) *IconService {
 	RegisterSVGDecoder()
 	return &IconService{
-		Repository: repo,
-		logger:     logging.Get().With().Str(logging.ServiceLogger, "icon-service").Logger(),
+		Repository:     repo,
+		reviewWorkflow: reviewWorkflow,
+		logger:         logging.Get().With().Str(logging.ServiceLogger, "icon-service").Logger(),
+	}
+}
+
+// canSeeUnpublished tells whether the user takes part in the review workflow as opposed to just consuming icons
+func canSeeUnpublished(userInfo authr.UserInfo) bool {
+	for _, permission := range []authr.PermissionID{authr.CREATE_ICON, authr.UPDATE_ICON, authr.ADD_ICONFILE, authr.APPROVE_ICON} {
+		if authr.HasRequiredPermissions(userInfo, []authr.PermissionID{permission}) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (service *IconService) newIconfileReviewStatus() domain.ReviewStatus {
+	if service.reviewWorkflow {
+		return domain.ReviewStatusDraft
 	}
+	return domain.ReviewStatusPublished
 }
 
-func (service *IconService) DescribeAllIcons(ctx context.Context) ([]domain.IconDescriptor, error) {
+func (service *IconService) DescribeAllIcons(ctx context.Context, viewer authr.UserInfo) ([]domain.IconDescriptor, error) {
 	icons, err := service.Repository.DescribeAllIcons(ctx)
 	if err != nil {
 		return []domain.IconDescriptor{}, fmt.Errorf("failed to describe all icons: %w", err)
 	}
-	return icons, err
+	if canSeeUnpublished(viewer) {
+		return icons, nil
+	}
+	published := []domain.IconDescriptor{}
+	for _, icon := range icons {
+		if publishedIcon, hasPublished := icon.OnlyPublished(); hasPublished {
+			published = append(published, publishedIcon)
+		}
+	}
+	return published, nil
 }
 
-func (service *IconService) DescribeIcon(ctx context.Context, iconName string) (domain.IconDescriptor, error) {
+// GetIconCatalog lists the icons changed and deleted since the revision given, or all of them if the revision is unknown.
+// To users who only consume icons, icons with no published iconfile left are as good as deleted.
+func (service *IconService) GetIconCatalog(ctx context.Context, since string, viewer authr.UserInfo) (domain.IconCatalog, error) {
+	catalog, err := service.Repository.GetIconCatalog(ctx, since)
+	if err != nil {
+		return domain.IconCatalog{}, fmt.Errorf("failed to get icon catalog since revision \"%s\": %w", since, err)
+	}
+	if canSeeUnpublished(viewer) {
+		return catalog, nil
+	}
+	published := []domain.IconDescriptor{}
+	deleted := append([]string{}, catalog.Deleted...)
+	for _, icon := range catalog.Icons {
+		if publishedIcon, hasPublished := icon.OnlyPublished(); hasPublished {
+			published = append(published, publishedIcon)
+		} else if !catalog.Full {
+			deleted = append(deleted, icon.Name)
+		}
+	}
+	slices.Sort(deleted)
+	catalog.Icons = published
+	catalog.Deleted = deleted
+	catalog.PublishedOnly = true
+	return catalog, nil
+}
+
+func (service *IconService) DescribeIcon(ctx context.Context, iconName string, viewer authr.UserInfo) (domain.IconDescriptor, error) {
 	icon, err := service.Repository.DescribeIcon(ctx, iconName)
 	if err != nil {
 		return domain.IconDescriptor{}, fmt.Errorf("failed to describe icon \"%s\": %w", iconName, err)
 	}
-	return icon, err
+	if canSeeUnpublished(viewer) {
+		return icon, nil
+	}
+	publishedIcon, hasPublished := icon.OnlyPublished()
+	if !hasPublished {
+		return domain.IconDescriptor{}, fmt.Errorf("icon \"%s\" has no published iconfiles: %w", iconName, domain.ErrIconNotFound)
+	}
+	return publishedIcon, nil
 }
 
 func (service *IconService) CreateIcon(ctx context.Context, iconName string, initialIconfileContent []byte, modifiedBy authr.UserInfo) (domain.Icon, error) {
@@ -71,8 +151,9 @@ func (service *IconService) CreateIcon(ctx context.Context, iconName string, ini
 	}
 	iconfile := domain.Iconfile{
 		IconfileDescriptor: domain.IconfileDescriptor{
-			Format: format,
-			Size:   fmt.Sprintf("%dpx", config.Height),
+			Format:       format,
+			Size:         fmt.Sprintf("%dpx", config.Height),
+			ReviewStatus: service.newIconfileReviewStatus(),
 		},
 		Content: initialIconfileContent,
 	}
@@ -96,7 +177,21 @@ func (service *IconService) CreateIcon(ctx context.Context, iconName string, ini
 	}, nil
 }
 
-func (service *IconService) GetIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor) ([]byte, error) {
+func (service *IconService) GetIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, viewer authr.UserInfo) ([]byte, error) {
+	if !canSeeUnpublished(viewer) {
+		icon, describeErr := service.Repository.DescribeIcon(ctx, iconName)
+		if describeErr != nil {
+			if errors.Is(describeErr, domain.ErrIconNotFound) {
+				return nil, fmt.Errorf("failed to retrieve iconfile %v: %w", iconfile, domain.ErrIconfileNotFound)
+			}
+			return nil, fmt.Errorf("failed to describe icon \"%s\" for retrieving iconfile %v: %w", iconName, iconfile, describeErr)
+		}
+		indexed, findErr := icon.FindIconfile(iconfile)
+		if findErr != nil || indexed.ReviewStatus != domain.ReviewStatusPublished {
+			return nil, fmt.Errorf("iconfile %v of \"%s\" is not published: %w", iconfile, iconName, domain.ErrIconfileNotFound)
+		}
+	}
+
 	content, err := service.Repository.GetIconfile(ctx, iconName, iconfile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve iconfile %v: %w", iconfile, err)
@@ -124,8 +219,9 @@ func (service *IconService) AddIconfile(ctx context.Context, iconName string, in
 	}
 	iconfile := domain.Iconfile{
 		IconfileDescriptor: domain.IconfileDescriptor{
-			Format: format,
-			Size:   fmt.Sprintf("%dpx", config.Height),
+			Format:       format,
+			Size:         fmt.Sprintf("%dpx", config.Height),
+			ReviewStatus: service.newIconfileReviewStatus(),
 		},
 		Content: initialIconfileContent,
 	}
@@ -154,8 +250,52 @@ func (service *IconService) DeleteIconfile(ctx context.Context, iconName string,
 	return service.Repository.DeleteIconfile(ctx, iconName, iconfileDescriptor, modifiedBy)
 }
 
-func (service *IconService) GetTags(ctx context.Context) ([]string, error) {
-	return service.Repository.GetTags(ctx)
+// GetTags lists the existing tags, to users who only consume icons those of icons with published iconfiles
+func (service *IconService) GetTags(ctx context.Context, viewer authr.UserInfo) ([]string, error) {
+	tags, err := service.Repository.GetTags(ctx)
+	if err != nil {
+		return []string{}, fmt.Errorf("failed to get tags: %w", err)
+	}
+	if canSeeUnpublished(viewer) {
+		return tags, nil
+	}
+	icons, err := service.Repository.DescribeAllIcons(ctx)
+	if err != nil {
+		return []string{}, fmt.Errorf("failed to describe all icons for their published tags: %w", err)
+	}
+	publishedTags := map[string]bool{}
+	for _, icon := range icons {
+		if _, hasPublished := icon.OnlyPublished(); hasPublished {
+			for _, tag := range icon.Tags {
+				publishedTags[tag] = true
+			}
+		}
+	}
+	published := []string{}
+	for _, tag := range tags {
+		if publishedTags[tag] {
+			published = append(published, tag)
+		}
+	}
+	return published, nil
+}
+
+// GetIconsWithTag describes the icons having the tag, to users who only consume icons the published ones
+func (service *IconService) GetIconsWithTag(ctx context.Context, tag string, viewer authr.UserInfo) ([]domain.IconDescriptor, error) {
+	icons, err := service.Repository.GetIconsWithTag(ctx, tag)
+	if err != nil {
+		return []domain.IconDescriptor{}, fmt.Errorf("failed to describe icons with tag %s: %w", tag, err)
+	}
+	if canSeeUnpublished(viewer) {
+		return icons, nil
+	}
+	published := []domain.IconDescriptor{}
+	for _, icon := range icons {
+		if publishedIcon, hasPublished := icon.OnlyPublished(); hasPublished {
+			published = append(published, publishedIcon)
+		}
+	}
+	return published, nil
 }
 
 func (service *IconService) AddTag(ctx context.Context, iconName string, tag string, userInfo authr.UserInfo) error {
@@ -181,3 +321,161 @@ func (service *IconService) RemoveTag(ctx context.Context, iconName string, tag
 	}
 	return nil
 }
+
+func (service *IconService) SetIconDescription(ctx context.Context, iconName string, description string, userInfo authr.UserInfo) error {
+	permErr := authr.HasRequiredPermissions(userInfo, []authr.PermissionID{authr.UPDATE_ICON})
+	if permErr != nil {
+		return authr.ErrPermission
+	}
+	dbErr := service.Repository.SetIconDescription(ctx, iconName, description, userInfo)
+	if dbErr != nil {
+		return fmt.Errorf("failed to set the description of \"%s\": %w", iconName, dbErr)
+	}
+	return nil
+}
+
+// changesetPermissions are the permissions needed by each operation of a changeset, as by the same change made on its own
+var changesetPermissions = map[domain.AuditAction][]authr.PermissionID{
+	domain.AuditActionCreateIcon:     {authr.CREATE_ICON},
+	domain.AuditActionAddIconfile:    {authr.UPDATE_ICON, authr.ADD_ICONFILE},
+	domain.AuditActionDeleteIcon:     {authr.REMOVE_ICON},
+	domain.AuditActionDeleteIconfile: {authr.REMOVE_ICONFILE},
+	domain.AuditActionAddTag:         {authr.ADD_TAG},
+	domain.AuditActionRemoveTag:      {authr.REMOVE_TAG},
+}
+
+// ApplyChangeset applies several changes at once: either all of them are made or none.
+// The format and size of the iconfiles added are taken from their content, as with AddIconfile.
+func (service *IconService) ApplyChangeset(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy authr.UserInfo) ([]domain.IconDescriptor, error) {
+	prepared := make([]domain.ChangesetOperation, 0, len(ops))
+	for i, op := range ops {
+		permissions, known := changesetPermissions[op.Action]
+		if !known {
+			return nil, fmt.Errorf("operation #%d: %s is not a changeset operation: %w", i, op.Action, domain.ErrInvalidChangeset)
+		}
+		if permErr := authr.HasRequiredPermissions(modifiedBy, permissions); permErr != nil {
+			return nil, fmt.Errorf("not enough permissions to %s: %w", op, permErr)
+		}
+		if len(op.IconName) == 0 {
+			return nil, fmt.Errorf("operation #%d: no icon name: %w", i, domain.ErrInvalidChangeset)
+		}
+		switch op.Action {
+		case domain.AuditActionCreateIcon, domain.AuditActionAddIconfile:
+			config, format, decodeErr := image.DecodeConfig(bytes.NewReader(op.Iconfile.Content))
+			if decodeErr != nil {
+				return nil, fmt.Errorf("failed to decode iconfile of operation #%d (%s %s): %w", i, op.Action, op.IconName, decodeErr)
+			}
+			op.Iconfile.IconfileDescriptor = domain.IconfileDescriptor{
+				Format:       format,
+				Size:         fmt.Sprintf("%dpx", config.Height),
+				ReviewStatus: service.newIconfileReviewStatus(),
+			}
+		case domain.AuditActionDeleteIconfile:
+			if len(op.Iconfile.Format) == 0 || len(op.Iconfile.Size) == 0 {
+				return nil, fmt.Errorf("operation #%d: no iconfile to delete: %w", i, domain.ErrInvalidChangeset)
+			}
+		case domain.AuditActionAddTag, domain.AuditActionRemoveTag:
+			if len(op.Tag) == 0 {
+				return nil, fmt.Errorf("operation #%d: no tag: %w", i, domain.ErrInvalidChangeset)
+			}
+		}
+		prepared = append(prepared, op)
+	}
+
+	icons, applyErr := service.Repository.ApplyChangeset(ctx, prepared, modifiedBy)
+	if applyErr != nil {
+		return nil, fmt.Errorf("failed to apply changeset of %d operation(s): %w", len(prepared), applyErr)
+	}
+	return icons, nil
+}
+
+// UpdateReviewStatus moves an iconfile forward in the review workflow: editors submit drafts (or rejected iconfiles)
+// for review, approvers publish or reject iconfiles in review.
+func (service *IconService) UpdateReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, comment string, modifiedBy authr.UserInfo) error {
+	requiredPermission := authr.APPROVE_ICON
+	if status == domain.ReviewStatusInReview {
+		requiredPermission = authr.UPDATE_ICON
+	}
+	err := authr.HasRequiredPermissions(modifiedBy, []authr.PermissionID{requiredPermission})
+	if err != nil {
+		return fmt.Errorf("not enough permissions to change the review status of %v of \"%s\" to %s: %w", iconfile, iconName, status, err)
+	}
+
+	icon, err := service.Repository.DescribeIcon(ctx, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to describe icon \"%s\" for changing review status: %w", iconName, err)
+	}
+	current, err := icon.FindIconfile(iconfile)
+	if err != nil {
+		return fmt.Errorf("failed to find %v of \"%s\" for changing review status: %w", iconfile, iconName, err)
+	}
+	if !current.ReviewStatus.CanChangeTo(status) {
+		return fmt.Errorf("review status of %v of \"%s\" cannot be changed from %s to %s: %w", iconfile, iconName, current.ReviewStatus, status, domain.ErrInvalidReviewChange)
+	}
+
+	return service.Repository.SetIconfileReviewStatus(ctx, iconName, iconfile, status, comment, modifiedBy)
+}
+
+// CompleteMergeRequest publishes or discards the iconfile proposed in a merge request, depending on whether the merge request
+// has been merged or closed. The caller is GitLab, which is trusted on the grounds of the webhook secret rather than permissions.
+func (service *IconService) CompleteMergeRequest(ctx context.Context, sourceBranch string, merged bool, modifiedBy authr.UserInfo) error {
+	return service.Repository.CompleteMergeRequest(ctx, sourceBranch, merged, modifiedBy)
+}
+
+// CheckConsistency reports (and optionally repairs) the differences between the index and the blobstore
+func (service *IconService) CheckConsistency(ctx context.Context, repair bool, userInfo authr.UserInfo) (domain.ConsistencyReport, error) {
+	err := authr.HasRequiredPermissions(userInfo, []authr.PermissionID{authr.ADMINISTER_REPO})
+	if err != nil {
+		return domain.ConsistencyReport{}, fmt.Errorf("not enough permissions to check consistency: %w", err)
+	}
+	return service.Repository.CheckConsistency(ctx, repair, userInfo)
+}
+
+// Reindex rebuilds the (empty) index from the iconfiles in the blobstore
+func (service *IconService) Reindex(ctx context.Context, userInfo authr.UserInfo) (domain.ReindexReport, error) {
+	err := authr.HasRequiredPermissions(userInfo, []authr.PermissionID{authr.ADMINISTER_REPO})
+	if err != nil {
+		return domain.ReindexReport{}, fmt.Errorf("not enough permissions to reindex: %w", err)
+	}
+	return service.Repository.Reindex(ctx, userInfo)
+}
+
+// GetMirrorStatus reports on the pushes of the blobstore to its remote mirrors
+func (service *IconService) GetMirrorStatus(ctx context.Context, userInfo authr.UserInfo) ([]domain.MirrorStatus, error) {
+	err := authr.HasRequiredPermissions(userInfo, []authr.PermissionID{authr.ADMINISTER_REPO})
+	if err != nil {
+		return nil, fmt.Errorf("not enough permissions to get the mirror status: %w", err)
+	}
+	return service.Repository.GetMirrorStatus(ctx)
+}
+
+// GetWriteQueueStats reports on the writes to the blobstore
+func (service *IconService) GetWriteQueueStats(ctx context.Context, userInfo authr.UserInfo) (domain.WriteQueueStats, error) {
+	err := authr.HasRequiredPermissions(userInfo, []authr.PermissionID{authr.ADMINISTER_REPO})
+	if err != nil {
+		return domain.WriteQueueStats{}, fmt.Errorf("not enough permissions to get the write queue stats: %w", err)
+	}
+	return service.Repository.GetWriteQueueStats(ctx)
+}
+
+// GetIconfileCacheStats reports on the cache of iconfiles read from the blobstore
+func (service *IconService) GetIconfileCacheStats(ctx context.Context, userInfo authr.UserInfo) (domain.IconfileCacheStats, error) {
+	err := authr.HasRequiredPermissions(userInfo, []authr.PermissionID{authr.ADMINISTER_REPO})
+	if err != nil {
+		return domain.IconfileCacheStats{}, fmt.Errorf("not enough permissions to get the iconfile cache stats: %w", err)
+	}
+	return service.Repository.GetIconfileCacheStats(ctx)
+}
+
+// GetAuditEntries is for approvers and repository administrators only: the entries describe iconfiles yet to be published
+func (service *IconService) GetAuditEntries(ctx context.Context, query domain.AuditQuery, userInfo authr.UserInfo) (domain.AuditPage, error) {
+	err := authr.HasRequiredPermissions(userInfo, []authr.PermissionID{authr.ADMINISTER_REPO})
+	if err != nil && authr.HasRequiredPermissions(userInfo, []authr.PermissionID{authr.APPROVE_ICON}) != nil {
+		return domain.AuditPage{}, fmt.Errorf("not enough permissions to query audit entries: %w", err)
+	}
+	page, err := service.Repository.GetAuditEntries(ctx, query)
+	if err != nil {
+		return domain.AuditPage{}, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+	return page, nil
+}
@@ -18,6 +18,12 @@ const (
 	NotifMsgIconDeleted     NotificationMessage = "iconDeleted"
 	NotifMsgIconfileAdded   NotificationMessage = "iconfileAdded"
 	NotifMsgIconfileDeleted NotificationMessage = "iconfileDeleted"
+	// NotifMsgChangesetApplied tells that several icons may have changed at once
+	NotifMsgChangesetApplied NotificationMessage = "changesetApplied"
+
+	NotifMsgIconfileSubmittedForReview NotificationMessage = "iconfileSubmittedForReview"
+	NotifMsgIconfilePublished          NotificationMessage = "iconfilePublished"
+	NotifMsgIconfileRejected           NotificationMessage = "iconfileRejected"
 )
 
 // subscriber represents a subscriber.
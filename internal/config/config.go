@@ -30,11 +30,38 @@ type Options struct {
 	LoadBalancerAddress         string                     `json:"loadBalancerAddress" env:"LOAD_BALANCER_ADDRESS" long:"load-balancer-address" short:"" default:"" description:"The load balancer address patter"`
 	AppDescription              string                     `json:"appDescription" env:"APP_DESCRIPTION" long:"app-description" short:"" default:"" description:"Application description"`
 	SessionDbName               string                     `json:"sessionDbName" env:"SESSION_DB_NAME" long:"session-db-name" short:"" default:"" description:"Name of the session DB"`
+	Storage                     string                     `json:"storage" env:"STORAGE" long:"storage" short:"" default:"" description:"Set to memory to keep both the index and the iconfiles in memory, for development and tests; the data is lost at exit"`
+	BlobstoreType               string                     `json:"blobstoreType" env:"BLOBSTORE_TYPE" long:"blobstore-type" short:"" default:"git" description:"Type of the blobstore: git (local or GitLab), gitea, filesystem, s3 or postgres"`
+	BlobstoreWriteTimeout       int                        `json:"blobstoreWriteTimeout" env:"BLOBSTORE_WRITE_TIMEOUT" long:"blobstore-write-timeout" short:"" default:"0" description:"Seconds a write to the local git or filesystem blobstore may take, waiting for its turn included; 0 means no limit"`
+	OutboxRetryDelay            int                        `json:"outboxRetryDelay" env:"OUTBOX_RETRY_DELAY" long:"outbox-retry-delay" short:"" default:"300" description:"Seconds after which the blobstore changes left pending by a failed or interrupted write are settled; 0 disables settling them in the background"`
+	IconfileCacheSize           int                        `json:"iconfileCacheSize" env:"ICONFILE_CACHE_SIZE" long:"iconfile-cache-size" short:"" default:"64" description:"Megabytes of iconfile content cached in memory; 0 disables the cache"`
+	IconfileCacheDir            string                     `json:"iconfileCacheDir" env:"ICONFILE_CACHE_DIR" long:"iconfile-cache-dir" short:"" default:"" description:"Directory keeping the iconfiles evicted from the in-memory cache; none if empty"`
+	IconfileCacheDiskSize       int                        `json:"iconfileCacheDiskSize" env:"ICONFILE_CACHE_DISK_SIZE" long:"iconfile-cache-disk-size" short:"" default:"512" description:"Megabytes of iconfile content cached on disk"`
+	IconCatalogMaxAge           int                        `json:"iconCatalogMaxAge" env:"ICON_CATALOG_MAX_AGE" long:"icon-catalog-max-age" short:"" default:"300" description:"Seconds after which the icon catalog kept in memory is reloaded from the index, catching up with changes made by other servers; 0 disables keeping the catalog in memory"`
+	FilesystemBlobstoreRoot     string                     `json:"filesystemBlobstoreRoot" env:"FILESYSTEM_BLOBSTORE_ROOT" long:"filesystem-blobstore-root" short:"" default:"" description:"Root directory of the filesystem blobstore"`
+	S3Bucket                    string                     `json:"s3Bucket" env:"S3_BUCKET" long:"s3-bucket" short:"" default:"iconrepo" description:"Name of the S3 bucket holding the iconfiles"`
+	S3URL                       string                     `json:"s3Url" env:"S3_URL" long:"s3-url" short:"" default:"" description:"Endpoint of an S3-compatible object store (e.g. MinIO); AWS S3 if empty"`
 	LocalGitRepo                string                     `json:"localGitRepo" env:"LOCAL_GIT_REPO" long:"local-git-repo" short:"g" default:"" description:"Path to the local git repository"`
+	GitMirrorRemotes            string                     `json:"gitMirrorRemotes" env:"GIT_MIRROR_REMOTES" long:"git-mirror-remotes" short:"" default:"" description:"Comma-separated URLs of remote repositories the local git repository is pushed to"`
+	GitMirrorInterval           int                        `json:"gitMirrorInterval" env:"GIT_MIRROR_INTERVAL" long:"git-mirror-interval" short:"" default:"0" description:"Seconds between pushes to the mirrors; 0 pushes after each commit"`
 	GitlabNamespacePath         string                     `json:"gitlabNamespacePath" env:"GITLAB_NAMESPACE_PATH" long:"gitlab-namespace-path" short:"" default:"" description:"GitLab namespace path"`
 	GitlabProjectPath           string                     `json:"gitlabProjectPath" env:"GITLAB_PROJECT_PATH" long:"gitlab-project-path" short:"" default:"iconrepo-gitrepo-test" description:"GitLab project path"`
 	GitlabMainBranch            string                     `json:"gitlabMainBranch" env:"GITLAB_MAIN_BRANCH" long:"gitlab-main-branch" short:"" default:"main" description:"The GitLab project's main branch"`
 	GitlabAccessToken           string                     `json:"gitlabAccessToken" env:"GITLAB_ACCESS_TOKEN" long:"gitlab-access-token" short:"" default:"" description:"GitLab API access token"`
+	GitlabAPIURL                string                     `json:"gitlabApiUrl" env:"GITLAB_API_URL" long:"gitlab-api-url" short:"" default:"https://gitlab.com/api/v4" description:"Base URL of the GitLab REST API"`
+	GitlabMergeRequests         bool                       `json:"gitlabMergeRequests" env:"GITLAB_MERGE_REQUESTS" long:"gitlab-merge-requests" short:"" description:"Add iconfiles through merge requests instead of committing them to the main branch"`
+	GitlabWebhookSecret         string                     `json:"gitlabWebhookSecret" env:"GITLAB_WEBHOOK_SECRET" long:"gitlab-webhook-secret" short:"" default:"" description:"Secret token of the GitLab merge request webhook"`
+	GitlabRequestTimeout        int                        `json:"gitlabRequestTimeout" env:"GITLAB_REQUEST_TIMEOUT" long:"gitlab-request-timeout" short:"" default:"10" description:"Seconds each attempt at a GitLab API request may take"`
+	GitlabMaxRetries            int                        `json:"gitlabMaxRetries" env:"GITLAB_MAX_RETRIES" long:"gitlab-max-retries" short:"" default:"3" description:"Number of times a GitLab API request failing with a transient error (network error, timeout, 429 or 5xx) is retried"`
+	GitlabRetryDelayMs          int                        `json:"gitlabRetryDelayMs" env:"GITLAB_RETRY_DELAY_MS" long:"gitlab-retry-delay-ms" short:"" default:"500" description:"Milliseconds before the first retry of a GitLab API request; doubled with each retry"`
+	GitlabMaxRetryDelay         int                        `json:"gitlabMaxRetryDelay" env:"GITLAB_MAX_RETRY_DELAY" long:"gitlab-max-retry-delay" short:"" default:"30" description:"Seconds a retry of a GitLab API request may be delayed at most"`
+	GitlabBreakerThreshold      int                        `json:"gitlabBreakerThreshold" env:"GITLAB_BREAKER_THRESHOLD" long:"gitlab-breaker-threshold" short:"" default:"5" description:"Consecutive GitLab API failures after which requests fail right away for a while; 0 disables the circuit breaker"`
+	GitlabBreakerCooldown       int                        `json:"gitlabBreakerCooldown" env:"GITLAB_BREAKER_COOLDOWN" long:"gitlab-breaker-cooldown" short:"" default:"30" description:"Seconds requests to GitLab fail right away once the circuit breaker has tripped"`
+	GiteaAPIURL                 string                     `json:"giteaApiUrl" env:"GITEA_API_URL" long:"gitea-api-url" short:"" default:"" description:"Base URL of the Gitea REST API, e.g. https://gitea.example.com/api/v1"`
+	GiteaOwner                  string                     `json:"giteaOwner" env:"GITEA_OWNER" long:"gitea-owner" short:"" default:"" description:"User or organization owning the Gitea repository"`
+	GiteaRepository             string                     `json:"giteaRepository" env:"GITEA_REPOSITORY" long:"gitea-repository" short:"" default:"iconrepo" description:"Name of the Gitea repository"`
+	GiteaMainBranch             string                     `json:"giteaMainBranch" env:"GITEA_MAIN_BRANCH" long:"gitea-main-branch" short:"" default:"main" description:"The Gitea repository's main branch"`
+	GiteaAccessToken            string                     `json:"giteaAccessToken" env:"GITEA_ACCESS_TOKEN" long:"gitea-access-token" short:"" default:"" description:"Gitea API access token"`
 	AuthenticationType          authn.AuthenticationScheme `json:"authenticationType" env:"AUTHENTICATION_TYPE" long:"authentication-type" short:"a" default:"oidc" description:"Authentication type"`
 	PasswordCredentials         []PasswordCredentials      `json:"passwordCredentials" env:"PASSWORD_CREDENTIALS" long:"password-credentials"`
 	OIDCClientID                string                     `json:"oidcClientId" env:"OIDC_CLIENT_ID" long:"oidc-client-id" short:"" default:"" description:"OIDC client id"`
@@ -59,12 +86,29 @@ type Options struct {
 	LogLevel                    string                     `json:"logLevel" env:"LOG_LEVEL" long:"log-level" short:"l" default:"info"`
 	AllowedClientURLsRegex      string                     `json:"allowedClientUrlsRegex" env:"ALLOWED_CLIENT_URLS_REGEX" long:"allowed-client-urls-regex" short:"" default:""`
 	DynamodbURL                 string                     `json:"dynamodbUrl" env:"DYNAMODB_URL" long:"dynamodb-url" short:"" default:""`
+	DynamodbCreateTables        bool                       `json:"dynamodbCreateTables" env:"DYNAMODB_CREATE_TABLES" long:"dynamodb-create-tables" short:"" description:"Create the DynamoDB tables and global secondary indexes missing"`
+	DynamodbBillingMode         string                     `json:"dynamodbBillingMode" env:"DYNAMODB_BILLING_MODE" long:"dynamodb-billing-mode" short:"" default:"PAY_PER_REQUEST" description:"Billing mode of the DynamoDB tables created: PAY_PER_REQUEST or PROVISIONED"`
+	SQLiteFile                  string                     `json:"sqliteFile" env:"SQLITE_FILE" long:"sqlite-file" short:"" default:"" description:"Keep the index in this SQLite database file instead of Postgres"`
+	EnableReviewWorkflow        bool                       `json:"enableReviewWorkflow" env:"ENABLE_REVIEW_WORKFLOW" long:"enable-review-workflow" short:"" description:"New icons and iconfiles are drafts until they are approved"`
 }
 
 var DefaultIconRepoHome = filepath.Join(os.Getenv("HOME"), ".ui-toolbox/iconrepo")
 var DefaultIconDataLocationGit = filepath.Join(DefaultIconRepoHome, "git-repo")
+var DefaultIconDataLocationFilesystem = filepath.Join(DefaultIconRepoHome, "blobstore")
 var DefaultConfigFilePath = filepath.Join(DefaultIconRepoHome, "config.json")
 
+// Blobstore types
+const (
+	BlobstoreTypeGit        = "git"
+	BlobstoreTypeGitea      = "gitea"
+	BlobstoreTypeFilesystem = "filesystem"
+	BlobstoreTypeS3         = "s3"
+	BlobstoreTypePostgres   = "postgres"
+)
+
+// StorageMemory keeps both the index and the blobstore in memory
+const StorageMemory = "memory"
+
 type ConfigFilePath string
 
 const (
@@ -193,6 +237,9 @@ func parseFlagsMergeSettings(clArgs []string, mapFromConfFile map[string]interfa
 	if opts.LocalGitRepo == "" {
 		opts.LocalGitRepo = DefaultIconDataLocationGit
 	}
+	if opts.FilesystemBlobstoreRoot == "" {
+		opts.FilesystemBlobstoreRoot = DefaultIconDataLocationFilesystem
+	}
 
 	return opts
 }
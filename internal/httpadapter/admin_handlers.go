@@ -0,0 +1,52 @@
+package httpadapter
+
+import (
+	"context"
+	"errors"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// adminReport responds with the report returned by the administrative function given, e.g. the consistency check
+// or the stats of the write queue. Users not allowed to administer the repository are refused.
+func adminReport[T any](
+	functionName string,
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	getReport func(ctx context.Context, userInfo authr.UserInfo) (T, error),
+) func(g *gin.Context) {
+	return func(g *gin.Context) {
+		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", functionName).Logger()
+
+		report, reportErr := getReport(g.Request.Context(), getUserInfo(g))
+		if reportErr != nil {
+			switch {
+			case errors.Is(reportErr, authr.ErrPermission):
+				logger.Info().Err(reportErr).Msg("not allowed to administer the repository")
+				g.AbortWithStatus(http.StatusForbidden)
+			case errors.Is(reportErr, domain.ErrIndexNotEmpty):
+				logger.Info().Err(reportErr).Msg("refusing to reindex")
+				g.AbortWithStatus(http.StatusConflict)
+			default:
+				logger.Error().Err(reportErr).Msg("administrative function failed")
+				g.AbortWithStatus(http.StatusInternalServerError)
+			}
+			return
+		}
+		g.JSON(200, report)
+	}
+}
+
+// checkConsistency reports the differences between the index and the blobstore; with repair set, it also repairs them
+func checkConsistency(
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	checkConsistency func(ctx context.Context, repair bool, userInfo authr.UserInfo) (domain.ConsistencyReport, error),
+	repair bool,
+) func(g *gin.Context) {
+	return adminReport("checkConsistency", getUserInfo, func(ctx context.Context, userInfo authr.UserInfo) (domain.ConsistencyReport, error) {
+		return checkConsistency(ctx, repair, userInfo)
+	})
+}
@@ -0,0 +1,77 @@
+package httpadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+const maxAuditPageSize = 500
+
+func parseAuditQuery(g *gin.Context) (domain.AuditQuery, error) {
+	query := domain.AuditQuery{
+		IconName: g.Query("icon"),
+		Actor:    g.Query("user"),
+		Cursor:   g.Query("cursor"),
+	}
+
+	var err error
+	if since := g.Query("since"); len(since) > 0 {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return query, fmt.Errorf("invalid \"since\" %s: %w", since, err)
+		}
+	}
+	if until := g.Query("until"); len(until) > 0 {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return query, fmt.Errorf("invalid \"until\" %s: %w", until, err)
+		}
+	}
+	if limit := g.Query("limit"); len(limit) > 0 {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 || query.Limit > maxAuditPageSize {
+			return query, fmt.Errorf("invalid \"limit\" %s: must be between 1 and %d", limit, maxAuditPageSize)
+		}
+	}
+
+	return query, nil
+}
+
+func getAuditEntries(
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	getAuditEntries func(ctx context.Context, query domain.AuditQuery, userInfo authr.UserInfo) (domain.AuditPage, error),
+) func(g *gin.Context) {
+	return func(g *gin.Context) {
+		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "getAuditEntries").Logger()
+
+		query, parseErr := parseAuditQuery(g)
+		if parseErr != nil {
+			logger.Info().Err(parseErr).Msg("invalid audit query")
+			g.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		page, serviceError := getAuditEntries(g.Request.Context(), query, getUserInfo(g))
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Info().Err(serviceError).Msg("not allowed to query audit entries")
+				g.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			if errors.Is(serviceError, domain.ErrInvalidAuditCursor) {
+				g.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			logger.Error().Err(serviceError).Interface("query", query).Msg("failed to retrieve audit entries")
+			g.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		g.JSON(200, page)
+	}
+}
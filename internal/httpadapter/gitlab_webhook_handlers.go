@@ -0,0 +1,100 @@
+package httpadapter
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/app/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+const gitlabWebhookPath = "/gitlab/merge-request-hook"
+
+const gitlabTokenHeader = "X-Gitlab-Token"
+
+// MergeRequestEvent holds the parts of a GitLab merge request webhook event we care about
+type MergeRequestEvent struct {
+	ObjectKind string `json:"object_kind"`
+	User       struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		State        string `json:"state"`
+		Action       string `json:"action"`
+	} `json:"object_attributes"`
+}
+
+const (
+	mergeRequestActionMerge = "merge"
+	mergeRequestActionClose = "close"
+)
+
+// gitlabMergeRequestHook finalizes the index when a merge request opened by the GitLab blobstore is merged or closed.
+// Events that don't concern us are acknowledged with 200 so that GitLab doesn't keep redelivering them.
+func gitlabMergeRequestHook(
+	secret string,
+	completeMergeRequest func(ctx context.Context, sourceBranch string, merged bool, modifiedBy authr.UserInfo) error,
+	publish func(ctx context.Context, msg services.NotificationMessage, initiator authn.UserID),
+) func(g *gin.Context) {
+	return func(g *gin.Context) {
+		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "gitlabMergeRequestHook").Logger()
+
+		if subtle.ConstantTimeCompare([]byte(g.GetHeader(gitlabTokenHeader)), []byte(secret)) != 1 {
+			logger.Info().Msg("invalid webhook token")
+			g.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		var event MergeRequestEvent
+		if bindErr := g.BindJSON(&event); bindErr != nil {
+			logger.Info().Err(bindErr).Msg("failed to parse merge request event")
+			return
+		}
+
+		attrs := event.ObjectAttributes
+		logger = logger.With().Int("iid", attrs.IID).Str("source-branch", attrs.SourceBranch).Str("action", attrs.Action).Logger()
+		if event.ObjectKind != "merge_request" || (attrs.Action != mergeRequestActionMerge && attrs.Action != mergeRequestActionClose) {
+			logger.Debug().Str("object-kind", event.ObjectKind).Msg("ignoring event")
+			g.Status(http.StatusOK)
+			return
+		}
+
+		username := event.User.Username
+		if len(username) == 0 {
+			username = "gitlab"
+		}
+		modifiedBy := authr.UserInfo{UserId: authn.LocalDomain.CreateUserID(username)}
+
+		merged := attrs.Action == mergeRequestActionMerge
+		completeErr := completeMergeRequest(g.Request.Context(), attrs.SourceBranch, merged, modifiedBy)
+		if completeErr != nil {
+			if errors.Is(completeErr, domain.ErrUnknownChangeBranch) ||
+				errors.Is(completeErr, domain.ErrIconNotFound) ||
+				errors.Is(completeErr, domain.ErrIconfileNotFound) ||
+				errors.Is(completeErr, domain.ErrInvalidReviewChange) {
+				logger.Info().Err(completeErr).Msg("ignoring merge request event")
+				g.Status(http.StatusOK)
+				return
+			}
+			logger.Error().Err(completeErr).Msg("failed to complete merge request")
+			g.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if merged {
+			publish(g.Request.Context(), services.NotifMsgIconfilePublished, modifiedBy.UserId)
+		} else {
+			publish(g.Request.Context(), services.NotifMsgIconfileDeleted, modifiedBy.UserId)
+		}
+		g.Status(http.StatusOK)
+	}
+}
@@ -0,0 +1,110 @@
+package httpadapter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+type completedMergeRequest struct {
+	sourceBranch string
+	merged       bool
+	modifiedBy   string
+}
+
+type gitlabWebhookTestSuite struct {
+	suite.Suite
+	completed   []completedMergeRequest
+	completeErr error
+	published   []services.NotificationMessage
+}
+
+func TestGitlabWebhookTestSuite(t *testing.T) {
+	suite.Run(t, &gitlabWebhookTestSuite{})
+}
+
+func (s *gitlabWebhookTestSuite) BeforeTest(suiteName string, testName string) {
+	s.completed = nil
+	s.completeErr = nil
+	s.published = nil
+}
+
+func (s *gitlabWebhookTestSuite) send(token string, body string) int {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST(gitlabWebhookPath, gitlabMergeRequestHook(
+		testWebhookSecret,
+		func(ctx context.Context, sourceBranch string, merged bool, modifiedBy authr.UserInfo) error {
+			s.completed = append(s.completed, completedMergeRequest{sourceBranch, merged, modifiedBy.UserId.String()})
+			return s.completeErr
+		},
+		func(ctx context.Context, msg services.NotificationMessage, initiator authn.UserID) {
+			s.published = append(s.published, msg)
+		},
+	))
+
+	request := httptest.NewRequest(http.MethodPost, gitlabWebhookPath, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if len(token) > 0 {
+		request.Header.Set(gitlabTokenHeader, token)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func mergeRequestEventBody(action string, sourceBranch string) string {
+	return fmt.Sprintf(
+		`{"object_kind":"merge_request","user":{"username":"reviewer"},"object_attributes":{"iid":3,"source_branch":"%s","target_branch":"main","action":"%s"}}`,
+		sourceBranch,
+		action,
+	)
+}
+
+func (s *gitlabWebhookTestSuite) TestRejectsInvalidToken() {
+	body := mergeRequestEventBody(mergeRequestActionMerge, "iconrepo/attach_money/svg/18px")
+	s.Equal(http.StatusUnauthorized, s.send("", body))
+	s.Equal(http.StatusUnauthorized, s.send("not-the-secret", body))
+	s.Empty(s.completed)
+}
+
+func (s *gitlabWebhookTestSuite) TestMergePublishes() {
+	s.Equal(http.StatusOK, s.send(testWebhookSecret, mergeRequestEventBody(mergeRequestActionMerge, "iconrepo/attach_money/svg/18px")))
+	s.Equal([]completedMergeRequest{{"iconrepo/attach_money/svg/18px", true, "reviewer"}}, s.completed)
+	s.Equal([]services.NotificationMessage{services.NotifMsgIconfilePublished}, s.published)
+}
+
+func (s *gitlabWebhookTestSuite) TestCloseDiscards() {
+	s.Equal(http.StatusOK, s.send(testWebhookSecret, mergeRequestEventBody(mergeRequestActionClose, "iconrepo/attach_money/svg/18px")))
+	s.Equal([]completedMergeRequest{{"iconrepo/attach_money/svg/18px", false, "reviewer"}}, s.completed)
+	s.Equal([]services.NotificationMessage{services.NotifMsgIconfileDeleted}, s.published)
+}
+
+func (s *gitlabWebhookTestSuite) TestIgnoresOtherEvents() {
+	s.Equal(http.StatusOK, s.send(testWebhookSecret, mergeRequestEventBody("open", "iconrepo/attach_money/svg/18px")))
+	s.Equal(http.StatusOK, s.send(testWebhookSecret, `{"object_kind":"push"}`))
+	s.Empty(s.completed)
+
+	s.completeErr = fmt.Errorf("feature/other: %w", domain.ErrUnknownChangeBranch)
+	s.Equal(http.StatusOK, s.send(testWebhookSecret, mergeRequestEventBody(mergeRequestActionMerge, "feature/other")))
+	s.Empty(s.published)
+}
+
+func (s *gitlabWebhookTestSuite) TestFailureIsRetried() {
+	s.completeErr = fmt.Errorf("index unavailable")
+	s.Equal(http.StatusInternalServerError, s.send(testWebhookSecret, mergeRequestEventBody(mergeRequestActionMerge, "iconrepo/attach_money/svg/18px")))
+	s.Empty(s.published)
+}
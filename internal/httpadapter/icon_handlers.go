@@ -10,8 +10,10 @@ import (
 	"iconrepo/internal/app/security/authn"
 	"iconrepo/internal/app/security/authr"
 	"iconrepo/internal/app/services"
+	"image"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -25,10 +27,11 @@ type IconPath struct {
 }
 
 type IconDTO struct {
-	Name       string     `json:"name"`
-	ModifiedBy string     `json:"modifiedBy"`
-	Paths      []IconPath `json:"paths"`
-	Tags       []string   `json:"tags"`
+	Name        string     `json:"name"`
+	ModifiedBy  string     `json:"modifiedBy"`
+	Description string     `json:"description,omitempty"`
+	Paths       []IconPath `json:"paths"`
+	Tags        []string   `json:"tags"`
 }
 
 func createIconfilePath(baseUrl string, iconName string, iconfileDescriptor domain.IconfileDescriptor) string {
@@ -38,8 +41,9 @@ func createIconfilePath(baseUrl string, iconName string, iconfileDescriptor doma
 func CreateIconPath(baseUrl string, iconName string, iconfileDescriptor domain.IconfileDescriptor) IconPath {
 	return IconPath{
 		IconfileDescriptor: domain.IconfileDescriptor{
-			Format: iconfileDescriptor.Format,
-			Size:   iconfileDescriptor.Size,
+			Format:       iconfileDescriptor.Format,
+			Size:         iconfileDescriptor.Size,
+			ReviewStatus: iconfileDescriptor.ReviewStatus,
 		},
 		Path: createIconfilePath(baseUrl, iconName, iconfileDescriptor),
 	}
@@ -55,10 +59,11 @@ func CreateIconfilePaths(baseUrl string, iconDesc domain.IconDescriptor) []IconP
 
 func CreateResponseIcon(iconPathRoot string, iconDesc domain.IconDescriptor) IconDTO {
 	return IconDTO{
-		Name:       iconDesc.Name,
-		ModifiedBy: iconDesc.ModifiedBy,
-		Paths:      CreateIconfilePaths(iconPathRoot, iconDesc),
-		Tags:       iconDesc.Tags,
+		Name:        iconDesc.Name,
+		ModifiedBy:  iconDesc.ModifiedBy,
+		Description: iconDesc.Description,
+		Paths:       CreateIconfilePaths(iconPathRoot, iconDesc),
+		Tags:        iconDesc.Tags,
 	}
 }
 
@@ -80,29 +85,84 @@ func iconToResponseIcon(icon domain.Icon) IconDTO {
 	)
 }
 
-func describeAllIcons(describeAllIcons func(ctx context.Context) ([]domain.IconDescriptor, error)) func(g *gin.Context) {
+// IconCatalogDTO lists the icons changed and deleted since the revision asked for, or all of them if Full is set
+type IconCatalogDTO struct {
+	Revision string    `json:"revision"`
+	Full     bool      `json:"full"`
+	Icons    []IconDTO `json:"icons"`
+	Deleted  []string  `json:"deleted"`
+}
+
+// catalogETag is empty if the catalog has no revision. Those who only see the published iconfiles get a list of their own.
+func catalogETag(catalog domain.IconCatalog) string {
+	if len(catalog.Revision) == 0 {
+		return ""
+	}
+	if catalog.PublishedOnly {
+		return fmt.Sprintf("\"%s.published\"", catalog.Revision)
+	}
+	return fmt.Sprintf("\"%s\"", catalog.Revision)
+}
+
+// etagMatches tells whether the If-None-Match header lists the ETag
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// describeAllIcons lists all the icons, or, with the "since" query parameter (even empty), the changes since the revision given
+func describeAllIcons(
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	getIconCatalog func(ctx context.Context, since string, viewer authr.UserInfo) (domain.IconCatalog, error),
+) func(g *gin.Context) {
 	return func(g *gin.Context) {
 		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "describeAllIcons").Logger()
 
-		icons, err := describeAllIcons(g.Request.Context())
+		since, delta := g.GetQuery("since")
+		catalog, err := getIconCatalog(g.Request.Context(), since, getUserInfo(g))
 		if err != nil {
 			logger.Error().Err(err).Send()
 			g.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if etag := catalogETag(catalog); len(etag) > 0 {
+			g.Header("ETag", etag)
+			if etagMatches(g.GetHeader("If-None-Match"), etag) {
+				g.Status(http.StatusNotModified)
+				return
+			}
 		}
 		responseIcon := []IconDTO{}
-		for _, icon := range icons {
+		for _, icon := range catalog.Icons {
 			responseIcon = append(responseIcon, CreateResponseIcon(iconRootPath, icon))
 		}
-		g.JSON(200, responseIcon)
+		if !delta {
+			g.JSON(200, responseIcon)
+			return
+		}
+		g.JSON(200, IconCatalogDTO{
+			Revision: catalog.Revision,
+			Full:     catalog.Full,
+			Icons:    responseIcon,
+			Deleted:  catalog.Deleted,
+		})
 	}
 }
 
-func describeIcon(describeIcon func(ctx context.Context, iconName string) (domain.IconDescriptor, error)) func(g *gin.Context) {
+func describeIcon(
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	describeIcon func(ctx context.Context, iconName string, viewer authr.UserInfo) (domain.IconDescriptor, error),
+) func(g *gin.Context) {
 	return func(g *gin.Context) {
 		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "describeIcon").Logger()
 
 		iconName := g.Param("name")
-		icon, err := describeIcon(g.Request.Context(), iconName)
+		icon, err := describeIcon(g.Request.Context(), iconName, getUserInfo(g))
 		if err != nil {
 			logger.Error().Err(err).Send()
 			if errors.Is(err, domain.ErrIconNotFound) {
@@ -169,7 +229,10 @@ func createIcon(
 	}
 }
 
-func getIconfile(getIconfile func(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor) ([]byte, error)) func(g *gin.Context) {
+func getIconfile(
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	getIconfile func(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, viewer authr.UserInfo) ([]byte, error),
+) func(g *gin.Context) {
 	return func(g *gin.Context) {
 		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "getIconfile").Logger()
 
@@ -179,7 +242,7 @@ func getIconfile(getIconfile func(ctx context.Context, iconName string, iconfile
 		iconfile, err := getIconfile(g.Request.Context(), iconName, domain.IconfileDescriptor{
 			Format: format,
 			Size:   size,
-		})
+		}, getUserInfo(g))
 		if err != nil {
 			if errors.Is(err, domain.ErrIconfileNotFound) {
 				g.AbortWithStatus(404)
@@ -323,11 +386,72 @@ func deleteIconfile(
 	}
 }
 
-func getTags(getTags func(ctx context.Context) ([]string, error)) func(g *gin.Context) {
+type ReviewStatusRequestData struct {
+	Status  string `json:"status"`
+	Comment string `json:"comment"`
+}
+
+var reviewNotifications = map[domain.ReviewStatus]services.NotificationMessage{
+	domain.ReviewStatusInReview:  services.NotifMsgIconfileSubmittedForReview,
+	domain.ReviewStatusPublished: services.NotifMsgIconfilePublished,
+	domain.ReviewStatusRejected:  services.NotifMsgIconfileRejected,
+}
+
+func updateReviewStatus(
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	updateReviewStatus func(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, comment string, modifiedBy authr.UserInfo) error,
+	publish func(ctx context.Context, msg services.NotificationMessage, initiator authn.UserID),
+) func(g *gin.Context) {
+	return func(g *gin.Context) {
+		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "updateReviewStatus").Logger()
+
+		authorInfo := getUserInfo(g)
+		iconName := g.Param("name")
+		iconfileDescriptor := domain.IconfileDescriptor{Format: g.Param("format"), Size: g.Param("size")}
+
+		var requestData ReviewStatusRequestData
+		if bindErr := g.BindJSON(&requestData); bindErr != nil {
+			logger.Info().Err(bindErr).Str("icon-name", iconName).Msg("failed to parse review status request")
+			return
+		}
+		status, parseErr := domain.ParseReviewStatus(requestData.Status)
+		if parseErr != nil {
+			logger.Info().Err(parseErr).Str("status", requestData.Status).Msg("invalid review status")
+			g.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		updateErr := updateReviewStatus(g.Request.Context(), iconName, iconfileDescriptor, status, requestData.Comment, authorInfo)
+		if updateErr != nil {
+			logger.Info().Err(updateErr).Str("icon-name", iconName).Interface("iconfile", iconfileDescriptor).Str("status", status.String()).Msg("failed to update review status")
+			if errors.Is(updateErr, authr.ErrPermission) {
+				g.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			if errors.Is(updateErr, domain.ErrIconNotFound) || errors.Is(updateErr, domain.ErrIconfileNotFound) {
+				g.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+			if errors.Is(updateErr, domain.ErrInvalidReviewChange) {
+				g.AbortWithStatus(http.StatusConflict)
+				return
+			}
+			g.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		publish(g.Request.Context(), reviewNotifications[status], authorInfo.UserId)
+		g.Status(204)
+	}
+}
+
+func getTags(
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	getTags func(ctx context.Context, viewer authr.UserInfo) ([]string, error),
+) func(g *gin.Context) {
 	return func(g *gin.Context) {
 		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "getTags").Logger()
 
-		tags, serviceError := getTags(g.Request.Context())
+		tags, serviceError := getTags(g.Request.Context(), getUserInfo(g))
 		if serviceError != nil {
 			logger.Error().Err(serviceError).Msg("failed to retrieve tags")
 			g.AbortWithStatus(http.StatusInternalServerError)
@@ -337,6 +461,28 @@ func getTags(getTags func(ctx context.Context) ([]string, error)) func(g *gin.Co
 	}
 }
 
+func getIconsWithTag(
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	getIconsWithTag func(ctx context.Context, tag string, viewer authr.UserInfo) ([]domain.IconDescriptor, error),
+) func(g *gin.Context) {
+	return func(g *gin.Context) {
+		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "getIconsWithTag").Logger()
+
+		tag := g.Param("tag")
+		icons, serviceError := getIconsWithTag(g.Request.Context(), tag, getUserInfo(g))
+		if serviceError != nil {
+			logger.Error().Err(serviceError).Str("tag", tag).Msg("failed to retrieve icons with tag")
+			g.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		responseIcons := []IconDTO{}
+		for _, icon := range icons {
+			responseIcons = append(responseIcons, CreateResponseIcon(iconRootPath, icon))
+		}
+		g.JSON(200, responseIcons)
+	}
+}
+
 type AddServiceRequestData struct {
 	Tag string `json:"tag"`
 }
@@ -415,3 +561,116 @@ func removeTag(
 		g.Status(204)
 	}
 }
+
+type DescriptionRequestData struct {
+	Description string `json:"description"`
+}
+
+func setIconDescription(
+	getUserInfo func(g *gin.Context) authr.UserInfo,
+	setIconDescription func(ctx context.Context, iconName string, description string, modifiedBy authr.UserInfo) error,
+) func(g *gin.Context) {
+	return func(g *gin.Context) {
+		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "setIconDescription").Logger()
+
+		userInfo := getUserInfo(g)
+		iconName := g.Param("name")
+
+		var requestData DescriptionRequestData
+		if bindErr := g.BindJSON(&requestData); bindErr != nil {
+			logger.Info().Err(bindErr).Str("icon-name", iconName).Msg("failed to parse description request")
+			return
+		}
+
+		serviceError := setIconDescription(g.Request.Context(), iconName, requestData.Description, userInfo)
+		if serviceError != nil {
+			if errors.Is(serviceError, authr.ErrPermission) {
+				logger.Info().Err(serviceError).Str("icon-name", iconName).Msg("not allowed to set the description")
+				g.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			if errors.Is(serviceError, domain.ErrIconNotFound) {
+				logger.Info().Err(serviceError).Str("icon-name", iconName).Msg("icon not found to set the description of")
+				g.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+			logger.Error().Err(serviceError).Str("icon-name", iconName).Msg("failed to set the description")
+			g.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		g.Status(http.StatusNoContent)
+	}
+}
+
+// ChangesetOperationDTO is one of the operations of a changeset. Content is the base64 encoded iconfile
+// added by createIcon and addIconfile, format and size select the iconfile deleted by deleteIconfile.
+type ChangesetOperationDTO struct {
+	Op       string `json:"op"`
+	IconName string `json:"iconName"`
+	Content  []byte `json:"content,omitempty"`
+	Format   string `json:"format,omitempty"`
+	Size     string `json:"size,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+}
+
+type ChangesetRequestData struct {
+	Operations []ChangesetOperationDTO `json:"operations"`
+}
+
+func applyChangeset(
+	getUserInfo func(c *gin.Context) authr.UserInfo,
+	applyChangeset func(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy authr.UserInfo) ([]domain.IconDescriptor, error),
+	publish func(ctx context.Context, msg services.NotificationMessage, initiator authn.UserID),
+) func(g *gin.Context) {
+	return func(g *gin.Context) {
+		logger := zerolog.Ctx(g.Request.Context()).With().Str("function", "applyChangeset").Logger()
+
+		var requestData ChangesetRequestData
+		if bindErr := g.BindJSON(&requestData); bindErr != nil {
+			logger.Info().Err(bindErr).Msg("failed to parse changeset")
+			return
+		}
+		if len(requestData.Operations) == 0 {
+			logger.Info().Msg("empty changeset")
+			g.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		ops := []domain.ChangesetOperation{}
+		for _, opData := range requestData.Operations {
+			ops = append(ops, domain.ChangesetOperation{
+				Action:   domain.AuditAction(opData.Op),
+				IconName: opData.IconName,
+				Iconfile: domain.Iconfile{
+					IconfileDescriptor: domain.IconfileDescriptor{Format: opData.Format, Size: opData.Size},
+					Content:            opData.Content,
+				},
+				Tag: opData.Tag,
+			})
+		}
+
+		authorInfo := getUserInfo(g)
+		icons, applyErr := applyChangeset(g.Request.Context(), ops, authorInfo)
+		if applyErr != nil {
+			logger.Info().Err(applyErr).Int("operation-count", len(ops)).Msg("failed to apply changeset")
+			switch {
+			case errors.Is(applyErr, authr.ErrPermission):
+				g.AbortWithStatus(http.StatusForbidden)
+			case errors.Is(applyErr, domain.ErrIconAlreadyExists), errors.Is(applyErr, domain.ErrIconfileAlreadyExists):
+				g.AbortWithStatus(http.StatusConflict)
+			case errors.Is(applyErr, domain.ErrIconNotFound), errors.Is(applyErr, domain.ErrIconfileNotFound):
+				g.AbortWithStatus(http.StatusNotFound)
+			case errors.Is(applyErr, domain.ErrInvalidChangeset), errors.Is(applyErr, image.ErrFormat):
+				g.AbortWithStatus(http.StatusBadRequest)
+			default:
+				g.AbortWithStatus(http.StatusInternalServerError)
+			}
+			return
+		}
+		publish(g.Request.Context(), services.NotifMsgChangesetApplied, authorInfo.UserId)
+		responseIcons := []IconDTO{}
+		for _, icon := range icons {
+			responseIcons = append(responseIcons, CreateResponseIcon(iconRootPath, icon))
+		}
+		g.JSON(200, responseIcons)
+	}
+}
@@ -170,18 +170,37 @@ func (s *server) initEndpoints(options config.Options) *gin.Engine {
 			authorizedGroup.GET("/backdoor/authentication", HandleGetIntoBackdoorRequest())
 		}
 
-		authorizedGroup.GET("/icon", describeAllIcons(s.api.DescribeAllIcons))
-		authorizedGroup.GET("/icon/:name", describeIcon(s.api.DescribeIcon))
+		authorizedGroup.GET("/icon", describeAllIcons(mustGetUserInfo, s.api.GetIconCatalog))
+		authorizedGroup.GET("/icon/:name", describeIcon(mustGetUserInfo, s.api.DescribeIcon))
 		authorizedGroup.POST("/icon", createIcon(mustGetUserInfo, s.api.CreateIcon, notifService.Publish))
 		authorizedGroup.DELETE("/icon/:name", deleteIcon(mustGetUserInfo, s.api.DeleteIcon, notifService.Publish))
 
 		authorizedGroup.POST("/icon/:name", addIconfile(mustGetUserInfo, s.api.AddIconfile, notifService.Publish))
-		authorizedGroup.GET("/icon/:name/format/:format/size/:size", getIconfile(s.api.GetIconfile))
+		authorizedGroup.GET("/icon/:name/format/:format/size/:size", getIconfile(mustGetUserInfo, s.api.GetIconfile))
 		authorizedGroup.DELETE("/icon/:name/format/:format/size/:size", deleteIconfile(mustGetUserInfo, s.api.DeleteIconfile, notifService.Publish))
+		authorizedGroup.PUT("/icon/:name/format/:format/size/:size/review-status", updateReviewStatus(mustGetUserInfo, s.api.UpdateReviewStatus, notifService.Publish))
 
-		authorizedGroup.GET("/tag", getTags(s.api.GetTags))
+		authorizedGroup.POST("/changeset", applyChangeset(mustGetUserInfo, s.api.ApplyChangeset, notifService.Publish))
+
+		authorizedGroup.GET("/tag", getTags(mustGetUserInfo, s.api.GetTags))
+		authorizedGroup.GET("/tag/:tag/icon", getIconsWithTag(mustGetUserInfo, s.api.GetIconsWithTag))
 		authorizedGroup.POST("/icon/:name/tag", addTag(mustGetUserInfo, s.api.AddTag))
 		authorizedGroup.DELETE("/icon/:name/tag/:tag", removeTag(mustGetUserInfo, s.api.RemoveTag))
+		authorizedGroup.PUT("/icon/:name/description", setIconDescription(mustGetUserInfo, s.api.SetIconDescription))
+
+		authorizedGroup.GET("/audit", getAuditEntries(mustGetUserInfo, s.api.GetAuditEntries))
+
+		authorizedGroup.GET("/admin/consistency", checkConsistency(mustGetUserInfo, s.api.CheckConsistency, false))
+		authorizedGroup.POST("/admin/consistency/repair", checkConsistency(mustGetUserInfo, s.api.CheckConsistency, true))
+		authorizedGroup.POST("/admin/reindex", adminReport("reindex", mustGetUserInfo, s.api.Reindex))
+		authorizedGroup.GET("/admin/mirrors", adminReport("getMirrorStatus", mustGetUserInfo, s.api.GetMirrorStatus))
+		authorizedGroup.GET("/admin/write-queue", adminReport("getWriteQueueStats", mustGetUserInfo, s.api.GetWriteQueueStats))
+		authorizedGroup.GET("/admin/iconfile-cache", adminReport("getIconfileCacheStats", mustGetUserInfo, s.api.GetIconfileCacheStats))
+
+		if options.GitlabMergeRequests && len(options.GitlabWebhookSecret) > 0 {
+			// GitLab authenticates with the webhook secret rather than a user session
+			rootEngine.POST(gitlabWebhookPath, gitlabMergeRequestHook(options.GitlabWebhookSecret, s.api.CompleteMergeRequest, notifService.Publish))
+		}
 	}
 
 	return rootEngine
@@ -201,10 +220,11 @@ func (s *server) Stop() {
 func RequestLogger(g *gin.Context) {
 	start := time.Now()
 
-	l := logging.Get().With().Str("req_xid", xid.New().String()).Logger()
+	requestID := xid.New().String()
+	l := logging.Get().With().Str("req_xid", requestID).Logger()
 
 	r := g.Request
-	g.Request = r.WithContext(l.WithContext(r.Context()))
+	g.Request = r.WithContext(l.WithContext(logging.WithRequestID(r.Context(), requestID)))
 
 	lrw := newLoggingResponseWriter(g.Writer)
 
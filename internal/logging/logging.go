@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -116,3 +117,16 @@ func CreateUnitLogger(logger zerolog.Logger, unitName string) zerolog.Logger {
 func CreateMethodLogger(logger zerolog.Logger, unitName string) zerolog.Logger {
 	return logger.With().Str(MethodLogger, unitName).Logger()
 }
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the id of the request being served
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// GetRequestID returns the id of the request being served or the empty string if ctx has no request id
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
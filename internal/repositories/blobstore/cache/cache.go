@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// IconfileCache keeps the content of the iconfiles most recently read from the blobstore in memory. The least recently
+// used iconfiles are evicted once the size limit is reached, to the disk tier if there is one, which is limited the same way.
+// The cache only knows of the changes it is told about: the iconfiles of an icon are to be invalidated once it has been
+// changed in the blobstore.
+type IconfileCache struct {
+	mutex sync.Mutex
+	// generation is bumped by each invalidation, so that content read before it isn't cached after it
+	generation uint64
+	memory     *lru
+	disk       *lru
+	diskDir    string
+	stats      domain.IconfileCacheStats
+}
+
+type cacheKey struct {
+	iconName string
+	format   string
+	size     string
+}
+
+func keyOf(iconName string, iconfile domain.IconfileDescriptor) cacheKey {
+	return cacheKey{iconName: iconName, format: iconfile.Format, size: iconfile.Size}
+}
+
+// lru keeps the sizes (and, in memory, the content) of the entries, the most recently used first
+type lru struct {
+	maxBytes int64
+	bytes    int64
+	entries  map[cacheKey]*list.Element
+	order    *list.List
+}
+
+type lruEntry struct {
+	key     cacheKey
+	size    int64
+	content []byte
+}
+
+func newLRU(maxBytes int64) *lru {
+	return &lru{maxBytes: maxBytes, entries: map[cacheKey]*list.Element{}, order: list.New()}
+}
+
+func (l *lru) get(key cacheKey) (*lruEntry, bool) {
+	element, found := l.entries[key]
+	if !found {
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	return element.Value.(*lruEntry), true
+}
+
+// add returns the entries evicted to make room for the new one
+func (l *lru) add(entry *lruEntry) []*lruEntry {
+	l.remove(entry.key)
+	l.entries[entry.key] = l.order.PushFront(entry)
+	l.bytes += entry.size
+	evicted := []*lruEntry{}
+	for l.bytes > l.maxBytes {
+		oldest := l.order.Back()
+		evictedEntry := oldest.Value.(*lruEntry)
+		l.remove(evictedEntry.key)
+		evicted = append(evicted, evictedEntry)
+	}
+	return evicted
+}
+
+func (l *lru) remove(key cacheKey) bool {
+	element, found := l.entries[key]
+	if !found {
+		return false
+	}
+	l.order.Remove(element)
+	delete(l.entries, key)
+	l.bytes -= element.Value.(*lruEntry).size
+	return true
+}
+
+func (l *lru) keysOf(iconName string) []cacheKey {
+	keys := []cacheKey{}
+	for key := range l.entries {
+		if key.iconName == iconName {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// cacheFileRegexp matches the names of the files of the disk tier
+var cacheFileRegexp = regexp.MustCompile(`^[0-9a-f]{64}\.iconfile$`)
+
+// NewIconfileCache creates a cache keeping up to maxBytes of content in memory. With a non-empty diskDir, up to
+// diskMaxBytes of content evicted from memory is kept in files in that directory; the files left there by an earlier
+// process are removed, as the iconfiles may have changed since.
+func NewIconfileCache(maxBytes int64, diskDir string, diskMaxBytes int64) (*IconfileCache, error) {
+	cache := &IconfileCache{
+		memory: newLRU(maxBytes),
+		stats:  domain.IconfileCacheStats{Enabled: true, MaxBytes: maxBytes},
+	}
+	if len(diskDir) == 0 || diskMaxBytes <= 0 {
+		return cache, nil
+	}
+
+	mkdirErr := os.MkdirAll(diskDir, 0700)
+	if mkdirErr != nil {
+		return nil, fmt.Errorf("failed to create iconfile cache directory %s: %w", diskDir, mkdirErr)
+	}
+	dirEntries, readDirErr := os.ReadDir(diskDir)
+	if readDirErr != nil {
+		return nil, fmt.Errorf("failed to read iconfile cache directory %s: %w", diskDir, readDirErr)
+	}
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() && cacheFileRegexp.MatchString(dirEntry.Name()) {
+			if removeErr := os.Remove(filepath.Join(diskDir, dirEntry.Name())); removeErr != nil {
+				return nil, fmt.Errorf("failed to clear iconfile cache directory %s: %w", diskDir, removeErr)
+			}
+		}
+	}
+	cache.diskDir = diskDir
+	cache.disk = newLRU(diskMaxBytes)
+	cache.stats.DiskMaxBytes = diskMaxBytes
+	return cache, nil
+}
+
+func (cache *IconfileCache) diskPath(key cacheKey) string {
+	hash := sha256.Sum256([]byte(key.iconName + "\x00" + key.format + "\x00" + key.size))
+	return filepath.Join(cache.diskDir, hex.EncodeToString(hash[:])+".iconfile")
+}
+
+// GetIconfile returns the content of the iconfile from the cache, or else as read by the function given, caching it.
+// The content returned is not to be modified.
+func (cache *IconfileCache) GetIconfile(iconName string, iconfile domain.IconfileDescriptor, read func() ([]byte, error)) ([]byte, error) {
+	key := keyOf(iconName, iconfile)
+
+	cache.mutex.Lock()
+	if entry, found := cache.memory.get(key); found {
+		cache.stats.Hits++
+		cache.mutex.Unlock()
+		return entry.content, nil
+	}
+	if content, found := cache.readFromDisk(key); found {
+		cache.stats.DiskHits++
+		cache.store(key, content)
+		cache.mutex.Unlock()
+		return content, nil
+	}
+	cache.stats.Misses++
+	generation := cache.generation
+	cache.mutex.Unlock()
+
+	content, readErr := read()
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	// The iconfile may have been changed while it was being read
+	if cache.generation == generation {
+		cache.store(key, content)
+	}
+	return content, nil
+}
+
+// readFromDisk moves the entry from the disk tier to memory, where it is stored by the caller
+func (cache *IconfileCache) readFromDisk(key cacheKey) ([]byte, bool) {
+	if cache.disk == nil {
+		return nil, false
+	}
+	if _, found := cache.disk.get(key); !found {
+		return nil, false
+	}
+	content, readErr := os.ReadFile(cache.diskPath(key))
+	cache.removeFromDisk(key)
+	if readErr != nil {
+		return nil, false
+	}
+	return content, true
+}
+
+func (cache *IconfileCache) removeFromDisk(key cacheKey) {
+	if cache.disk.remove(key) {
+		_ = os.Remove(cache.diskPath(key))
+	}
+}
+
+// store keeps the content in memory, the entries evicted going to disk. Content larger than the limit isn't cached.
+func (cache *IconfileCache) store(key cacheKey, content []byte) {
+	entry := &lruEntry{key: key, size: int64(len(content)), content: content}
+	if entry.size > cache.memory.maxBytes {
+		cache.storeOnDisk(entry)
+		return
+	}
+	for _, evicted := range cache.memory.add(entry) {
+		cache.stats.Evictions++
+		cache.storeOnDisk(evicted)
+	}
+}
+
+func (cache *IconfileCache) storeOnDisk(entry *lruEntry) {
+	if cache.disk == nil || entry.size > cache.disk.maxBytes {
+		return
+	}
+	// Written aside and renamed into place, so that a file is never read half-written
+	path := cache.diskPath(entry.key)
+	tempPath := path + ".tmp"
+	if writeErr := os.WriteFile(tempPath, entry.content, 0600); writeErr != nil {
+		_ = os.Remove(tempPath)
+		return
+	}
+	if renameErr := os.Rename(tempPath, path); renameErr != nil {
+		_ = os.Remove(tempPath)
+		return
+	}
+	for _, evicted := range cache.disk.add(&lruEntry{key: entry.key, size: entry.size}) {
+		_ = os.Remove(cache.diskPath(evicted.key))
+	}
+}
+
+// InvalidateIcon drops the iconfiles of the icon from the cache
+func (cache *IconfileCache) InvalidateIcon(iconName string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.generation++
+	cache.stats.Invalidations++
+	for _, key := range cache.memory.keysOf(iconName) {
+		cache.memory.remove(key)
+	}
+	if cache.disk != nil {
+		for _, key := range cache.disk.keysOf(iconName) {
+			cache.removeFromDisk(key)
+		}
+	}
+}
+
+// Stats reports on the use of the cache so far
+func (cache *IconfileCache) Stats() domain.IconfileCacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	stats := cache.stats
+	stats.Entries = len(cache.memory.entries)
+	stats.Bytes = cache.memory.bytes
+	if cache.disk != nil {
+		stats.DiskEntries = len(cache.disk.entries)
+		stats.DiskBytes = cache.disk.bytes
+	}
+	return stats
+}
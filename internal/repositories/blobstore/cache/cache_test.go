@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"errors"
+	"iconrepo/internal/app/domain"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type cacheTestSuite struct {
+	suite.Suite
+	reads int
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, &cacheTestSuite{})
+}
+
+func (s *cacheTestSuite) BeforeTest(suiteName string, testName string) {
+	s.reads = 0
+}
+
+var png24 = domain.IconfileDescriptor{Format: "png", Size: "24px"}
+var png48 = domain.IconfileDescriptor{Format: "png", Size: "48px"}
+
+// reader returns a function reading the content given, counting the reads
+func (s *cacheTestSuite) reader(content string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		s.reads++
+		return []byte(content), nil
+	}
+}
+
+func (s *cacheTestSuite) get(cache *IconfileCache, iconName string, iconfile domain.IconfileDescriptor, content string) {
+	got, err := cache.GetIconfile(iconName, iconfile, s.reader(content))
+	s.Require().NoError(err)
+	s.Equal(content, string(got))
+}
+
+func (s *cacheTestSuite) newCache(maxBytes int64, diskDir string, diskMaxBytes int64) *IconfileCache {
+	cache, err := NewIconfileCache(maxBytes, diskDir, diskMaxBytes)
+	s.Require().NoError(err)
+	return cache
+}
+
+func (s *cacheTestSuite) TestSecondReadIsAHit() {
+	cache := s.newCache(100, "", 0)
+
+	s.get(cache, "cart", png24, "0123456789")
+	s.get(cache, "cart", png24, "0123456789")
+
+	s.Equal(1, s.reads)
+	stats := cache.Stats()
+	s.Equal(uint64(1), stats.Hits)
+	s.Equal(uint64(1), stats.Misses)
+	s.Equal(1, stats.Entries)
+	s.Equal(int64(10), stats.Bytes)
+}
+
+func (s *cacheTestSuite) TestLeastRecentlyUsedIsEvicted() {
+	cache := s.newCache(25, "", 0)
+
+	s.get(cache, "cart", png24, "0123456789")
+	s.get(cache, "cart", png48, "0123456789")
+	s.get(cache, "cart", png24, "0123456789")
+	s.get(cache, "basket", png24, "0123456789")
+	s.Equal(3, s.reads)
+
+	s.get(cache, "cart", png24, "0123456789")
+	s.Equal(3, s.reads)
+	s.get(cache, "cart", png48, "0123456789")
+	s.Equal(4, s.reads)
+	s.Equal(uint64(2), cache.Stats().Evictions)
+	s.LessOrEqual(cache.Stats().Bytes, int64(25))
+}
+
+func (s *cacheTestSuite) TestTooLargeIsNotCached() {
+	cache := s.newCache(5, "", 0)
+
+	s.get(cache, "cart", png24, "0123456789")
+	s.get(cache, "cart", png24, "0123456789")
+
+	s.Equal(2, s.reads)
+	s.Equal(0, cache.Stats().Entries)
+}
+
+func (s *cacheTestSuite) TestReadErrorIsNotCached() {
+	cache := s.newCache(100, "", 0)
+	readErr := errors.New("blobstore down")
+
+	_, err := cache.GetIconfile("cart", png24, func() ([]byte, error) { return nil, readErr })
+	s.ErrorIs(err, readErr)
+
+	s.get(cache, "cart", png24, "0123456789")
+	s.Equal(1, s.reads)
+}
+
+func (s *cacheTestSuite) TestInvalidatedIconIsReadAgain() {
+	cache := s.newCache(100, "", 0)
+	s.get(cache, "cart", png24, "0123456789")
+	s.get(cache, "basket", png24, "0123456789")
+
+	cache.InvalidateIcon("cart")
+
+	s.get(cache, "cart", png24, "9876543210")
+	s.get(cache, "basket", png24, "0123456789")
+	s.Equal(3, s.reads)
+	s.Equal(uint64(1), cache.Stats().Invalidations)
+}
+
+func (s *cacheTestSuite) TestContentReadWhileInvalidatedIsNotCached() {
+	cache := s.newCache(100, "", 0)
+
+	_, err := cache.GetIconfile("cart", png24, func() ([]byte, error) {
+		// The icon is changed while its former content is being read
+		cache.InvalidateIcon("cart")
+		return []byte("stale"), nil
+	})
+	s.Require().NoError(err)
+
+	s.get(cache, "cart", png24, "fresh")
+	s.Equal(1, s.reads)
+}
+
+func (s *cacheTestSuite) TestEvictedIconfilesAreKeptOnDisk() {
+	diskDir := s.T().TempDir()
+	cache := s.newCache(15, diskDir, 100)
+
+	s.get(cache, "cart", png24, "0123456789")
+	s.get(cache, "basket", png24, "abcdefghij")
+	s.Equal(1, cache.Stats().DiskEntries)
+
+	s.get(cache, "cart", png24, "0123456789")
+	s.Equal(2, s.reads)
+	stats := cache.Stats()
+	s.Equal(uint64(1), stats.DiskHits)
+	// Moved back to memory, pushing the other one out to disk
+	s.Equal(1, stats.Entries)
+	s.Equal(1, stats.DiskEntries)
+	s.Equal(int64(10), stats.DiskBytes)
+}
+
+func (s *cacheTestSuite) TestInvalidationReachesTheDisk() {
+	diskDir := s.T().TempDir()
+	cache := s.newCache(15, diskDir, 100)
+	s.get(cache, "cart", png24, "0123456789")
+	s.get(cache, "basket", png24, "abcdefghij")
+
+	cache.InvalidateIcon("cart")
+
+	s.Equal(0, cache.Stats().DiskEntries)
+	files, readDirErr := os.ReadDir(diskDir)
+	s.NoError(readDirErr)
+	s.Empty(files)
+	s.get(cache, "cart", png24, "9876543210")
+	s.Equal(3, s.reads)
+}
+
+func (s *cacheTestSuite) TestDiskFilesOfEarlierProcessesAreRemoved() {
+	diskDir := s.T().TempDir()
+	cache := s.newCache(15, diskDir, 100)
+	s.get(cache, "cart", png24, "0123456789")
+	s.get(cache, "basket", png24, "abcdefghij")
+	otherFile := filepath.Join(diskDir, "README")
+	s.Require().NoError(os.WriteFile(otherFile, []byte("not ours"), 0600))
+
+	s.newCache(15, diskDir, 100)
+
+	files, readDirErr := os.ReadDir(diskDir)
+	s.NoError(readDirErr)
+	s.Len(files, 1)
+	s.Equal("README", files[0].Name())
+}
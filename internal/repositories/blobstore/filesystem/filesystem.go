@@ -0,0 +1,369 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/internal/repositories/blobstore/scheduler"
+
+	"github.com/rs/zerolog"
+)
+
+// Filesystem stores the iconfiles in a plain directory tree laid out like the git repository, without versioning.
+// Files are written to a temporary file first and renamed into place, so readers never see a partially written iconfile.
+// Writes to the same icon are serialized; writes to different icons run in parallel.
+type Filesystem struct {
+	Location string
+	Logger   zerolog.Logger
+	// Writes runs the changes to the blobstore, keyed by the name of the icon changed
+	Writes *scheduler.Scheduler
+}
+
+const tempFilePrefix = ".tmp-"
+
+func NewFilesystemBlobstore(location string) *Filesystem {
+	repo := &Filesystem{Location: location}
+	repo.Writes = scheduler.NewScheduler(repo.String(), 0)
+	return repo
+}
+
+func (repo *Filesystem) String() string {
+	return fmt.Sprintf("Filesystem blobstore at %s", repo.Location)
+}
+
+// WriteQueueStats reports on the changes made to the blobstore
+func (repo *Filesystem) WriteQueueStats() domain.WriteQueueStats {
+	return repo.Writes.Stats()
+}
+
+func (repo *Filesystem) CreateRepository(ctx context.Context) error {
+	if err := os.MkdirAll(repo.Location, 0700); err != nil {
+		return fmt.Errorf("failed to create filesystem blobstore at %s: %w", repo.Location, err)
+	}
+	return nil
+}
+
+func (repo *Filesystem) ResetRepository(ctx context.Context) error {
+	deleteErr := repo.DeleteRepository(ctx)
+	if deleteErr != nil {
+		return deleteErr
+	}
+	return repo.CreateRepository(ctx)
+}
+
+func (repo *Filesystem) DeleteRepository(ctx context.Context) error {
+	return os.RemoveAll(repo.Location)
+}
+
+func syncDir(dir string) error {
+	dirFile, openErr := os.Open(dir)
+	if openErr != nil {
+		return openErr
+	}
+	defer dirFile.Close()
+	return dirFile.Sync()
+}
+
+// writeFileAtomically writes the content to a temporary file in the target directory, flushes it to disk
+// and renames it to the target path
+func writeFileAtomically(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	if mkdirErr := os.MkdirAll(dir, 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, mkdirErr)
+	}
+
+	tempFile, createErr := os.CreateTemp(dir, tempFilePrefix+filepath.Base(path)+"-*")
+	if createErr != nil {
+		return fmt.Errorf("failed to create temporary file for %s: %w", path, createErr)
+	}
+	tempPath := tempFile.Name()
+	cleanUp := func(err error) error {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return err
+	}
+
+	if _, writeErr := tempFile.Write(content); writeErr != nil {
+		return cleanUp(fmt.Errorf("failed to write %s: %w", tempPath, writeErr))
+	}
+	if syncErr := tempFile.Sync(); syncErr != nil {
+		return cleanUp(fmt.Errorf("failed to sync %s: %w", tempPath, syncErr))
+	}
+	if closeErr := tempFile.Close(); closeErr != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to close %s: %w", tempPath, closeErr)
+	}
+	if renameErr := os.Rename(tempPath, path); renameErr != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename %s to %s: %w", tempPath, path, renameErr)
+	}
+	if syncErr := syncDir(dir); syncErr != nil {
+		return fmt.Errorf("failed to sync directory %s: %w", dir, syncErr)
+	}
+	return nil
+}
+
+// removeFile removes the file and flushes the removal to disk
+func removeFile(path string) error {
+	if removeErr := os.Remove(path); removeErr != nil {
+		return removeErr
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func (repo *Filesystem) AddIconfile(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy string) error {
+	return repo.Writes.Run(ctx, []string{iconName}, func(ctx context.Context) error {
+		pathToIconfile := git.NewGitFilePaths(repo.Location).GetAbsolutePathToIconfile(iconName, iconfile.IconfileDescriptor)
+		repo.Logger.Debug().Str("path", pathToIconfile).Msg("writing iconfile")
+		if writeErr := writeFileAtomically(pathToIconfile, iconfile.Content); writeErr != nil {
+			return fmt.Errorf("failed to add iconfile %v for %s to filesystem blobstore at %s: %w", iconfile.IconfileDescriptor, iconName, repo.Location, writeErr)
+		}
+		return nil
+	})
+}
+
+func (repo *Filesystem) GetIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) ([]byte, error) {
+	pathToFile := git.NewGitFilePaths(repo.Location).GetAbsolutePathToIconfile(iconName, iconfileDesc)
+	content, err := os.ReadFile(pathToFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read file %s from filesystem blobstore: %w", pathToFile, domain.ErrIconfileNotFound)
+		}
+		return nil, fmt.Errorf("failed to read file %s from filesystem blobstore: %w", pathToFile, err)
+	}
+	return content, nil
+}
+
+func (repo *Filesystem) deleteIconfileFile(iconName string, iconfileDesc domain.IconfileDescriptor) error {
+	removeErr := removeFile(git.NewGitFilePaths(repo.Location).GetAbsolutePathToIconfile(iconName, iconfileDesc))
+	if removeErr != nil {
+		if errors.Is(removeErr, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove iconfile %v for icon %s: %w", iconfileDesc, iconName, domain.ErrIconfileNotFound)
+		}
+		return fmt.Errorf("failed to remove iconfile %v for icon %s: %w", iconfileDesc, iconName, removeErr)
+	}
+	return nil
+}
+
+// movedFile is a file moved aside to be removed, under a temporary name the listings skip
+type movedFile struct {
+	path      string
+	asidePath string
+}
+
+func moveAside(path string) (movedFile, error) {
+	file := movedFile{path: path, asidePath: filepath.Join(filepath.Dir(path), tempFilePrefix+filepath.Base(path)+"-deleted")}
+	if renameErr := os.Rename(file.path, file.asidePath); renameErr != nil {
+		return movedFile{}, renameErr
+	}
+	return file, nil
+}
+
+// DeleteIcon moves the files of the icon aside before removing any of them: should one of them fail to be moved,
+// those already moved are put back, leaving the icon as it was.
+func (repo *Filesystem) DeleteIcon(ctx context.Context, iconDesc domain.IconDescriptor, modifiedBy authn.UserID) error {
+	return repo.Writes.Run(ctx, []string{iconDesc.Name}, func(ctx context.Context) error {
+		paths := git.NewGitFilePaths(repo.Location)
+		moved := []movedFile{}
+		putBack := func(err error) error {
+			for _, file := range moved {
+				if renameErr := os.Rename(file.asidePath, file.path); renameErr != nil {
+					repo.Logger.Error().Err(renameErr).Str("path", file.path).Msg("failed to put back file of icon not deleted")
+				}
+			}
+			return err
+		}
+
+		for _, iconfileDesc := range iconDesc.Iconfiles {
+			file, moveErr := moveAside(paths.GetAbsolutePathToIconfile(iconDesc.Name, iconfileDesc))
+			if errors.Is(moveErr, os.ErrNotExist) {
+				moveErr = domain.ErrIconfileNotFound
+			}
+			if moveErr != nil {
+				return putBack(fmt.Errorf("failed to remove iconfile %v of icon %s from filesystem blobstore: %w", iconfileDesc, iconDesc.Name, moveErr))
+			}
+			moved = append(moved, file)
+		}
+		metadataFile, moveErr := moveAside(paths.GetAbsolutePathToIconMetadata(iconDesc.Name))
+		if moveErr != nil && !errors.Is(moveErr, os.ErrNotExist) {
+			return putBack(fmt.Errorf("failed to remove metadata of icon %s from filesystem blobstore: %w", iconDesc.Name, moveErr))
+		}
+		if moveErr == nil {
+			moved = append(moved, metadataFile)
+		}
+
+		// The files are out of sight already: those failing to be removed are left behind as temporary files
+		dirs := []string{}
+		for _, file := range moved {
+			if removeErr := os.Remove(file.asidePath); removeErr != nil {
+				repo.Logger.Warn().Err(removeErr).Str("path", file.asidePath).Msg("failed to remove file of icon deleted")
+			}
+			if dir := filepath.Dir(file.path); !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+		for _, dir := range dirs {
+			if syncErr := syncDir(dir); syncErr != nil {
+				return fmt.Errorf("failed to sync directory %s: %w", dir, syncErr)
+			}
+		}
+		return nil
+	})
+}
+
+func (repo *Filesystem) DeleteIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor, modifiedBy authn.UserID) error {
+	return repo.Writes.Run(ctx, []string{iconName}, func(ctx context.Context) error {
+		if deleteErr := repo.deleteIconfileFile(iconName, iconfileDesc); deleteErr != nil {
+			return fmt.Errorf("failed to remove iconfile %v of \"%s\" from filesystem blobstore: %w", iconfileDesc, iconName, deleteErr)
+		}
+		return nil
+	})
+}
+
+// walkFiles calls fn with the slash-separated path, relative to the root, of every regular file in the blobstore.
+// Temporary files of writes in progress are skipped.
+func (repo *Filesystem) walkFiles(fn func(pathInRepo string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(repo.Location, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if errors.Is(walkErr, os.ErrNotExist) {
+				return nil
+			}
+			return walkErr
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			return nil
+		}
+		relPath, relErr := filepath.Rel(repo.Location, path)
+		if relErr != nil {
+			return relErr
+		}
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			return infoErr
+		}
+		return fn(filepath.ToSlash(relPath), info)
+	})
+}
+
+// GetIconfiles lists the files in the blobstore, except the metadata files of icons
+func (repo *Filesystem) GetIconfiles(ctx context.Context) ([]string, error) {
+	fileList := []string{}
+	walkErr := repo.walkFiles(func(pathInRepo string, info fs.FileInfo) error {
+		if !git.IsIconMetadataPath(pathInRepo) {
+			fileList = append(fileList, pathInRepo)
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return nil, fmt.Errorf("failed to list files in filesystem blobstore at %s: %w", repo.Location, walkErr)
+	}
+	sort.Strings(fileList)
+	return fileList, nil
+}
+
+func (repo *Filesystem) UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error {
+	content, marshalErr := git.MarshalIconMetadata(metadata)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal metadata of icon %s: %w", iconName, marshalErr)
+	}
+
+	return repo.Writes.Run(ctx, []string{iconName}, func(ctx context.Context) error {
+		pathToMetadata := git.NewGitFilePaths(repo.Location).GetAbsolutePathToIconMetadata(iconName)
+		if current, readErr := os.ReadFile(pathToMetadata); readErr == nil && bytes.Equal(current, content) {
+			return nil
+		}
+		if writeErr := writeFileAtomically(pathToMetadata, content); writeErr != nil {
+			return fmt.Errorf("failed to update metadata of icon %s in filesystem blobstore at %s: %w", iconName, repo.Location, writeErr)
+		}
+		return nil
+	})
+}
+
+func (repo *Filesystem) GetIconMetadata(ctx context.Context, iconName string) (domain.IconMetadata, error) {
+	content, readErr := os.ReadFile(git.NewGitFilePaths(repo.Location).GetAbsolutePathToIconMetadata(iconName))
+	if readErr != nil {
+		if errors.Is(readErr, os.ErrNotExist) {
+			return domain.IconMetadata{}, fmt.Errorf("no metadata for icon %s: %w", iconName, domain.ErrIconMetadataNotFound)
+		}
+		return domain.IconMetadata{}, fmt.Errorf("failed to read metadata of icon %s from filesystem blobstore: %w", iconName, readErr)
+	}
+	metadata, unmarshalErr := git.UnmarshalIconMetadata(content)
+	if unmarshalErr != nil {
+		return domain.IconMetadata{}, fmt.Errorf("failed to unmarshal metadata of icon %s: %w", iconName, unmarshalErr)
+	}
+	return metadata, nil
+}
+
+// CheckStatus tells whether no write has been left unfinished in the blobstore
+func (repo *Filesystem) CheckStatus() (bool, error) {
+	clean := true
+	walkErr := filepath.WalkDir(repo.Location, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			clean = false
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return false, fmt.Errorf("failed to check status of filesystem blobstore at %s: %w", repo.Location, walkErr)
+	}
+	return clean, nil
+}
+
+// GetStateID returns a digest of the paths, sizes and modification times of the files in the blobstore
+func (repo *Filesystem) GetStateID(ctx context.Context) (string, error) {
+	entries := []string{}
+	walkErr := repo.walkFiles(func(pathInRepo string, info fs.FileInfo) error {
+		entries = append(entries, fmt.Sprintf("%s %d %d", pathInRepo, info.Size(), info.ModTime().UnixNano()))
+		return nil
+	})
+	if walkErr != nil {
+		return "", fmt.Errorf("failed to get state of filesystem blobstore at %s: %w", repo.Location, walkErr)
+	}
+	sort.Strings(entries)
+	digest := sha1.Sum([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// GetVersionFor returns the modification time of the iconfile in nanoseconds as its version.
+// Returns empty string in case the file doesn't exist in the blobstore
+func (repo *Filesystem) GetVersionFor(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) (string, error) {
+	info, statErr := os.Stat(git.NewGitFilePaths(repo.Location).GetAbsolutePathToIconfile(iconName, iconfileDesc))
+	if statErr != nil {
+		if errors.Is(statErr, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get version of %s::%s: %w", iconName, iconfileDesc.String(), statErr)
+	}
+	return strconv.FormatInt(info.ModTime().UnixNano(), 10), nil
+}
+
+// GetVersionMetadata describes a version returned by GetVersionFor in the shape of git commit metadata
+func (repo *Filesystem) GetVersionMetadata(ctx context.Context, versionId string) (git.CommitMetadata, error) {
+	modTime, parseErr := strconv.ParseInt(versionId, 10, 64)
+	if parseErr != nil {
+		return git.CommitMetadata{}, fmt.Errorf("failed to parse version %s: %w", versionId, parseErr)
+	}
+	return git.CommitMetadata{
+		Commit:     versionId,
+		CommitDate: time.Unix(0, modTime),
+		AuthorDate: time.Unix(0, modTime),
+	}, nil
+}
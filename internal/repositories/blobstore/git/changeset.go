@@ -0,0 +1,168 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+const changesetAppliedSuccessMessage = "changeset applied:\n\n%s"
+
+// ApplyChangeset makes all the changes of a changeset in a single commit
+func (repo *Local) ApplyChangeset(ctx context.Context, changes []domain.BlobstoreChange, modifiedBy string) error {
+	iconfileOperation := func() ([]string, error) {
+		fileList := []string{}
+		for _, change := range changes {
+			pathsInRepo, changeErr := repo.applyBlobstoreChange(change)
+			fileList = append(fileList, pathsInRepo...)
+			if changeErr != nil {
+				return fileList, fmt.Errorf("failed to %s: %w", change, changeErr)
+			}
+		}
+		return fileList, nil
+	}
+
+	jobTextProvider := gitJobTextProvider{
+		"apply changeset",
+		func(fileList []string) string {
+			return fmt.Sprintf(changesetAppliedSuccessMessage, fileListAsText(fileList))
+		},
+	}
+
+	err := repo.Writes.Run(ctx, nil, func(ctx context.Context) error {
+		return repo.executeIconfileJob(ctx, iconfileOperation, jobTextProvider, modifiedBy)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to apply changeset to git repository at %s: %w", repo.Location, err)
+	}
+	return nil
+}
+
+// applyBlobstoreChange returns the paths in the repository the change has been made to
+func (repo *Local) applyBlobstoreChange(change domain.BlobstoreChange) ([]string, error) {
+	switch change.Kind {
+	case domain.BlobstoreChangeAddIconfile:
+		pathInRepo, err := repo.createIconfile(change.IconName, change.Iconfile, "")
+		return []string{pathInRepo}, err
+	case domain.BlobstoreChangeDeleteIconfile:
+		pathInRepo, err := repo.deleteIconfileFile(change.IconName, change.Iconfile.IconfileDescriptor)
+		return []string{pathInRepo}, err
+	case domain.BlobstoreChangeUpdateMetadata:
+		content, marshalErr := MarshalIconMetadata(change.Metadata)
+		if marshalErr != nil {
+			return nil, fmt.Errorf("failed to marshal metadata of icon %s: %w", change.IconName, marshalErr)
+		}
+		if current, readErr := os.ReadFile(repo.FilePaths.GetAbsolutePathToIconMetadata(change.IconName)); readErr == nil && bytes.Equal(current, content) {
+			return nil, nil
+		}
+		pathInRepo, err := repo.writeIconMetadataFile(change.IconName, content)
+		return []string{pathInRepo}, err
+	case domain.BlobstoreChangeDeleteIcon:
+		fileList := []string{}
+		for _, iconfile := range change.Icon.Iconfiles {
+			pathInRepo, err := repo.deleteIconfileFile(change.IconName, iconfile)
+			if err != nil {
+				return fileList, err
+			}
+			fileList = append(fileList, pathInRepo)
+		}
+		metadataPath, err := repo.deleteIconMetadataFile(change.IconName)
+		if len(metadataPath) > 0 {
+			fileList = append(fileList, metadataPath)
+		}
+		return fileList, err
+	default:
+		return nil, fmt.Errorf("unknown blobstore change %s: %w", change.Kind, domain.ErrInvalidChangeset)
+	}
+}
+
+// ApplyChangeset makes all the changes of a changeset in a single commit on the main branch
+func (g *Gitlab) ApplyChangeset(ctx context.Context, changes []domain.BlobstoreChange, modifiedBy string) error {
+	actions := []commitActionOnByteSlice{}
+	for _, change := range changes {
+		changeActions, actionsErr := blobstoreChangeActions(ctx, change, g.getIconMetadataFile)
+		if actionsErr != nil {
+			return fmt.Errorf("failed to prepare commit of changeset to GitLab repo: %w", actionsErr)
+		}
+		actions = append(actions, changeActions...)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	commitErr := g.commit(ctx, g.mainBranch, "", modifiedBy, fmt.Sprintf("Applying changeset of %d change(s)", len(changes)), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to commit changeset to GitLab repo: %w", commitErr)
+	}
+	zerolog.Ctx(ctx).Info().Int("changeCount", len(changes)).Msg("Changeset committed to GitLab repository")
+	return nil
+}
+
+// blobstoreChangeActions tells the commit actions making the change on a remote repository, the current metadata of icons
+// being read with getIconMetadataFile
+func blobstoreChangeActions(ctx context.Context, change domain.BlobstoreChange, getIconMetadataFile func(ctx context.Context, iconName string) ([]byte, error)) ([]commitActionOnByteSlice, error) {
+	switch change.Kind {
+	case domain.BlobstoreChangeAddIconfile:
+		action := commitActionCreate
+		if change.Replace {
+			action = commitActionUpdate
+		}
+		return []commitActionOnByteSlice{{
+			Action:   action,
+			FilePath: paths.getPathComponents(change.IconName, change.Iconfile.IconfileDescriptor).pathToIconfile,
+			Content:  change.Iconfile.Content,
+		}}, nil
+	case domain.BlobstoreChangeDeleteIconfile:
+		return []commitActionOnByteSlice{{
+			Action:   commitActionDelete,
+			FilePath: paths.getPathComponents(change.IconName, change.Iconfile.IconfileDescriptor).pathToIconfile,
+		}}, nil
+	case domain.BlobstoreChangeUpdateMetadata:
+		content, marshalErr := MarshalIconMetadata(change.Metadata)
+		if marshalErr != nil {
+			return nil, fmt.Errorf("failed to marshal metadata of icon %s: %w", change.IconName, marshalErr)
+		}
+		current, getErr := getIconMetadataFile(ctx, change.IconName)
+		if getErr != nil {
+			return nil, getErr
+		}
+		if bytes.Equal(current, content) {
+			return nil, nil
+		}
+		action := commitActionUpdate
+		if current == nil {
+			action = commitActionCreate
+		}
+		return []commitActionOnByteSlice{{
+			Action:   action,
+			FilePath: paths.getPathToIconMetadataInRepo(change.IconName),
+			Content:  content,
+		}}, nil
+	case domain.BlobstoreChangeDeleteIcon:
+		actions := []commitActionOnByteSlice{}
+		for _, iconfile := range change.Icon.Iconfiles {
+			actions = append(actions, commitActionOnByteSlice{
+				Action:   commitActionDelete,
+				FilePath: paths.getPathComponents(change.IconName, iconfile).pathToIconfile,
+			})
+		}
+		metadata, getErr := getIconMetadataFile(ctx, change.IconName)
+		if getErr != nil {
+			return nil, fmt.Errorf("failed to check for metadata of icon %s: %w", change.IconName, getErr)
+		}
+		if metadata != nil {
+			actions = append(actions, commitActionOnByteSlice{
+				Action:   commitActionDelete,
+				FilePath: paths.getPathToIconMetadataInRepo(change.IconName),
+			})
+		}
+		return actions, nil
+	default:
+		return nil, fmt.Errorf("unknown blobstore change %s: %w", change.Kind, domain.ErrInvalidChangeset)
+	}
+}
@@ -2,9 +2,10 @@ package git
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 type CommitMetadata struct {
@@ -15,68 +16,14 @@ type CommitMetadata struct {
 	Message    string
 }
 
-var authorRegexp = regexp.MustCompile(`^Author:[\s]+(.+)$`)
-var authorDateRegexp = regexp.MustCompile(`^AuthorDate:[\s]+(.+)([0-9]{2})([0-9]{2})$`)
-var commitRegexp = regexp.MustCompile(`^Commit:[\s]+(.+)$`)
-var commitDateRegexp = regexp.MustCompile(`^CommitDate:[\s]+(.+)([0-9]{2})([0-9]{2})$`)
-
-func parseLocalCommitMetadata(metadata string) (CommitMetadata, error) {
-	commitMetadata := CommitMetadata{}
-	commitMessageBuffer := []string{}
-
-	lines := strings.Split(metadata, "\n")
-	for _, line := range lines {
-		submatch := authorRegexp.FindStringSubmatch(line)
-		if submatch != nil {
-			commitMetadata.Author = submatch[1]
-			continue
-		}
-
-		submatch = commitRegexp.FindStringSubmatch(line)
-		if submatch != nil {
-			commitMetadata.Commit = submatch[1]
-			continue
-		}
-
-		tim, found, err := parseTimeFromLocalCommitOutput(*authorDateRegexp, line)
-		if err != nil {
-			return commitMetadata, err
-		}
-		if found {
-			commitMetadata.AuthorDate = tim
-			continue
-		}
-
-		tim, found, err = parseTimeFromLocalCommitOutput(*commitDateRegexp, line)
-		if err != nil {
-			return commitMetadata, err
-		}
-		if found {
-			commitMetadata.CommitDate = tim
-			continue
-		}
-
-		if strings.Index(line, "    ") == 0 {
-			commitMessageBuffer = append(commitMessageBuffer, strings.Trim(line, " \t"))
-		}
-	}
-
-	commitMetadata.Message = strings.Join(commitMessageBuffer, "\n")
-
-	return commitMetadata, nil
-}
-
-func parseTimeFromLocalCommitOutput(rexp regexp.Regexp, line string) (time.Time, bool, error) {
-	if rexp.MatchString(line) {
-		submatch := rexp.FindStringSubmatch(line)
-		rfc3339 := fmt.Sprintf("%s%s:%s", submatch[1], submatch[2], submatch[3])
-		t, err := time.Parse(time.RFC3339, rfc3339)
-		if err != nil {
-			return time.Time{}, false, fmt.Errorf("failed to parse time `%s` as RFC3339: %w", rfc3339, err)
-		}
-		return t, true, nil
+func localCommitMetadata(commit *object.Commit) CommitMetadata {
+	return CommitMetadata{
+		Author:     fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email),
+		AuthorDate: commit.Author.When,
+		Commit:     fmt.Sprintf("%s <%s>", commit.Committer.Name, commit.Committer.Email),
+		CommitDate: commit.Committer.When,
+		Message:    strings.TrimSpace(commit.Message),
 	}
-	return time.Time{}, false, nil
 }
 
 func gitlabCommitResponseToMetadata(response commitQueryResponseItem) (CommitMetadata, error) {
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"iconrepo/internal/app/domain"
 	"path/filepath"
+	"strings"
 )
 
+// IconMetadataDir is the directory (relative to the repository root) holding the metadata files of icons
+const IconMetadataDir = "_meta"
+
 type iconfilePathComponents struct {
 	pathToFormatDir      string
 	pathToSizeDir        string
@@ -25,6 +29,21 @@ func getFileName(iconName string, format string, size string) string {
 	return fmt.Sprintf("%s@%s.%s", iconName, size, format)
 }
 
+// ParseIconfilePath is the inverse of GetPathToIconfileInRepo: it tells which iconfile is stored at the path relative to the repository root
+func ParseIconfilePath(pathInRepo string) (string, domain.IconfileDescriptor, error) {
+	segments := strings.Split(filepath.ToSlash(pathInRepo), "/")
+	if len(segments) != 3 {
+		return "", domain.IconfileDescriptor{}, fmt.Errorf("unexpected number of path segments in %s", pathInRepo)
+	}
+	format, size, fileName := segments[0], segments[1], segments[2]
+	nameSuffix := fmt.Sprintf("@%s.%s", size, format)
+	iconName := strings.TrimSuffix(fileName, nameSuffix)
+	if len(format) == 0 || len(size) == 0 || len(iconName) == 0 || iconName == fileName {
+		return "", domain.IconfileDescriptor{}, fmt.Errorf("%s is not the path of an iconfile", pathInRepo)
+	}
+	return iconName, domain.IconfileDescriptor{Format: format, Size: size}, nil
+}
+
 func (p filePaths) getPathComponents0(iconName string, format string, size string) iconfilePathComponents {
 	fileName := getFileName(iconName, format, size)
 	pathToFormatDir := filepath.Join(p.pathPrefix, format)
@@ -54,3 +73,16 @@ func (p filePaths) GetAbsolutePathToIconfile(iconName string, iconfile domain.Ic
 func (p filePaths) GetPathToIconfileInRepo(iconName string, iconfile domain.IconfileDescriptor) string {
 	return p.getPathComponents(iconName, iconfile).pathToIconfileInRepo
 }
+
+func (p filePaths) getPathToIconMetadataInRepo(iconName string) string {
+	return filepath.Join(IconMetadataDir, iconName+".json")
+}
+
+func (p filePaths) GetAbsolutePathToIconMetadata(iconName string) string {
+	return filepath.Join(p.pathPrefix, p.getPathToIconMetadataInRepo(iconName))
+}
+
+// IsIconMetadataPath tells whether the path relative to the repository root is that of an icon metadata file
+func IsIconMetadataPath(pathInRepo string) bool {
+	return strings.HasPrefix(filepath.ToSlash(pathInRepo), IconMetadataDir+"/")
+}
@@ -0,0 +1,537 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Gitea keeps the iconfiles in a repository of a Gitea (or Forgejo) server, through its GitHub-like REST API.
+// The changes are committed with the endpoint changing several files at once, which needs Gitea 1.20 or newer.
+type Gitea struct {
+	apiURL     string
+	owner      string
+	repoName   string
+	mainBranch string
+	apikey     string
+	client     *http.Client
+}
+
+const giteaRequestTimeout = 30 * time.Second
+
+func NewGiteaRepositoryClient(apiURL string, owner string, repoName string, branch string, apikey string) (*Gitea, error) {
+	if len(apiURL) == 0 {
+		return nil, fmt.Errorf("no API URL for Gitea repository")
+	}
+	if len(apikey) == 0 {
+		return nil, fmt.Errorf("no API token for Gitea repository")
+	}
+	if len(owner) == 0 || len(repoName) == 0 {
+		return nil, fmt.Errorf("no owner or name for Gitea repository")
+	}
+	return &Gitea{
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		owner:      owner,
+		repoName:   repoName,
+		mainBranch: branch,
+		apikey:     apikey,
+		client:     &http.Client{Timeout: giteaRequestTimeout},
+	}, nil
+}
+
+func (g *Gitea) String() string {
+	return fmt.Sprintf("Gitea repository at %s/%s?ref=%s", g.owner, g.repoName, g.mainBranch)
+}
+
+func (g *Gitea) repoPath() string {
+	return fmt.Sprintf("/repos/%s/%s", url.PathEscape(g.owner), url.PathEscape(g.repoName))
+}
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+type giteaCreateRepoOption struct {
+	Name          string `json:"name"`
+	DefaultBranch string `json:"default_branch"`
+	AutoInit      bool   `json:"auto_init"`
+	Private       bool   `json:"private"`
+}
+
+type giteaContentsResponse struct {
+	Type     string `json:"type"`
+	Path     string `json:"path"`
+	SHA      string `json:"sha"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+type giteaTreeEntry struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+type giteaTreeResponse struct {
+	Tree      []giteaTreeEntry `json:"tree"`
+	Truncated bool             `json:"truncated"`
+	Page      int              `json:"page"`
+}
+
+type giteaIdentity struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Date  string `json:"date,omitempty"`
+}
+
+type giteaCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message   string        `json:"message"`
+		Author    giteaIdentity `json:"author"`
+		Committer giteaIdentity `json:"committer"`
+	} `json:"commit"`
+}
+
+type giteaChangeFileOperation struct {
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	Content   string `json:"content,omitempty"`
+	SHA       string `json:"sha,omitempty"`
+}
+
+type giteaChangeFilesOptions struct {
+	Branch    string                     `json:"branch"`
+	Message   string                     `json:"message"`
+	Author    giteaIdentity              `json:"author"`
+	Committer giteaIdentity              `json:"committer"`
+	Files     []giteaChangeFileOperation `json:"files"`
+}
+
+// CreateRepository creates the repository under the owner, be it the user of the token or an organization.
+// A repository already there is used as it is.
+func (g *Gitea) CreateRepository(ctx context.Context) error {
+	logger := zerolog.Ctx(ctx).With().Str("method", "CreateRepository").Logger()
+
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", "/user", nil)
+	if err != nil || statusCode != 200 {
+		return fmt.Errorf("failed to get the user of the Gitea token: (%d) %s -- %w", statusCode, body, err)
+	}
+	user := giteaUser{}
+	if jsonErr := json.Unmarshal([]byte(body), &user); jsonErr != nil {
+		return fmt.Errorf("failed to unmarshal Gitea user: %w", jsonErr)
+	}
+
+	createPath := fmt.Sprintf("/orgs/%s/repos", url.PathEscape(g.owner))
+	if user.Login == g.owner {
+		createPath = "/user/repos"
+	}
+	requestBody, marshalErr := json.Marshal(giteaCreateRepoOption{Name: g.repoName, DefaultBranch: g.mainBranch, Private: true})
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal repository creation data: %w", marshalErr)
+	}
+	statusCode, _, body, err = g.sendRequest(ctx, "POST", createPath, requestBody)
+	if err != nil || (statusCode != 201 && statusCode != 409) {
+		return fmt.Errorf("failed to create Gitea repository %s/%s: (%d) %s -- %w", g.owner, g.repoName, statusCode, body, err)
+	}
+	if statusCode == 409 {
+		logger.Info().Str("repository", g.String()).Msg("Gitea repository already exists")
+		return nil
+	}
+	logger.Info().Str("repository", g.String()).Msg("Gitea repository created")
+	return nil
+}
+
+func (g *Gitea) ResetRepository(ctx context.Context) error {
+	deleteRepoErr := g.DeleteRepository(ctx)
+	if deleteRepoErr != nil {
+		return deleteRepoErr
+	}
+	return g.CreateRepository(ctx)
+}
+
+func (g *Gitea) DeleteRepository(ctx context.Context) error {
+	statusCode, _, body, err := g.sendRequest(ctx, "DELETE", g.repoPath(), nil)
+	if err != nil || (statusCode != 204 && statusCode != 404) {
+		return fmt.Errorf("failed to delete Gitea repository: (%d) %s -- %w", statusCode, body, err)
+	}
+	zerolog.Ctx(ctx).Info().Str("repository", g.String()).Msg("Gitea repository deleted")
+	return nil
+}
+
+// GetIconfiles lists the files on the main branch, except the metadata files of icons. Large trees are paginated by Gitea.
+func (g *Gitea) GetIconfiles(ctx context.Context) ([]string, error) {
+	fileList := []string{}
+
+	for page := 1; ; page++ {
+		statusCode, _, body, err := g.sendRequest(
+			ctx,
+			"GET",
+			fmt.Sprintf("%s/git/trees/%s?recursive=true&per_page=1000&page=%d", g.repoPath(), url.PathEscape(g.mainBranch), page),
+			nil,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request to get repository tree from Gitea repo: %w", err)
+		}
+		if statusCode == 404 || statusCode == 409 {
+			// No commit yet on the main branch
+			return fileList, nil
+		}
+		if statusCode != 200 {
+			return nil, fmt.Errorf("failed to get repository tree from Gitea repo: (%d) %s", statusCode, body)
+		}
+
+		tree := giteaTreeResponse{}
+		if jsonErr := json.Unmarshal([]byte(body), &tree); jsonErr != nil {
+			return nil, fmt.Errorf("failed to unmarshal Gitea repository tree response: %w", jsonErr)
+		}
+		for _, entry := range tree.Tree {
+			if entry.Type == "blob" && !IsIconMetadataPath(entry.Path) {
+				fileList = append(fileList, entry.Path)
+			}
+		}
+		if !tree.Truncated || len(tree.Tree) == 0 {
+			return fileList, nil
+		}
+	}
+}
+
+// getFile returns the content and the blob SHA of the file on the main branch, nil content if there is no such file
+func (g *Gitea) getFile(ctx context.Context, filePath string) ([]byte, string, error) {
+	statusCode, _, body, err := g.sendRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf("%s/contents/%s?ref=%s", g.repoPath(), escapeGiteaFilePath(filePath), url.QueryEscape(g.mainBranch)),
+		nil,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to send request to get %s from Gitea repo: %w", filePath, err)
+	}
+	if statusCode == 404 {
+		return nil, "", nil
+	}
+	if statusCode != 200 {
+		return nil, "", fmt.Errorf("failed to get %s from Gitea repo: (%d) %s", filePath, statusCode, body)
+	}
+
+	contents := giteaContentsResponse{}
+	if jsonErr := json.Unmarshal([]byte(body), &contents); jsonErr != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal Gitea contents response for %s: %w", filePath, jsonErr)
+	}
+	if contents.Type != "file" || contents.Encoding != "base64" {
+		return nil, "", fmt.Errorf("unexpected %s (encoding: %s) in Gitea repo at %s", contents.Type, contents.Encoding, filePath)
+	}
+	content, decodeErr := base64.StdEncoding.DecodeString(contents.Content)
+	if decodeErr != nil {
+		return nil, "", fmt.Errorf("failed to decode content of %s: %w", filePath, decodeErr)
+	}
+	return content, contents.SHA, nil
+}
+
+func (g *Gitea) GetIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) ([]byte, error) {
+	content, _, err := g.getFile(ctx, paths.getPathComponents(iconName, iconfileDesc).pathToIconfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get iconfile from Gitea repo %s::%s: %w", iconName, iconfileDesc.String(), err)
+	}
+	if content == nil {
+		return nil, fmt.Errorf("no iconfile in Gitea repo for %s::%s", iconName, iconfileDesc.String())
+	}
+	return content, nil
+}
+
+func (g *Gitea) AddIconfile(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy string) error {
+	filePath := paths.getPathComponents(iconName, iconfile.IconfileDescriptor).pathToIconfile
+	commitErr := g.commit(ctx, modifiedBy, fmt.Sprintf("Adding iconfile: %s", filePath), []commitActionOnByteSlice{
+		{
+			Action:   commitActionCreate,
+			FilePath: filePath,
+			Content:  iconfile.Content,
+		},
+	})
+	if commitErr != nil {
+		return fmt.Errorf("failed to add iconfile to Gitea repo %s::%s: %w", iconName, iconfile.String(), commitErr)
+	}
+	zerolog.Ctx(ctx).Info().Str("iconName", iconName).Str("filePath", filePath).Msg("Iconfile added to Gitea repository")
+	return nil
+}
+
+func (g *Gitea) DeleteIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor, modifiedBy authn.UserID) error {
+	filePath := paths.getPathComponents(iconName, iconfileDesc).pathToIconfile
+	commitErr := g.commit(ctx, modifiedBy.String(), fmt.Sprintf("Deleting iconfile: %s", filePath), []commitActionOnByteSlice{
+		{
+			Action:   commitActionDelete,
+			FilePath: filePath,
+		},
+	})
+	if commitErr != nil {
+		return fmt.Errorf("failed to delete iconfile from Gitea repo %s::%s: %w", iconName, iconfileDesc.String(), commitErr)
+	}
+	zerolog.Ctx(ctx).Info().Str("iconName", iconName).Str("filePath", filePath).Msg("Iconfile deleted from Gitea repository")
+	return nil
+}
+
+func (g *Gitea) DeleteIcon(ctx context.Context, iconDesc domain.IconDescriptor, modifiedBy authn.UserID) error {
+	actions, actionsErr := blobstoreChangeActions(ctx, domain.BlobstoreChange{Kind: domain.BlobstoreChangeDeleteIcon, IconName: iconDesc.Name, Icon: iconDesc}, g.getIconMetadataFile)
+	if actionsErr != nil {
+		return fmt.Errorf("failed to prepare deletion of icon %s from Gitea repo: %w", iconDesc.Name, actionsErr)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	commitErr := g.commit(ctx, modifiedBy.String(), fmt.Sprintf("Deleting icon: %s", iconDesc.Name), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to delete icon from Gitea repo %s: %w", iconDesc.Name, commitErr)
+	}
+	zerolog.Ctx(ctx).Info().Str("iconName", iconDesc.Name).Msg("Icon deleted from Gitea repository")
+	return nil
+}
+
+// getIconMetadataFile returns the content of the metadata file of the icon on the main branch, or nil if there is none
+func (g *Gitea) getIconMetadataFile(ctx context.Context, iconName string) ([]byte, error) {
+	content, _, err := g.getFile(ctx, paths.getPathToIconMetadataInRepo(iconName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata of icon %s: %w", iconName, err)
+	}
+	return content, nil
+}
+
+func (g *Gitea) UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error {
+	actions, actionsErr := blobstoreChangeActions(ctx, domain.BlobstoreChange{Kind: domain.BlobstoreChangeUpdateMetadata, IconName: iconName, Metadata: metadata}, g.getIconMetadataFile)
+	if actionsErr != nil {
+		return fmt.Errorf("failed to prepare update of metadata of icon %s in Gitea repo: %w", iconName, actionsErr)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	commitErr := g.commit(ctx, modifiedBy, fmt.Sprintf("Updating icon metadata: %s", actions[0].FilePath), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to update metadata of icon %s in Gitea repo: %w", iconName, commitErr)
+	}
+	zerolog.Ctx(ctx).Info().Str("iconName", iconName).Msg("Icon metadata updated in Gitea repository")
+	return nil
+}
+
+func (g *Gitea) GetIconMetadata(ctx context.Context, iconName string) (domain.IconMetadata, error) {
+	content, getErr := g.getIconMetadataFile(ctx, iconName)
+	if getErr != nil {
+		return domain.IconMetadata{}, getErr
+	}
+	if content == nil {
+		return domain.IconMetadata{}, fmt.Errorf("no metadata for icon %s: %w", iconName, domain.ErrIconMetadataNotFound)
+	}
+	metadata, unmarshalErr := UnmarshalIconMetadata(content)
+	if unmarshalErr != nil {
+		return domain.IconMetadata{}, fmt.Errorf("failed to unmarshal metadata of icon %s: %w", iconName, unmarshalErr)
+	}
+	return metadata, nil
+}
+
+// ApplyChangeset makes all the changes of a changeset in a single commit on the main branch
+func (g *Gitea) ApplyChangeset(ctx context.Context, changes []domain.BlobstoreChange, modifiedBy string) error {
+	actions := []commitActionOnByteSlice{}
+	for _, change := range changes {
+		changeActions, actionsErr := blobstoreChangeActions(ctx, change, g.getIconMetadataFile)
+		if actionsErr != nil {
+			return fmt.Errorf("failed to prepare commit of changeset to Gitea repo: %w", actionsErr)
+		}
+		actions = append(actions, changeActions...)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	commitErr := g.commit(ctx, modifiedBy, fmt.Sprintf("Applying changeset of %d change(s)", len(changes)), actions)
+	if commitErr != nil {
+		return fmt.Errorf("failed to commit changeset to Gitea repo: %w", commitErr)
+	}
+	zerolog.Ctx(ctx).Info().Int("changeCount", len(changes)).Msg("Changeset committed to Gitea repository")
+	return nil
+}
+
+// commit commits the actions to the main branch. Gitea wants the current blob SHA of the files updated or deleted.
+func (g *Gitea) commit(ctx context.Context, authorName string, commitMessage string, actions []commitActionOnByteSlice) error {
+	if os.Getenv(SimulateGitCommitFailureEnvvarName) == "true" {
+		return fmt.Errorf("simulate git commit failure")
+	}
+
+	files := make([]giteaChangeFileOperation, len(actions))
+	for i, action := range actions {
+		files[i] = giteaChangeFileOperation{Operation: string(action.Action), Path: action.FilePath}
+		if action.Action == commitActionUpdate || action.Action == commitActionDelete {
+			_, sha, getErr := g.getFile(ctx, action.FilePath)
+			if getErr != nil {
+				return getErr
+			}
+			if len(sha) == 0 {
+				return fmt.Errorf("no %s to %s in Gitea repo", action.FilePath, action.Action)
+			}
+			files[i].SHA = sha
+		}
+		if action.Action != commitActionDelete {
+			files[i].Content = base64.StdEncoding.EncodeToString(action.Content)
+		}
+	}
+
+	requestBody, marshalErr := json.Marshal(giteaChangeFilesOptions{
+		Branch:    g.mainBranch,
+		Message:   commitMessage,
+		Author:    giteaIdentity{Name: authorName + "@IconRepoServer", Email: authorName},
+		Committer: giteaIdentity{Name: committerName, Email: committerEmail},
+		Files:     files,
+	})
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal commit data: %w", marshalErr)
+	}
+
+	statusCode, _, body, err := g.sendRequest(ctx, "POST", g.repoPath()+"/contents", requestBody)
+	if err != nil || statusCode != 201 {
+		return fmt.Errorf("failed to commit to Gitea repo: (%d) %s -- %w", statusCode, body, err)
+	}
+	return nil
+}
+
+// lastCommit returns the most recent commit on the main branch, only among those changing filePath if it isn't empty.
+// The result is nil if there is no such commit.
+func (g *Gitea) lastCommit(ctx context.Context, filePath string) (*giteaCommit, error) {
+	query := url.Values{}
+	query.Set("sha", g.mainBranch)
+	query.Set("limit", "1")
+	query.Set("stat", "false")
+	if len(filePath) > 0 {
+		query.Set("path", filePath)
+	}
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("%s/commits?%s", g.repoPath(), query.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to get commit list from Gitea repo: %w", err)
+	}
+	if statusCode == 404 || statusCode == 409 {
+		// No such branch, or no commit at all yet
+		return nil, nil
+	}
+	if statusCode != 200 {
+		return nil, fmt.Errorf("failed to get commit list from Gitea repo: (%d) %s", statusCode, body)
+	}
+
+	commits := []giteaCommit{}
+	if jsonErr := json.Unmarshal([]byte(body), &commits); jsonErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal Gitea commit list response: %w", jsonErr)
+	}
+	if len(commits) == 0 {
+		return nil, nil
+	}
+	return &commits[0], nil
+}
+
+// GetStateID implements repositories_tests.gitTestRepo
+func (g *Gitea) GetStateID(ctx context.Context) (string, error) {
+	commit, err := g.lastCommit(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	if commit == nil {
+		return "", fmt.Errorf("no commit yet in %s", g.String())
+	}
+	return commit.SHA, nil
+}
+
+// CheckStatus always returns true, since the Gitea server handles consistency (and returns error if it cannot)
+func (g *Gitea) CheckStatus() (bool, error) {
+	return true, nil
+}
+
+// GetVersionFor returns the ID of the last commit changing the iconfile, empty string in case the file doesn't exist in the repository
+func (g *Gitea) GetVersionFor(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) (string, error) {
+	filePath := paths.getPathComponents(iconName, iconfileDesc).pathToIconfile
+	content, _, getErr := g.getFile(ctx, filePath)
+	if getErr != nil {
+		return "", fmt.Errorf("failed to get iconfile commit ID from Gitea repo %s::%s: %w", iconName, iconfileDesc.String(), getErr)
+	}
+	if content == nil {
+		return "", nil
+	}
+	commit, err := g.lastCommit(ctx, filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get iconfile commit ID from Gitea repo %s::%s: %w", iconName, iconfileDesc.String(), err)
+	}
+	if commit == nil {
+		return "", nil
+	}
+	return commit.SHA, nil
+}
+
+func (g *Gitea) GetVersionMetadata(ctx context.Context, commitId string) (CommitMetadata, error) {
+	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("%s/git/commits/%s", g.repoPath(), url.PathEscape(commitId)), nil)
+	if err != nil {
+		return CommitMetadata{}, fmt.Errorf("failed to send request to get commit meta-data for %s from Gitea repo: %w", commitId, err)
+	}
+	if statusCode != 200 {
+		return CommitMetadata{}, fmt.Errorf("failed to get commit meta-data for %s from Gitea repo: (%d) %s", commitId, statusCode, body)
+	}
+
+	commit := giteaCommit{}
+	if jsonErr := json.Unmarshal([]byte(body), &commit); jsonErr != nil {
+		return CommitMetadata{}, fmt.Errorf("failed to unmarshal Gitea commit meta-data response for %s: %w", commitId, jsonErr)
+	}
+	authorDate, authorDateErr := time.Parse(time.RFC3339, commit.Commit.Author.Date)
+	if authorDateErr != nil {
+		return CommitMetadata{}, fmt.Errorf("failed to parse time `%s` as RFC3339: %w", commit.Commit.Author.Date, authorDateErr)
+	}
+	commitDate, commitDateErr := time.Parse(time.RFC3339, commit.Commit.Committer.Date)
+	if commitDateErr != nil {
+		return CommitMetadata{}, fmt.Errorf("failed to parse time `%s` as RFC3339: %w", commit.Commit.Committer.Date, commitDateErr)
+	}
+	return CommitMetadata{
+		Author:     fmt.Sprintf("%s <%s>", commit.Commit.Author.Name, commit.Commit.Author.Email),
+		AuthorDate: authorDate,
+		Commit:     fmt.Sprintf("%s <%s>", commit.Commit.Committer.Name, commit.Commit.Committer.Email),
+		CommitDate: commitDate,
+		Message:    strings.TrimSpace(commit.Commit.Message),
+	}, nil
+}
+
+func (g *Gitea) sendRequest(ctx context.Context, method string, apiCallPath string, body []byte) (int, http.Header, string, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	request, requestCreationErr := http.NewRequestWithContext(ctx, method, g.apiURL+apiCallPath, bodyReader)
+	if requestCreationErr != nil {
+		return 0, nil, "", fmt.Errorf("failed to create request: %w", requestCreationErr)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("Authorization", "token "+g.apikey)
+
+	zerolog.Ctx(ctx).Debug().Str("request-method", method).Str("apiCallPath", apiCallPath).Msg("send request to Gitea")
+	resp, requestExecutionErr := g.client.Do(request)
+	if requestExecutionErr != nil {
+		return 0, nil, "", fmt.Errorf("failed to execute request: %w", requestExecutionErr)
+	}
+	defer resp.Body.Close()
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return resp.StatusCode, nil, "", fmt.Errorf("failed to read body: %w", readErr)
+	}
+	return resp.StatusCode, resp.Header, string(respBody), nil
+}
+
+// escapeGiteaFilePath escapes the segments of the path, keeping the slashes between them
+func escapeGiteaFilePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -23,6 +23,8 @@ import (
 
 var paths = NewGitFilePaths("")
 
+const DefaultGitlabAPIURL = "https://gitlab.com/api/v4"
+
 const gitlabRepoHasAlreadyBeenTaken = "has already been taken"
 
 var transientGitlabRepoCreationErrMessages = []string{
@@ -64,10 +66,15 @@ func (g gitlabProject) String() string {
 }
 
 type Gitlab struct {
+	apiURL     string
 	project    gitlabProject
 	mainBranch string
 	apikey     string
 	clientPool *blockingQueues.BlockingQueue
+	options    GitlabClientOptions
+	breaker    *circuitBreaker
+	// With mergeRequests set, iconfiles are added on a feature branch of their own through a merge request
+	mergeRequests bool
 }
 
 func (repo *Gitlab) String() string {
@@ -92,6 +99,7 @@ type commitActionOnByteSlice struct {
 
 type commitProperties struct {
 	Branch        string         `json:"branch"`
+	StartBranch   string         `json:"start_branch,omitempty"`
 	AuthorName    string         `json:"author_name"`
 	CommitMessage string         `json:"commit_message"`
 	Actions       []commitAction `json:"actions"`
@@ -130,26 +138,33 @@ type projectProperties struct {
 	InitializeWithReadme string `json:"initialize_with_readme"`
 }
 
-func NewGitlabRepositoryClient(ctx context.Context, namespacePath string, projectPath string, branch string, apikey string) (*Gitlab, error) {
+func NewGitlabRepositoryClient(ctx context.Context, apiURL string, namespacePath string, projectPath string, branch string, apikey string, mergeRequests bool, options GitlabClientOptions) (*Gitlab, error) {
 	if len(apikey) == 0 {
 		return &Gitlab{}, fmt.Errorf("no API token for GitLab repository")
 	}
 
+	if len(apiURL) == 0 {
+		apiURL = DefaultGitlabAPIURL
+	}
+
 	gitlab := Gitlab{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
 		project: gitlabProject{
 			namespacePath: namespacePath,
 			path:          projectPath,
 		},
-		mainBranch: branch,
-		apikey:     apikey,
+		mainBranch:    branch,
+		apikey:        apikey,
+		mergeRequests: mergeRequests,
+		options:       options,
+		breaker:       newCircuitBreaker(options.BreakerThreshold, options.BreakerCooldown),
 	}
 
 	var poolSize uint64 = 20
 	gitlab.clientPool, _ = blockingQueues.NewLinkedBlockingQueue(poolSize)
 	for i := 0; i < int(poolSize); i++ {
-		client := http.Client{
-			Timeout: 5 * time.Second,
-		}
+		// Each attempt is limited by the request timeout in the client options
+		client := http.Client{}
 		_, _ = gitlab.clientPool.Put(client)
 	}
 
@@ -236,33 +251,49 @@ func (g *Gitlab) DeleteRepository(ctx context.Context) error {
 	return nil
 }
 
+// GetIconfiles lists the files on the main branch, except the metadata files of icons. The tree is paginated by GitLab,
+// so it is read page by page.
 func (g *Gitlab) GetIconfiles(ctx context.Context) ([]string, error) {
-	statusCode, _, body, err := g.sendRequest(ctx, "GET", fmt.Sprintf("/projects/%s/repository/tree?ref=%s&recursive=true", url.PathEscape(g.project.String()), g.mainBranch), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request to get repository tree from GitLab repo: %w", err)
-	}
-	if statusCode != 200 {
-		return nil, fmt.Errorf("failed to get repository tree from GitLab repo (%d) %s -- %w", statusCode, body, err)
-	}
+	fileList := []string{}
 
-	tree := []repositoryTreeItem{}
-	jsonErr := json.Unmarshal([]byte(body), &tree)
-	if jsonErr != nil {
-		return nil, fmt.Errorf("failed to unmarshal GitLab repository tree response: %w", jsonErr)
-	}
+	page := "1"
+	for len(page) > 0 {
+		statusCode, header, body, err := g.sendRequest(
+			ctx,
+			"GET",
+			fmt.Sprintf("/projects/%s/repository/tree?ref=%s&recursive=true&per_page=100&page=%s", url.PathEscape(g.project.String()), g.mainBranch, page),
+			nil,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request to get repository tree from GitLab repo: %w", err)
+		}
+		if statusCode == 404 {
+			// No commit yet on the main branch
+			return fileList, nil
+		}
+		if statusCode != 200 {
+			return nil, fmt.Errorf("failed to get repository tree from GitLab repo (%d) %s -- %w", statusCode, body, err)
+		}
 
-	fileList := []string{}
+		tree := []repositoryTreeItem{}
+		jsonErr := json.Unmarshal([]byte(body), &tree)
+		if jsonErr != nil {
+			return nil, fmt.Errorf("failed to unmarshal GitLab repository tree response: %w", jsonErr)
+		}
 
-	for _, treeItem := range tree {
-		if treeItem.Type == "blob" {
-			fileList = append(fileList, treeItem.Path)
+		for _, treeItem := range tree {
+			if treeItem.Type == "blob" && !IsIconMetadataPath(treeItem.Path) {
+				fileList = append(fileList, treeItem.Path)
+			}
 		}
+
+		page = header.Get("X-Next-Page")
 	}
 
 	return fileList, nil
 }
 
-func (g *Gitlab) createCommitBody(authorName string, commitMessage string, actionsIn []commitActionOnByteSlice) (io.Reader, error) {
+func (g *Gitlab) createCommitBody(branch string, startBranch string, authorName string, commitMessage string, actionsIn []commitActionOnByteSlice) (io.Reader, error) {
 	commActs := make([]commitAction, len(actionsIn))
 
 	for index, actionIn := range actionsIn {
@@ -278,7 +309,8 @@ func (g *Gitlab) createCommitBody(authorName string, commitMessage string, actio
 	}
 
 	commitProps := commitProperties{
-		Branch:        g.mainBranch,
+		Branch:        branch,
+		StartBranch:   startBranch,
 		AuthorName:    authorName,
 		CommitMessage: commitMessage,
 		Actions:       commActs,
@@ -391,14 +423,26 @@ func (g *Gitlab) AddIconfile(ctx context.Context, iconName string, iconfile doma
 
 	filePath := paths.getPathComponents(iconName, iconfile.IconfileDescriptor).pathToIconfile
 
-	logger.Debug().Str("filePath", filePath).Msg("about to commit...")
-	commitErr := g.commit(ctx, modifiedBy, fmt.Sprintf("Adding iconfile: %s", filePath), []commitActionOnByteSlice{
+	commitMessage := fmt.Sprintf("Adding iconfile: %s", filePath)
+	actions := []commitActionOnByteSlice{
 		{
 			Action:   commitActionCreate,
 			FilePath: filePath,
 			Content:  iconfile.Content,
 		},
-	})
+	}
+
+	if g.mergeRequests {
+		proposeErr := g.proposeChange(ctx, ChangeBranch(iconName, iconfile.IconfileDescriptor), modifiedBy, commitMessage, actions)
+		if proposeErr != nil {
+			return fmt.Errorf("failed to propose iconfile to GitLab repo %s::%s: %w", iconName, iconfile.String(), proposeErr)
+		}
+		logger.Info().Msg("Merge request opened for iconfile in GitLab repository")
+		return nil
+	}
+
+	logger.Debug().Str("filePath", filePath).Msg("about to commit...")
+	commitErr := g.commit(ctx, g.mainBranch, "", modifiedBy, commitMessage, actions)
 	if commitErr != nil {
 		return fmt.Errorf("failed to add iconfile to GitLab repo %s::%s: %w", iconName, iconfile.String(), commitErr)
 	}
@@ -408,15 +452,36 @@ func (g *Gitlab) AddIconfile(ctx context.Context, iconName string, iconfile doma
 
 func (g *Gitlab) DeleteIcon(ctx context.Context, iconDesc domain.IconDescriptor, modifiedBy authn.UserID) error {
 	logger := zerolog.Ctx(ctx).With().Str("iconName", iconDesc.Name).Str("method", "DeleteIcon").Logger()
-	actionList := make([]commitActionOnByteSlice, len(iconDesc.Iconfiles))
+	actionList := []commitActionOnByteSlice{}
 
-	for index, ifDesc := range iconDesc.Iconfiles {
-		actionList[index] = commitActionOnByteSlice{
+	for _, ifDesc := range iconDesc.Iconfiles {
+		if g.isProposed(ifDesc) {
+			discardErr := g.discardChange(ctx, ChangeBranch(iconDesc.Name, ifDesc))
+			if discardErr != nil {
+				return fmt.Errorf("failed to discard proposed iconfile %s::%s: %w", iconDesc.Name, ifDesc.String(), discardErr)
+			}
+			continue
+		}
+		actionList = append(actionList, commitActionOnByteSlice{
 			Action:   commitActionDelete,
 			FilePath: paths.getPathComponents(iconDesc.Name, ifDesc).pathToIconfile,
-		}
+		})
+	}
+	metadata, getMetadataErr := g.getIconMetadataFile(ctx, iconDesc.Name)
+	if getMetadataErr != nil {
+		return fmt.Errorf("failed to check for metadata of icon %s: %w", iconDesc.Name, getMetadataErr)
+	}
+	if metadata != nil {
+		actionList = append(actionList, commitActionOnByteSlice{
+			Action:   commitActionDelete,
+			FilePath: paths.getPathToIconMetadataInRepo(iconDesc.Name),
+		})
+	}
+	if len(actionList) == 0 {
+		logger.Info().Msg("No iconfile of the icon on the main branch of the GitLab repository")
+		return nil
 	}
-	commitErr := g.commit(ctx, modifiedBy.String(), fmt.Sprintf("Deleting icon: %s", iconDesc.Name), actionList)
+	commitErr := g.commit(ctx, g.mainBranch, "", modifiedBy.String(), fmt.Sprintf("Deleting icon: %s", iconDesc.Name), actionList)
 	if commitErr != nil {
 		return fmt.Errorf("failed to delete iconfile from GitLab repo %s: %w", iconDesc.Name, commitErr)
 	}
@@ -427,9 +492,18 @@ func (g *Gitlab) DeleteIcon(ctx context.Context, iconDesc domain.IconDescriptor,
 func (g *Gitlab) DeleteIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor, modifiedBy authn.UserID) error {
 	logger := zerolog.Ctx(ctx).With().Str("iconName", iconName).Str("icon-file", iconfileDesc.String()).Str("method", "DeleteIconfile").Logger()
 
+	if g.isProposed(iconfileDesc) {
+		discardErr := g.discardChange(ctx, ChangeBranch(iconName, iconfileDesc))
+		if discardErr != nil {
+			return fmt.Errorf("failed to discard proposed iconfile %s::%s: %w", iconName, iconfileDesc.String(), discardErr)
+		}
+		logger.Info().Msg("Proposed iconfile discarded from GitLab repository")
+		return nil
+	}
+
 	filePath := paths.getPathComponents(iconName, iconfileDesc).pathToIconfile
 
-	commitErr := g.commit(ctx, modifiedBy.String(), fmt.Sprintf("Deleting iconfile: %s", filePath), []commitActionOnByteSlice{
+	commitErr := g.commit(ctx, g.mainBranch, "", modifiedBy.String(), fmt.Sprintf("Deleting iconfile: %s", filePath), []commitActionOnByteSlice{
 		{
 			Action:   commitActionDelete,
 			FilePath: filePath,
@@ -444,21 +518,17 @@ func (g *Gitlab) DeleteIconfile(ctx context.Context, iconName string, iconfileDe
 }
 
 func (g *Gitlab) GetIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) ([]byte, error) {
-	filePath := paths.getPathComponents(iconName, iconfileDesc).pathToIconfile
-	statusCode, _, body, err := g.sendRequest(
-		ctx,
-		"GET",
-		fmt.Sprintf(
-			"/projects/%s/repository/files/%s?%s",
-			url.PathEscape(g.project.String()),
-			url.PathEscape(filePath),
-			fmt.Sprintf("ref=%s", g.mainBranch),
-		),
-		nil,
-	)
+	statusCode, body, err := g.getFile(ctx, iconName, iconfileDesc, g.mainBranch)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request to get iconfigle from GitLab repo %s::%s: %w", iconName, iconfileDesc, err)
 	}
+	if statusCode == 404 && g.mergeRequests {
+		// The iconfile may still be waiting for its merge request to be merged
+		statusCode, body, err = g.getFile(ctx, iconName, iconfileDesc, ChangeBranch(iconName, iconfileDesc))
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request to get proposed iconfile from GitLab repo %s::%s: %w", iconName, iconfileDesc, err)
+		}
+	}
 	if statusCode != 200 {
 		return nil, fmt.Errorf("failed to get iconfile from GitLab repo %s::%s: (%d) %s -- %w", iconName, iconfileDesc.String(), statusCode, body, err)
 	}
@@ -481,12 +551,29 @@ func (g *Gitlab) GetIconfile(ctx context.Context, iconName string, iconfileDesc
 	return content, nil
 }
 
-func (g *Gitlab) commit(ctx context.Context, authorName string, commitMessage string, actions []commitActionOnByteSlice) error {
+func (g *Gitlab) getFile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor, ref string) (int, string, error) {
+	filePath := paths.getPathComponents(iconName, iconfileDesc).pathToIconfile
+	statusCode, _, body, err := g.sendRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf(
+			"/projects/%s/repository/files/%s?%s",
+			url.PathEscape(g.project.String()),
+			url.PathEscape(filePath),
+			fmt.Sprintf("ref=%s", url.QueryEscape(ref)),
+		),
+		nil,
+	)
+	return statusCode, body, err
+}
+
+// commit commits the actions to branch. A non-empty startBranch has the branch created off startBranch.
+func (g *Gitlab) commit(ctx context.Context, branch string, startBranch string, authorName string, commitMessage string, actions []commitActionOnByteSlice) error {
 	if os.Getenv(SimulateGitCommitFailureEnvvarName) == "true" {
 		return fmt.Errorf("simulate git commit failure")
 	}
 
-	commitBody, createCommitBodyErr := g.createCommitBody(authorName, commitMessage, actions)
+	commitBody, createCommitBodyErr := g.createCommitBody(branch, startBranch, authorName, commitMessage, actions)
 	if createCommitBodyErr != nil {
 		return fmt.Errorf("failed to create commit request body: %w", createCommitBodyErr)
 	}
@@ -494,7 +581,7 @@ func (g *Gitlab) commit(ctx context.Context, authorName string, commitMessage st
 	statusCode, _, body, err := g.sendRequest(
 		ctx,
 		"POST",
-		fmt.Sprintf("/projects/%s/repository/commits?%s", url.PathEscape(g.project.String()), url.PathEscape(fmt.Sprintf("ref=%s", g.mainBranch))),
+		fmt.Sprintf("/projects/%s/repository/commits", url.PathEscape(g.project.String())),
 		commitBody,
 	)
 	if err != nil || statusCode != 201 {
@@ -503,7 +590,58 @@ func (g *Gitlab) commit(ctx context.Context, authorName string, commitMessage st
 	return nil
 }
 
+// sendRequest sends the request to the GitLab API, retrying it on transient errors as the client options tell
 func (g *Gitlab) sendRequest(ctx context.Context, method string, apiCallPath string, body io.Reader) (int, http.Header, string, error) {
+	logger := zerolog.Ctx(ctx).With().Str("method", "sendRequest").Str("request-method", method).Str("apiCallPath", apiCallPath).Logger()
+
+	// The body is sent anew with each attempt
+	var bodyBytes []byte
+	if body != nil {
+		var readErr error
+		bodyBytes, readErr = io.ReadAll(body)
+		if readErr != nil {
+			return 0, nil, "", fmt.Errorf("failed to read request body: %w", readErr)
+		}
+	}
+
+	for retry := 0; ; retry++ {
+		if !g.breaker.allow(time.Now()) {
+			return 0, nil, "", fmt.Errorf("%w: too many consecutive failures, not sending %s %s", ErrGitlabUnavailable, method, apiCallPath)
+		}
+
+		statusCode, header, respBody, err := g.sendRequestOnce(ctx, logger, method, apiCallPath, bodyBytes)
+		if ctx.Err() != nil {
+			// Given up on by the caller, which tells nothing about GitLab
+			g.breaker.release()
+			return statusCode, header, respBody, err
+		}
+		transient := err != nil || isTransientGitlabStatus(statusCode)
+		if !transient {
+			g.breaker.recordSuccess()
+			return statusCode, header, respBody, err
+		}
+		// Rate limiting tells nothing about GitLab being down
+		if statusCode == http.StatusTooManyRequests {
+			g.breaker.release()
+		} else {
+			g.breaker.recordFailure(time.Now())
+		}
+
+		if retry >= g.options.MaxRetries || !isRetriableGitlabRequest(method, err, statusCode) {
+			return statusCode, header, respBody, err
+		}
+		delay, delayErr := g.options.retryDelay(retry+1, header, time.Now())
+		if delayErr != nil {
+			return statusCode, header, respBody, fmt.Errorf("giving up on %s %s (%d): %w", method, apiCallPath, statusCode, delayErr)
+		}
+		logger.Warn().Err(err).Int("statusCode", statusCode).Int("retry", retry+1).Dur("delay", delay).Msg("transient GitLab error, retrying")
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			return statusCode, header, respBody, fmt.Errorf("gave up waiting to retry %s %s: %w", method, apiCallPath, sleepErr)
+		}
+	}
+}
+
+func (g *Gitlab) sendRequestOnce(ctx context.Context, logger zerolog.Logger, method string, apiCallPath string, body []byte) (int, http.Header, string, error) {
 	poolItem, _ := g.clientPool.Get()
 	defer func() {
 		_, _ = g.clientPool.Put(poolItem)
@@ -514,14 +652,26 @@ func (g *Gitlab) sendRequest(ctx context.Context, method string, apiCallPath str
 		return 0, nil, "", errors.New("type asssertion error")
 	}
 
-	logger := zerolog.Ctx(ctx).With().Str("method", "sendRequest").Str("request-method", method).Str("apiCallPath", apiCallPath).Logger()
-	urlString := fmt.Sprintf("https://gitlab.com/api/v4%s", apiCallPath)
+	urlString := fmt.Sprintf("%s%s", g.apiURL, apiCallPath)
+
+	attemptCtx := ctx
+	if g.options.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, g.options.RequestTimeout)
+		defer cancel()
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
 
 	logger.Debug().Msg("send request")
-	request, requestCreationError := http.NewRequest(
+	request, requestCreationError := http.NewRequestWithContext(
+		attemptCtx,
 		method,
 		urlString,
-		body,
+		bodyReader,
 	)
 
 	if requestCreationError != nil {
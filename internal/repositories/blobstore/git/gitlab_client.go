@@ -0,0 +1,174 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GitlabClientOptions tells how the GitLab client copes with a slow or failing GitLab
+type GitlabClientOptions struct {
+	// RequestTimeout limits each attempt at a request
+	RequestTimeout time.Duration
+	// MaxRetries is the number of times a request failing with a transient error (a network error, a timeout,
+	// 429 or 5xx) is retried
+	MaxRetries int
+	// RetryDelay is the delay before the first retry. It is doubled with each retry up to MaxRetryDelay, with jitter.
+	// A longer delay asked for by GitLab (Retry-After or RateLimit-Reset) is waited for, unless it is over MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// After BreakerThreshold consecutive transient errors, requests fail right away with ErrGitlabUnavailable
+	// for BreakerCooldown; then a single request is let through to find out whether GitLab is back.
+	// Zero BreakerThreshold disables the circuit breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+var DefaultGitlabClientOptions = GitlabClientOptions{
+	RequestTimeout:   10 * time.Second,
+	MaxRetries:       3,
+	RetryDelay:       500 * time.Millisecond,
+	MaxRetryDelay:    30 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
+// ErrGitlabUnavailable is returned without calling GitLab while the circuit breaker is open
+var ErrGitlabUnavailable = errors.New("GitLab unavailable")
+
+func isTransientGitlabStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// isRetriableGitlabRequest tells whether a request which failed transiently may be sent again.
+// A POST (a commit, a merge request) may have been carried out though its response was lost,
+// so it is sent again only if GitLab tells it turned the request away.
+func isRetriableGitlabRequest(method string, err error, statusCode int) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return err == nil && (statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable)
+}
+
+// retryDelay returns the delay before the retry given (counted from 1): the exponential backoff with jitter,
+// or what the response asks for if longer
+func (options GitlabClientOptions) retryDelay(retry int, header http.Header, now time.Time) (time.Duration, error) {
+	delay := options.RetryDelay
+	for i := 1; i < retry && delay < options.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if options.MaxRetryDelay > 0 && delay > options.MaxRetryDelay {
+		delay = options.MaxRetryDelay
+	}
+	// "Equal jitter": somewhere between half and the whole of the delay
+	if delay > 1 {
+		delay = delay/2 + rand.N(delay/2)
+	}
+
+	if requested, ok := requestedDelay(header, now); ok && requested > delay {
+		if options.MaxRetryDelay > 0 && requested > options.MaxRetryDelay {
+			return 0, fmt.Errorf("GitLab asks to wait %v before retrying, more than the %v allowed", requested, options.MaxRetryDelay)
+		}
+		delay = requested
+	}
+	return delay, nil
+}
+
+// requestedDelay parses the Retry-After header (seconds or HTTP date) or, failing that,
+// the RateLimit-Reset header (Unix time) GitLab sends along with 429 responses
+func requestedDelay(header http.Header, now time.Time) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+	if retryAfter := header.Get("Retry-After"); len(retryAfter) > 0 {
+		if seconds, parseErr := strconv.Atoi(retryAfter); parseErr == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, parseErr := http.ParseTime(retryAfter); parseErr == nil {
+			return date.Sub(now), true
+		}
+	}
+	if reset := header.Get("RateLimit-Reset"); len(reset) > 0 {
+		if unixTime, parseErr := strconv.ParseInt(reset, 10, 64); parseErr == nil {
+			return time.Unix(unixTime, 0).Sub(now), true
+		}
+	}
+	return 0, false
+}
+
+// sleepContext waits for the delay or until the context is done
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// circuitBreaker keeps requests from piling up on a GitLab which is down
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mux                 sync.Mutex
+	consecutiveFailures int
+	openUntil           time.Time
+	probing             bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow tells whether a request may be sent. Once the cooldown is over, a single request is let through as a probe.
+func (breaker *circuitBreaker) allow(now time.Time) bool {
+	if breaker.threshold <= 0 {
+		return true
+	}
+	breaker.mux.Lock()
+	defer breaker.mux.Unlock()
+	if breaker.consecutiveFailures < breaker.threshold {
+		return true
+	}
+	if now.Before(breaker.openUntil) || breaker.probing {
+		return false
+	}
+	breaker.probing = true
+	return true
+}
+
+func (breaker *circuitBreaker) recordSuccess() {
+	breaker.mux.Lock()
+	defer breaker.mux.Unlock()
+	breaker.consecutiveFailures = 0
+	breaker.probing = false
+}
+
+// release lets another probe through when the request let through tells nothing about GitLab being up or down
+func (breaker *circuitBreaker) release() {
+	breaker.mux.Lock()
+	defer breaker.mux.Unlock()
+	breaker.probing = false
+}
+
+func (breaker *circuitBreaker) recordFailure(now time.Time) {
+	breaker.mux.Lock()
+	defer breaker.mux.Unlock()
+	breaker.consecutiveFailures++
+	breaker.probing = false
+	if breaker.threshold > 0 && breaker.consecutiveFailures >= breaker.threshold {
+		breaker.openUntil = now.Add(breaker.cooldown)
+	}
+}
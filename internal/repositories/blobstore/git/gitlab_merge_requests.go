@@ -0,0 +1,156 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// changeBranchPrefix marks the feature branches created for merge requests: iconrepo/<icon-name>/<format>/<size>
+const changeBranchPrefix = "iconrepo/"
+
+// ChangeBranch returns the name of the feature branch proposing the iconfile in a merge request
+func ChangeBranch(iconName string, iconfileDesc domain.IconfileDescriptor) string {
+	return fmt.Sprintf("%s%s/%s/%s", changeBranchPrefix, iconName, iconfileDesc.Format, iconfileDesc.Size)
+}
+
+// parseChangeBranch is the inverse of ChangeBranch
+func parseChangeBranch(branch string) (string, domain.IconfileDescriptor, error) {
+	if !strings.HasPrefix(branch, changeBranchPrefix) {
+		return "", domain.IconfileDescriptor{}, fmt.Errorf("%s: %w", branch, domain.ErrUnknownChangeBranch)
+	}
+	segments := strings.Split(strings.TrimPrefix(branch, changeBranchPrefix), "/")
+	if len(segments) != 3 || len(segments[0]) == 0 || len(segments[1]) == 0 || len(segments[2]) == 0 {
+		return "", domain.IconfileDescriptor{}, fmt.Errorf("%s: %w", branch, domain.ErrUnknownChangeBranch)
+	}
+	return segments[0], domain.IconfileDescriptor{Format: segments[1], Size: segments[2]}, nil
+}
+
+// ReviewsInMergeRequests tells whether iconfiles are added through merge requests
+func (g *Gitlab) ReviewsInMergeRequests() bool {
+	return g.mergeRequests
+}
+
+// ParseChangeBranch tells which iconfile the feature branch of a merge request proposes
+func (g *Gitlab) ParseChangeBranch(branch string) (string, domain.IconfileDescriptor, error) {
+	return parseChangeBranch(branch)
+}
+
+// isProposed tells whether the iconfile lives on its change branch (as opposed to the main branch)
+func (g *Gitlab) isProposed(iconfileDesc domain.IconfileDescriptor) bool {
+	return g.mergeRequests && iconfileDesc.ReviewStatus == domain.ReviewStatusInReview
+}
+
+type mergeRequestProperties struct {
+	SourceBranch       string `json:"source_branch"`
+	TargetBranch       string `json:"target_branch"`
+	Title              string `json:"title"`
+	RemoveSourceBranch bool   `json:"remove_source_branch"`
+}
+
+type mergeRequestItem struct {
+	IID          int    `json:"iid"`
+	State        string `json:"state"`
+	SourceBranch string `json:"source_branch"`
+	WebURL       string `json:"web_url"`
+}
+
+// proposeChange commits the actions to a new branch and opens a merge request for it against the main branch
+func (g *Gitlab) proposeChange(ctx context.Context, branch string, authorName string, title string, actions []commitActionOnByteSlice) error {
+	logger := zerolog.Ctx(ctx).With().Str("method", "proposeChange").Str("branch", branch).Logger()
+
+	commitErr := g.commit(ctx, branch, g.mainBranch, authorName, title, actions)
+	if commitErr != nil {
+		return commitErr
+	}
+
+	jsonInBytes, marshalErr := json.Marshal(mergeRequestProperties{
+		SourceBranch:       branch,
+		TargetBranch:       g.mainBranch,
+		Title:              title,
+		RemoveSourceBranch: true,
+	})
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal merge request data for %s: %w", branch, marshalErr)
+	}
+
+	statusCode, _, body, err := g.sendRequest(
+		ctx,
+		"POST",
+		fmt.Sprintf("/projects/%s/merge_requests", url.PathEscape(g.project.String())),
+		bytes.NewReader(jsonInBytes),
+	)
+	if err != nil || statusCode != 201 {
+		deleteBranchErr := g.deleteBranch(ctx, branch)
+		if deleteBranchErr != nil {
+			logger.Error().Err(deleteBranchErr).Msg("failed to clean up branch of failed merge request")
+		}
+		return fmt.Errorf("failed to open merge request for %s: (%d) %s -- %w", branch, statusCode, body, err)
+	}
+
+	mergeRequest := mergeRequestItem{}
+	if jsonErr := json.Unmarshal([]byte(body), &mergeRequest); jsonErr == nil {
+		logger = logger.With().Int("iid", mergeRequest.IID).Str("url", mergeRequest.WebURL).Logger()
+	}
+	logger.Info().Msg("Merge request opened")
+	return nil
+}
+
+// discardChange closes the open merge requests of the branch and deletes the branch
+func (g *Gitlab) discardChange(ctx context.Context, branch string) error {
+	logger := zerolog.Ctx(ctx).With().Str("method", "discardChange").Str("branch", branch).Logger()
+
+	statusCode, _, body, err := g.sendRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf(
+			"/projects/%s/merge_requests?state=opened&source_branch=%s",
+			url.PathEscape(g.project.String()),
+			url.QueryEscape(branch),
+		),
+		nil,
+	)
+	if err != nil || statusCode != 200 {
+		return fmt.Errorf("failed to list merge requests of %s: (%d) %s -- %w", branch, statusCode, body, err)
+	}
+
+	mergeRequests := []mergeRequestItem{}
+	if jsonErr := json.Unmarshal([]byte(body), &mergeRequests); jsonErr != nil {
+		return fmt.Errorf("failed to unmarshal GitLab merge request list: %w", jsonErr)
+	}
+
+	for _, mergeRequest := range mergeRequests {
+		statusCode, _, body, err = g.sendRequest(
+			ctx,
+			"PUT",
+			fmt.Sprintf("/projects/%s/merge_requests/%d", url.PathEscape(g.project.String()), mergeRequest.IID),
+			strings.NewReader(`{"state_event":"close"}`),
+		)
+		if err != nil || statusCode != 200 {
+			return fmt.Errorf("failed to close merge request %d of %s: (%d) %s -- %w", mergeRequest.IID, branch, statusCode, body, err)
+		}
+		logger.Info().Int("iid", mergeRequest.IID).Msg("Merge request closed")
+	}
+
+	return g.deleteBranch(ctx, branch)
+}
+
+// deleteBranch deletes the branch; a missing branch is not an error, since GitLab may have removed it already
+func (g *Gitlab) deleteBranch(ctx context.Context, branch string) error {
+	statusCode, _, body, err := g.sendRequest(
+		ctx,
+		"DELETE",
+		fmt.Sprintf("/projects/%s/repository/branches/%s", url.PathEscape(g.project.String()), url.PathEscape(branch)),
+		nil,
+	)
+	if err != nil || (statusCode != 204 && statusCode != 404) {
+		return fmt.Errorf("failed to delete branch %s: (%d) %s -- %w", branch, statusCode, body, err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rs/zerolog"
+)
+
+// The metadata of an icon is kept in a JSON "sidecar" file under IconMetadataDir, so that
+// the git repository holds everything needed to restore the icon.
+
+const metadataUpdatedSuccessMessage = "icon metadata updated"
+
+// MarshalIconMetadata encodes the metadata as stored in the sidecar file; tags and iconfiles are sorted, so that
+// the same metadata is always stored the same way
+func MarshalIconMetadata(metadata domain.IconMetadata) ([]byte, error) {
+	tags := append([]string{}, metadata.Tags...)
+	sort.Strings(tags)
+	iconfiles := append([]domain.IconfileDescriptor{}, metadata.Iconfiles...)
+	sort.Slice(iconfiles, func(i, j int) bool {
+		if iconfiles[i].Format != iconfiles[j].Format {
+			return iconfiles[i].Format < iconfiles[j].Format
+		}
+		return iconfiles[i].Size < iconfiles[j].Size
+	})
+	content, marshalErr := json.MarshalIndent(domain.IconMetadata{
+		ModifiedBy:  metadata.ModifiedBy,
+		Description: metadata.Description,
+		Tags:        tags,
+		Iconfiles:   iconfiles,
+	}, "", "  ")
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	return append(content, '\n'), nil
+}
+
+// UnmarshalIconMetadata decodes the content of a sidecar file
+func UnmarshalIconMetadata(content []byte) (domain.IconMetadata, error) {
+	metadata := domain.IconMetadata{}
+	unmarshalErr := json.Unmarshal(content, &metadata)
+	if metadata.Tags == nil {
+		metadata.Tags = []string{}
+	}
+	return metadata, unmarshalErr
+}
+
+func (repo *Local) UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error {
+	content, marshalErr := MarshalIconMetadata(metadata)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal metadata of icon %s: %w", iconName, marshalErr)
+	}
+	pathToMetadata := repo.FilePaths.GetAbsolutePathToIconMetadata(iconName)
+
+	iconfileOperation := func() ([]string, error) {
+		pathInRepo, writeErr := repo.writeIconMetadataFile(iconName, content)
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		return []string{pathInRepo}, nil
+	}
+
+	jobTextProvider := gitJobTextProvider{
+		fmt.Sprintf("update metadata of icon \"%s\"", iconName),
+		defaultCommitMessageProvider(metadataUpdatedSuccessMessage),
+	}
+
+	err := repo.Writes.Run(ctx, nil, func(ctx context.Context) error {
+		// Nothing to commit if the metadata hasn't changed
+		if current, readErr := os.ReadFile(pathToMetadata); readErr == nil && bytes.Equal(current, content) {
+			return nil
+		}
+		return repo.executeIconfileJob(ctx, iconfileOperation, jobTextProvider, modifiedBy)
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to update metadata of icon %s in git repository at %s: %w", iconName, repo.Location, err)
+	}
+	return nil
+}
+
+func (repo *Local) GetIconMetadata(ctx context.Context, iconName string) (domain.IconMetadata, error) {
+	pathToMetadata := repo.FilePaths.GetAbsolutePathToIconMetadata(iconName)
+	content, readErr := os.ReadFile(pathToMetadata)
+	if readErr != nil {
+		if errors.Is(readErr, os.ErrNotExist) {
+			return domain.IconMetadata{}, fmt.Errorf("no metadata for icon %s: %w", iconName, domain.ErrIconMetadataNotFound)
+		}
+		return domain.IconMetadata{}, fmt.Errorf("failed to read metadata of icon %s from local git repo: %w", iconName, readErr)
+	}
+	metadata, unmarshalErr := UnmarshalIconMetadata(content)
+	if unmarshalErr != nil {
+		return domain.IconMetadata{}, fmt.Errorf("failed to unmarshal metadata of icon %s: %w", iconName, unmarshalErr)
+	}
+	return metadata, nil
+}
+
+// writeIconMetadataFile returns the path to the metadata file in the repository
+func (repo *Local) writeIconMetadataFile(iconName string, content []byte) (string, error) {
+	pathToMetadata := repo.FilePaths.GetAbsolutePathToIconMetadata(iconName)
+	mkdirErr := os.MkdirAll(filepath.Dir(pathToMetadata), 0700)
+	if mkdirErr != nil {
+		return "", fmt.Errorf("failed to create directory for metadata of icon %s: %w", iconName, mkdirErr)
+	}
+	writeErr := os.WriteFile(pathToMetadata, content, 0600)
+	if writeErr != nil {
+		return "", fmt.Errorf("failed to write metadata of icon %s: %w", iconName, writeErr)
+	}
+	return repo.FilePaths.getPathToIconMetadataInRepo(iconName), nil
+}
+
+// deleteIconMetadataFile removes the metadata file of the icon if there is one; the returned path is empty if there isn't
+func (repo *Local) deleteIconMetadataFile(iconName string) (string, error) {
+	removeErr := os.Remove(repo.FilePaths.GetAbsolutePathToIconMetadata(iconName))
+	if removeErr != nil {
+		if errors.Is(removeErr, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to remove metadata of icon %s: %w", iconName, removeErr)
+	}
+	return repo.FilePaths.getPathToIconMetadataInRepo(iconName), nil
+}
+
+// getIconMetadataFile returns the content of the metadata file of the icon on the main branch, or nil if there is none
+func (g *Gitlab) getIconMetadataFile(ctx context.Context, iconName string) ([]byte, error) {
+	filePath := paths.getPathToIconMetadataInRepo(iconName)
+	statusCode, _, body, err := g.sendRequest(
+		ctx,
+		"GET",
+		fmt.Sprintf(
+			"/projects/%s/repository/files/%s?ref=%s",
+			url.PathEscape(g.project.String()),
+			url.PathEscape(filePath),
+			url.QueryEscape(g.mainBranch),
+		),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to get metadata of icon %s from GitLab repo: %w", iconName, err)
+	}
+	if statusCode == 404 {
+		return nil, nil
+	}
+	if statusCode != 200 {
+		return nil, fmt.Errorf("failed to get metadata of icon %s from GitLab repo: (%d) %s", iconName, statusCode, body)
+	}
+
+	respFileItem := responseFileItem{}
+	jsonErr := json.Unmarshal([]byte(body), &respFileItem)
+	if jsonErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal GitLab file response for metadata of icon %s: %w", iconName, jsonErr)
+	}
+	content, decodeErr := base64.StdEncoding.DecodeString(respFileItem.Content)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode metadata of icon %s: %w", iconName, decodeErr)
+	}
+	return content, nil
+}
+
+// UpdateIconMetadata commits the metadata directly to the main branch: only iconfiles are reviewed in merge requests
+func (g *Gitlab) UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "gitlab-client").Str("method", "UpdateIconMetadata").Str("iconName", iconName).Logger()
+
+	content, marshalErr := MarshalIconMetadata(metadata)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal metadata of icon %s: %w", iconName, marshalErr)
+	}
+
+	current, getErr := g.getIconMetadataFile(ctx, iconName)
+	if getErr != nil {
+		return getErr
+	}
+	if bytes.Equal(current, content) {
+		logger.Debug().Msg("metadata unchanged")
+		return nil
+	}
+
+	action := commitActionUpdate
+	if current == nil {
+		action = commitActionCreate
+	}
+	filePath := paths.getPathToIconMetadataInRepo(iconName)
+	commitErr := g.commit(ctx, g.mainBranch, "", modifiedBy, fmt.Sprintf("Updating icon metadata: %s", filePath), []commitActionOnByteSlice{
+		{
+			Action:   action,
+			FilePath: filePath,
+			Content:  content,
+		},
+	})
+	if commitErr != nil {
+		return fmt.Errorf("failed to update metadata of icon %s in GitLab repo: %w", iconName, commitErr)
+	}
+	logger.Info().Msg("Icon metadata updated in GitLab repository")
+	return nil
+}
+
+func (g *Gitlab) GetIconMetadata(ctx context.Context, iconName string) (domain.IconMetadata, error) {
+	content, getErr := g.getIconMetadataFile(ctx, iconName)
+	if getErr != nil {
+		return domain.IconMetadata{}, getErr
+	}
+	if content == nil {
+		return domain.IconMetadata{}, fmt.Errorf("no metadata for icon %s: %w", iconName, domain.ErrIconMetadataNotFound)
+	}
+	metadata, unmarshalErr := UnmarshalIconMetadata(content)
+	if unmarshalErr != nil {
+		return domain.IconMetadata{}, fmt.Errorf("failed to unmarshal metadata of icon %s: %w", iconName, unmarshalErr)
+	}
+	return metadata, nil
+}
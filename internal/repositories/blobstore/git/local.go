@@ -2,14 +2,21 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"iconrepo/internal/app/domain"
 	"iconrepo/internal/app/security/authn"
-	"iconrepo/internal/config"
 	"iconrepo/internal/logging"
+	"iconrepo/internal/repositories/blobstore/scheduler"
 	"os"
-	"strings"
-
+	"path/filepath"
+	"sort"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/rs/zerolog"
 )
 
@@ -17,6 +24,10 @@ type Local struct {
 	Location  string
 	Logger    zerolog.Logger
 	FilePaths filePaths
+	// Mirror, if set, is notified of each commit to push it to the remote mirrors
+	Mirror *Mirror
+	// Writes runs the changes to the repository. All of them go through the index of the repository, so they run one at a time.
+	Writes *scheduler.Scheduler
 }
 
 func (repo Local) String() string {
@@ -28,12 +39,19 @@ func NewLocalGitRepository(location string) Local {
 		Location:  location,
 		FilePaths: NewGitFilePaths(location),
 	}
+	git.Writes = scheduler.NewScheduler(git.String(), 0)
 	return git
 }
 
-var (
-	SimulateGitCommitFailureEnvvarName = "GIT_COMMIT_FAIL_INTRUSIVE_TEST"
-	gitCommitFailureTestCommand        = "procyon lotor"
+// SimulateGitCommitFailureEnvvarName makes commits fail when set to "true", for testing the rollback of failed changes
+var SimulateGitCommitFailureEnvvarName = "GIT_COMMIT_FAIL_INTRUSIVE_TEST"
+
+var errSimulatedCommitFailure = errors.New("simulated commit failure")
+
+// The committer of all the commits; the author is the user making the change
+const (
+	committerName  = "Icon Repo Server"
+	committerEmail = "IconRepoServer@UIToolBox"
 )
 
 const (
@@ -42,8 +60,6 @@ const (
 	fileDeleteSuccessMessage   = "iconfile for icon \"%s\" deleted:\n\n%s"
 )
 
-const cleanStatusMessageTail = "nothing to commit, working tree clean"
-
 func (repo *Local) CreateRepository(ctx context.Context) error {
 	return repo.initMaybe()
 }
@@ -64,12 +80,28 @@ func (repo *Local) GetAbsolutePathToIconfile(iconName string, iconfileDescriptor
 	return repo.FilePaths.GetAbsolutePathToIconfile(iconName, iconfileDescriptor)
 }
 
-func (repo *Local) ExecuteGitCommand(args []string) (string, error) {
-	return config.ExecuteCommand(config.ExecCmdParams{
-		Name: "git",
-		Args: args,
-		Opts: &config.CmdOpts{Cwd: repo.Location},
-	}, repo.Logger)
+func (repo *Local) open() (*gogit.Repository, error) {
+	gitRepo, openErr := gogit.PlainOpen(repo.Location)
+	if openErr != nil {
+		return nil, fmt.Errorf("failed to open git repository at %s: %w", repo.Location, openErr)
+	}
+	return gitRepo, nil
+}
+
+// headCommit returns the commit HEAD points to or nil if nothing has been committed yet
+func headCommit(gitRepo *gogit.Repository) (*object.Commit, error) {
+	head, headErr := gitRepo.Head()
+	if headErr != nil {
+		if errors.Is(headErr, plumbing.ErrReferenceNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", headErr)
+	}
+	commit, commitErr := gitRepo.CommitObject(head.Hash())
+	if commitErr != nil {
+		return nil, fmt.Errorf("failed to get the commit at HEAD: %w", commitErr)
+	}
+	return commit, nil
 }
 
 type getCommitMessageFn func(filelist []string) string
@@ -101,65 +133,104 @@ func defaultCommitMessageProvider(messageBase string) getCommitMessageFn {
 	}
 }
 
-func getCommitCommand() string {
-	if os.Getenv(SimulateGitCommitFailureEnvvarName) == "true" {
-		return gitCommitFailureTestCommand
-	} else {
-		return "commit"
+// commit commits the changes to the listed paths, which can be additions, modifications or deletions
+func (repo *Local) commit(gitRepo *gogit.Repository, pathsInRepo []string, message string, userName string) error {
+	worktree, worktreeErr := gitRepo.Worktree()
+	if worktreeErr != nil {
+		return fmt.Errorf("failed to get worktree: %w", worktreeErr)
+	}
+	for _, pathInRepo := range pathsInRepo {
+		addErr := worktree.AddWithOptions(&gogit.AddOptions{Path: filepath.ToSlash(pathInRepo), SkipStatus: true})
+		if addErr != nil {
+			return fmt.Errorf("failed to add %s to index: %w", pathInRepo, addErr)
+		}
 	}
-}
 
-func commit(messageBase string, userName string) []string {
-	return []string{
-		getCommitCommand(),
-		"-m", messageBase + " by " + userName,
-		fmt.Sprintf("--author=%s@IconRepoServer <%s>", userName, userName),
+	if os.Getenv(SimulateGitCommitFailureEnvvarName) == "true" {
+		return errSimulatedCommitFailure
 	}
-}
 
-var rollbackCommands = [][]string{
-	{"reset", "--hard", "HEAD"},
-	{"clean", "-qfdx"},
+	now := time.Now()
+	_, commitErr := worktree.Commit(message+" by "+userName, &gogit.CommitOptions{
+		Author:    &object.Signature{Name: userName + "@IconRepoServer", Email: userName, When: now},
+		Committer: &object.Signature{Name: committerName, Email: committerEmail, When: now},
+		// Otherwise deleting the last files of the repository would fail, as go-git takes an empty index for an empty commit
+		AllowEmptyCommits: true,
+	})
+	if commitErr != nil {
+		return fmt.Errorf("failed to commit: %w", commitErr)
+	}
+	return nil
 }
 
-func (repo *Local) rollback() {
-	for _, rollbackCmd := range rollbackCommands {
-		_, _ = repo.ExecuteGitCommand(rollbackCmd)
+// rollback drops the changes not committed, in the index as well as in the worktree
+func (repo *Local) rollback(gitRepo *gogit.Repository) error {
+	worktree, worktreeErr := gitRepo.Worktree()
+	if worktreeErr != nil {
+		return fmt.Errorf("failed to get worktree: %w", worktreeErr)
+	}
+	head, headErr := headCommit(gitRepo)
+	if headErr != nil {
+		return headErr
 	}
+	if head != nil {
+		if resetErr := worktree.Reset(&gogit.ResetOptions{Commit: head.Hash, Mode: gogit.HardReset}); resetErr != nil {
+			return fmt.Errorf("failed to reset worktree: %w", resetErr)
+		}
+	} else if setIndexErr := gitRepo.Storer.SetIndex(&index.Index{Version: 2}); setIndexErr != nil {
+		return fmt.Errorf("failed to empty index: %w", setIndexErr)
+	}
+	if cleanErr := worktree.Clean(&gogit.CleanOptions{Dir: true}); cleanErr != nil {
+		return fmt.Errorf("failed to remove untracked files: %w", cleanErr)
+	}
+	return nil
 }
 
-func (repo *Local) executeIconfileJob(iconfileOperation func() ([]string, error), messages gitJobTextProvider, userName string) error {
+func (repo *Local) executeIconfileJob(ctx context.Context, iconfileOperation func() ([]string, error), messages gitJobTextProvider, userName string) error {
 	logger := logging.CreateMethodLogger(repo.Logger, fmt.Sprintf("git: %s", messages.logContext))
 
-	var out string
-	var err error
-	var iconfilePathsInRepo []string
+	gitRepo, err := repo.open()
+	if err != nil {
+		return err
+	}
 
 	defer func() {
 		if err != nil {
-			logger.Debug().Err(err).Str("out", out).Msg("failed GIT operation")
-			repo.rollback()
+			logger.Debug().Err(err).Msg("failed GIT operation")
+			if rollbackErr := repo.rollback(gitRepo); rollbackErr != nil {
+				logger.Error().Err(rollbackErr).Msg("failed to roll back GIT operation")
+			}
 		} else {
 			logger.Debug().Msg("Success")
 		}
 	}()
 
+	var iconfilePathsInRepo []string
 	iconfilePathsInRepo, err = iconfileOperation()
 	if err != nil {
 		return fmt.Errorf("failed iconfile operation: %w", err)
 	}
-	out, err = repo.ExecuteGitCommand([]string{"add", "-A"})
-	if err != nil {
-		return fmt.Errorf("failed to add files to index: %w -> %s", err, out)
+
+	// Nothing to commit, e.g. when a changeset leaves the metadata of its icons as it was
+	if len(iconfilePathsInRepo) == 0 {
+		return nil
+	}
+
+	// Rather not commit a change the caller isn't waiting for anymore
+	if err = ctx.Err(); err != nil {
+		return fmt.Errorf("not committing iconfile operation: %w", err)
 	}
 
-	commitMessage := messages.getCommitMessage(iconfilePathsInRepo)
-	out, err = repo.ExecuteGitCommand(commit(commitMessage, userName))
+	err = repo.commit(gitRepo, iconfilePathsInRepo, messages.getCommitMessage(iconfilePathsInRepo), userName)
 	if err != nil {
-		return fmt.Errorf("failed to commit: %w -> %s", err, out)
+		return err
+	}
+
+	if repo.Mirror != nil {
+		repo.Mirror.Notify()
 	}
 
-	return err
+	return nil
 }
 
 func (repo *Local) createIconfile(iconName string, iconfile domain.Iconfile, modifiedBy string) (string, error) {
@@ -201,9 +272,8 @@ func (repo *Local) AddIconfile(ctx context.Context, iconName string, iconfile do
 		defaultCommitMessageProvider(filesAddedSuccessMessage),
 	}
 
-	var err error
-	config.Enqueue(func() {
-		err = repo.executeIconfileJob(iconfileOperation, jobTextProvider, modifiedBy)
+	err := repo.Writes.Run(ctx, nil, func(ctx context.Context) error {
+		return repo.executeIconfileJob(ctx, iconfileOperation, jobTextProvider, modifiedBy)
 	})
 
 	if err != nil {
@@ -246,6 +316,15 @@ func (repo *Local) DeleteIcon(ctx context.Context, iconDesc domain.IconDescripto
 			}
 			fileList = append(fileList, filePath)
 		}
+		if opError == nil {
+			metadataPath, deletionError := repo.deleteIconMetadataFile(iconDesc.Name)
+			if deletionError != nil {
+				return fileList, deletionError
+			}
+			if len(metadataPath) > 0 {
+				fileList = append(fileList, metadataPath)
+			}
+		}
 		return fileList, opError
 	}
 
@@ -256,9 +335,8 @@ func (repo *Local) DeleteIcon(ctx context.Context, iconDesc domain.IconDescripto
 		},
 	}
 
-	var err error
-	config.Enqueue(func() {
-		err = repo.executeIconfileJob(iconfileOperation, jobTextProvider, modifiedBy.String())
+	err := repo.Writes.Run(ctx, nil, func(ctx context.Context) error {
+		return repo.executeIconfileJob(ctx, iconfileOperation, jobTextProvider, modifiedBy.String())
 	})
 
 	if err != nil {
@@ -280,9 +358,8 @@ func (repo *Local) DeleteIconfile(ctx context.Context, iconName string, iconfile
 		},
 	}
 
-	var err error
-	config.Enqueue(func() {
-		err = repo.executeIconfileJob(iconfileOperation, jobTextProvider, modifiedBy.String())
+	err := repo.Writes.Run(ctx, nil, func(ctx context.Context) error {
+		return repo.executeIconfileJob(ctx, iconfileOperation, jobTextProvider, modifiedBy.String())
 	})
 
 	if err != nil {
@@ -291,85 +368,165 @@ func (repo *Local) DeleteIconfile(ctx context.Context, iconName string, iconfile
 	return nil
 }
 
+// MirrorStatus reports on the pushes to the remote mirrors, if any
+func (repo *Local) MirrorStatus() []domain.MirrorStatus {
+	if repo.Mirror == nil {
+		return []domain.MirrorStatus{}
+	}
+	return repo.Mirror.Status()
+}
+
+// WriteQueueStats reports on the changes made to the repository
+func (repo *Local) WriteQueueStats() domain.WriteQueueStats {
+	return repo.Writes.Stats()
+}
+
+// CheckStatus tells whether the worktree is clean
 func (repo Local) CheckStatus() (bool, error) {
-	out, err := repo.ExecuteGitCommand([]string{"status"})
-	if err != nil {
-		return false, fmt.Errorf("failed to get current git commit: %w", err)
+	gitRepo, openErr := repo.open()
+	if openErr != nil {
+		return false, openErr
+	}
+	worktree, worktreeErr := gitRepo.Worktree()
+	if worktreeErr != nil {
+		return false, fmt.Errorf("failed to get worktree: %w", worktreeErr)
+	}
+	status, statusErr := worktree.Status()
+	if statusErr != nil {
+		return false, fmt.Errorf("failed to get git status: %w", statusErr)
 	}
-	status := strings.TrimSpace(out)
-	return strings.Contains(status, cleanStatusMessageTail), nil
+	return status.IsClean(), nil
 }
 
 func (repo Local) GetStateID(ctx context.Context) (string, error) {
-	out, err := repo.ExecuteGitCommand([]string{"rev-parse", "HEAD"})
-	if err != nil {
-		return "", fmt.Errorf("failed to get current git commit: %w", err)
+	gitRepo, openErr := repo.open()
+	if openErr != nil {
+		return "", openErr
+	}
+	head, headErr := gitRepo.Head()
+	if headErr != nil {
+		return "", fmt.Errorf("failed to get current git commit: %w", headErr)
 	}
-	return strings.TrimSpace(out), nil
+	return head.Hash().String(), nil
 }
 
+// GetIconfiles lists the files in the repository, except the metadata files of icons
 func (repo Local) GetIconfiles(ctx context.Context) ([]string, error) {
-	output, err := repo.ExecuteGitCommand([]string{"ls-tree", "-r", "HEAD", "--name-only"})
-	if err != nil {
-		return nil, err
+	gitRepo, openErr := repo.open()
+	if openErr != nil {
+		return nil, openErr
+	}
+	head, headErr := headCommit(gitRepo)
+	if headErr != nil {
+		return nil, headErr
 	}
-
 	fileList := []string{}
-	outputLines := strings.Split(output, config.LineBreak)
-	for _, line := range outputLines {
-		trimmedLine := strings.TrimSpace(line)
-		if len(trimmedLine) > 0 {
-			fileList = append(fileList, trimmedLine)
+	// A fresh repository has no commit to list the files of
+	if head == nil {
+		return fileList, nil
+	}
+
+	tree, treeErr := head.Tree()
+	if treeErr != nil {
+		return nil, fmt.Errorf("failed to get the tree of commit %s: %w", head.Hash, treeErr)
+	}
+	walkErr := tree.Files().ForEach(func(file *object.File) error {
+		if !IsIconMetadataPath(file.Name) {
+			fileList = append(fileList, file.Name)
 		}
+		return nil
+	})
+	if walkErr != nil {
+		return nil, fmt.Errorf("failed to list the files of commit %s: %w", head.Hash, walkErr)
 	}
+	sort.Strings(fileList)
 	return fileList, nil
 }
 
+// blobHashAt returns the hash of the file at the path in the commit, or the zero hash if the commit doesn't have the file
+func blobHashAt(commit *object.Commit, pathInRepo string) (plumbing.Hash, error) {
+	tree, treeErr := commit.Tree()
+	if treeErr != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get the tree of commit %s: %w", commit.Hash, treeErr)
+	}
+	entry, findErr := tree.FindEntry(pathInRepo)
+	if findErr != nil {
+		if errors.Is(findErr, object.ErrEntryNotFound) || errors.Is(findErr, object.ErrDirectoryNotFound) {
+			return plumbing.ZeroHash, nil
+		}
+		return plumbing.ZeroHash, fmt.Errorf("failed to look up %s in commit %s: %w", pathInRepo, commit.Hash, findErr)
+	}
+	return entry.Hash, nil
+}
+
 // GetVersionFor returns the commit ID of the iconfile specified by the method paramters.
 // Return empty string in case the file doesn't exist in the repository
 func (repo Local) GetVersionFor(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) (string, error) {
-	printCommitIDArgs := []string{"log", "-n", "1", "--pretty=format:%H", "--", repo.FilePaths.GetPathToIconfileInRepo(iconName, iconfileDesc)}
-	output, execErr := repo.ExecuteGitCommand(printCommitIDArgs)
-	if execErr != nil {
-		return "", fmt.Errorf("failed to execute command to get last commit modifying %s::%s: %w", iconName, iconfileDesc.String(), execErr)
+	pathInRepo := filepath.ToSlash(repo.FilePaths.GetPathToIconfileInRepo(iconName, iconfileDesc))
+
+	gitRepo, openErr := repo.open()
+	if openErr != nil {
+		return "", openErr
+	}
+	commit, headErr := headCommit(gitRepo)
+	if headErr != nil || commit == nil {
+		return "", headErr
 	}
-	return output, nil
+	blobHash, hashErr := blobHashAt(commit, pathInRepo)
+	if hashErr != nil || blobHash.IsZero() {
+		return "", hashErr
+	}
+
+	// Go back in the history till the commit which has written the current content
+	for commit.NumParents() > 0 {
+		parent, parentErr := commit.Parent(0)
+		if parentErr != nil {
+			return "", fmt.Errorf("failed to get the parent of commit %s: %w", commit.Hash, parentErr)
+		}
+		parentBlobHash, parentHashErr := blobHashAt(parent, pathInRepo)
+		if parentHashErr != nil {
+			return "", parentHashErr
+		}
+		if parentBlobHash != blobHash {
+			break
+		}
+		commit = parent
+	}
+	return commit.Hash.String(), nil
 }
 
 func (repo Local) GetVersionMetadata(ctx context.Context, commitId string) (CommitMetadata, error) {
-	logger := logging.CreateMethodLogger(repo.Logger, fmt.Sprintf("git: GetVersionMetadata: %s", commitId))
-
-	printCommitMetadataArgs := []string{"show", "--quiet", "--format=fuller", "--date=format:%Y-%m-%dT%H:%M:%S%z"}
-	output, execErr := repo.ExecuteGitCommand(printCommitMetadataArgs)
-	if execErr != nil {
-		return CommitMetadata{}, fmt.Errorf("failed to get metadata from repo for commit %s: %w", commitId, execErr)
+	gitRepo, openErr := repo.open()
+	if openErr != nil {
+		return CommitMetadata{}, openErr
 	}
-	logger.Debug().Str("meta-data", output).Msg("raw metadata extracted")
-	commitMetadata, parseErr := parseLocalCommitMetadata(output)
-	if parseErr != nil {
-		return commitMetadata, fmt.Errorf("failed to parse metadata from commit %s: %w", commitId, parseErr)
+	commit, commitErr := gitRepo.CommitObject(plumbing.NewHash(commitId))
+	if commitErr != nil {
+		return CommitMetadata{}, fmt.Errorf("failed to get metadata from repo for commit %s: %w", commitId, commitErr)
 	}
-	return commitMetadata, nil
+	return localCommitMetadata(commit), nil
 }
 
 func (repo Local) createInitializeGitRepo() error {
-	var err error
-	var out string
-
-	var cmds []config.ExecCmdParams = []config.ExecCmdParams{
-		{Name: "rm", Args: []string{"-rf", repo.Location}, Opts: nil},
-		{Name: "mkdir", Args: []string{"-p", repo.Location}, Opts: nil},
-		{Name: "git", Args: []string{"init"}, Opts: &config.CmdOpts{Cwd: repo.Location}},
-		{Name: "git", Args: []string{"config", "user.name", "Icon Repo Server"}, Opts: &config.CmdOpts{Cwd: repo.Location}},
-		{Name: "git", Args: []string{"config", "user.email", "IconRepoServer@UIToolBox"}, Opts: &config.CmdOpts{Cwd: repo.Location}},
+	if removeErr := os.RemoveAll(repo.Location); removeErr != nil {
+		return fmt.Errorf("failed to create git repo at %s: %w", repo.Location, removeErr)
+	}
+	if mkdirErr := os.MkdirAll(repo.Location, 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create git repo at %s: %w", repo.Location, mkdirErr)
+	}
+	gitRepo, initErr := gogit.PlainInit(repo.Location, false)
+	if initErr != nil {
+		return fmt.Errorf("failed to create git repo at %s: %w", repo.Location, initErr)
 	}
 
-	for _, cmd := range cmds {
-		out, err = config.ExecuteCommand(cmd, repo.Logger)
-		println(out)
-		if err != nil {
-			return fmt.Errorf("failed to create git repo at %s: %w", repo.Location, err)
-		}
+	gitConfig, configErr := gitRepo.Config()
+	if configErr != nil {
+		return fmt.Errorf("failed to read the configuration of git repo at %s: %w", repo.Location, configErr)
+	}
+	gitConfig.User.Name = committerName
+	gitConfig.User.Email = committerEmail
+	if setConfigErr := gitRepo.SetConfig(gitConfig); setConfigErr != nil {
+		return fmt.Errorf("failed to configure git repo at %s: %w", repo.Location, setConfigErr)
 	}
 
 	return nil
@@ -377,13 +534,12 @@ func (repo Local) createInitializeGitRepo() error {
 
 func (repo Local) locationHasRepo() bool {
 	if GitRepoLocationExists(repo.Location) {
-		testCommand := config.ExecCmdParams{Name: "git", Args: []string{"init"}, Opts: &config.CmdOpts{Cwd: repo.Location}}
-		outOrErr, err := config.ExecuteCommand(testCommand, repo.Logger)
-		if err != nil {
-			if strings.Contains(outOrErr, "not a git repository") { // TODO: Is it really possible to get this error message here?
+		_, openErr := gogit.PlainOpen(repo.Location)
+		if openErr != nil {
+			if errors.Is(openErr, gogit.ErrRepositoryNotExists) {
 				return false
 			}
-			panic(err)
+			panic(openErr)
 		}
 		return true
 	}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -74,21 +75,18 @@ func (testSuite *localGitRepoTestSuite) TestLocationHasRepo() {
 	testSuite.Equal(true, testSuite.gitRepoClient.locationHasRepo())
 }
 
-func (testSuite *localGitRepoTestSuite) TestParseCommitMetadata() {
-	// git show --quiet --format=fuller --date=format:'%Y-%m-%dT%H:%M:%S%z' 95fb34a325e697bafffb785ac65ecca986ca06a6
-
-	testInput := `Author:     Kovács, Péter <peter.dunay.kovacs@gmail.com>
-AuthorDate: 2022-10-09T13:42:12+0200
-Commit:     Péter Kovács <peter.dunay.kovacs@gmail.com>
-CommitDate: 2022-10-31T15:30:17+0100
-	
-    [DEV] distributed git access`
-
+func (testSuite *localGitRepoTestSuite) TestLocalCommitMetadata() {
 	authorDate, authorDateErr := time.Parse(time.RFC3339, "2022-10-09T13:42:12+02:00")
 	testSuite.Nil(authorDateErr)
 	commitDate, commitDateErr := time.Parse(time.RFC3339, "2022-10-31T15:30:17+01:00")
 	testSuite.Nil(commitDateErr)
 
+	commit := object.Commit{
+		Author:    object.Signature{Name: "Kovács, Péter", Email: "peter.dunay.kovacs@gmail.com", When: authorDate},
+		Committer: object.Signature{Name: "Péter Kovács", Email: "peter.dunay.kovacs@gmail.com", When: commitDate},
+		Message:   "[DEV] distributed git access\n",
+	}
+
 	expectedOutput := CommitMetadata{
 		Author:     "Kovács, Péter <peter.dunay.kovacs@gmail.com>",
 		AuthorDate: authorDate,
@@ -97,7 +95,5 @@ CommitDate: 2022-10-31T15:30:17+0100
 		Message:    "[DEV] distributed git access",
 	}
 
-	commitMetadata, parseErr := parseLocalCommitMetadata(testInput)
-	testSuite.Nil(parseErr)
-	testSuite.Equal(expectedOutput, commitMetadata)
+	testSuite.Equal(expectedOutput, localCommitMetadata(&commit))
 }
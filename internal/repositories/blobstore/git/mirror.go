@@ -0,0 +1,277 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/logging"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/rs/zerolog"
+)
+
+// Mirrors on the local filesystem are pushed to in-process, rather than by running git-receive-pack
+func init() {
+	client.InstallProtocol("file", server.NewClient(server.NewFilesystemLoader(osfs.New(""))))
+}
+
+// MirrorOptions tells when to push to the mirrors
+type MirrorOptions struct {
+	// Interval between pushes; zero pushes after each commit
+	Interval time.Duration
+	// RetryDelay is the delay before retrying a failed push. It is doubled with each failure up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// PushTimeout bounds each push, so that a remote which doesn't answer is retried later; zero doesn't bound pushes
+	PushTimeout time.Duration
+}
+
+var DefaultMirrorOptions = MirrorOptions{
+	RetryDelay:    time.Second,
+	MaxRetryDelay: 5 * time.Minute,
+	PushTimeout:   5 * time.Minute,
+}
+
+// mirrorRefspec pushes the local branches as they are. Pushes are never forced: a mirror whose history
+// has diverged, e.g. after the local repository has been recreated empty, is reported as failing
+// rather than overwritten.
+const mirrorRefspec = "refs/heads/*:refs/heads/*"
+
+type mirrorRemote struct {
+	url     string
+	trigger chan struct{}
+	mutex   sync.Mutex
+	status  domain.MirrorStatus
+}
+
+// Mirror pushes a local git repository to remote repositories, in the background, retrying failed pushes
+type Mirror struct {
+	location string
+	options  MirrorOptions
+	remotes  []*mirrorRemote
+	logger   zerolog.Logger
+	// stopping is cancelled by Stop, which interrupts the pushes in progress
+	stopping context.Context
+	stop     context.CancelFunc
+	stopped  sync.WaitGroup
+}
+
+func NewMirror(location string, remoteURLs []string, options MirrorOptions) *Mirror {
+	mirror := &Mirror{
+		location: location,
+		options:  options,
+		logger:   logging.Get().With().Str(logging.UnitLogger, "git-mirror").Logger(),
+	}
+	for _, remoteURL := range remoteURLs {
+		mirror.remotes = append(mirror.remotes, &mirrorRemote{
+			url:     remoteURL,
+			trigger: make(chan struct{}, 1),
+			// The mirror may be behind: it is pushed to as soon as the mirror starts
+			status: domain.MirrorStatus{Remote: redactRemoteURL(remoteURL), Pending: true},
+		})
+	}
+	return mirror
+}
+
+// ParseMirrorRemotes splits the comma-separated list of remote URLs in the configuration
+func ParseMirrorRemotes(remotes string) []string {
+	remoteURLs := []string{}
+	for _, remote := range strings.Split(remotes, ",") {
+		if trimmed := strings.TrimSpace(remote); len(trimmed) > 0 {
+			remoteURLs = append(remoteURLs, trimmed)
+		}
+	}
+	return remoteURLs
+}
+
+// redactRemoteURL hides the password in the URL, if any. URLs in the scp-like syntax of ssh remotes are left as they are.
+func redactRemoteURL(remoteURL string) string {
+	parsed, parseErr := url.Parse(remoteURL)
+	if parseErr != nil || parsed.User == nil {
+		return remoteURL
+	}
+	return parsed.Redacted()
+}
+
+// redactRemoteError hides the password of the remote URL in the error message, as go-git errors may include the URL
+func redactRemoteError(remoteURL string, err error) string {
+	message := strings.ReplaceAll(err.Error(), remoteURL, redactRemoteURL(remoteURL))
+	if parsed, parseErr := url.Parse(remoteURL); parseErr == nil && parsed.User != nil {
+		if password, hasPassword := parsed.User.Password(); hasPassword && len(password) > 0 {
+			message = strings.ReplaceAll(message, password, "xxxxx")
+		}
+	}
+	return message
+}
+
+// Start starts pushing to the mirrors
+func (mirror *Mirror) Start() {
+	mirror.stopping, mirror.stop = context.WithCancel(context.Background())
+	for _, remote := range mirror.remotes {
+		mirror.stopped.Add(1)
+		go mirror.run(remote)
+		mirror.trigger(remote)
+	}
+}
+
+// Stop stops pushing to the mirrors, interrupting the pushes in progress
+func (mirror *Mirror) Stop() {
+	if mirror.stop == nil {
+		return
+	}
+	mirror.stop()
+	mirror.stopped.Wait()
+	mirror.stop = nil
+}
+
+func (mirror *Mirror) trigger(remote *mirrorRemote) {
+	select {
+	case remote.trigger <- struct{}{}:
+	default:
+		// A push is already due
+	}
+}
+
+// Notify tells the mirror about a new commit. The commit is pushed right away unless the mirror pushes on a schedule.
+func (mirror *Mirror) Notify() {
+	for _, remote := range mirror.remotes {
+		remote.mutex.Lock()
+		remote.status.Pending = true
+		remote.mutex.Unlock()
+		if mirror.options.Interval == 0 {
+			mirror.trigger(remote)
+		}
+	}
+}
+
+// Status reports on each of the mirrors
+func (mirror *Mirror) Status() []domain.MirrorStatus {
+	statuses := []domain.MirrorStatus{}
+	for _, remote := range mirror.remotes {
+		remote.mutex.Lock()
+		statuses = append(statuses, remote.status)
+		remote.mutex.Unlock()
+	}
+	return statuses
+}
+
+func (mirror *Mirror) run(remote *mirrorRemote) {
+	defer mirror.stopped.Done()
+
+	var schedule <-chan time.Time
+	if mirror.options.Interval > 0 {
+		ticker := time.NewTicker(mirror.options.Interval)
+		defer ticker.Stop()
+		schedule = ticker.C
+	}
+
+	for {
+		select {
+		case <-mirror.stopping.Done():
+			return
+		case <-remote.trigger:
+		case <-schedule:
+		}
+		remote.mutex.Lock()
+		pending := remote.status.Pending
+		remote.mutex.Unlock()
+		if pending {
+			mirror.pushWithRetry(remote)
+		}
+	}
+}
+
+func (mirror *Mirror) pushWithRetry(remote *mirrorRemote) {
+	retryDelay := mirror.options.RetryDelay
+	for mirror.push(remote) != nil {
+		select {
+		case <-mirror.stopping.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+		retryDelay = min(2*retryDelay, mirror.options.MaxRetryDelay)
+	}
+}
+
+// pushBranches pushes the local branches to the remote and returns HEAD, which is nil if nothing has been committed yet
+func (mirror *Mirror) pushBranches(ctx context.Context, remoteURL string) (*plumbing.Reference, error) {
+	gitRepo, openErr := gogit.PlainOpen(mirror.location)
+	if openErr != nil {
+		return nil, fmt.Errorf("failed to open git repository at %s: %w", mirror.location, openErr)
+	}
+	head, headErr := gitRepo.Head()
+	if headErr != nil {
+		if errors.Is(headErr, plumbing.ErrReferenceNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", headErr)
+	}
+
+	pushErr := gogit.NewRemote(gitRepo.Storer, &gitconfig.RemoteConfig{
+		Name: "mirror",
+		URLs: []string{remoteURL},
+	}).PushContext(ctx, &gogit.PushOptions{
+		RemoteName: "mirror",
+		RefSpecs:   []gitconfig.RefSpec{mirrorRefspec},
+	})
+	if pushErr != nil && !errors.Is(pushErr, gogit.NoErrAlreadyUpToDate) {
+		return head, pushErr
+	}
+	return head, nil
+}
+
+func (mirror *Mirror) push(remote *mirrorRemote) error {
+	logger := mirror.logger.With().Str("remote", remote.status.Remote).Logger()
+
+	// Pending is cleared before pushing, so that a commit made while pushing is pushed next time
+	remote.mutex.Lock()
+	remote.status.Pending = false
+	remote.mutex.Unlock()
+
+	ctx := mirror.stopping
+	if mirror.options.PushTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, mirror.options.PushTimeout)
+		defer cancel()
+	}
+	head, pushErr := mirror.pushBranches(ctx, remote.url)
+	if pushErr == nil && head == nil {
+		// Nothing has been committed yet
+		return nil
+	}
+	if pushErr != nil && mirror.stopping.Err() != nil {
+		// Interrupted by Stop: the push is still due
+		remote.mutex.Lock()
+		remote.status.Pending = true
+		remote.mutex.Unlock()
+		return pushErr
+	}
+
+	now := time.Now()
+	remote.mutex.Lock()
+	defer remote.mutex.Unlock()
+	remote.status.LastAttempt = &now
+	if pushErr != nil {
+		remote.status.Pending = true
+		remote.status.ConsecutiveFailures++
+		remote.status.LastError = redactRemoteError(remote.url, pushErr)
+		logger.Error().Str("error", remote.status.LastError).Int("consecutive_failures", remote.status.ConsecutiveFailures).Msg("failed to push to mirror")
+		return fmt.Errorf("failed to push to mirror %s: %s", remote.status.Remote, remote.status.LastError)
+	}
+	remote.status.LastSuccess = &now
+	remote.status.LastPushedCommit = head.Hash().String()
+	remote.status.LastError = ""
+	remote.status.ConsecutiveFailures = 0
+	logger.Debug().Str("commit", remote.status.LastPushedCommit).Msg("pushed to mirror")
+	return nil
+}
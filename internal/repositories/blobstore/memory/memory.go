@@ -0,0 +1,229 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/repositories/blobstore/git"
+)
+
+type blob struct {
+	content    []byte
+	modifiedBy string
+	modifiedAt time.Time
+	version    int64
+}
+
+// Blobstore keeps the iconfiles in memory, keyed by the paths they would have in the git repository.
+// It is meant for development and tests: the data is lost when the process exits.
+type Blobstore struct {
+	mutex       sync.Mutex
+	blobs       map[string]blob
+	lastVersion int64
+}
+
+func NewBlobstore() *Blobstore {
+	return &Blobstore{blobs: map[string]blob{}}
+}
+
+var (
+	sharedBlobstoresMutex sync.Mutex
+	sharedBlobstores      = map[string]*Blobstore{}
+)
+
+// Shared returns the blobstore registered in the process under the name, creating it on first use. Servers started
+// one after the other in the same process with the same name see the same data, the way they would with a persistent blobstore.
+func Shared(name string) *Blobstore {
+	sharedBlobstoresMutex.Lock()
+	defer sharedBlobstoresMutex.Unlock()
+	blobstore, found := sharedBlobstores[name]
+	if !found {
+		blobstore = NewBlobstore()
+		sharedBlobstores[name] = blobstore
+	}
+	return blobstore
+}
+
+func (repo *Blobstore) String() string {
+	return "In-memory blobstore"
+}
+
+func iconfilePath(iconName string, iconfileDesc domain.IconfileDescriptor) string {
+	return filepath.ToSlash(git.NewGitFilePaths("").GetPathToIconfileInRepo(iconName, iconfileDesc))
+}
+
+func iconMetadataPath(iconName string) string {
+	return filepath.ToSlash(git.NewGitFilePaths("").GetAbsolutePathToIconMetadata(iconName))
+}
+
+// CreateRepository has nothing to do: the blobstore is ready as soon as it is created
+func (repo *Blobstore) CreateRepository(ctx context.Context) error {
+	return nil
+}
+
+func (repo *Blobstore) putBlob(path string, content []byte, modifiedBy string) {
+	repo.lastVersion++
+	repo.blobs[path] = blob{
+		content:    append([]byte{}, content...),
+		modifiedBy: modifiedBy,
+		modifiedAt: time.Now(),
+		version:    repo.lastVersion,
+	}
+}
+
+func (repo *Blobstore) AddIconfile(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.putBlob(iconfilePath(iconName, iconfile.IconfileDescriptor), iconfile.Content, modifiedBy)
+	return nil
+}
+
+func (repo *Blobstore) GetIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) ([]byte, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	stored, found := repo.blobs[iconfilePath(iconName, iconfileDesc)]
+	if !found {
+		return nil, fmt.Errorf("failed to read iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, domain.ErrIconfileNotFound)
+	}
+	return append([]byte{}, stored.content...), nil
+}
+
+func (repo *Blobstore) deleteIconfile(iconName string, iconfileDesc domain.IconfileDescriptor) error {
+	path := iconfilePath(iconName, iconfileDesc)
+	if _, found := repo.blobs[path]; !found {
+		return fmt.Errorf("failed to remove iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, domain.ErrIconfileNotFound)
+	}
+	delete(repo.blobs, path)
+	return nil
+}
+
+func (repo *Blobstore) DeleteIcon(ctx context.Context, iconDesc domain.IconDescriptor, modifiedBy authn.UserID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, iconfileDesc := range iconDesc.Iconfiles {
+		if deleteErr := repo.deleteIconfile(iconDesc.Name, iconfileDesc); deleteErr != nil {
+			return fmt.Errorf("failed to remove icon %s from %s: %w", iconDesc.Name, repo, deleteErr)
+		}
+	}
+	delete(repo.blobs, iconMetadataPath(iconDesc.Name))
+	return nil
+}
+
+func (repo *Blobstore) DeleteIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor, modifiedBy authn.UserID) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	return repo.deleteIconfile(iconName, iconfileDesc)
+}
+
+// GetIconfiles lists the paths of the blobs, except those of the metadata of icons
+func (repo *Blobstore) GetIconfiles(ctx context.Context) ([]string, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	fileList := []string{}
+	for path := range repo.blobs {
+		if !git.IsIconMetadataPath(path) {
+			fileList = append(fileList, path)
+		}
+	}
+	sort.Strings(fileList)
+	return fileList, nil
+}
+
+func (repo *Blobstore) UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error {
+	content, marshalErr := git.MarshalIconMetadata(metadata)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal metadata of icon %s: %w", iconName, marshalErr)
+	}
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	path := iconMetadataPath(iconName)
+	if current, found := repo.blobs[path]; found && bytes.Equal(current.content, content) {
+		return nil
+	}
+	repo.putBlob(path, content, modifiedBy)
+	return nil
+}
+
+func (repo *Blobstore) GetIconMetadata(ctx context.Context, iconName string) (domain.IconMetadata, error) {
+	repo.mutex.Lock()
+	stored, found := repo.blobs[iconMetadataPath(iconName)]
+	repo.mutex.Unlock()
+	if !found {
+		return domain.IconMetadata{}, fmt.Errorf("no metadata for icon %s: %w", iconName, domain.ErrIconMetadataNotFound)
+	}
+	metadata, unmarshalErr := git.UnmarshalIconMetadata(stored.content)
+	if unmarshalErr != nil {
+		return domain.IconMetadata{}, fmt.Errorf("failed to unmarshal metadata of icon %s: %w", iconName, unmarshalErr)
+	}
+	return metadata, nil
+}
+
+func (repo *Blobstore) ResetRepository(ctx context.Context) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.blobs = map[string]blob{}
+	return nil
+}
+
+func (repo *Blobstore) DeleteRepository(ctx context.Context) error {
+	return repo.ResetRepository(ctx)
+}
+
+// CheckStatus always reports a clean state: writes are never left half-done
+func (repo *Blobstore) CheckStatus() (bool, error) {
+	return true, nil
+}
+
+// GetStateID returns a digest of the paths and versions of the blobs
+func (repo *Blobstore) GetStateID(ctx context.Context) (string, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	entries := []string{}
+	for path, stored := range repo.blobs {
+		entries = append(entries, fmt.Sprintf("%s %d", path, stored.version))
+	}
+	sort.Strings(entries)
+	digest := sha1.Sum([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// GetVersionFor returns the version number of the iconfile, which changes with every write.
+// Returns empty string in case the iconfile doesn't exist
+func (repo *Blobstore) GetVersionFor(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) (string, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	stored, found := repo.blobs[iconfilePath(iconName, iconfileDesc)]
+	if !found {
+		return "", nil
+	}
+	return strconv.FormatInt(stored.version, 10), nil
+}
+
+// GetVersionMetadata describes a version returned by GetVersionFor in the shape of git commit metadata.
+// Only current versions are kept.
+func (repo *Blobstore) GetVersionMetadata(ctx context.Context, version string) (git.CommitMetadata, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, stored := range repo.blobs {
+		if strconv.FormatInt(stored.version, 10) == version {
+			return git.CommitMetadata{
+				Commit:     version,
+				Author:     stored.modifiedBy,
+				AuthorDate: stored.modifiedAt,
+				CommitDate: stored.modifiedAt,
+			}, nil
+		}
+	}
+	return git.CommitMetadata{}, fmt.Errorf("failed to get metadata of version %s: not found in %s", version, repo)
+}
@@ -0,0 +1,264 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/internal/repositories/indexing/pgdb"
+)
+
+// Postgres stores the iconfiles in the "blob" table of the database, keyed by the paths they would have in the git repository.
+// When the side-effect of a change to a Postgres index writes to it, the write takes part in the transaction of the index:
+// the index and the content are committed (or rolled back) together.
+type Postgres struct {
+	pool *sql.DB
+}
+
+func NewPostgresBlobstore(pool *sql.DB) *Postgres {
+	return &Postgres{pool: pool}
+}
+
+func (repo *Postgres) String() string {
+	return "Postgres blobstore"
+}
+
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// db returns the transaction of the change to the index in progress, if any, or the connection pool
+func (repo *Postgres) db(ctx context.Context) dbExecutor {
+	if tx := pgdb.TxFromContext(ctx); tx != nil {
+		return tx
+	}
+	return repo.pool
+}
+
+func iconfilePath(iconName string, iconfileDesc domain.IconfileDescriptor) string {
+	return filepath.ToSlash(git.NewGitFilePaths("").GetPathToIconfileInRepo(iconName, iconfileDesc))
+}
+
+func iconMetadataPath(iconName string) string {
+	return filepath.ToSlash(git.NewGitFilePaths("").GetAbsolutePathToIconMetadata(iconName))
+}
+
+// CreateRepository has nothing to do: the table is created along with the database schema
+func (repo *Postgres) CreateRepository(ctx context.Context) error {
+	return nil
+}
+
+func (repo *Postgres) putBlob(ctx context.Context, path string, content []byte, modifiedBy string) error {
+	const upsertSQL = "INSERT INTO blob(path, content, modified_by) VALUES($1, $2, $3) " +
+		"ON CONFLICT (path) DO UPDATE SET content = EXCLUDED.content, modified_by = EXCLUDED.modified_by, " +
+		"modified_at = now(), version = nextval('blob_version_seq')"
+	_, err := repo.db(ctx).ExecContext(ctx, upsertSQL, path, content, modifiedBy)
+	return err
+}
+
+// getBlob returns nil if there is no blob at the path
+func (repo *Postgres) getBlob(ctx context.Context, path string) ([]byte, error) {
+	var content []byte
+	err := repo.db(ctx).QueryRowContext(ctx, "SELECT content FROM blob WHERE path = $1", path).Scan(&content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return content, nil
+}
+
+// deleteBlob tells whether there was a blob to delete at the path
+func (repo *Postgres) deleteBlob(ctx context.Context, path string) (bool, error) {
+	result, err := repo.db(ctx).ExecContext(ctx, "DELETE FROM blob WHERE path = $1", path)
+	if err != nil {
+		return false, err
+	}
+	deleted, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return false, rowsErr
+	}
+	return deleted > 0, nil
+}
+
+func (repo *Postgres) AddIconfile(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy string) error {
+	putErr := repo.putBlob(ctx, iconfilePath(iconName, iconfile.IconfileDescriptor), iconfile.Content, modifiedBy)
+	if putErr != nil {
+		return fmt.Errorf("failed to add iconfile %v for %s to %s: %w", iconfile.IconfileDescriptor, iconName, repo, putErr)
+	}
+	return nil
+}
+
+func (repo *Postgres) GetIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) ([]byte, error) {
+	content, getErr := repo.getBlob(ctx, iconfilePath(iconName, iconfileDesc))
+	if getErr != nil {
+		return nil, fmt.Errorf("failed to read iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, getErr)
+	}
+	if content == nil {
+		return nil, fmt.Errorf("failed to read iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, domain.ErrIconfileNotFound)
+	}
+	return content, nil
+}
+
+func (repo *Postgres) DeleteIcon(ctx context.Context, iconDesc domain.IconDescriptor, modifiedBy authn.UserID) error {
+	for _, iconfileDesc := range iconDesc.Iconfiles {
+		if deleteErr := repo.DeleteIconfile(ctx, iconDesc.Name, iconfileDesc, modifiedBy); deleteErr != nil {
+			return fmt.Errorf("failed to remove icon %s from %s: %w", iconDesc.Name, repo, deleteErr)
+		}
+	}
+	if _, deleteErr := repo.deleteBlob(ctx, iconMetadataPath(iconDesc.Name)); deleteErr != nil {
+		return fmt.Errorf("failed to remove metadata of icon %s from %s: %w", iconDesc.Name, repo, deleteErr)
+	}
+	return nil
+}
+
+func (repo *Postgres) DeleteIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor, modifiedBy authn.UserID) error {
+	deleted, deleteErr := repo.deleteBlob(ctx, iconfilePath(iconName, iconfileDesc))
+	if deleteErr != nil {
+		return fmt.Errorf("failed to remove iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, deleteErr)
+	}
+	if !deleted {
+		return fmt.Errorf("failed to remove iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, domain.ErrIconfileNotFound)
+	}
+	return nil
+}
+
+// GetIconfiles lists the paths of the blobs, except those of the metadata of icons
+func (repo *Postgres) GetIconfiles(ctx context.Context) ([]string, error) {
+	rows, queryErr := repo.db(ctx).QueryContext(ctx, "SELECT path FROM blob ORDER BY path")
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to list iconfiles in %s: %w", repo, queryErr)
+	}
+	defer rows.Close()
+
+	fileList := []string{}
+	for rows.Next() {
+		var path string
+		if scanErr := rows.Scan(&path); scanErr != nil {
+			return nil, fmt.Errorf("failed to list iconfiles in %s: %w", repo, scanErr)
+		}
+		if !git.IsIconMetadataPath(path) {
+			fileList = append(fileList, path)
+		}
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, fmt.Errorf("failed to list iconfiles in %s: %w", repo, rowsErr)
+	}
+	return fileList, nil
+}
+
+func (repo *Postgres) UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error {
+	content, marshalErr := git.MarshalIconMetadata(metadata)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal metadata of icon %s: %w", iconName, marshalErr)
+	}
+	path := iconMetadataPath(iconName)
+	current, getErr := repo.getBlob(ctx, path)
+	if getErr != nil {
+		return fmt.Errorf("failed to read metadata of icon %s from %s: %w", iconName, repo, getErr)
+	}
+	if bytes.Equal(current, content) {
+		return nil
+	}
+	if putErr := repo.putBlob(ctx, path, content, modifiedBy); putErr != nil {
+		return fmt.Errorf("failed to update metadata of icon %s in %s: %w", iconName, repo, putErr)
+	}
+	return nil
+}
+
+func (repo *Postgres) GetIconMetadata(ctx context.Context, iconName string) (domain.IconMetadata, error) {
+	content, getErr := repo.getBlob(ctx, iconMetadataPath(iconName))
+	if getErr != nil {
+		return domain.IconMetadata{}, fmt.Errorf("failed to read metadata of icon %s from %s: %w", iconName, repo, getErr)
+	}
+	if content == nil {
+		return domain.IconMetadata{}, fmt.Errorf("no metadata for icon %s: %w", iconName, domain.ErrIconMetadataNotFound)
+	}
+	metadata, unmarshalErr := git.UnmarshalIconMetadata(content)
+	if unmarshalErr != nil {
+		return domain.IconMetadata{}, fmt.Errorf("failed to unmarshal metadata of icon %s: %w", iconName, unmarshalErr)
+	}
+	return metadata, nil
+}
+
+func (repo *Postgres) ResetRepository(ctx context.Context) error {
+	if _, err := repo.pool.ExecContext(ctx, "DELETE FROM blob"); err != nil {
+		return fmt.Errorf("failed to delete the content of %s: %w", repo, err)
+	}
+	return nil
+}
+
+func (repo *Postgres) DeleteRepository(ctx context.Context) error {
+	return repo.ResetRepository(ctx)
+}
+
+// CheckStatus always reports a clean state: writes are transactional
+func (repo *Postgres) CheckStatus() (bool, error) {
+	return true, nil
+}
+
+// GetStateID returns a digest of the paths and versions of the blobs
+func (repo *Postgres) GetStateID(ctx context.Context) (string, error) {
+	rows, queryErr := repo.pool.QueryContext(ctx, "SELECT path, version FROM blob")
+	if queryErr != nil {
+		return "", fmt.Errorf("failed to get state of %s: %w", repo, queryErr)
+	}
+	defer rows.Close()
+
+	entries := []string{}
+	for rows.Next() {
+		var path string
+		var version int64
+		if scanErr := rows.Scan(&path, &version); scanErr != nil {
+			return "", fmt.Errorf("failed to get state of %s: %w", repo, scanErr)
+		}
+		entries = append(entries, fmt.Sprintf("%s %d", path, version))
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return "", fmt.Errorf("failed to get state of %s: %w", repo, rowsErr)
+	}
+	sort.Strings(entries)
+	digest := sha1.Sum([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// GetVersionFor returns the version number of the iconfile, which changes with every write.
+// Returns empty string in case the iconfile doesn't exist
+func (repo *Postgres) GetVersionFor(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) (string, error) {
+	var version int64
+	err := repo.pool.QueryRowContext(ctx, "SELECT version FROM blob WHERE path = $1", iconfilePath(iconName, iconfileDesc)).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get version of %s::%s: %w", iconName, iconfileDesc.String(), err)
+	}
+	return strconv.FormatInt(version, 10), nil
+}
+
+// GetVersionMetadata describes a version returned by GetVersionFor in the shape of git commit metadata.
+// Only current versions are kept.
+func (repo *Postgres) GetVersionMetadata(ctx context.Context, version string) (git.CommitMetadata, error) {
+	metadata := git.CommitMetadata{Commit: version}
+	err := repo.pool.QueryRowContext(ctx, "SELECT modified_by, modified_at FROM blob WHERE version = $1", version).
+		Scan(&metadata.Author, &metadata.CommitDate)
+	if err != nil {
+		return git.CommitMetadata{}, fmt.Errorf("failed to get metadata of version %s: %w", version, err)
+	}
+	metadata.AuthorDate = metadata.CommitDate
+	return metadata, nil
+}
@@ -0,0 +1,46 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"iconrepo/internal/config"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_config "github.com/aws/aws-sdk-go-v2/config"
+	aws_s3 "github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func createAwsConfig(ctx context.Context, conf *config.Options) (aws.Config, error) {
+	loadOptions := []func(*aws_config.LoadOptions) error{}
+
+	if len(conf.S3URL) > 0 {
+		customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{
+				URL:               conf.S3URL,
+				SigningRegion:     region,
+				HostnameImmutable: true,
+			}, nil
+		})
+		loadOptions = append(loadOptions, aws_config.WithEndpointResolverWithOptions(customResolver))
+	}
+
+	if profile := os.Getenv("AWS_PROFILE"); len(profile) > 0 {
+		loadOptions = append(loadOptions, aws_config.WithSharedConfigProfile(profile))
+	}
+
+	return aws_config.LoadDefaultConfig(ctx, loadOptions...)
+}
+
+// NewS3Blobstore connects to the bucket in AWS S3 or, if conf.S3URL is set, in the S3-compatible object store at that URL
+func NewS3Blobstore(ctx context.Context, conf *config.Options) (*S3, error) {
+	awsConf, err := createAwsConfig(ctx, conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS config for S3: %w", err)
+	}
+	client := aws_s3.NewFromConfig(awsConf, func(o *aws_s3.Options) {
+		// Object stores other than AWS S3 seldom support virtual-hosted-style bucket addressing
+		o.UsePathStyle = len(conf.S3URL) > 0
+	})
+	return &S3{Bucket: conf.S3Bucket, region: awsConf.Region, client: client}, nil
+}
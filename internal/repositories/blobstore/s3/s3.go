@@ -0,0 +1,402 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/repositories/blobstore/git"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_s3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/rs/zerolog"
+)
+
+// S3 stores the iconfiles as objects in an S3-compatible bucket. Object keys are the paths of the iconfiles
+// in the git repository. With bucket versioning enabled, every write keeps the previous version of the object.
+type S3 struct {
+	Bucket string
+	region string
+	client *aws_s3.Client
+}
+
+// modifiedByMetadataKey is the user-defined object metadata recording who wrote the version of the object
+const modifiedByMetadataKey = "modified-by"
+
+// nullVersionId is the version ID S3 reports for objects written while versioning is not enabled
+const nullVersionId = "null"
+
+func (repo *S3) String() string {
+	return fmt.Sprintf("S3 bucket %s", repo.Bucket)
+}
+
+func iconfileKey(iconName string, iconfileDesc domain.IconfileDescriptor) string {
+	return filepath.ToSlash(git.NewGitFilePaths("").GetPathToIconfileInRepo(iconName, iconfileDesc))
+}
+
+func iconMetadataKey(iconName string) string {
+	return filepath.ToSlash(git.NewGitFilePaths("").GetAbsolutePathToIconMetadata(iconName))
+}
+
+func isNotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	var noSuchBucket *types.NoSuchBucket
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) || errors.As(err, &noSuchBucket) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotFound", "NoSuchKey", "NoSuchBucket", "NoSuchVersion":
+			return true
+		}
+	}
+	return false
+}
+
+// CreateRepository creates the bucket unless it exists and enables versioning on it where the object store supports it
+func (repo *S3) CreateRepository(ctx context.Context) error {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "s3-blobstore").Str("bucket", repo.Bucket).Logger()
+
+	_, headErr := repo.client.HeadBucket(ctx, &aws_s3.HeadBucketInput{Bucket: &repo.Bucket})
+	if headErr != nil {
+		if !isNotFound(headErr) {
+			return fmt.Errorf("failed to check bucket %s: %w", repo.Bucket, headErr)
+		}
+		createInput := &aws_s3.CreateBucketInput{Bucket: &repo.Bucket}
+		// us-east-1 is the default location and must not be specified
+		if len(repo.region) > 0 && repo.region != "us-east-1" {
+			createInput.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+				LocationConstraint: types.BucketLocationConstraint(repo.region),
+			}
+		}
+		_, createErr := repo.client.CreateBucket(ctx, createInput)
+		if createErr != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", repo.Bucket, createErr)
+		}
+		logger.Info().Msg("bucket created")
+	}
+
+	versioning, getVersioningErr := repo.client.GetBucketVersioning(ctx, &aws_s3.GetBucketVersioningInput{Bucket: &repo.Bucket})
+	if getVersioningErr == nil && versioning.Status == types.BucketVersioningStatusEnabled {
+		return nil
+	}
+	_, putVersioningErr := repo.client.PutBucketVersioning(ctx, &aws_s3.PutBucketVersioningInput{
+		Bucket:                  &repo.Bucket,
+		VersioningConfiguration: &types.VersioningConfiguration{Status: types.BucketVersioningStatusEnabled},
+	})
+	if putVersioningErr != nil {
+		logger.Warn().Err(putVersioningErr).Msg("failed to enable versioning: no history will be kept")
+	}
+	return nil
+}
+
+func (repo *S3) putObject(ctx context.Context, key string, content []byte, modifiedBy string) error {
+	_, err := repo.client.PutObject(ctx, &aws_s3.PutObjectInput{
+		Bucket:   &repo.Bucket,
+		Key:      &key,
+		Body:     bytes.NewReader(content),
+		Metadata: map[string]string{modifiedByMetadataKey: modifiedBy},
+	})
+	return err
+}
+
+// getObject returns nil if the object doesn't exist
+func (repo *S3) getObject(ctx context.Context, key string) ([]byte, error) {
+	out, getErr := repo.client.GetObject(ctx, &aws_s3.GetObjectInput{Bucket: &repo.Bucket, Key: &key})
+	if getErr != nil {
+		if isNotFound(getErr) {
+			return nil, nil
+		}
+		return nil, getErr
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+func (repo *S3) objectExists(ctx context.Context, key string) (bool, error) {
+	_, headErr := repo.client.HeadObject(ctx, &aws_s3.HeadObjectInput{Bucket: &repo.Bucket, Key: &key})
+	if headErr != nil {
+		if isNotFound(headErr) {
+			return false, nil
+		}
+		return false, headErr
+	}
+	return true, nil
+}
+
+func (repo *S3) AddIconfile(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy string) error {
+	putErr := repo.putObject(ctx, iconfileKey(iconName, iconfile.IconfileDescriptor), iconfile.Content, modifiedBy)
+	if putErr != nil {
+		return fmt.Errorf("failed to add iconfile %v for %s to %s: %w", iconfile.IconfileDescriptor, iconName, repo, putErr)
+	}
+	return nil
+}
+
+func (repo *S3) GetIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) ([]byte, error) {
+	content, getErr := repo.getObject(ctx, iconfileKey(iconName, iconfileDesc))
+	if getErr != nil {
+		return nil, fmt.Errorf("failed to read iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, getErr)
+	}
+	if content == nil {
+		return nil, fmt.Errorf("failed to read iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, domain.ErrIconfileNotFound)
+	}
+	return content, nil
+}
+
+func (repo *S3) DeleteIcon(ctx context.Context, iconDesc domain.IconDescriptor, modifiedBy authn.UserID) error {
+	for _, iconfileDesc := range iconDesc.Iconfiles {
+		if deleteErr := repo.DeleteIconfile(ctx, iconDesc.Name, iconfileDesc, modifiedBy); deleteErr != nil {
+			return fmt.Errorf("failed to remove icon %s from %s: %w", iconDesc.Name, repo, deleteErr)
+		}
+	}
+	metadataKey := iconMetadataKey(iconDesc.Name)
+	_, deleteErr := repo.client.DeleteObject(ctx, &aws_s3.DeleteObjectInput{Bucket: &repo.Bucket, Key: &metadataKey})
+	if deleteErr != nil && !isNotFound(deleteErr) {
+		return fmt.Errorf("failed to remove metadata of icon %s from %s: %w", iconDesc.Name, repo, deleteErr)
+	}
+	return nil
+}
+
+func (repo *S3) DeleteIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor, modifiedBy authn.UserID) error {
+	key := iconfileKey(iconName, iconfileDesc)
+	// Deleting a missing key succeeds in S3
+	exists, headErr := repo.objectExists(ctx, key)
+	if headErr != nil {
+		return fmt.Errorf("failed to check iconfile %v of %s in %s: %w", iconfileDesc, iconName, repo, headErr)
+	}
+	if !exists {
+		return fmt.Errorf("failed to remove iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, domain.ErrIconfileNotFound)
+	}
+	_, deleteErr := repo.client.DeleteObject(ctx, &aws_s3.DeleteObjectInput{Bucket: &repo.Bucket, Key: &key})
+	if deleteErr != nil {
+		return fmt.Errorf("failed to remove iconfile %v of %s from %s: %w", iconfileDesc, iconName, repo, deleteErr)
+	}
+	return nil
+}
+
+func (repo *S3) listObjects(ctx context.Context) ([]types.Object, error) {
+	objects := []types.Object{}
+	paginator := aws_s3.NewListObjectsV2Paginator(repo.client, &aws_s3.ListObjectsV2Input{Bucket: &repo.Bucket})
+	for paginator.HasMorePages() {
+		page, pageErr := paginator.NextPage(ctx)
+		if pageErr != nil {
+			return nil, pageErr
+		}
+		objects = append(objects, page.Contents...)
+	}
+	return objects, nil
+}
+
+// GetIconfiles lists the keys in the bucket, except those of the metadata of icons
+func (repo *S3) GetIconfiles(ctx context.Context) ([]string, error) {
+	objects, listErr := repo.listObjects(ctx)
+	if listErr != nil {
+		return nil, fmt.Errorf("failed to list objects in %s: %w", repo, listErr)
+	}
+	fileList := []string{}
+	for _, object := range objects {
+		if !git.IsIconMetadataPath(*object.Key) {
+			fileList = append(fileList, *object.Key)
+		}
+	}
+	sort.Strings(fileList)
+	return fileList, nil
+}
+
+func (repo *S3) UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error {
+	content, marshalErr := git.MarshalIconMetadata(metadata)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal metadata of icon %s: %w", iconName, marshalErr)
+	}
+	key := iconMetadataKey(iconName)
+	current, getErr := repo.getObject(ctx, key)
+	if getErr != nil {
+		return fmt.Errorf("failed to read metadata of icon %s from %s: %w", iconName, repo, getErr)
+	}
+	// Spare a new version if the metadata hasn't changed
+	if bytes.Equal(current, content) {
+		return nil
+	}
+	if putErr := repo.putObject(ctx, key, content, modifiedBy); putErr != nil {
+		return fmt.Errorf("failed to update metadata of icon %s in %s: %w", iconName, repo, putErr)
+	}
+	return nil
+}
+
+func (repo *S3) GetIconMetadata(ctx context.Context, iconName string) (domain.IconMetadata, error) {
+	content, getErr := repo.getObject(ctx, iconMetadataKey(iconName))
+	if getErr != nil {
+		return domain.IconMetadata{}, fmt.Errorf("failed to read metadata of icon %s from %s: %w", iconName, repo, getErr)
+	}
+	if content == nil {
+		return domain.IconMetadata{}, fmt.Errorf("no metadata for icon %s: %w", iconName, domain.ErrIconMetadataNotFound)
+	}
+	metadata, unmarshalErr := git.UnmarshalIconMetadata(content)
+	if unmarshalErr != nil {
+		return domain.IconMetadata{}, fmt.Errorf("failed to unmarshal metadata of icon %s: %w", iconName, unmarshalErr)
+	}
+	return metadata, nil
+}
+
+// GetIconfileHistory returns the versions of the iconfile, newest first, including those of deleted iconfiles.
+// Without bucket versioning, only the current version is returned.
+func (repo *S3) GetIconfileHistory(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) ([]git.CommitMetadata, error) {
+	key := iconfileKey(iconName, iconfileDesc)
+	versionIds := []string{}
+	paginator := aws_s3.NewListObjectVersionsPaginator(repo.client, &aws_s3.ListObjectVersionsInput{Bucket: &repo.Bucket, Prefix: &key})
+	for paginator.HasMorePages() {
+		page, pageErr := paginator.NextPage(ctx)
+		if pageErr != nil {
+			return nil, fmt.Errorf("failed to list versions of iconfile %v of %s in %s: %w", iconfileDesc, iconName, repo, pageErr)
+		}
+		for _, version := range page.Versions {
+			if *version.Key == key {
+				versionIds = append(versionIds, aws.ToString(version.VersionId))
+			}
+		}
+	}
+
+	history := []git.CommitMetadata{}
+	for _, versionId := range versionIds {
+		metadata, metadataErr := repo.GetVersionMetadata(ctx, encodeVersion(key, versionId))
+		if metadataErr != nil {
+			return nil, metadataErr
+		}
+		history = append(history, metadata)
+	}
+	sort.SliceStable(history, func(i, j int) bool { return history[i].CommitDate.After(history[j].CommitDate) })
+	return history, nil
+}
+
+// ResetRepository removes every object (and every version of it) from the bucket
+func (repo *S3) ResetRepository(ctx context.Context) error {
+	paginator := aws_s3.NewListObjectVersionsPaginator(repo.client, &aws_s3.ListObjectVersionsInput{Bucket: &repo.Bucket})
+	for paginator.HasMorePages() {
+		page, pageErr := paginator.NextPage(ctx)
+		if pageErr != nil {
+			if isNotFound(pageErr) {
+				return nil
+			}
+			return fmt.Errorf("failed to list object versions in %s: %w", repo, pageErr)
+		}
+		objectIds := []types.ObjectIdentifier{}
+		for _, version := range page.Versions {
+			objectIds = append(objectIds, types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+		}
+		for _, marker := range page.DeleteMarkers {
+			objectIds = append(objectIds, types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+		}
+		if len(objectIds) == 0 {
+			continue
+		}
+		_, deleteErr := repo.client.DeleteObjects(ctx, &aws_s3.DeleteObjectsInput{
+			Bucket: &repo.Bucket,
+			Delete: &types.Delete{Objects: objectIds, Quiet: true},
+		})
+		if deleteErr != nil {
+			return fmt.Errorf("failed to delete objects in %s: %w", repo, deleteErr)
+		}
+	}
+	return nil
+}
+
+func (repo *S3) DeleteRepository(ctx context.Context) error {
+	if resetErr := repo.ResetRepository(ctx); resetErr != nil {
+		return resetErr
+	}
+	_, deleteErr := repo.client.DeleteBucket(ctx, &aws_s3.DeleteBucketInput{Bucket: &repo.Bucket})
+	if deleteErr != nil && !isNotFound(deleteErr) {
+		return fmt.Errorf("failed to delete bucket %s: %w", repo.Bucket, deleteErr)
+	}
+	return nil
+}
+
+// CheckStatus always reports a clean state: objects are written in a single request, so no write can be left half done
+func (repo *S3) CheckStatus() (bool, error) {
+	return true, nil
+}
+
+// GetStateID returns a digest of the keys, ETags and modification times of the objects in the bucket
+func (repo *S3) GetStateID(ctx context.Context) (string, error) {
+	objects, listErr := repo.listObjects(ctx)
+	if listErr != nil {
+		return "", fmt.Errorf("failed to get state of %s: %w", repo, listErr)
+	}
+	entries := []string{}
+	for _, object := range objects {
+		entries = append(entries, fmt.Sprintf("%s %s %d", *object.Key, aws.ToString(object.ETag), aws.ToTime(object.LastModified).UnixNano()))
+	}
+	sort.Strings(entries)
+	digest := sha1.Sum([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// A version returned by GetVersionFor identifies both the object and its version: <key>#<version ID>
+const versionSeparator = "#"
+
+func encodeVersion(key string, versionId string) string {
+	if len(versionId) == 0 {
+		versionId = nullVersionId
+	}
+	return key + versionSeparator + versionId
+}
+
+func decodeVersion(version string) (string, string, error) {
+	separatorIndex := strings.LastIndex(version, versionSeparator)
+	if separatorIndex < 0 {
+		return "", "", fmt.Errorf("malformed version: %s", version)
+	}
+	return version[:separatorIndex], version[separatorIndex+1:], nil
+}
+
+// GetVersionFor returns the current version of the iconfile.
+// Returns empty string in case the iconfile doesn't exist in the bucket
+func (repo *S3) GetVersionFor(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor) (string, error) {
+	key := iconfileKey(iconName, iconfileDesc)
+	out, headErr := repo.client.HeadObject(ctx, &aws_s3.HeadObjectInput{Bucket: &repo.Bucket, Key: &key})
+	if headErr != nil {
+		if isNotFound(headErr) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get version of %s::%s: %w", iconName, iconfileDesc.String(), headErr)
+	}
+	return encodeVersion(key, aws.ToString(out.VersionId)), nil
+}
+
+// GetVersionMetadata describes a version returned by GetVersionFor in the shape of git commit metadata
+func (repo *S3) GetVersionMetadata(ctx context.Context, version string) (git.CommitMetadata, error) {
+	key, versionId, decodeErr := decodeVersion(version)
+	if decodeErr != nil {
+		return git.CommitMetadata{}, decodeErr
+	}
+	// Not HeadObject: some S3-compatible stores ignore the version ID in HEAD requests
+	getInput := &aws_s3.GetObjectInput{Bucket: &repo.Bucket, Key: &key}
+	if versionId != nullVersionId {
+		getInput.VersionId = &versionId
+	}
+	out, getErr := repo.client.GetObject(ctx, getInput)
+	if getErr != nil {
+		return git.CommitMetadata{}, fmt.Errorf("failed to get metadata of version %s: %w", version, getErr)
+	}
+	out.Body.Close()
+	lastModified := aws.ToTime(out.LastModified)
+	return git.CommitMetadata{
+		Author:     out.Metadata[modifiedByMetadataKey],
+		AuthorDate: lastModified,
+		Commit:     version,
+		CommitDate: lastModified,
+	}, nil
+}
@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"sync"
+	"time"
+)
+
+// Scheduler runs the writes to a blobstore. Each write holds a set of keys, e.g. the name of the icon it changes:
+// writes with a key in common run one at a time, in the order they were scheduled, while the others run in parallel.
+// A write without keys holds the whole blobstore.
+type Scheduler struct {
+	name string
+	// timeout is the deadline of each write, including the time spent waiting for its turn; zero means none
+	timeout time.Duration
+
+	mutex     sync.Mutex
+	waiting   []*write
+	running   int
+	heldKeys  map[string]int
+	exclusive bool
+	stats     domain.WriteQueueStats
+}
+
+type write struct {
+	keys    []string
+	granted chan struct{}
+}
+
+// NewScheduler creates a scheduler for the blobstore named; timeout is the deadline of each write, zero means none
+func NewScheduler(name string, timeout time.Duration) *Scheduler {
+	return &Scheduler{
+		name:     name,
+		timeout:  timeout,
+		heldKeys: map[string]int{},
+	}
+}
+
+// Run runs the job once the writes scheduled earlier with any of the keys have completed.
+// The job gets a context bound by the deadline of the write; a job still waiting for its turn gives up when the context is done.
+func (s *Scheduler) Run(ctx context.Context, keys []string, job func(ctx context.Context) error) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	w := &write{keys: keys, granted: make(chan struct{})}
+	s.mutex.Lock()
+	s.waiting = append(s.waiting, w)
+	s.stats.MaxQueued = max(s.stats.MaxQueued, len(s.waiting))
+	s.dispatch()
+	s.mutex.Unlock()
+
+	select {
+	case <-w.granted:
+	case <-ctx.Done():
+		if s.giveUp(w, ctx.Err()) {
+			return fmt.Errorf("gave up waiting for earlier writes to %s: %w", s.name, ctx.Err())
+		}
+	}
+
+	jobErr := job(ctx)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.release(w)
+	switch {
+	case jobErr == nil:
+		s.stats.Completed++
+	case errors.Is(jobErr, context.DeadlineExceeded):
+		s.stats.TimedOut++
+	default:
+		s.stats.Failed++
+	}
+	s.dispatch()
+	return jobErr
+}
+
+// giveUp takes the write off the queue. It returns false if the write has been granted its turn meanwhile.
+func (s *Scheduler) giveUp(w *write, reason error) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-w.granted:
+		return false
+	default:
+	}
+	for i, waiting := range s.waiting {
+		if waiting == w {
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			break
+		}
+	}
+	if errors.Is(reason, context.DeadlineExceeded) {
+		s.stats.TimedOut++
+	} else {
+		s.stats.Cancelled++
+	}
+	// The writes queued behind this one may be free to go now
+	s.dispatch()
+	return true
+}
+
+// Stats reports on the writes queued, running and done
+func (s *Scheduler) Stats() domain.WriteQueueStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats := s.stats
+	stats.Blobstore = s.name
+	stats.Queued = len(s.waiting)
+	stats.Running = s.running
+	return stats
+}
+
+// dispatch starts the waiting writes which conflict neither with a running write nor with a write queued before them
+func (s *Scheduler) dispatch() {
+	stillWaiting := s.waiting[:0]
+	for _, w := range s.waiting {
+		if s.conflictsWithRunning(w) || conflictsWithAny(w, stillWaiting) {
+			stillWaiting = append(stillWaiting, w)
+			continue
+		}
+		s.acquire(w)
+		close(w.granted)
+	}
+	for i := len(stillWaiting); i < len(s.waiting); i++ {
+		s.waiting[i] = nil
+	}
+	s.waiting = stillWaiting
+}
+
+func (s *Scheduler) conflictsWithRunning(w *write) bool {
+	if s.exclusive {
+		return true
+	}
+	if len(w.keys) == 0 {
+		return s.running > 0
+	}
+	for _, key := range w.keys {
+		if s.heldKeys[key] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scheduler) acquire(w *write) {
+	s.running++
+	if len(w.keys) == 0 {
+		s.exclusive = true
+	}
+	for _, key := range w.keys {
+		s.heldKeys[key]++
+	}
+}
+
+func (s *Scheduler) release(w *write) {
+	s.running--
+	if len(w.keys) == 0 {
+		s.exclusive = false
+	}
+	for _, key := range w.keys {
+		if s.heldKeys[key]--; s.heldKeys[key] == 0 {
+			delete(s.heldKeys, key)
+		}
+	}
+}
+
+func conflictsWithAny(w *write, others []*write) bool {
+	for _, other := range others {
+		if conflicts(w, other) {
+			return true
+		}
+	}
+	return false
+}
+
+func conflicts(a *write, b *write) bool {
+	if len(a.keys) == 0 || len(b.keys) == 0 {
+		return true
+	}
+	for _, aKey := range a.keys {
+		for _, bKey := range b.keys {
+			if aKey == bKey {
+				return true
+			}
+		}
+	}
+	return false
+}
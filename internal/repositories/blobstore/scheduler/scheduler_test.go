@@ -0,0 +1,161 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type schedulerTestSuite struct {
+	suite.Suite
+	scheduler *Scheduler
+	ctx       context.Context
+}
+
+func TestSchedulerTestSuite(t *testing.T) {
+	suite.Run(t, &schedulerTestSuite{})
+}
+
+func (s *schedulerTestSuite) BeforeTest(suiteName string, testName string) {
+	s.scheduler = NewScheduler("test blobstore", 0)
+	s.ctx = context.Background()
+}
+
+// start runs the job in the background; the returned channel gets the result of the write
+func (s *schedulerTestSuite) start(ctx context.Context, keys []string, job func(ctx context.Context) error) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.scheduler.Run(ctx, keys, job)
+	}()
+	return done
+}
+
+// blockingJob returns a job which signals when started and returns when released
+func blockingJob() (func(ctx context.Context) error, chan struct{}, chan struct{}) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	return func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}, started, release
+}
+
+func (s *schedulerTestSuite) waitForQueued(queued int) {
+	s.Eventually(func() bool { return s.scheduler.Stats().Queued == queued }, time.Second, time.Millisecond)
+}
+
+func (s *schedulerTestSuite) TestWritesWithDifferentKeysRunInParallel() {
+	job1, started1, release1 := blockingJob()
+	job2, started2, release2 := blockingJob()
+
+	done1 := s.start(s.ctx, []string{"cartouche"}, job1)
+	<-started1
+	done2 := s.start(s.ctx, []string{"zazie"}, job2)
+	<-started2
+
+	s.Equal(2, s.scheduler.Stats().Running)
+	close(release1)
+	close(release2)
+	s.NoError(<-done1)
+	s.NoError(<-done2)
+	s.Equal(uint64(2), s.scheduler.Stats().Completed)
+}
+
+func (s *schedulerTestSuite) TestWritesWithCommonKeyRunInOrder() {
+	job1, started1, release1 := blockingJob()
+	done1 := s.start(s.ctx, []string{"cartouche", "zazie"}, job1)
+	<-started1
+
+	order := make(chan int, 2)
+	done2 := s.start(s.ctx, []string{"zazie"}, func(ctx context.Context) error {
+		order <- 2
+		return nil
+	})
+	s.waitForQueued(1)
+	done3 := s.start(s.ctx, []string{"zazie"}, func(ctx context.Context) error {
+		order <- 3
+		return nil
+	})
+	s.waitForQueued(2)
+	s.Equal(2, s.scheduler.Stats().MaxQueued)
+
+	close(release1)
+	s.NoError(<-done1)
+	s.NoError(<-done2)
+	s.NoError(<-done3)
+	s.Equal(2, <-order)
+	s.Equal(3, <-order)
+}
+
+func (s *schedulerTestSuite) TestWriteWithoutKeysHoldsEverything() {
+	job1, started1, release1 := blockingJob()
+	done1 := s.start(s.ctx, nil, job1)
+	<-started1
+
+	ran := false
+	done2 := s.start(s.ctx, []string{"cartouche"}, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	s.waitForQueued(1)
+	s.False(ran)
+
+	close(release1)
+	s.NoError(<-done1)
+	s.NoError(<-done2)
+	s.True(ran)
+}
+
+func (s *schedulerTestSuite) TestWaitingWriteIsCancelled() {
+	job1, started1, release1 := blockingJob()
+	done1 := s.start(s.ctx, nil, job1)
+	<-started1
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	ran := false
+	done2 := s.start(ctx, nil, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	s.waitForQueued(1)
+	cancel()
+	s.ErrorIs(<-done2, context.Canceled)
+	s.waitForQueued(0)
+
+	close(release1)
+	s.NoError(<-done1)
+	s.False(ran)
+	s.Equal(uint64(1), s.scheduler.Stats().Cancelled)
+}
+
+func (s *schedulerTestSuite) TestDeadlineIncludesWaiting() {
+	s.scheduler = NewScheduler("test blobstore", 50*time.Millisecond)
+	job1, started1, release1 := blockingJob()
+	done1 := s.start(s.ctx, nil, job1)
+	<-started1
+
+	done2 := s.start(s.ctx, nil, func(ctx context.Context) error {
+		return nil
+	})
+	s.ErrorIs(<-done2, context.DeadlineExceeded)
+
+	close(release1)
+	s.NoError(<-done1)
+	s.Equal(uint64(1), s.scheduler.Stats().TimedOut)
+}
+
+func (s *schedulerTestSuite) TestFailedWriteReleasesKeys() {
+	jobErr := errors.New("procyon lotor")
+	s.ErrorIs(s.scheduler.Run(s.ctx, []string{"cartouche"}, func(ctx context.Context) error { return jobErr }), jobErr)
+	s.NoError(s.scheduler.Run(s.ctx, []string{"cartouche"}, func(ctx context.Context) error { return nil }))
+
+	stats := s.scheduler.Stats()
+	s.Equal("test blobstore", stats.Blobstore)
+	s.Equal(uint64(1), stats.Failed)
+	s.Equal(uint64(1), stats.Completed)
+	s.Equal(0, stats.Running)
+}
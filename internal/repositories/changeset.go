@@ -0,0 +1,290 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/repositories/indexing"
+	"slices"
+
+	"github.com/rs/zerolog"
+)
+
+// changesetIcon follows an icon through the operations of a changeset
+type changesetIcon struct {
+	before *domain.IconDescriptor
+	after  *domain.IconDescriptor
+	// added are the iconfiles added by the changeset, the last one added wins
+	added []domain.Iconfile
+}
+
+// ApplyChangeset applies the operations, in order, in a single index transaction along with the changes to the blobstore:
+// either all of them succeed or none. Blobstores implementing ChangesetBlobstore make all the changes at once, e.g. in a single commit;
+// the changes made one by one to the other blobstores are undone should one of them fail.
+// It returns the icons changed which still exist.
+func (combo *RepoCombo) ApplyChangeset(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy authr.UserInfo) ([]domain.IconDescriptor, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no operation in changeset: %w", domain.ErrInvalidChangeset)
+	}
+	if combo.mergeRequests() != nil {
+		return nil, fmt.Errorf("iconfiles are proposed in merge requests one by one: %w", domain.ErrInvalidChangeset)
+	}
+	user := modifiedBy.UserId.String()
+
+	// The operations are tried on the current state of the icons first, to know what to change in the blobstore
+	iconNames := []string{}
+	icons := map[string]*changesetIcon{}
+	auditBefore := make([]*domain.IconDescriptor, len(ops))
+	for i, op := range ops {
+		icon, seen := icons[op.IconName]
+		if !seen {
+			current, describeErr := combo.Index.DescribeIcon(ctx, op.IconName)
+			icon = &changesetIcon{}
+			if describeErr == nil {
+				icon.before = &current
+			} else if !errors.Is(describeErr, domain.ErrIconNotFound) {
+				return nil, fmt.Errorf("failed to have icon \"%s\" of changeset described: %w", op.IconName, describeErr)
+			}
+			icon.after = icon.before
+			icons[op.IconName] = icon
+			iconNames = append(iconNames, op.IconName)
+		}
+		auditBefore[i] = icon.after
+		var applyErr error
+		icon.after, applyErr = op.ApplyTo(icon.after, user)
+		if applyErr != nil {
+			return nil, applyErr
+		}
+		if op.Action == domain.AuditActionCreateIcon || op.Action == domain.AuditActionAddIconfile {
+			icon.added = slices.DeleteFunc(icon.added, func(added domain.Iconfile) bool { return added.Equals(op.Iconfile.IconfileDescriptor) })
+			icon.added = append(icon.added, op.Iconfile)
+		}
+	}
+
+	changes := []domain.BlobstoreChange{}
+	sortedIconNames := slices.Clone(iconNames)
+	slices.Sort(sortedIconNames)
+	for _, iconName := range sortedIconNames {
+		changes = append(changes, blobstoreChanges(iconName, icons[iconName])...)
+	}
+
+	auditEntries := []domain.AuditEntry{}
+	for i, op := range ops {
+		entry := domain.AuditEntry{
+			Actor:    user,
+			Action:   op.Action,
+			IconName: op.IconName,
+			Tag:      op.Tag,
+			Before:   auditBefore[i],
+		}
+		if op.Action != domain.AuditActionDeleteIcon && len(op.Iconfile.Format) > 0 {
+			entry.Iconfile = &op.Iconfile.IconfileDescriptor
+		}
+		auditEntries = append(auditEntries, entry)
+	}
+
+	// An icon may be changed in the index without any net change to the blobstore
+	defer combo.refreshCatalog(ctx, iconNames)
+	err := combo.writeThroughOutbox(ctx, user, changes, func(sideEffect func(ctx context.Context) error) error {
+		return combo.Index.ApplyChangeset(ctx, ops, user, sideEffect)
+	}, combo.audited(func(ctx context.Context) error {
+		return combo.applyBlobstoreChanges(ctx, withCommittedMetadata(ctx, changes), modifiedBy)
+	}, auditEntries...))
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []domain.IconDescriptor{}
+	for _, iconName := range iconNames {
+		if icons[iconName].after == nil {
+			continue
+		}
+		iconDesc, describeErr := combo.Index.DescribeIcon(ctx, iconName)
+		if describeErr != nil {
+			return nil, fmt.Errorf("failed to have icon \"%s\" described after applying changeset: %w", iconName, describeErr)
+		}
+		changed = append(changed, iconDesc)
+	}
+	return changed, nil
+}
+
+// blobstoreChanges returns the net changes the changeset makes to the icon in the blobstore
+func blobstoreChanges(iconName string, icon *changesetIcon) []domain.BlobstoreChange {
+	if icon.after == nil {
+		if icon.before == nil {
+			return nil
+		}
+		return []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeDeleteIcon, IconName: iconName, Icon: *icon.before}}
+	}
+
+	changes := []domain.BlobstoreChange{}
+	beforeIconfiles := []domain.IconfileDescriptor{}
+	if icon.before != nil {
+		beforeIconfiles = icon.before.Iconfiles
+	}
+	for _, iconfile := range beforeIconfiles {
+		if !slices.ContainsFunc(icon.after.Iconfiles, iconfile.Equals) {
+			changes = append(changes, domain.BlobstoreChange{
+				Kind:     domain.BlobstoreChangeDeleteIconfile,
+				IconName: iconName,
+				Iconfile: domain.Iconfile{IconfileDescriptor: iconfile},
+			})
+		}
+	}
+	for _, iconfile := range icon.added {
+		if !slices.ContainsFunc(icon.after.Iconfiles, iconfile.Equals) {
+			continue
+		}
+		changes = append(changes, domain.BlobstoreChange{
+			Kind:     domain.BlobstoreChangeAddIconfile,
+			IconName: iconName,
+			Iconfile: iconfile,
+			Replace:  slices.ContainsFunc(beforeIconfiles, iconfile.Equals),
+		})
+	}
+
+	// The metadata is filled in from the state the index commits
+	changes = append(changes, domain.BlobstoreChange{Kind: domain.BlobstoreChangeUpdateMetadata, IconName: iconName})
+	return changes
+}
+
+// withCommittedMetadata fills the changes to the metadata of the icons in with the state the index is committing,
+// which is handed over to the side-effect, so that a change made concurrently to an icon isn't lost in its metadata.
+// The metadata of an icon removed from the index goes along with the icon.
+func withCommittedMetadata(ctx context.Context, changes []domain.BlobstoreChange) []domain.BlobstoreChange {
+	completed := []domain.BlobstoreChange{}
+	for _, change := range changes {
+		if change.Kind != domain.BlobstoreChangeUpdateMetadata {
+			completed = append(completed, change)
+		}
+	}
+	for _, iconName := range changedIconNames(changes) {
+		icon, found := indexing.CommittedIcon(ctx, iconName)
+		if !found {
+			continue
+		}
+		if len(icon.Iconfiles) > 0 {
+			completed = append(completed, domain.BlobstoreChange{Kind: domain.BlobstoreChangeUpdateMetadata, IconName: iconName, Metadata: icon.Metadata()})
+			continue
+		}
+		iconDeleted := slices.ContainsFunc(changes, func(change domain.BlobstoreChange) bool {
+			return change.Kind == domain.BlobstoreChangeDeleteIcon && change.IconName == iconName
+		})
+		if !iconDeleted {
+			completed = append(completed, domain.BlobstoreChange{Kind: domain.BlobstoreChangeDeleteIcon, IconName: iconName, Icon: icon})
+		}
+	}
+	return completed
+}
+
+// applyBlobstoreChanges makes the changes to the blobstore, all at once if it implements ChangesetBlobstore, otherwise one by one:
+// the changes made are then undone, the last one first, should one of them fail.
+func (combo *RepoCombo) applyBlobstoreChanges(ctx context.Context, changes []domain.BlobstoreChange, modifiedBy authr.UserInfo) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	// Iconfiles proposed in merge requests are added on branches of their own
+	if changesetBlobstore, ok := combo.Blobstore.(ChangesetBlobstore); ok && combo.mergeRequests() == nil {
+		return changesetBlobstore.ApplyChangeset(ctx, changes, modifiedBy.UserId.String())
+	}
+
+	undos := []domain.BlobstoreChange{}
+	for _, change := range changes {
+		changeUndos, undoErr := combo.undoBlobstoreChange(ctx, change)
+		if undoErr == nil {
+			undoErr = combo.applyBlobstoreChange(ctx, change, modifiedBy)
+		}
+		if undoErr != nil {
+			combo.undoBlobstoreChanges(ctx, undos, modifiedBy)
+			return fmt.Errorf("failed to %s: %w", change, undoErr)
+		}
+		undos = append(undos, changeUndos...)
+	}
+	return nil
+}
+
+func (combo *RepoCombo) applyBlobstoreChange(ctx context.Context, change domain.BlobstoreChange, modifiedBy authr.UserInfo) error {
+	switch change.Kind {
+	case domain.BlobstoreChangeAddIconfile:
+		return combo.Blobstore.AddIconfile(ctx, change.IconName, change.Iconfile, modifiedBy.UserId.String())
+	case domain.BlobstoreChangeDeleteIconfile:
+		return combo.Blobstore.DeleteIconfile(ctx, change.IconName, change.Iconfile.IconfileDescriptor, modifiedBy.UserId)
+	case domain.BlobstoreChangeUpdateMetadata:
+		return combo.Blobstore.UpdateIconMetadata(ctx, change.IconName, change.Metadata, modifiedBy.UserId.String())
+	case domain.BlobstoreChangeDeleteIcon:
+		return combo.Blobstore.DeleteIcon(ctx, change.Icon, modifiedBy.UserId)
+	default:
+		return fmt.Errorf("unknown blobstore change %s: %w", change.Kind, domain.ErrInvalidChangeset)
+	}
+}
+
+// undoBlobstoreChange returns the changes restoring what the change is about to overwrite in the blobstore
+func (combo *RepoCombo) undoBlobstoreChange(ctx context.Context, change domain.BlobstoreChange) ([]domain.BlobstoreChange, error) {
+	switch change.Kind {
+	case domain.BlobstoreChangeAddIconfile:
+		if change.Replace {
+			return combo.restoreIconfile(ctx, change.IconName, change.Iconfile.IconfileDescriptor)
+		}
+		return []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeDeleteIconfile, IconName: change.IconName, Iconfile: change.Iconfile}}, nil
+	case domain.BlobstoreChangeDeleteIconfile:
+		return combo.restoreIconfile(ctx, change.IconName, change.Iconfile.IconfileDescriptor)
+	case domain.BlobstoreChangeUpdateMetadata:
+		return combo.restoreIconMetadata(ctx, change.IconName)
+	case domain.BlobstoreChangeDeleteIcon:
+		undos, getErr := combo.restoreIconMetadata(ctx, change.IconName)
+		if getErr != nil {
+			return nil, getErr
+		}
+		for _, iconfile := range change.Icon.Iconfiles {
+			undo, getErr := combo.restoreIconfile(ctx, change.IconName, iconfile)
+			if getErr != nil {
+				return nil, getErr
+			}
+			undos = append(undos, undo...)
+		}
+		return undos, nil
+	default:
+		return nil, nil
+	}
+}
+
+// restoreIconfile returns the change writing the iconfile back as it is, if it is in the blobstore
+func (combo *RepoCombo) restoreIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor) ([]domain.BlobstoreChange, error) {
+	content, getErr := combo.Blobstore.GetIconfile(ctx, iconName, iconfile)
+	if errors.Is(getErr, domain.ErrIconfileNotFound) {
+		return nil, nil
+	}
+	if getErr != nil {
+		return nil, fmt.Errorf("failed to read iconfile %v of \"%s\" to be able to restore it: %w", iconfile, iconName, getErr)
+	}
+	return []domain.BlobstoreChange{{
+		Kind:     domain.BlobstoreChangeAddIconfile,
+		IconName: iconName,
+		Iconfile: domain.Iconfile{IconfileDescriptor: iconfile, Content: content},
+		Replace:  true,
+	}}, nil
+}
+
+// restoreIconMetadata returns the change writing the metadata of the icon back as it is, or removing it if there is none
+func (combo *RepoCombo) restoreIconMetadata(ctx context.Context, iconName string) ([]domain.BlobstoreChange, error) {
+	metadata, getErr := combo.Blobstore.GetIconMetadata(ctx, iconName)
+	if errors.Is(getErr, domain.ErrIconMetadataNotFound) {
+		// Deleting an icon without iconfiles deletes its metadata only
+		return []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeDeleteIcon, IconName: iconName, Icon: domain.IconDescriptor{IconAttributes: domain.IconAttributes{Name: iconName}}}}, nil
+	}
+	if getErr != nil {
+		return nil, fmt.Errorf("failed to read metadata of icon \"%s\" to be able to restore it: %w", iconName, getErr)
+	}
+	return []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeUpdateMetadata, IconName: iconName, Metadata: metadata}}, nil
+}
+
+// undoBlobstoreChanges makes the changes in reverse order. Changes failing to be undone are left for the outbox to settle.
+func (combo *RepoCombo) undoBlobstoreChanges(ctx context.Context, undos []domain.BlobstoreChange, modifiedBy authr.UserInfo) {
+	for i := len(undos) - 1; i >= 0; i-- {
+		if undoErr := combo.applyBlobstoreChange(ctx, undos[i], modifiedBy); undoErr != nil {
+			zerolog.Ctx(ctx).Error().Err(undoErr).Str("change", undos[i].String()).Msg("failed to undo blobstore change")
+		}
+	}
+}
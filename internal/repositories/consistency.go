@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/internal/repositories/indexing"
+	"sort"
+
+	"github.com/rs/zerolog"
+)
+
+const consistencyRepairComment = "consistency repair"
+
+func noSideEffect(ctx context.Context) error {
+	return nil
+}
+
+func sortIconfileRefs(refs []domain.IconfileRef) {
+	sort.Slice(refs, func(i, j int) bool { return refs[i].String() < refs[j].String() })
+}
+
+// listStoredIconfiles lists the iconfiles in the blobstore along with the files which are not iconfiles
+func (combo *RepoCombo) listStoredIconfiles(ctx context.Context) ([]domain.IconfileRef, []string, error) {
+	files, listErr := combo.Blobstore.GetIconfiles(ctx)
+	if listErr != nil {
+		return nil, nil, fmt.Errorf("failed to list iconfiles in %s: %w", combo.Blobstore, listErr)
+	}
+
+	refs := []domain.IconfileRef{}
+	unrecognized := []string{}
+	for _, file := range files {
+		iconName, iconfile, parseErr := git.ParseIconfilePath(file)
+		if parseErr != nil {
+			unrecognized = append(unrecognized, file)
+			continue
+		}
+		refs = append(refs, domain.IconfileRef{IconName: iconName, Iconfile: iconfile})
+	}
+	sortIconfileRefs(refs)
+	return refs, unrecognized, nil
+}
+
+// CheckConsistency compares the iconfiles in the index with those in the blobstore.
+// With repair set, the index is brought in line with the blobstore, which holds the content and is taken to be right:
+// iconfiles missing from the index are indexed, index entries without content are deleted.
+func (combo *RepoCombo) CheckConsistency(ctx context.Context, repair bool, modifiedBy authr.UserInfo) (domain.ConsistencyReport, error) {
+	logger := zerolog.Ctx(ctx).With().Str("method", "CheckConsistency").Bool("repair", repair).Logger()
+	if repair && combo.Catalog != nil {
+		// The icons repaired are not told one by one
+		defer combo.Catalog.Invalidate()
+	}
+
+	report := domain.ConsistencyReport{
+		MissingFromIndex:     []domain.IconfileRef{},
+		MissingFromBlobstore: []domain.IconfileRef{},
+		UnrecognizedFiles:    []string{},
+	}
+
+	icons, describeErr := combo.Index.DescribeAllIcons(ctx)
+	if describeErr != nil {
+		return report, fmt.Errorf("failed to describe icons for consistency check: %w", describeErr)
+	}
+	storedRefs, unrecognized, listErr := combo.listStoredIconfiles(ctx)
+	if listErr != nil {
+		return report, fmt.Errorf("failed to list iconfiles for consistency check: %w", listErr)
+	}
+	report.UnrecognizedFiles = unrecognized
+
+	stored := map[string]domain.IconfileRef{}
+	for _, ref := range storedRefs {
+		stored[ref.String()] = ref
+	}
+
+	indexed := map[string]domain.IconfileRef{}
+	for _, icon := range icons {
+		for _, iconfile := range icon.Iconfiles {
+			ref := domain.IconfileRef{IconName: icon.Name, Iconfile: iconfile}
+			indexed[ref.String()] = ref
+			// Iconfiles proposed in merge requests are not on the main branch yet
+			if combo.mergeRequests() != nil && iconfile.ReviewStatus == domain.ReviewStatusInReview {
+				continue
+			}
+			if _, ok := stored[ref.String()]; !ok {
+				report.MissingFromBlobstore = append(report.MissingFromBlobstore, ref)
+			}
+		}
+	}
+	for key, ref := range stored {
+		if _, ok := indexed[key]; !ok {
+			report.MissingFromIndex = append(report.MissingFromIndex, ref)
+		}
+	}
+	sortIconfileRefs(report.MissingFromIndex)
+	sortIconfileRefs(report.MissingFromBlobstore)
+
+	logger.Info().
+		Int("missingFromIndex", len(report.MissingFromIndex)).
+		Int("missingFromBlobstore", len(report.MissingFromBlobstore)).
+		Int("unrecognizedFiles", len(report.UnrecognizedFiles)).
+		Msg("consistency checked")
+
+	if !repair || report.IsConsistent() {
+		return report, nil
+	}
+
+	for _, ref := range report.MissingFromIndex {
+		if repairErr := combo.indexStoredIconfile(ctx, ref, modifiedBy); repairErr != nil {
+			return report, fmt.Errorf("failed to index %v found in the blobstore: %w", ref, repairErr)
+		}
+	}
+	for _, ref := range report.MissingFromBlobstore {
+		if repairErr := combo.deleteDanglingIconfile(ctx, ref, consistencyRepairComment, modifiedBy); repairErr != nil {
+			return report, fmt.Errorf("failed to delete %v missing from the blobstore from the index: %w", ref, repairErr)
+		}
+	}
+	report.Repaired = true
+	logger.Info().Msg("inconsistencies repaired")
+	return report, nil
+}
+
+// indexStoredIconfile adds an iconfile to the index which is already in the blobstore
+func (combo *RepoCombo) indexStoredIconfile(ctx context.Context, ref domain.IconfileRef, modifiedBy authr.UserInfo) error {
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   domain.AuditActionAddIconfile,
+		IconName: ref.IconName,
+		Iconfile: &ref.Iconfile,
+		Comment:  consistencyRepairComment,
+		Before:   combo.describeForAudit(ctx, ref.IconName),
+	}
+	err := combo.Index.AddIconfileToIcon(ctx, ref.IconName, ref.Iconfile, modifiedBy.UserId.String(), combo.audited(noSideEffect, entry))
+	if errors.Is(err, domain.ErrIconNotFound) {
+		entry.Action = domain.AuditActionCreateIcon
+		err = combo.Index.CreateIcon(ctx, ref.IconName, ref.Iconfile, modifiedBy.UserId.String(), combo.audited(noSideEffect, entry))
+	}
+	return err
+}
+
+// deleteDanglingIconfile deletes an iconfile from the index which has no content in the blobstore.
+// The icon itself is deleted along with its last iconfile.
+func (combo *RepoCombo) deleteDanglingIconfile(ctx context.Context, ref domain.IconfileRef, comment string, modifiedBy authr.UserInfo) error {
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   domain.AuditActionDeleteIconfile,
+		IconName: ref.IconName,
+		Iconfile: &ref.Iconfile,
+		Comment:  comment,
+		Before:   combo.describeForAudit(ctx, ref.IconName),
+	}
+	err := combo.Index.DeleteIconfile(ctx, ref.IconName, ref.Iconfile, modifiedBy.UserId.String(), func(ctx context.Context) error {
+		if icon, found := indexing.CommittedIcon(ctx, ref.IconName); found && len(icon.Iconfiles) == 0 {
+			entry.Action = domain.AuditActionDeleteIcon
+		}
+		return combo.audited(noSideEffect, entry)(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Some indexes delete the icon themselves along with its last iconfile, the others keep it without iconfiles
+	iconDesc, describeErr := combo.Index.DescribeIcon(ctx, ref.IconName)
+	if errors.Is(describeErr, domain.ErrIconNotFound) {
+		return nil
+	} else if describeErr != nil {
+		return fmt.Errorf("failed to describe icon \"%s\" after deleting %v: %w", ref.IconName, ref.Iconfile, describeErr)
+	}
+	if len(iconDesc.Iconfiles) == 0 {
+		if deleteErr := combo.Index.DeleteIcon(ctx, ref.IconName, modifiedBy.UserId.String(), noSideEffect); deleteErr != nil {
+			return fmt.Errorf("failed to delete icon \"%s\" left without iconfiles: %w", ref.IconName, deleteErr)
+		}
+	}
+	return nil
+}
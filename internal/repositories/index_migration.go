@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultMigrationBatchSize is the number of icons copied between two progress reports
+const DefaultMigrationBatchSize = 100
+
+// describeMigratableIcons returns the icons of the index sorted by name. Icons without iconfiles are left out:
+// not every index backend can hold them.
+func describeMigratableIcons(ctx context.Context, index IndexRepository) ([]domain.IconDescriptor, error) {
+	icons, describeErr := index.DescribeAllIcons(ctx)
+	if describeErr != nil {
+		return nil, describeErr
+	}
+	migratable := []domain.IconDescriptor{}
+	for _, icon := range icons {
+		if len(icon.Iconfiles) == 0 {
+			zerolog.Ctx(ctx).Warn().Str("icon_name", icon.Name).Msg("icon without iconfiles left out")
+			continue
+		}
+		migratable = append(migratable, icon)
+	}
+	sort.Slice(migratable, func(i, j int) bool { return migratable[i].Name < migratable[j].Name })
+	return migratable, nil
+}
+
+// SummarizeIndex counts the icons, iconfiles and tags in the index and calculates a checksum over them
+func SummarizeIndex(ctx context.Context, index IndexRepository) (domain.IndexSummary, error) {
+	icons, describeErr := describeMigratableIcons(ctx, index)
+	if describeErr != nil {
+		return domain.IndexSummary{}, fmt.Errorf("failed to describe icons to summarize: %w", describeErr)
+	}
+
+	summary := domain.IndexSummary{}
+	hash := sha256.New()
+	for _, icon := range icons {
+		iconfiles := []string{}
+		for _, iconfile := range icon.Iconfiles {
+			iconfiles = append(iconfiles, fmt.Sprintf("%s/%s/%s", iconfile.Format, iconfile.Size, iconfile.ReviewStatus))
+		}
+		sort.Strings(iconfiles)
+		tags := append([]string{}, icon.Tags...)
+		sort.Strings(tags)
+		fmt.Fprintf(hash, "%s\n%s\n%q\n%s\n%s\n", icon.Name, icon.ModifiedBy, icon.Description, strings.Join(iconfiles, ","), strings.Join(tags, ","))
+
+		summary.Icons++
+		summary.Iconfiles += len(icon.Iconfiles)
+		summary.Tags += len(icon.Tags)
+	}
+	summary.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return summary, nil
+}
+
+// copyIcon creates the icon in the target or completes it with the iconfiles, the description and the tags it is missing.
+// It returns false if the target already had everything.
+func copyIcon(ctx context.Context, target IndexRepository, icon domain.IconDescriptor) (bool, error) {
+	existing, describeErr := target.DescribeIcon(ctx, icon.Name)
+	if describeErr != nil {
+		if !errors.Is(describeErr, domain.ErrIconNotFound) {
+			return false, fmt.Errorf("failed to describe icon in target: %w", describeErr)
+		}
+		createErr := target.CreateIcon(ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy, noSideEffect)
+		if createErr != nil {
+			return false, fmt.Errorf("failed to create icon in target: %w", createErr)
+		}
+		existing = domain.IconDescriptor{IconAttributes: domain.IconAttributes{Name: icon.Name}, Iconfiles: icon.Iconfiles[:1]}
+	}
+
+	copied := describeErr != nil
+	for _, iconfile := range icon.Iconfiles {
+		if _, findErr := existing.FindIconfile(iconfile); findErr == nil {
+			continue
+		}
+		addErr := target.AddIconfileToIcon(ctx, icon.Name, iconfile, icon.ModifiedBy, noSideEffect)
+		if addErr != nil {
+			return false, fmt.Errorf("failed to add iconfile %v in target: %w", iconfile, addErr)
+		}
+		copied = true
+	}
+	if existing.Description != icon.Description {
+		descriptionErr := target.SetIconDescription(ctx, icon.Name, icon.Description, icon.ModifiedBy, noSideEffect)
+		if descriptionErr != nil {
+			return false, fmt.Errorf("failed to set the description in target: %w", descriptionErr)
+		}
+		copied = true
+	}
+	for _, tag := range icon.Tags {
+		if slices.Contains(existing.Tags, tag) {
+			continue
+		}
+		tagErr := target.AddTag(ctx, icon.Name, tag, icon.ModifiedBy, noSideEffect)
+		if tagErr != nil {
+			return false, fmt.Errorf("failed to add tag \"%s\" in target: %w", tag, tagErr)
+		}
+		copied = true
+	}
+	return copied, nil
+}
+
+// MigrateIndex copies the icons, iconfiles and tags of the source index into the target index, batchSize icons at a time,
+// then verifies that the two hold the same. Icons already copied are skipped, so an interrupted migration is resumed by running it again.
+func MigrateIndex(ctx context.Context, source IndexRepository, target IndexRepository, batchSize int) (domain.IndexMigrationReport, error) {
+	logger := zerolog.Ctx(ctx).With().Str("method", "MigrateIndex").Logger()
+	report := domain.IndexMigrationReport{}
+	if batchSize <= 0 {
+		batchSize = DefaultMigrationBatchSize
+	}
+
+	icons, describeErr := describeMigratableIcons(ctx, source)
+	if describeErr != nil {
+		return report, fmt.Errorf("failed to describe icons to migrate: %w", describeErr)
+	}
+
+	for batchStart := 0; batchStart < len(icons); batchStart += batchSize {
+		batch := icons[batchStart:min(batchStart+batchSize, len(icons))]
+		for _, icon := range batch {
+			copied, copyErr := copyIcon(ctx, target, icon)
+			if copyErr != nil {
+				return report, fmt.Errorf("failed to migrate icon \"%s\": %w", icon.Name, copyErr)
+			}
+			if copied {
+				report.Copied++
+			}
+		}
+		logger.Info().
+			Int("migrated", batchStart+len(batch)).
+			Int("total", len(icons)).
+			Str("last_icon", batch[len(batch)-1].Name).
+			Msg("batch migrated")
+	}
+
+	var summaryErr error
+	report.Source, summaryErr = SummarizeIndex(ctx, source)
+	if summaryErr != nil {
+		return report, fmt.Errorf("failed to summarize source index: %w", summaryErr)
+	}
+	report.Target, summaryErr = SummarizeIndex(ctx, target)
+	if summaryErr != nil {
+		return report, fmt.Errorf("failed to summarize target index: %w", summaryErr)
+	}
+	report.Verified = report.Source == report.Target
+
+	logger.Info().Int("copied", report.Copied).Bool("verified", report.Verified).Msg("index migrated")
+	return report, nil
+}
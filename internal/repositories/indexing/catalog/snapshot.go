@@ -0,0 +1,219 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// maxTombstones is the number of deleted icons remembered; older deletions can't be told apart in deltas
+const maxTombstones = 1000
+
+// Snapshot keeps the icons described by the index, so that the catalog can be served without reading the index.
+// The snapshot is kept up to date by having the icons changed refreshed, and reloaded from the index (compared to
+// what it holds) once it is older than its maximum age, to catch up with changes made by other processes.
+//
+// Each change to the snapshot moves it to a new revision, the icons changed (and deleted) being marked with it, so that
+// the changes since an earlier revision can be listed. Revisions are only comparable within the process: they are
+// prefixed with an ID of the snapshot, the changes since a revision of another one being unknown.
+type Snapshot struct {
+	maxAge time.Duration
+	id     string
+
+	// loadMutex serializes the reads of the index, so that the last one to be applied is the last one made
+	loadMutex sync.Mutex
+
+	mutex    sync.Mutex
+	loaded   bool
+	stale    bool
+	loadedAt time.Time
+	revision uint64
+	icons    map[string]snapshotEntry
+	// tombstones keeps the revisions at which icons were deleted
+	tombstones map[string]uint64
+	// floor is the latest revision at which a tombstone was forgotten: the deletions up to it are unknown
+	floor uint64
+}
+
+type snapshotEntry struct {
+	icon     domain.IconDescriptor
+	revision uint64
+}
+
+func NewSnapshot(maxAge time.Duration) *Snapshot {
+	return &Snapshot{
+		maxAge:     maxAge,
+		id:         xid.New().String(),
+		icons:      map[string]snapshotEntry{},
+		tombstones: map[string]uint64{},
+	}
+}
+
+func (snapshot *Snapshot) revisionString(revision uint64) string {
+	return fmt.Sprintf("%s.%d", snapshot.id, revision)
+}
+
+// parseRevision returns false if the revision isn't one of the snapshot
+func (snapshot *Snapshot) parseRevision(revision string) (uint64, bool) {
+	id, counter, found := strings.Cut(revision, ".")
+	if !found || id != snapshot.id {
+		return 0, false
+	}
+	parsed, parseErr := strconv.ParseUint(counter, 10, 64)
+	if parseErr != nil || parsed > snapshot.revision {
+		return 0, false
+	}
+	return parsed, true
+}
+
+func (snapshot *Snapshot) needsLoading() bool {
+	snapshot.mutex.Lock()
+	defer snapshot.mutex.Unlock()
+	return !snapshot.loaded || snapshot.stale || time.Since(snapshot.loadedAt) > snapshot.maxAge
+}
+
+// GetCatalog returns the icons changed and deleted since the revision given, or all of them if the changes since
+// the revision are unknown (an empty revision being one). The icons are loaded with the function given if need be.
+func (snapshot *Snapshot) GetCatalog(ctx context.Context, since string, load func(ctx context.Context) ([]domain.IconDescriptor, error)) (domain.IconCatalog, error) {
+	if snapshot.needsLoading() {
+		snapshot.loadMutex.Lock()
+		loadErr := snapshot.loadIfNeeded(ctx, load)
+		snapshot.loadMutex.Unlock()
+		if loadErr != nil {
+			return domain.IconCatalog{}, loadErr
+		}
+	}
+
+	snapshot.mutex.Lock()
+	defer snapshot.mutex.Unlock()
+	catalog := domain.IconCatalog{Revision: snapshot.revisionString(snapshot.revision), Icons: []domain.IconDescriptor{}, Deleted: []string{}}
+	sinceRevision, known := snapshot.parseRevision(since)
+	catalog.Full = !known || sinceRevision < snapshot.floor
+	if catalog.Full {
+		sinceRevision = 0
+	}
+	for _, entry := range snapshot.icons {
+		if entry.revision > sinceRevision {
+			catalog.Icons = append(catalog.Icons, entry.icon)
+		}
+	}
+	sort.Slice(catalog.Icons, func(i, j int) bool { return catalog.Icons[i].Name < catalog.Icons[j].Name })
+	if !catalog.Full {
+		for iconName, revision := range snapshot.tombstones {
+			if revision > sinceRevision {
+				catalog.Deleted = append(catalog.Deleted, iconName)
+			}
+		}
+		sort.Strings(catalog.Deleted)
+	}
+	return catalog, nil
+}
+
+// loadIfNeeded is called with the load mutex held
+func (snapshot *Snapshot) loadIfNeeded(ctx context.Context, load func(ctx context.Context) ([]domain.IconDescriptor, error)) error {
+	if !snapshot.needsLoading() {
+		// Loaded while waiting for the mutex
+		return nil
+	}
+	icons, loadErr := load(ctx)
+	if loadErr != nil {
+		return fmt.Errorf("failed to load icon catalog: %w", loadErr)
+	}
+
+	snapshot.mutex.Lock()
+	defer snapshot.mutex.Unlock()
+	loaded := map[string]bool{}
+	for _, icon := range icons {
+		loaded[icon.Name] = true
+		snapshot.apply(icon.Name, &icon)
+	}
+	for iconName := range snapshot.icons {
+		if !loaded[iconName] {
+			snapshot.apply(iconName, nil)
+		}
+	}
+	snapshot.loaded = true
+	snapshot.stale = false
+	snapshot.loadedAt = time.Now()
+	return nil
+}
+
+// apply records the current state of the icon, nil if it has been deleted, moving the snapshot to a new revision
+// if the icon has changed. It is called with the mutex held.
+func (snapshot *Snapshot) apply(iconName string, icon *domain.IconDescriptor) {
+	current, exists := snapshot.icons[iconName]
+	if icon == nil {
+		if !exists {
+			return
+		}
+		snapshot.revision++
+		delete(snapshot.icons, iconName)
+		snapshot.tombstones[iconName] = snapshot.revision
+		snapshot.forgetOldestTombstones()
+		return
+	}
+	if exists && reflect.DeepEqual(current.icon, *icon) {
+		return
+	}
+	snapshot.revision++
+	snapshot.icons[iconName] = snapshotEntry{icon: *icon, revision: snapshot.revision}
+	delete(snapshot.tombstones, iconName)
+}
+
+func (snapshot *Snapshot) forgetOldestTombstones() {
+	for len(snapshot.tombstones) > maxTombstones {
+		oldestName := ""
+		oldest := snapshot.revision + 1
+		for iconName, revision := range snapshot.tombstones {
+			if revision < oldest {
+				oldestName, oldest = iconName, revision
+			}
+		}
+		delete(snapshot.tombstones, oldestName)
+		snapshot.floor = max(snapshot.floor, oldest)
+	}
+}
+
+// RefreshIcons has the icons given described anew with the function given, once they have been changed in the index.
+// Should an icon fail to be described, the snapshot is reloaded on its next use.
+func (snapshot *Snapshot) RefreshIcons(ctx context.Context, iconNames []string, describe func(ctx context.Context, iconName string) (domain.IconDescriptor, error)) {
+	snapshot.loadMutex.Lock()
+	defer snapshot.loadMutex.Unlock()
+	snapshot.mutex.Lock()
+	loaded := snapshot.loaded
+	snapshot.mutex.Unlock()
+	if !loaded {
+		// Everything is read on the first use anyway
+		return
+	}
+
+	for _, iconName := range iconNames {
+		icon, describeErr := describe(ctx, iconName)
+		snapshot.mutex.Lock()
+		switch {
+		case describeErr == nil:
+			snapshot.apply(iconName, &icon)
+		case errors.Is(describeErr, domain.ErrIconNotFound):
+			snapshot.apply(iconName, nil)
+		default:
+			snapshot.stale = true
+		}
+		snapshot.mutex.Unlock()
+	}
+}
+
+// Invalidate has the snapshot reloaded on its next use, after changes to the index it doesn't know the details of
+func (snapshot *Snapshot) Invalidate() {
+	snapshot.mutex.Lock()
+	defer snapshot.mutex.Unlock()
+	snapshot.stale = true
+}
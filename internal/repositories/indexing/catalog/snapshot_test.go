@@ -0,0 +1,206 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeIndex holds the icons the snapshot is loaded and refreshed from
+type fakeIndex struct {
+	icons       map[string]domain.IconDescriptor
+	loads       int
+	describeErr error
+}
+
+func (index *fakeIndex) DescribeAllIcons(ctx context.Context) ([]domain.IconDescriptor, error) {
+	index.loads++
+	icons := []domain.IconDescriptor{}
+	for _, icon := range index.icons {
+		icons = append(icons, icon)
+	}
+	sort.Slice(icons, func(i, j int) bool { return icons[i].Name < icons[j].Name })
+	return icons, nil
+}
+
+func (index *fakeIndex) DescribeIcon(ctx context.Context, iconName string) (domain.IconDescriptor, error) {
+	if index.describeErr != nil {
+		return domain.IconDescriptor{}, index.describeErr
+	}
+	icon, found := index.icons[iconName]
+	if !found {
+		return domain.IconDescriptor{}, domain.ErrIconNotFound
+	}
+	return icon, nil
+}
+
+func (index *fakeIndex) put(iconName string, tags ...string) {
+	index.icons[iconName] = domain.IconDescriptor{
+		IconAttributes: domain.IconAttributes{Name: iconName, ModifiedBy: "ux", Tags: tags},
+		Iconfiles:      []domain.IconfileDescriptor{{Format: "png", Size: "24px", ReviewStatus: domain.ReviewStatusPublished}},
+	}
+}
+
+type snapshotTestSuite struct {
+	suite.Suite
+	ctx      context.Context
+	index    *fakeIndex
+	snapshot *Snapshot
+}
+
+func TestSnapshotTestSuite(t *testing.T) {
+	suite.Run(t, &snapshotTestSuite{ctx: context.Background()})
+}
+
+func (s *snapshotTestSuite) BeforeTest(suiteName string, testName string) {
+	s.index = &fakeIndex{icons: map[string]domain.IconDescriptor{}}
+	s.index.put("basket")
+	s.index.put("cart")
+	s.snapshot = NewSnapshot(time.Hour)
+}
+
+func (s *snapshotTestSuite) get(since string) domain.IconCatalog {
+	catalog, err := s.snapshot.GetCatalog(s.ctx, since, s.index.DescribeAllIcons)
+	s.Require().NoError(err)
+	return catalog
+}
+
+func (s *snapshotTestSuite) refresh(iconNames ...string) {
+	s.snapshot.RefreshIcons(s.ctx, iconNames, s.index.DescribeIcon)
+}
+
+func iconNames(icons []domain.IconDescriptor) []string {
+	names := []string{}
+	for _, icon := range icons {
+		names = append(names, icon.Name)
+	}
+	return names
+}
+
+func (s *snapshotTestSuite) TestIndexIsReadOnce() {
+	first := s.get("")
+	second := s.get("")
+
+	s.Equal(1, s.index.loads)
+	s.True(first.Full)
+	s.Equal([]string{"basket", "cart"}, iconNames(first.Icons))
+	s.Equal(first, second)
+}
+
+func (s *snapshotTestSuite) TestChangesSinceRevision() {
+	before := s.get("")
+	s.index.put("cart", "shopping")
+	s.index.put("trolley")
+	delete(s.index.icons, "basket")
+	s.refresh("cart", "trolley", "basket")
+
+	delta := s.get(before.Revision)
+
+	s.False(delta.Full)
+	s.NotEqual(before.Revision, delta.Revision)
+	s.Equal([]string{"cart", "trolley"}, iconNames(delta.Icons))
+	s.Equal([]string{"shopping"}, delta.Icons[0].Tags)
+	s.Equal([]string{"basket"}, delta.Deleted)
+	s.Equal(1, s.index.loads)
+}
+
+func (s *snapshotTestSuite) TestUnchangedIconKeepsTheRevision() {
+	before := s.get("")
+	s.refresh("cart")
+
+	s.Equal(before.Revision, s.get(before.Revision).Revision)
+}
+
+func (s *snapshotTestSuite) TestRecreatedIconIsNoLongerDeleted() {
+	before := s.get("")
+	delete(s.index.icons, "cart")
+	s.refresh("cart")
+	s.index.put("cart")
+	s.refresh("cart")
+
+	delta := s.get(before.Revision)
+
+	s.Equal([]string{"cart"}, iconNames(delta.Icons))
+	s.Empty(delta.Deleted)
+}
+
+func (s *snapshotTestSuite) TestRevisionOfAnotherSnapshotGetsAllIcons() {
+	other := NewSnapshot(time.Hour)
+	otherCatalog, err := other.GetCatalog(s.ctx, "", s.index.DescribeAllIcons)
+	s.Require().NoError(err)
+
+	catalog := s.get(otherCatalog.Revision)
+
+	s.True(catalog.Full)
+	s.Equal([]string{"basket", "cart"}, iconNames(catalog.Icons))
+}
+
+func (s *snapshotTestSuite) TestFailedRefreshHasTheIndexReadAgain() {
+	before := s.get("")
+	s.index.put("cart", "shopping")
+	s.index.describeErr = errors.New("index down")
+	s.refresh("cart")
+	s.index.describeErr = nil
+
+	delta := s.get(before.Revision)
+
+	s.Equal(2, s.index.loads)
+	s.False(delta.Full)
+	s.Equal([]string{"cart"}, iconNames(delta.Icons))
+}
+
+func (s *snapshotTestSuite) TestInvalidatedSnapshotIsComparedToTheIndex() {
+	before := s.get("")
+	delete(s.index.icons, "basket")
+	s.index.put("trolley")
+	s.snapshot.Invalidate()
+
+	delta := s.get(before.Revision)
+
+	s.Equal(2, s.index.loads)
+	s.False(delta.Full)
+	s.Equal([]string{"trolley"}, iconNames(delta.Icons))
+	s.Equal([]string{"basket"}, delta.Deleted)
+}
+
+func (s *snapshotTestSuite) TestSnapshotOlderThanMaxAgeIsReloaded() {
+	s.snapshot = NewSnapshot(time.Millisecond)
+	before := s.get("")
+	// Changed by another process, without the snapshot being told
+	s.index.put("trolley")
+	time.Sleep(5 * time.Millisecond)
+
+	delta := s.get(before.Revision)
+
+	s.Equal(2, s.index.loads)
+	s.Equal([]string{"trolley"}, iconNames(delta.Icons))
+}
+
+func (s *snapshotTestSuite) TestChangesBeforeForgottenDeletionsAreUnknown() {
+	before := s.get("")
+	for i := 0; i <= maxTombstones; i++ {
+		iconName := fmt.Sprintf("icon-%d", i)
+		s.index.put(iconName)
+		s.refresh(iconName)
+		delete(s.index.icons, iconName)
+		s.refresh(iconName)
+	}
+
+	catalog := s.get(before.Revision)
+
+	s.True(catalog.Full)
+	s.Equal([]string{"basket", "cart"}, iconNames(catalog.Icons))
+}
+
+func (s *snapshotTestSuite) TestRefreshBeforeTheFirstLoadIsSkipped() {
+	s.refresh("cart")
+
+	s.get("")
+	s.Equal(1, s.index.loads)
+}
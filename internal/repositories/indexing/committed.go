@@ -0,0 +1,57 @@
+package indexing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"slices"
+)
+
+type committedIconsContextKey struct{}
+
+// ContextWithCommittedIcons hands the state of the icons, as it is about to be committed, over to the side-effect of
+// a change to the index, so that the side-effect doesn't have to read it outside the transaction.
+// An icon removed by the change is handed over without iconfiles.
+func ContextWithCommittedIcons(ctx context.Context, icons ...domain.IconDescriptor) context.Context {
+	committed := map[string]domain.IconDescriptor{}
+	for _, icon := range icons {
+		committed[icon.Name] = icon
+	}
+	return context.WithValue(ctx, committedIconsContextKey{}, committed)
+}
+
+// CommittedIcon returns the state of the icon handed over by ContextWithCommittedIcons; found is false if it wasn't,
+// as is the case when the change has left the icon as it was
+func CommittedIcon(ctx context.Context, iconName string) (icon domain.IconDescriptor, found bool) {
+	committed, _ := ctx.Value(committedIconsContextKey{}).(map[string]domain.IconDescriptor)
+	icon, found = committed[iconName]
+	return icon, found
+}
+
+// DescribeCommittedIcons describes the icons changed in a transaction with describe, which is to return
+// domain.ErrIconNotFound for the icons removed
+func DescribeCommittedIcons(iconNames []string, describe func(iconName string) (domain.IconDescriptor, error)) ([]domain.IconDescriptor, error) {
+	icons := []domain.IconDescriptor{}
+	for _, iconName := range iconNames {
+		icon, describeErr := describe(iconName)
+		if errors.Is(describeErr, domain.ErrIconNotFound) {
+			icon = domain.IconDescriptor{IconAttributes: domain.IconAttributes{Name: iconName, Tags: []string{}}}
+		} else if describeErr != nil {
+			return nil, fmt.Errorf("failed to describe icon \"%s\" for the side-effect: %w", iconName, describeErr)
+		}
+		icons = append(icons, icon)
+	}
+	return icons, nil
+}
+
+// ChangesetIconNames lists the icons the operations are about, each once
+func ChangesetIconNames(ops []domain.ChangesetOperation) []string {
+	iconNames := []string{}
+	for _, op := range ops {
+		if !slices.Contains(iconNames, op.IconName) {
+			iconNames = append(iconNames, op.IconName)
+		}
+	}
+	return iconNames
+}
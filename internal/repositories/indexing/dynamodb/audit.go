@@ -0,0 +1,353 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	aws_dyndb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rs/xid"
+)
+
+// auditTimestampFormat has a fixed width, so that the lexical order of the formatted timestamps is the chronological order
+const auditTimestampFormat = "2006-01-02T15:04:05.000000000Z"
+
+func formatAuditTimestamp(t time.Time) string {
+	return t.UTC().Format(auditTimestampFormat)
+}
+
+// auditLogPartition is the value of the AuditLog attribute of every entry: the index of all the entries
+// has a single partition, the entries being recorded at the pace the icons are changed
+const auditLogPartition = "all"
+
+// DyndbAuditEntry is keyed by the icon name and an entry id starting with the timestamp of the entry,
+// so that the entries of an icon can be queried in chronological order. The indexes on the actor and on
+// the AuditLog attribute, with the entry id as the range key, do the same for the entries of an actor and all the entries.
+type DyndbAuditEntry struct {
+	IconName       string `dynamodbav:"IconName"`
+	EntryID        string `dynamodbav:"EntryID"`
+	AuditLog       string `dynamodbav:"AuditLog"`
+	Timestamp      string `dynamodbav:"Timestamp"`
+	Actor          string `dynamodbav:"Actor"`
+	Action         string `dynamodbav:"Action"`
+	IconfileFormat string `dynamodbav:"IconfileFormat,omitempty"`
+	IconfileSize   string `dynamodbav:"IconfileSize,omitempty"`
+	Tag            string `dynamodbav:"Tag,omitempty"`
+	Before         string `dynamodbav:"Before,omitempty"`
+	After          string `dynamodbav:"After,omitempty"`
+	RequestID      string `dynamodbav:"RequestID,omitempty"`
+	Comment        string `dynamodbav:"Comment,omitempty"`
+}
+
+func (dyEntry *DyndbAuditEntry) GetKey(ctx context.Context) (map[string]types.AttributeValue, error) {
+	return map[string]types.AttributeValue{
+		iconNameAttribute:     &types.AttributeValueMemberS{Value: dyEntry.IconName},
+		auditEntryIDAttribute: &types.AttributeValueMemberS{Value: dyEntry.EntryID},
+	}, nil
+}
+
+func (dyEntry *DyndbAuditEntry) unmarshal(attrmap map[string]types.AttributeValue) error {
+	unmarshalErr := attributevalue.UnmarshalMap(attrmap, dyEntry)
+	if unmarshalErr != nil {
+		return fmt.Errorf("failed to unmarshal %T: %w", DyndbAuditEntry{}, unmarshalErr)
+	}
+	return nil
+}
+
+func marshalAuditedIcon(iconDesc *domain.IconDescriptor) (string, error) {
+	if iconDesc == nil {
+		return "", nil
+	}
+	iconJSON, err := json.Marshal(iconDesc)
+	return string(iconJSON), err
+}
+
+func unmarshalAuditedIcon(iconJSON string) (*domain.IconDescriptor, error) {
+	if len(iconJSON) == 0 {
+		return nil, nil
+	}
+	iconDesc := domain.IconDescriptor{}
+	err := json.Unmarshal([]byte(iconJSON), &iconDesc)
+	if err != nil {
+		return nil, err
+	}
+	return &iconDesc, nil
+}
+
+func (dyEntry *DyndbAuditEntry) fromAuditEntry(entry domain.AuditEntry) error {
+	before, marshalBeforeErr := marshalAuditedIcon(entry.Before)
+	if marshalBeforeErr != nil {
+		return fmt.Errorf("failed to marshal icon state before %v: %w", entry, marshalBeforeErr)
+	}
+	after, marshalAfterErr := marshalAuditedIcon(entry.After)
+	if marshalAfterErr != nil {
+		return fmt.Errorf("failed to marshal icon state after %v: %w", entry, marshalAfterErr)
+	}
+	timestamp := formatAuditTimestamp(entry.Timestamp)
+	newEntry := DyndbAuditEntry{
+		IconName:  entry.IconName,
+		EntryID:   timestamp + "#" + xid.New().String(),
+		AuditLog:  auditLogPartition,
+		Timestamp: timestamp,
+		Actor:     entry.Actor,
+		Action:    string(entry.Action),
+		Tag:       entry.Tag,
+		Before:    before,
+		After:     after,
+		RequestID: entry.RequestID,
+		Comment:   entry.Comment,
+	}
+	if entry.Iconfile != nil {
+		newEntry.IconfileFormat = entry.Iconfile.Format
+		newEntry.IconfileSize = entry.Iconfile.Size
+	}
+	*dyEntry = newEntry
+	return nil
+}
+
+func (dyEntry *DyndbAuditEntry) toAuditEntry() (domain.AuditEntry, error) {
+	timestamp, parseErr := time.Parse(auditTimestampFormat, dyEntry.Timestamp)
+	if parseErr != nil {
+		return domain.AuditEntry{}, fmt.Errorf("failed to parse timestamp of audit entry %s: %w", dyEntry.EntryID, parseErr)
+	}
+	entry := domain.AuditEntry{
+		ID:        dyEntry.EntryID,
+		Timestamp: timestamp,
+		Actor:     dyEntry.Actor,
+		Action:    domain.AuditAction(dyEntry.Action),
+		IconName:  dyEntry.IconName,
+		Tag:       dyEntry.Tag,
+		RequestID: dyEntry.RequestID,
+		Comment:   dyEntry.Comment,
+	}
+	if len(dyEntry.IconfileFormat) > 0 || len(dyEntry.IconfileSize) > 0 {
+		entry.Iconfile = &domain.IconfileDescriptor{Format: dyEntry.IconfileFormat, Size: dyEntry.IconfileSize}
+	}
+	var unmarshalErr error
+	if entry.Before, unmarshalErr = unmarshalAuditedIcon(dyEntry.Before); unmarshalErr != nil {
+		return domain.AuditEntry{}, fmt.Errorf("failed to unmarshal icon state before in audit entry %s: %w", dyEntry.EntryID, unmarshalErr)
+	}
+	if entry.After, unmarshalErr = unmarshalAuditedIcon(dyEntry.After); unmarshalErr != nil {
+		return domain.AuditEntry{}, fmt.Errorf("failed to unmarshal icon state after in audit entry %s: %w", dyEntry.EntryID, unmarshalErr)
+	}
+	return entry, nil
+}
+
+func (repo *DynamodbRepository) RecordAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	dyEntry := DyndbAuditEntry{}
+	if convErr := dyEntry.fromAuditEntry(entry); convErr != nil {
+		return convErr
+	}
+
+	item, marshalErr := attributevalue.MarshalMap(dyEntry)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal audit entry %v: %w", entry, marshalErr)
+	}
+
+	_, putErr := repo.awsClient.PutItem(ctx, &aws_dyndb.PutItemInput{
+		TableName: aws.String(IconAuditTableName),
+		Item:      item,
+	})
+	if putErr != nil {
+		return fmt.Errorf("failed to record audit entry %v: %w", entry, Unwrap(ctx, putErr))
+	}
+	return nil
+}
+
+// addEntriesToAuditLogIndex sets the AuditLog attribute, which puts the entries in the index of all the entries,
+// on the entries recorded before there was one
+func addEntriesToAuditLogIndex(ctx context.Context, awsClient *aws_dyndb.Client) error {
+	entries, scanErr := NewDyndbIconAuditTable(awsClient).GetItems(ctx)
+	if scanErr != nil {
+		return fmt.Errorf("failed to read audit entries for adding them to the audit log index: %w", scanErr)
+	}
+	for _, entry := range entries {
+		if len(entry.AuditLog) > 0 {
+			continue
+		}
+		key, keyErr := entry.GetKey(ctx)
+		if keyErr != nil {
+			return keyErr
+		}
+		_, updateErr := awsClient.UpdateItem(ctx, &aws_dyndb.UpdateItemInput{
+			TableName:                 aws.String(IconAuditTableName),
+			Key:                       key,
+			UpdateExpression:          aws.String("SET AuditLog = :auditLog"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":auditLog": &types.AttributeValueMemberS{Value: auditLogPartition}},
+		})
+		if updateErr != nil {
+			return fmt.Errorf("failed to add audit entry %s of %s to the audit log index: %w", entry.EntryID, entry.IconName, Unwrap(ctx, updateErr))
+		}
+	}
+	return nil
+}
+
+// QueryAuditEntries returns the entries matching the query, the most recent first, by querying the partition of the icon,
+// the index of the entries by actor or the index of all the entries, each ordered by the entry id, which starts with
+// the timestamp of the entry. The cursor encodes the key of the last entry on the previous page.
+func (repo *DynamodbRepository) QueryAuditEntries(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = domain.DefaultAuditPageSize
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && !query.Since.Before(query.Until) {
+		return domain.AuditPage{Entries: []domain.AuditEntry{}}, nil
+	}
+
+	input := &aws_dyndb.QueryInput{
+		TableName:        aws.String(IconAuditTableName),
+		ScanIndexForward: aws.Bool(false),
+		// One more than the page, for the caller to tell whether there is a next page
+		Limit: aws.Int32(int32(limit + 1)),
+	}
+	values := map[string]types.AttributeValue{}
+	var partitionAttribute, partition string
+	switch {
+	case len(query.IconName) > 0:
+		partitionAttribute, partition = iconNameAttribute, query.IconName
+		if len(query.Actor) > 0 {
+			input.FilterExpression = aws.String("Actor = :actor")
+			values[":actor"] = &types.AttributeValueMemberS{Value: query.Actor}
+		}
+	case len(query.Actor) > 0:
+		input.IndexName = aws.String(AuditByActorIndexName)
+		partitionAttribute, partition = actorAttribute, query.Actor
+	default:
+		input.IndexName = aws.String(AuditByTimeIndexName)
+		partitionAttribute, partition = auditLogAttribute, auditLogPartition
+	}
+	partitionValue := &types.AttributeValueMemberS{Value: partition}
+	values[":partition"] = partitionValue
+
+	keyCondition := partitionAttribute + " = :partition"
+	// An entry id is never equal to a timestamp, so that BETWEEN leaves out the entries recorded at the end of the range
+	switch {
+	case !query.Since.IsZero() && !query.Until.IsZero():
+		keyCondition += " AND EntryID BETWEEN :since AND :until"
+	case !query.Since.IsZero():
+		keyCondition += " AND EntryID >= :since"
+	case !query.Until.IsZero():
+		keyCondition += " AND EntryID < :until"
+	}
+	input.KeyConditionExpression = aws.String(keyCondition)
+	if !query.Since.IsZero() {
+		values[":since"] = &types.AttributeValueMemberS{Value: formatAuditTimestamp(query.Since)}
+	}
+	if !query.Until.IsZero() {
+		values[":until"] = &types.AttributeValueMemberS{Value: formatAuditTimestamp(query.Until)}
+	}
+	input.ExpressionAttributeValues = values
+
+	if len(query.Cursor) > 0 {
+		cursor, decodeErr := decodeAuditCursor(query.Cursor)
+		if decodeErr != nil || (len(query.IconName) > 0 && cursor.IconName != query.IconName) {
+			return domain.AuditPage{}, fmt.Errorf("invalid audit cursor %s: %w", query.Cursor, domain.ErrInvalidAuditCursor)
+		}
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			iconNameAttribute:     &types.AttributeValueMemberS{Value: cursor.IconName},
+			auditEntryIDAttribute: &types.AttributeValueMemberS{Value: cursor.EntryID},
+			partitionAttribute:    partitionValue,
+		}
+	}
+
+	items := []map[string]types.AttributeValue{}
+	for {
+		output, queryErr := repo.awsClient.Query(ctx, input)
+		if queryErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("failed to query audit entries: %w", Unwrap(ctx, queryErr))
+		}
+		items = append(items, output.Items...)
+		if len(items) > limit || output.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+
+	entries := []domain.AuditEntry{}
+	for _, item := range items {
+		dyEntry := DyndbAuditEntry{}
+		if unmarshalErr := dyEntry.unmarshal(item); unmarshalErr != nil {
+			return domain.AuditPage{}, unmarshalErr
+		}
+		entry, convErr := dyEntry.toAuditEntry()
+		if convErr != nil {
+			return domain.AuditPage{}, convErr
+		}
+		entries = append(entries, entry)
+	}
+
+	page := domain.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		last := page.Entries[limit-1]
+		page.NextCursor = encodeAuditCursor(auditCursor{IconName: last.IconName, EntryID: last.ID})
+	}
+	return page, nil
+}
+
+// auditCursor is the key of an entry in the table, the key of the index queried being taken from the query
+type auditCursor struct {
+	IconName string `json:"iconName"`
+	EntryID  string `json:"entryId"`
+}
+
+func encodeAuditCursor(cursor auditCursor) string {
+	cursorJSON, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(cursorJSON)
+}
+
+func decodeAuditCursor(encoded string) (auditCursor, error) {
+	cursor := auditCursor{}
+	cursorJSON, decodeErr := base64.RawURLEncoding.DecodeString(encoded)
+	if decodeErr != nil {
+		return cursor, decodeErr
+	}
+	if unmarshalErr := json.Unmarshal(cursorJSON, &cursor); unmarshalErr != nil {
+		return cursor, unmarshalErr
+	}
+	if len(cursor.IconName) == 0 || len(cursor.EntryID) == 0 {
+		return cursor, fmt.Errorf("incomplete audit cursor %s", encoded)
+	}
+	return cursor, nil
+}
+
+// scanFilteredItems reads all the items of the table matching the filters
+func (repo *DynamodbRepository) scanFilteredItems(
+	ctx context.Context,
+	tableName string,
+	filters []string,
+	values map[string]types.AttributeValue,
+	names map[string]string,
+) ([]map[string]types.AttributeValue, error) {
+	input := &aws_dyndb.ScanInput{
+		TableName: aws.String(tableName),
+	}
+	if len(filters) > 0 {
+		input.FilterExpression = aws.String(strings.Join(filters, " AND "))
+		input.ExpressionAttributeValues = values
+	}
+	if len(names) > 0 {
+		input.ExpressionAttributeNames = names
+	}
+
+	items := []map[string]types.AttributeValue{}
+	for {
+		output, scanErr := repo.awsClient.Scan(ctx, input)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", tableName, Unwrap(ctx, scanErr))
+		}
+		items = append(items, output.Items...)
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	return items, nil
+}
@@ -0,0 +1,362 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"math/rand"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	aws_dyndb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// maxWriteAttempts is the number of times an icon is read and written before giving up on concurrent changes to it
+	maxWriteAttempts = 10
+	// maxTransactItems is the number of items DynamoDB accepts in a single TransactWriteItems call
+	maxTransactItems = 100
+	writeRetryDelay  = 20 * time.Millisecond
+
+	// The icon item is expected to be at the version read unless it was written before items had versions
+	iconUnchangedCondition = "attribute_exists(" + iconNameAttribute + ") AND (attribute_not_exists(#version) OR #version = :version)"
+	iconAbsentCondition    = "attribute_not_exists(" + iconNameAttribute + ")"
+)
+
+// errWriteConflict is returned when an item was changed since it was read
+var errWriteConflict = errors.New("item changed concurrently")
+
+// iconChange is the state an icon item was read in and the state it is to be left in.
+// A nil original stands for an icon that didn't exist, a nil changed for an icon to be deleted.
+type iconChange struct {
+	original *DyndbIcon
+	changed  *DyndbIcon
+}
+
+// changeIcons reads the icons, lets change modify them and writes the icons it changed along with the
+// reference counts of their tags, provided none of the icons has been written since it was read.
+// Otherwise the icons are read and changed again.
+// Icons are absent from the map passed to change if they don't exist; change can create and delete them
+// by setting and removing their entries.
+func (repo *DynamodbRepository) changeIcons(ctx context.Context, iconNames []string, change func(icons map[string]*DyndbIcon) error) ([]iconChange, error) {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "changeIcons").Logger()
+
+	for attempt := 1; ; attempt++ {
+		originals := map[string]*DyndbIcon{}
+		icons := map[string]*DyndbIcon{}
+		for _, iconName := range iconNames {
+			iconItem, getIconItemErr := repo.getIconItem(ctx, iconName, true)
+			if getIconItemErr != nil {
+				if errors.Is(getIconItemErr, domain.ErrIconNotFound) {
+					continue
+				}
+				return nil, getIconItemErr
+			}
+			originals[iconName] = iconItem
+			icons[iconName] = iconItem.clone()
+		}
+
+		if changeErr := change(icons); changeErr != nil {
+			return nil, changeErr
+		}
+
+		changes := []iconChange{}
+		for _, iconName := range iconNames {
+			if !reflect.DeepEqual(originals[iconName], icons[iconName]) {
+				changes = append(changes, iconChange{original: originals[iconName], changed: icons[iconName]})
+			}
+		}
+
+		writeErr := repo.writeIconChanges(ctx, changes)
+		if writeErr == nil {
+			return changes, nil
+		}
+		if !errors.Is(writeErr, errWriteConflict) || attempt == maxWriteAttempts {
+			return nil, writeErr
+		}
+
+		delay := time.Duration(attempt)*writeRetryDelay + time.Duration(rand.Int63n(int64(writeRetryDelay)))
+		logger.Debug().Strs("iconNames", iconNames).Int("attempt", attempt).Dur("delay", delay).Msg("icons changed concurrently, retrying")
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// revertIconChanges restores the icons written by changeIcons, unless they have been written again since
+func (repo *DynamodbRepository) revertIconChanges(ctx context.Context, changes []iconChange) {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "revertIconChanges").Logger()
+
+	reverts := []iconChange{}
+	for _, change := range changes {
+		reverts = append(reverts, iconChange{original: change.changed, changed: change.original.clone()})
+	}
+	if revertErr := repo.writeIconChanges(ctx, reverts); revertErr != nil {
+		for _, change := range changes {
+			logger.Error().Err(revertErr).Str("IconName", change.iconName()).Msg("failed to roll back icon change")
+		}
+	}
+}
+
+// writeIconChanges writes the icon items, each on the condition that it is still in its original state, and
+// updates the reference counts and the member items of the tags added and removed, in a single transaction.
+// Tags no icon refers to anymore are deleted afterwards.
+func (repo *DynamodbRepository) writeIconChanges(ctx context.Context, changes []iconChange) error {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "writeIconChanges").Logger()
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	writeItems := []types.TransactWriteItem{}
+	tagRefCountChanges := map[string]int64{}
+	type tagMember struct {
+		tag      string
+		iconName string
+		added    bool
+	}
+	memberItems := []tagMember{}
+	for _, change := range changes {
+		writeItem, writeItemErr := change.toTransactWriteItem(ctx)
+		if writeItemErr != nil {
+			return writeItemErr
+		}
+		writeItems = append(writeItems, writeItem)
+
+		var originalTags, changedTags []string
+		if change.original != nil {
+			originalTags = change.original.Tags
+		}
+		if change.changed != nil {
+			changedTags = change.changed.Tags
+		}
+		for _, tag := range changedTags {
+			if !slices.Contains(originalTags, tag) {
+				tagRefCountChanges[tag]++
+				memberItems = append(memberItems, tagMember{tag, change.iconName(), true})
+			}
+		}
+		for _, tag := range originalTags {
+			if !slices.Contains(changedTags, tag) {
+				tagRefCountChanges[tag]--
+				memberItems = append(memberItems, tagMember{tag, change.iconName(), false})
+			}
+		}
+	}
+	for _, member := range memberItems {
+		writeItem, writeItemErr := tagMemberWriteItem(ctx, member.tag, member.iconName, member.added)
+		if writeItemErr != nil {
+			return writeItemErr
+		}
+		writeItems = append(writeItems, writeItem)
+	}
+
+	tags := []string{}
+	for tag, delta := range tagRefCountChanges {
+		if delta != 0 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		writeItem, writeItemErr := tagRefCountChangeItem(ctx, tag, tagRefCountChanges[tag])
+		if writeItemErr != nil {
+			return writeItemErr
+		}
+		writeItems = append(writeItems, writeItem)
+	}
+
+	if len(writeItems) > maxTransactItems {
+		return fmt.Errorf("failed to write %d icons: %d items exceed the %d items of a transaction", len(changes), len(writeItems), maxTransactItems)
+	}
+
+	writeErr := repo.transactWriteItems(ctx, writeItems)
+	if writeErr != nil {
+		iconNames := []string{}
+		for _, change := range changes {
+			iconNames = append(iconNames, change.iconName())
+		}
+		return fmt.Errorf("failed to write changes to %s: %w", strings.Join(iconNames, ", "), writeErr)
+	}
+
+	for _, tag := range tags {
+		if tagRefCountChanges[tag] < 0 {
+			if deleteErr := repo.deleteUnreferencedTag(ctx, tag); deleteErr != nil {
+				logger.Warn().Err(deleteErr).Str("tag", tag).Msg("failed to delete unreferenced tag")
+			}
+		}
+	}
+
+	return nil
+}
+
+// transactWriteItems spares the transaction for a single put or delete, which can be conditional by itself
+func (repo *DynamodbRepository) transactWriteItems(ctx context.Context, writeItems []types.TransactWriteItem) error {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "transactWriteItems").Logger()
+
+	var output any
+	var err error
+	switch {
+	case len(writeItems) == 1 && writeItems[0].Put != nil:
+		put := writeItems[0].Put
+		output, err = repo.awsClient.PutItem(ctx, &aws_dyndb.PutItemInput{
+			TableName:                 put.TableName,
+			Item:                      put.Item,
+			ConditionExpression:       put.ConditionExpression,
+			ExpressionAttributeNames:  put.ExpressionAttributeNames,
+			ExpressionAttributeValues: put.ExpressionAttributeValues,
+			ReturnConsumedCapacity:    "TOTAL",
+		})
+	case len(writeItems) == 1 && writeItems[0].Delete != nil:
+		del := writeItems[0].Delete
+		output, err = repo.awsClient.DeleteItem(ctx, &aws_dyndb.DeleteItemInput{
+			TableName:                 del.TableName,
+			Key:                       del.Key,
+			ConditionExpression:       del.ConditionExpression,
+			ExpressionAttributeNames:  del.ExpressionAttributeNames,
+			ExpressionAttributeValues: del.ExpressionAttributeValues,
+			ReturnConsumedCapacity:    "TOTAL",
+		})
+	default:
+		output, err = repo.awsClient.TransactWriteItems(ctx, &aws_dyndb.TransactWriteItemsInput{
+			TransactItems:          writeItems,
+			ReturnConsumedCapacity: "TOTAL",
+		})
+	}
+	if err != nil {
+		if isWriteConflict(err) {
+			return fmt.Errorf("%w: %v", errWriteConflict, err)
+		}
+		return Unwrap(ctx, err)
+	}
+	if logger.GetLevel() == zerolog.DebugLevel {
+		logger.Debug().Int("itemCount", len(writeItems)).Interface("output", output).Send()
+	}
+	return nil
+}
+
+// isWriteConflict tells whether a write failed on its condition or on a concurrent transaction on the same items
+func isWriteConflict(err error) bool {
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return true
+	}
+	var conflictErr *types.TransactionConflictException
+	if errors.As(err, &conflictErr) {
+		return true
+	}
+	var canceledErr *types.TransactionCanceledException
+	if errors.As(err, &canceledErr) {
+		for _, reason := range canceledErr.CancellationReasons {
+			code := aws.ToString(reason.Code)
+			if code == "ConditionalCheckFailed" || code == "TransactionConflict" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (change iconChange) iconName() string {
+	if change.changed != nil {
+		return change.changed.IconName
+	}
+	return change.original.IconName
+}
+
+// toTransactWriteItem gives the changed icon the version following both the original and its own
+func (change iconChange) toTransactWriteItem(ctx context.Context) (types.TransactWriteItem, error) {
+	condition := iconAbsentCondition
+	var conditionNames map[string]string
+	var conditionValues map[string]types.AttributeValue
+	if change.original != nil {
+		condition = iconUnchangedCondition
+		conditionNames = map[string]string{"#version": versionAttribute}
+		conditionValues = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: fmt.Sprint(change.original.Version)},
+		}
+	}
+
+	if change.changed == nil {
+		key, keyErr := change.original.GetKey(ctx)
+		if keyErr != nil {
+			return types.TransactWriteItem{}, fmt.Errorf("failed to get key for deleting %s: %w", change.original.IconName, keyErr)
+		}
+		return types.TransactWriteItem{Delete: &types.Delete{
+			TableName:                 aws.String(IconsTableName),
+			Key:                       key,
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  conditionNames,
+			ExpressionAttributeValues: conditionValues,
+		}}, nil
+	}
+
+	version := change.changed.Version
+	if change.original != nil {
+		version = max(version, change.original.Version)
+	}
+	change.changed.Version = version + 1
+
+	item, marshalErr := attributevalue.MarshalMap(change.changed)
+	if marshalErr != nil {
+		return types.TransactWriteItem{}, fmt.Errorf("failed to marshal icon item %s: %w", change.changed.IconName, marshalErr)
+	}
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName:                 aws.String(IconsTableName),
+		Item:                      item,
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  conditionNames,
+		ExpressionAttributeValues: conditionValues,
+	}}, nil
+}
+
+// tagRefCountChangeItem adds to the reference count of the tag, creating the tag item if needed
+func tagRefCountChangeItem(ctx context.Context, tag string, delta int64) (types.TransactWriteItem, error) {
+	key, keyErr := (&DyndbTag{Tag: tag}).GetKey(ctx)
+	if keyErr != nil {
+		return types.TransactWriteItem{}, keyErr
+	}
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName:                aws.String(IconTagsTableName),
+		Key:                      key,
+		UpdateExpression:         aws.String("ADD #refCount :delta"),
+		ExpressionAttributeNames: map[string]string{"#refCount": referenceCountAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":delta": &types.AttributeValueMemberN{Value: fmt.Sprint(delta)},
+		},
+	}}, nil
+}
+
+// deleteUnreferencedTag deletes the tag unless an icon has been given it in the meantime
+func (repo *DynamodbRepository) deleteUnreferencedTag(ctx context.Context, tag string) error {
+	key, keyErr := (&DyndbTag{Tag: tag}).GetKey(ctx)
+	if keyErr != nil {
+		return keyErr
+	}
+	_, deleteErr := repo.awsClient.DeleteItem(ctx, &aws_dyndb.DeleteItemInput{
+		TableName:                aws.String(IconTagsTableName),
+		Key:                      key,
+		ConditionExpression:      aws.String("#refCount <= :zero"),
+		ExpressionAttributeNames: map[string]string{"#refCount": referenceCountAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+		},
+	})
+	if deleteErr != nil {
+		if isWriteConflict(deleteErr) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete tag %s: %w", tag, Unwrap(ctx, deleteErr))
+	}
+	return nil
+}
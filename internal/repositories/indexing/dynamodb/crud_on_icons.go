@@ -6,32 +6,20 @@ import (
 	"iconrepo/internal/app/domain"
 	"iconrepo/internal/config"
 	"iconrepo/internal/logging"
-	"strings"
-	"time"
+	"iconrepo/internal/repositories/indexing"
+	"slices"
+	"sort"
 
-	"cirello.io/dynamolock/v2"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/rs/zerolog"
 
 	aws_dyndb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
-type dlockLogger struct {
-	logger zerolog.Logger
-}
-
-func (l *dlockLogger) Println(arg ...any) {
-	if l.logger.GetLevel() == zerolog.DebugLevel {
-		l.logger.Print(arg...)
-	}
-}
-
+// DynamodbRepository writes each item on the condition that it hasn't changed since it was read,
+// and the items of an icon and of its tags in a single transaction. Writes failing on a concurrent change are retried.
 type DynamodbRepository struct {
-	awsClient                *aws_dyndb.Client
-	iconsLockClient          *dynamolock.Client
-	iconTagsLockClient       *dynamolock.Client
-	commonAcquireLockOptions []dynamolock.AcquireLockOption
+	awsClient *aws_dyndb.Client
 }
 
 func NewDynamodbRepository(conf *config.Options) (*DynamodbRepository, error) {
@@ -40,64 +28,15 @@ func NewDynamodbRepository(conf *config.Options) (*DynamodbRepository, error) {
 		return nil, fmt.Errorf("failed to create dynamodb client: %w", clientErr)
 	}
 
-	iconsLockClient, iconsLockClientErr := dynamolock.New(
-		svc,
-		iconsLockTableName,
-		dynamolock.WithLeaseDuration(3*time.Second),
-		dynamolock.WithHeartbeatPeriod(1*time.Second),
-		dynamolock.WithLogger(&dlockLogger{logging.Get().With().Str("unit", "cirello.io/dynamolock/v2").Logger()}),
-	)
-	if iconsLockClientErr != nil {
-		return nil, fmt.Errorf("failed to create iconsLockClient: %w", iconsLockClientErr)
-	}
-
-	iconTagsLockClient, iconTagsLockClientErr := dynamolock.New(
-		svc,
-		iconTagsLockTableName,
-		dynamolock.WithLeaseDuration(3*time.Second),
-		dynamolock.WithHeartbeatPeriod(1*time.Second),
-		dynamolock.WithLogger(&dlockLogger{logging.Get().With().Str("unit", "cirello.io/dynamolock/v2").Logger()}),
-	)
-	if iconsLockClientErr != nil {
-		return nil, fmt.Errorf("failed to create iconTagsLockClient: %w", iconTagsLockClientErr)
+	ctx := logging.Get().WithContext(context.Background())
+	if schemaErr := OpenSchema(ctx, svc, conf.DynamodbCreateTables, conf.DynamodbBillingMode); schemaErr != nil {
+		return nil, fmt.Errorf("failed to open the DynamoDB tables: %w", schemaErr)
 	}
 
-	commonAcquireLockOptions := []dynamolock.AcquireLockOption{
-		dynamolock.ReplaceData(),
-	}
-	// TODO:: let this controllable by an env var
-	commonAcquireLockOptions = append(commonAcquireLockOptions, dynamolock.WithDeleteLockOnRelease())
-
-	return &DynamodbRepository{svc, iconsLockClient, iconTagsLockClient, commonAcquireLockOptions}, nil
+	return &DynamodbRepository{svc}, nil
 }
 
 func (repo *DynamodbRepository) Close() error {
-	logger := logging.Get().With().Str("unit", "DynamodbRepository").Str("method", "Close").Logger()
-
-	var myErr error
-
-	iconsLockCloseErr := repo.iconsLockClient.Close()
-	if iconsLockCloseErr != nil {
-		logger.Err(iconsLockCloseErr).Msg("error while closing iconsLockClose")
-		myErr = iconsLockCloseErr
-	}
-
-	iconTagsLockCloseErr := repo.iconTagsLockClient.Close()
-	if iconTagsLockCloseErr != nil {
-		logger.Err(iconTagsLockCloseErr).Msg("error while closing iconTagsLockClose")
-		if myErr == nil { // we only return the first one
-			myErr = iconTagsLockCloseErr
-		}
-	}
-
-	if myErr != nil {
-		if strings.Contains(myErr.Error(), "client already closed") {
-			logger.Info().Msg("supressing `client already closed` message")
-			return nil
-		}
-		return fmt.Errorf("errors while closing DynamodbRepository: %w", myErr)
-	}
-
 	return nil
 }
 
@@ -130,14 +69,7 @@ func (repo *DynamodbRepository) DescribeIcon(ctx context.Context, iconName strin
 		iconItem.Tags = []string{}
 	}
 
-	return domain.IconDescriptor{
-		IconAttributes: domain.IconAttributes{
-			Name:       iconName,
-			ModifiedBy: iconItem.ModifiedBy,
-			Tags:       iconItem.Tags,
-		},
-		Iconfiles: toIconfileDescriptorList(iconItem.Iconfiles),
-	}, nil
+	return iconItem.toIconDescriptor(), nil
 }
 
 func (repo *DynamodbRepository) GetExistingTags(ctx context.Context) ([]string, error) {
@@ -154,6 +86,10 @@ func (repo *DynamodbRepository) GetExistingTags(ctx context.Context) ([]string,
 		if unmarshalErr := dynTag.unmarshal(tagItem); unmarshalErr != nil {
 			return nil, fmt.Errorf("failed to unmarshal tag: %w", unmarshalErr)
 		}
+		// Left behind if deleting the tag failed after its last icon was untagged
+		if dynTag.ReferenceCount <= 0 {
+			continue
+		}
 		tags = append(tags, dynTag.Tag)
 	}
 	return tags, nil
@@ -164,42 +100,27 @@ func (repo *DynamodbRepository) CreateIcon(
 	iconName string,
 	iconfile domain.IconfileDescriptor,
 	modifiedBy string,
-	createSideEffect func() error,
+	createSideEffect func(ctx context.Context) error,
 ) error {
-	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "CreateIcon").Logger()
-
 	dyndbIconfile := DyndbIconfile{}
 	dyndbIconfile.fromIconfileDescriptor(iconfile)
 
-	icon := &DyndbIcon{
-		IconName:   iconName,
-		ModifiedBy: modifiedBy,
-		Iconfiles:  []DyndbIconfile{dyndbIconfile},
-	}
-
-	lock, lockErr := repo.iconsLockClient.AcquireLockWithContext(ctx, iconName, repo.createAcquireLockOptions("CreateIcon")...)
-	if lockErr != nil {
-		return fmt.Errorf("failed to acquire lock on icons_table#%s: %w", iconName, lockErr)
-	}
-	defer repo.releaseLock(ctx, repo.iconsLockClient, iconName, lock)
-
-	updateErr := repo.updateIcon(ctx, icon)
-	if updateErr != nil {
-		return fmt.Errorf("failed to create icon %s: %w", iconName, updateErr)
-	}
-
-	if createSideEffect != nil {
-		sideEffectErr := createSideEffect()
-		if sideEffectErr != nil {
-			rollbackErr := repo.deleteIcon(ctx, icon)
-			if rollbackErr != nil {
-				logger.Error().Err(rollbackErr).Str("IconName", iconName).Msg("failed to rollback on sideeffect error")
-			}
-			return sideEffectErr
+	changes, changeErr := repo.changeIcons(ctx, []string{iconName}, func(icons map[string]*DyndbIcon) error {
+		if icons[iconName] != nil {
+			return domain.ErrIconAlreadyExists
+		}
+		icons[iconName] = &DyndbIcon{
+			IconName:   iconName,
+			ModifiedBy: modifiedBy,
+			Iconfiles:  []DyndbIconfile{dyndbIconfile},
 		}
+		return nil
+	})
+	if changeErr != nil {
+		return fmt.Errorf("failed to create icon %s: %w", iconName, changeErr)
 	}
 
-	return nil
+	return repo.runSideEffect(ctx, changes, createSideEffect)
 }
 
 func (repo *DynamodbRepository) AddIconfileToIcon(
@@ -207,323 +128,246 @@ func (repo *DynamodbRepository) AddIconfileToIcon(
 	iconName string,
 	iconfile domain.IconfileDescriptor,
 	modifiedBy string,
-	createSideEffect func() error,
+	createSideEffect func(ctx context.Context) error,
 ) error {
-	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "AddIconfileToIcon").Logger()
-
-	lock, lockErr := repo.iconsLockClient.AcquireLockWithContext(ctx, iconName, repo.createAcquireLockOptions("AddIconfileToIcon")...)
-	if lockErr != nil {
-		return fmt.Errorf("failed to acquire lock on icons_table#%s: %w", iconName, lockErr)
-	}
-	defer repo.releaseLock(ctx, repo.iconsLockClient, iconName, lock)
-
-	original, getOriginalErr := repo.getIconItem(ctx, iconName, true)
-	if getOriginalErr != nil {
-		return fmt.Errorf("failed to get original of %s for adding iconfile to it: %w", iconName, getOriginalErr)
-	}
-
-	originalDescriptors := toIconfileDescriptorList(original.Iconfiles)
-	for _, originalDescriptor := range originalDescriptors {
-		if iconfile.Equals(originalDescriptor) {
-			return domain.ErrIconfileAlreadyExists
-		}
-	}
-
 	iconfileToAdd := DyndbIconfile{}
 	iconfileToAdd.fromIconfileDescriptor(iconfile)
 
-	updatedIcon := &DyndbIcon{IconName: iconName, ModifiedBy: modifiedBy}
-	if original != nil {
-		updatedIcon.Iconfiles = original.Iconfiles
-	}
-	updatedIcon.Iconfiles = append(updatedIcon.Iconfiles, iconfileToAdd)
-
-	updateIconErr := repo.updateIcon(ctx, updatedIcon)
-	if updateIconErr != nil {
-		// TODO: back out and propagate meaningful error to client on failed condition validation
-		return fmt.Errorf("failed to update icon %s: %w", iconName, updateIconErr)
-	}
-
-	if createSideEffect != nil {
-		sideEffectErr := createSideEffect()
-		if sideEffectErr != nil {
-			rollbackErr := repo.updateIcon(ctx, original)
-			if rollbackErr != nil {
-				logger.Error().Err(rollbackErr).Str("IconName", iconName).Msg("failed to rollback on sideeffect error")
+	changes, changeErr := repo.changeIcons(ctx, []string{iconName}, func(icons map[string]*DyndbIcon) error {
+		icon := icons[iconName]
+		if icon == nil {
+			return domain.ErrIconNotFound
+		}
+		for _, original := range icon.Iconfiles {
+			if iconfile.Equals(original.toIconfileDescriptor()) {
+				return domain.ErrIconfileAlreadyExists
 			}
-			return sideEffectErr
 		}
+		icon.Iconfiles = append(icon.Iconfiles, iconfileToAdd)
+		icon.ModifiedBy = modifiedBy
+		return nil
+	})
+	if changeErr != nil {
+		return fmt.Errorf("failed to add iconfile %v to %s: %w", iconfile, iconName, changeErr)
 	}
 
-	return nil
+	return repo.runSideEffect(ctx, changes, createSideEffect)
 }
 
-func (repo *DynamodbRepository) AddTag(ctx context.Context, iconName string, tag string, modifiedBy string) error {
-	lock, lockErr := repo.iconsLockClient.AcquireLockWithContext(ctx, iconName, repo.createAcquireLockOptions("AddTag")...)
-	if lockErr != nil {
-		return fmt.Errorf("failed to acquire lock on icons_table#%s: %w", iconName, lockErr)
-	}
-	defer repo.releaseLock(ctx, repo.iconsLockClient, iconName, lock)
-
-	tagsLock, lockErr := repo.iconTagsLockClient.AcquireLockWithContext(ctx, tag, repo.createAcquireLockOptions("AddTag")...)
-	if lockErr != nil {
-		return fmt.Errorf("failed to acquire lock on icon_tags_table#%s: %w", iconName, lockErr)
-	}
-	defer repo.releaseLock(ctx, repo.iconTagsLockClient, tag, tagsLock)
-
-	oldIconItem, getIconItemErr := repo.getIconItem(ctx, iconName, false)
-	if getIconItemErr != nil {
-		return fmt.Errorf("failed to get icon item %s to add tag %s to: %w", iconName, tag, getIconItemErr)
-	}
-
-	oldTags := oldIconItem.Tags
-	for _, oldTag := range oldTags {
-		if tag == oldTag {
-			return nil
+// AddTag adds the tag to the icon and counts the reference to it in the same transaction
+func (repo *DynamodbRepository) AddTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	changes, changeErr := repo.changeIcons(ctx, []string{iconName}, func(icons map[string]*DyndbIcon) error {
+		icon := icons[iconName]
+		if icon == nil {
+			return domain.ErrIconNotFound
 		}
-	}
-
-	newTags := append(oldTags, tag)
-
-	newIconItem := &DyndbIcon{IconName: iconName, Iconfiles: oldIconItem.Iconfiles, ModifiedBy: modifiedBy, Tags: newTags}
-
-	updateIconErr := repo.updateIcon(ctx, newIconItem)
-	if updateIconErr != nil {
-		return fmt.Errorf("failed to update icon item %s to add tag %s to: %w", iconName, tag, updateIconErr)
-	}
-
-	oldTagItem, err := repo.getTagItem(ctx, tag, true)
-	if err != nil {
-		return fmt.Errorf("failed to get old tag-items for icon %s about to be deleted: %w", iconName, err)
-	}
-	if oldTagItem == nil {
-		oldTagItem = &DyndbTag{tag, 0}
-	}
-
-	updateTagsErr := repo.updateTag(ctx, *oldTagItem, true)
-	if updateTagsErr != nil {
-		// Roll back
-		updateErr := repo.updateIcon(ctx, oldIconItem)
-		if updateErr != nil {
-			return fmt.Errorf("failed to rollback updating icon %s with tag %s: %w", iconName, tag, updateErr)
+		if slices.Contains(icon.Tags, tag) {
+			return nil
 		}
-		return fmt.Errorf("failed to update tags table with tag %s to be added to icon item %s: %w", tag, iconName, updateTagsErr)
+		icon.Tags = append(icon.Tags, tag)
+		icon.ModifiedBy = modifiedBy
+		return nil
+	})
+	if changeErr != nil {
+		return fmt.Errorf("failed to add tag %s to icon %s: %w", tag, iconName, changeErr)
 	}
 
-	return nil
+	return repo.runSideEffect(ctx, changes, createSideEffect)
 }
 
-func (repo *DynamodbRepository) RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy string) error {
-	logger := zerolog.Ctx(ctx).With().Str("method", "DynamodbRepository.RemoveTag").Logger()
-
-	lock, lockErr := repo.iconsLockClient.AcquireLockWithContext(ctx, iconName, repo.createAcquireLockOptions("RemoveTag")...)
-	if lockErr != nil {
-		lock, readDataErr := repo.iconsLockClient.Get(iconName)
-		if readDataErr != nil {
-			logger.Error().Err(readDataErr).Str("lockTable", "icons").Str("lockKey", iconName).Msg("failed to read lock data after failing to acquire lock")
+func (repo *DynamodbRepository) RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	changes, changeErr := repo.changeIcons(ctx, []string{iconName}, func(icons map[string]*DyndbIcon) error {
+		icon := icons[iconName]
+		if icon == nil {
+			return domain.ErrIconNotFound
 		}
-		return fmt.Errorf("failed to acquire lock on icons_table#%s (<%s): %w", iconName, string(lock.Data()), lockErr)
-	}
-	defer repo.releaseLock(ctx, repo.iconsLockClient, iconName, lock)
-
-	tagsLock, lockErr := repo.iconTagsLockClient.AcquireLockWithContext(ctx, tag, repo.createAcquireLockOptions("RemoveTag")...)
-	if lockErr != nil {
-		return fmt.Errorf("failed to acquire lock on icon_tags_table#%s: %w", tag, lockErr)
-	}
-	defer repo.releaseLock(ctx, repo.iconTagsLockClient, tag, tagsLock)
-
-	oldIconItem, getIconItemErr := repo.getIconItem(ctx, iconName, false)
-	if getIconItemErr != nil {
-		return fmt.Errorf("failed to get icon item %s to add tag %s to: %w", iconName, tag, getIconItemErr)
-	}
-
-	newTags := []string{}
-	oldTags := oldIconItem.Tags
-	foundTag := false
-	for _, oldTag := range oldTags {
-		if tag == oldTag {
-			foundTag = true
-			continue
+		if !slices.Contains(icon.Tags, tag) {
+			return nil
 		}
-		newTags = append(newTags, oldTag)
-	}
-	if !foundTag {
+		icon.Tags = slices.DeleteFunc(icon.Tags, func(oldTag string) bool { return oldTag == tag })
+		icon.ModifiedBy = modifiedBy
 		return nil
+	})
+	if changeErr != nil {
+		return fmt.Errorf("failed to remove tag %s from icon %s: %w", tag, iconName, changeErr)
 	}
 
-	newIconItem := &DyndbIcon{IconName: iconName, Iconfiles: oldIconItem.Iconfiles, ModifiedBy: modifiedBy, Tags: newTags}
-
-	updateIconErr := repo.updateIcon(ctx, newIconItem)
-	if updateIconErr != nil {
-		return fmt.Errorf("failed to update icon item %s to add tag %s to: %w", iconName, tag, updateIconErr)
-	}
-
-	oldTagItem, err := repo.getTagItem(ctx, tag, true)
-	if err != nil {
-		return fmt.Errorf("failed to get old tag-items for icon %s about to be deleted: %w", iconName, err)
-	}
-
-	updateTagsErr := repo.updateTag(ctx, *oldTagItem, false)
-	if updateTagsErr != nil {
-		// Roll back
-		updateErr := repo.updateIcon(ctx, oldIconItem)
-		if updateErr != nil {
-			return fmt.Errorf("failed to rollback updating icon %s with tag %s: %w", iconName, tag, updateErr)
-		}
-		return fmt.Errorf("failed to update tags table with tag %s to be added to icon item %s: %w", tag, iconName, updateTagsErr)
-	}
-
-	return nil
+	return repo.runSideEffect(ctx, changes, createSideEffect)
 }
 
-func (repo *DynamodbRepository) DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func() error) error {
-	logger := zerolog.Ctx(ctx).With().Str("method", "DynamodbRepository.DeleteIcon").Str("iconName", iconName).Logger()
-
-	lock, lockErr := repo.iconsLockClient.AcquireLockWithContext(ctx, iconName, repo.createAcquireLockOptions("DeleteIcon")...)
-	if lockErr != nil {
-		lock, readDataErr := repo.iconsLockClient.Get(iconName)
-		if readDataErr != nil {
-			logger.Error().Err(readDataErr).Str("lockTable", "icons").Str("lockKey", iconName).Msg("failed to read lock data after failing to acquire lock")
+func (repo *DynamodbRepository) DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	changes, changeErr := repo.changeIcons(ctx, []string{iconName}, func(icons map[string]*DyndbIcon) error {
+		if icons[iconName] == nil {
+			return domain.ErrIconNotFound
 		}
-		return fmt.Errorf("failed to acquire lock on icons_table#%s (<%s): %w", iconName, string(lock.Data()), lockErr)
-	}
-	defer repo.releaseLock(ctx, repo.iconsLockClient, iconName, lock)
-
-	return repo.deleteIconNoLock(ctx, iconName, modifiedBy, createSideEffect)
-}
-
-func (repo *DynamodbRepository) deleteIconNoLock(ctx context.Context, iconName string, modifiedBy string, createSideEffect func() error) error {
-	logger := zerolog.Ctx(ctx).With().Str("method", "DynamodbRepository.deleteIcon0").Str("iconName", iconName).Logger()
-
-	iconItem, getIconItemErr := repo.getIconItem(ctx, iconName, false)
-	if getIconItemErr != nil {
-		return fmt.Errorf("failed to fetch %s for deletion (to delete associated tags): %w", iconName, getIconItemErr)
+		delete(icons, iconName)
+		return nil
+	})
+	if changeErr != nil {
+		return fmt.Errorf("failed to delete icon %s: %w", iconName, changeErr)
 	}
 
-	tagsUpdatedWithDecrRefCount := []*DyndbTag{}
-
-	rollbackTagsUpdatedSoFar := func() {
-		for _, item := range tagsUpdatedWithDecrRefCount {
-			rollbackErr := repo.updateTag(ctx, *item, true)
-			if rollbackErr != nil {
-				logger.Error().Err(rollbackErr).Str("icon", iconName).Str("tag", item.Tag).Msg("failed to rollback tag ref-count decrement")
-			}
-		}
+	sideEffectErr := repo.runSideEffect(ctx, changes, createSideEffect)
+	if sideEffectErr != nil {
+		return fmt.Errorf("failed to delete icon %s due to side-effect failure: %w", iconName, sideEffectErr)
 	}
+	return nil
+}
 
-	for _, oldTag := range iconItem.Tags {
-		oldItem, err := repo.getTagItem(ctx, oldTag, true)
-		if err != nil {
-			return fmt.Errorf("failed to get old tag-items for icon %s about to be deleted: %w", iconName, err)
+// DeleteIconfile deletes the icon along with its last iconfile
+func (repo *DynamodbRepository) DeleteIconfile(
+	ctx context.Context,
+	iconName string,
+	iconfile domain.IconfileDescriptor,
+	modifiedBy string,
+	createSideEffect func(ctx context.Context) error,
+) error {
+	changes, changeErr := repo.changeIcons(ctx, []string{iconName}, func(icons map[string]*DyndbIcon) error {
+		icon := icons[iconName]
+		if icon == nil {
+			return domain.ErrIconNotFound
 		}
-
-		tagsLock, lockErr := repo.iconTagsLockClient.AcquireLockWithContext(ctx, oldTag, repo.createAcquireLockOptions("DeleteIcon")...)
-		if lockErr != nil {
-			rollbackTagsUpdatedSoFar()
-			return fmt.Errorf("failed to acquire lock on icon_tags_table#%s: %w", iconName, lockErr)
+		remaining := slices.DeleteFunc(slices.Clone(icon.Iconfiles), func(existing DyndbIconfile) bool {
+			return existing.toIconfileDescriptor().Equals(iconfile)
+		})
+		if len(remaining) == len(icon.Iconfiles) {
+			return domain.ErrIconfileNotFound
 		}
-		defer repo.releaseLock(ctx, repo.iconTagsLockClient, oldTag, tagsLock)
-
-		updateErr := repo.updateTag(ctx, *oldItem, false)
-		if updateErr != nil {
-			rollbackTagsUpdatedSoFar()
-			return fmt.Errorf("failed to update tags for icon %s about to be deleted: %w", iconName, updateErr)
+		if len(remaining) == 0 {
+			delete(icons, iconName)
+			return nil
 		}
-		tagsUpdatedWithDecrRefCount = append(tagsUpdatedWithDecrRefCount, oldItem)
-	}
-
-	deleteErr := repo.deleteIcon(ctx, iconItem)
-	if deleteErr != nil {
-		return fmt.Errorf("failed to delete icon %s: %w", iconName, deleteErr)
+		icon.Iconfiles = remaining
+		icon.ModifiedBy = modifiedBy
+		return nil
+	})
+	if changeErr != nil {
+		return fmt.Errorf("failed to delete iconfile %v from %s: %w", iconfile, iconName, changeErr)
 	}
 
-	if createSideEffect != nil {
-		sideEffectErr := createSideEffect()
-		if sideEffectErr != nil {
-			rollbackTagsUpdatedSoFar()
-			rollbackErr := repo.updateIcon(ctx, iconItem)
-			if rollbackErr != nil {
-				logger.Error().Err(rollbackErr).Str("IconName", iconName).Msg("failed to rollback on side-effect error")
-			}
-			return fmt.Errorf("failed to delete icon %s due to side-effect failure: %w", iconName, sideEffectErr)
-		}
+	sideEffectErr := repo.runSideEffect(ctx, changes, createSideEffect)
+	if sideEffectErr != nil {
+		return fmt.Errorf("failed to delete icon file %v from %s due to side-effect failure: %w", iconfile, iconName, sideEffectErr)
 	}
-
 	return nil
 }
 
-func (repo *DynamodbRepository) DeleteIconfile(
+func (repo *DynamodbRepository) SetIconfileReviewStatus(
 	ctx context.Context,
 	iconName string,
 	iconfile domain.IconfileDescriptor,
+	status domain.ReviewStatus,
 	modifiedBy string,
-	createSideEffect func() error,
+	createSideEffect func(ctx context.Context) error,
 ) error {
-	logger := zerolog.Ctx(ctx).With().Str("method", "DynamodbRepository.DeleteIconfile").Str("iconName", iconName).Str("iconfile", iconfile.String()).Logger()
+	changes, changeErr := repo.changeIcons(ctx, []string{iconName}, func(icons map[string]*DyndbIcon) error {
+		icon := icons[iconName]
+		if icon == nil {
+			return domain.ErrIconNotFound
+		}
+		for i := range icon.Iconfiles {
+			if icon.Iconfiles[i].toIconfileDescriptor().Equals(iconfile) {
+				icon.Iconfiles[i].ReviewStatus = string(status)
+				icon.ModifiedBy = modifiedBy
+				return nil
+			}
+		}
+		return domain.ErrIconfileNotFound
+	})
+	if changeErr != nil {
+		return fmt.Errorf("failed to set the review status of %v of %s: %w", iconfile, iconName, changeErr)
+	}
 
-	readDataLock, _ := repo.iconsLockClient.Get(iconName)
-	logger.Debug().Str("currentLockData", string(readDataLock.Data())).Msg("about to acquire lock")
+	return repo.runSideEffect(ctx, changes, createSideEffect)
+}
 
-	lock, lockErr := repo.iconsLockClient.AcquireLockWithContext(ctx, iconName, repo.createAcquireLockOptions("DeleteIconfile")...)
-	if lockErr != nil {
-		lock, readDataErr := repo.iconsLockClient.Get(iconName)
-		if readDataErr != nil {
-			logger.Error().Err(readDataErr).Str("lockTable", "icons").Str("lockKey", iconName).Msg("failed to read lock data after failing to acquire lock")
+func (repo *DynamodbRepository) SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	changes, changeErr := repo.changeIcons(ctx, []string{iconName}, func(icons map[string]*DyndbIcon) error {
+		icon := icons[iconName]
+		if icon == nil {
+			return domain.ErrIconNotFound
 		}
-		return fmt.Errorf("failed to acquire lock on icons_table#%s (<%s): %w", iconName, string(lock.Data()), lockErr)
+		icon.Description = description
+		icon.ModifiedBy = modifiedBy
+		return nil
+	})
+	if changeErr != nil {
+		return fmt.Errorf("failed to set the description of %s: %w", iconName, changeErr)
 	}
-	defer repo.releaseLock(ctx, repo.iconsLockClient, iconName, lock)
 
-	logger.Debug().Msg("about to call repo.getIconItem...")
-	oldIconItem, getIconItemErr := repo.getIconItem(ctx, iconName, false)
-	if getIconItemErr != nil {
-		return fmt.Errorf("failed to get DyndbIcon to remove %s: %w", iconName, getIconItemErr)
-	}
+	return repo.runSideEffect(ctx, changes, createSideEffect)
+}
 
-	newIconItem := DyndbIcon{
-		IconName:   iconName,
-		ModifiedBy: modifiedBy,
-		Tags:       oldIconItem.Tags,
+// ApplyChangeset writes the state the operations leave the icons involved in, along with the reference counts
+// of their tags, in a single transaction. The icons are restored if the side-effect fails.
+func (repo *DynamodbRepository) ApplyChangeset(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	iconNames := []string{}
+	for _, op := range ops {
+		if !slices.Contains(iconNames, op.IconName) {
+			iconNames = append(iconNames, op.IconName)
+		}
 	}
+	sort.Strings(iconNames)
 
-	newIconfiles := []DyndbIconfile{}
-	found := false
-	for _, oldIconfile := range oldIconItem.Iconfiles {
-		if oldIconfile.toIconfileDescriptor().Equals(iconfile) {
-			found = true
-			continue
+	changes, changeErr := repo.changeIcons(ctx, iconNames, func(icons map[string]*DyndbIcon) error {
+		changed := map[string]*domain.IconDescriptor{}
+		for iconName, icon := range icons {
+			iconDesc := icon.toIconDescriptor()
+			changed[iconName] = &iconDesc
+		}
+
+		for _, op := range ops {
+			var applyErr error
+			changed[op.IconName], applyErr = op.ApplyTo(changed[op.IconName], modifiedBy)
+			if applyErr != nil {
+				return applyErr
+			}
 		}
-		newIconfiles = append(newIconfiles, oldIconfile)
-	}
-	if !found {
-		return domain.ErrIconfileNotFound
-	}
 
-	if len(newIconfiles) == 0 {
-		deleteIconErr := repo.deleteIconNoLock(ctx, iconName, modifiedBy, createSideEffect)
-		if deleteIconErr != nil {
-			return fmt.Errorf("failed to delete icon (with no more iconfiles left) %s: %w", iconName, deleteIconErr)
+		for _, iconName := range iconNames {
+			iconDesc := changed[iconName]
+			if iconDesc == nil {
+				delete(icons, iconName)
+				continue
+			}
+			iconItem := &DyndbIcon{}
+			iconItem.fromIconDescriptor(*iconDesc)
+			if original := icons[iconName]; original != nil {
+				iconItem.Version = original.Version
+			}
+			icons[iconName] = iconItem
 		}
 		return nil
+	})
+	if changeErr != nil {
+		return fmt.Errorf("failed to apply changeset: %w", changeErr)
 	}
-	newIconItem.Iconfiles = newIconfiles
 
-	updateErr := repo.updateIcon(ctx, &newIconItem)
-	if updateErr != nil {
-		return fmt.Errorf("failed to update icon %s with %v removed: %w", iconName, iconfile, updateErr)
+	sideEffectErr := repo.runSideEffect(ctx, changes, createSideEffect)
+	if sideEffectErr != nil {
+		return fmt.Errorf("failed to apply changeset due to side-effect failure: %w", sideEffectErr)
 	}
+	return nil
+}
 
-	if createSideEffect != nil {
-		sideEffectErr := createSideEffect()
-		if sideEffectErr != nil {
-			rollbackErr := repo.updateIcon(ctx, oldIconItem)
-			if rollbackErr != nil {
-				logger.Error().Err(rollbackErr).Str("IconName", iconName).Msg("failed to rollback on side-effect error")
-			}
-			return fmt.Errorf("failed to delete icon file %v from %s due to side-effect failure: %w", iconfile, iconName, sideEffectErr)
+// runSideEffect hands the state of the icons changed over to the side-effect and reverts the changes if the side-effect fails
+func (repo *DynamodbRepository) runSideEffect(ctx context.Context, changes []iconChange, createSideEffect func(ctx context.Context) error) error {
+	if createSideEffect == nil {
+		return nil
+	}
+	committed := []domain.IconDescriptor{}
+	for _, change := range changes {
+		if change.changed == nil {
+			committed = append(committed, domain.IconDescriptor{IconAttributes: domain.IconAttributes{Name: change.iconName(), Tags: []string{}}})
+			continue
 		}
+		committed = append(committed, change.changed.toIconDescriptor())
+	}
+	sideEffectErr := createSideEffect(indexing.ContextWithCommittedIcons(ctx, committed...))
+	if sideEffectErr != nil {
+		repo.revertIconChanges(ctx, changes)
+		return sideEffectErr
 	}
-
 	return nil
 }
 
@@ -560,148 +404,3 @@ func (repo *DynamodbRepository) getIconItem(ctx context.Context, iconName string
 
 	return icon, nil
 }
-
-func (repo *DynamodbRepository) updateIcon(ctx context.Context, icon *DyndbIcon) error {
-	logger := zerolog.Ctx(ctx).With().Str("method", "DynamodbRepository.updateIcon").Logger()
-
-	newItem, marshalErr := attributevalue.MarshalMap(icon)
-	if marshalErr != nil {
-		return fmt.Errorf("failed to marshal new icon item for updating %s: %w", icon.IconName, marshalErr)
-	}
-
-	input := &aws_dyndb.PutItemInput{
-		TableName:              aws.String(IconsTableName),
-		Item:                   newItem,
-		ReturnConsumedCapacity: "TOTAL",
-	}
-	output, err := repo.awsClient.PutItem(ctx, input)
-	if err != nil {
-		return fmt.Errorf("failed to update icon %s: %w", icon.IconName, Unwrap(ctx, err))
-	}
-	if logger.GetLevel() == zerolog.DebugLevel {
-		logger.Debug().Str("IconName", icon.IconName).Interface("output", output).Send()
-	}
-
-	return nil
-}
-
-func (repo *DynamodbRepository) deleteIcon(ctx context.Context, iconItem *DyndbIcon) error {
-	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "deleteIcon").Logger()
-
-	pk, getKeyErr := iconItem.GetKey(ctx)
-	if getKeyErr != nil {
-		return fmt.Errorf("failed to get key for deleting %s: %w", iconItem.IconName, getKeyErr)
-	}
-
-	input := &aws_dyndb.DeleteItemInput{
-		TableName:              aws.String(IconsTableName),
-		Key:                    pk,
-		ReturnConsumedCapacity: "TOTAL",
-	}
-	output, err := repo.awsClient.DeleteItem(ctx, input)
-	if err != nil {
-		return fmt.Errorf("failed to delete icon %s: %w", iconItem.IconName, Unwrap(ctx, err))
-	}
-
-	if logger.GetLevel() == zerolog.DebugLevel {
-		logger.Debug().Str("IconName", iconItem.IconName).Interface("delete-result", output).Send()
-	}
-
-	return nil
-}
-
-func (repo *DynamodbRepository) getTagItem(ctx context.Context, tag string, consistentRead bool) (*DyndbTag, error) {
-	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "getTagItem").Logger()
-
-	tagItem := &DyndbTag{Tag: tag}
-	key, keyErr := tagItem.GetKey(ctx)
-	if keyErr != nil {
-		return tagItem, keyErr
-	}
-
-	input := &aws_dyndb.GetItemInput{
-		TableName:      aws.String(IconTagsTableName),
-		Key:            key,
-		ConsistentRead: &consistentRead,
-	}
-	output, getItemErr := repo.awsClient.GetItem(ctx, input)
-	if getItemErr != nil {
-		return tagItem, fmt.Errorf("failed to get tag item for %s: %w", tag, Unwrap(ctx, getItemErr))
-	}
-
-	if logger.GetLevel() == zerolog.DebugLevel {
-		logger.Debug().Interface("output", output).Str("api", "dynamodb.GetItem").Send()
-	}
-	if output.Item == nil {
-		return nil, nil
-	}
-
-	unmarshalErr := tagItem.unmarshal(output.Item)
-	if unmarshalErr != nil {
-		return tagItem, fmt.Errorf("failed to unmarshal tag item for %s: %w", tag, Unwrap(ctx, unmarshalErr))
-	}
-
-	return tagItem, nil
-}
-
-func (repo *DynamodbRepository) updateTag(ctx context.Context, tagItem DyndbTag, add bool) error {
-	var newRefCount int64
-	if add {
-		newRefCount = tagItem.ReferenceCount + 1
-	} else {
-		newRefCount = tagItem.ReferenceCount - 1
-	}
-
-	if newRefCount > 0 {
-		newItem, marshalErr := attributevalue.MarshalMap(&DyndbTag{tagItem.Tag, newRefCount})
-		if marshalErr != nil {
-			return fmt.Errorf("failed to marshal new icon item for updating %s: %w", tagItem.Tag, marshalErr)
-		}
-
-		input := &aws_dyndb.PutItemInput{
-			TableName:              aws.String(IconTagsTableName),
-			Item:                   newItem,
-			ReturnConsumedCapacity: "TOTAL",
-		}
-		_, err := repo.awsClient.PutItem(ctx, input)
-		if err != nil {
-			return fmt.Errorf("failed to update icon %s: %w", tagItem.Tag, Unwrap(ctx, err))
-		}
-
-		return nil
-	}
-
-	pk, getKeyErr := tagItem.GetKey(ctx)
-	if getKeyErr != nil {
-		return fmt.Errorf("failed to generate PK of %v for removal: %w", tagItem, getKeyErr)
-	}
-
-	input := &aws_dyndb.DeleteItemInput{
-		TableName:              aws.String(IconTagsTableName),
-		Key:                    pk,
-		ReturnConsumedCapacity: "TOTAL",
-	}
-	_, deleteErr := repo.awsClient.DeleteItem(ctx, input)
-	if deleteErr != nil {
-		return fmt.Errorf("failed to delete tag %v: %w", tagItem, deleteErr)
-	}
-
-	return nil
-}
-
-func (repo *DynamodbRepository) createAcquireLockOptions(data string) []dynamolock.AcquireLockOption {
-	return append(repo.commonAcquireLockOptions, dynamolock.WithData([]byte(data)))
-}
-
-func (repo *DynamodbRepository) releaseLock(ctx context.Context, lockClient *dynamolock.Client, lockKey string, lock *dynamolock.Lock) {
-	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "releaseLock").Logger()
-	success, releaseLockErr := lockClient.ReleaseLock(lock)
-	if releaseLockErr != nil {
-		logger.Error().Err(releaseLockErr).Str("key", lockKey).Msg("error while releasing lock")
-	}
-	if success {
-		logger.Debug().Str("lockTable", "icons").Str("lockKey", lockKey).Bool("lockReleaseResult", success).Send()
-	} else {
-		logger.Info().Str("lockTable", "icons").Str("lockKey", lockKey).Bool("lockReleaseResult", success).Send()
-	}
-}
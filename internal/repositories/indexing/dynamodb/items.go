@@ -4,36 +4,48 @@ import (
 	"context"
 	"fmt"
 	"iconrepo/internal/app/domain"
+	"slices"
 
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 const (
-	IconsTableName        string = "icons"
-	iconNameAttribute     string = "IconName"
-	IconTagsTableName     string = "icon_tags"
-	tagAttribute          string = "Tag"
-	iconsLockTableName    string = "icons_locks"
-	iconTagsLockTableName string = "icon_tags_locks"
+	IconsTableName          string = "icons"
+	iconNameAttribute       string = "IconName"
+	IconTagsTableName       string = "icon_tags"
+	tagAttribute            string = "Tag"
+	versionAttribute        string = "Version"
+	referenceCountAttribute string = "ReferenceCount"
+	IconAuditTableName      string = "icon_audit"
+	auditEntryIDAttribute   string = "EntryID"
+	actorAttribute          string = "Actor"
+	auditLogAttribute       string = "AuditLog"
+	AuditByActorIndexName   string = "icon_audit_by_actor"
+	AuditByTimeIndexName    string = "icon_audit_by_time"
+	IconOutboxTableName     string = "icon_outbox"
+	IconTagMembersTableName string = "icon_tag_members"
 )
 
 type DyndbIconfile struct {
-	Format string `dynamodbav:"Format"`
-	Size   string `dynamodbav:"Size"`
+	Format       string `dynamodbav:"Format"`
+	Size         string `dynamodbav:"Size"`
+	ReviewStatus string `dynamodbav:"ReviewStatus,omitempty"`
 }
 
 func (dyIconfile *DyndbIconfile) toIconfileDescriptor() domain.IconfileDescriptor {
 	return domain.IconfileDescriptor{
-		Format: dyIconfile.Format,
-		Size:   dyIconfile.Size,
+		Format:       dyIconfile.Format,
+		Size:         dyIconfile.Size,
+		ReviewStatus: domain.ReviewStatus(dyIconfile.ReviewStatus),
 	}
 }
 
 func (dyIconfile *DyndbIconfile) fromIconfileDescriptor(descriptor domain.IconfileDescriptor) {
 	newIconfile := DyndbIconfile{
-		Format: descriptor.Format,
-		Size:   descriptor.Size,
+		Format:       descriptor.Format,
+		Size:         descriptor.Size,
+		ReviewStatus: string(descriptor.ReviewStatus),
 	}
 	*dyIconfile = newIconfile
 }
@@ -47,10 +59,23 @@ func toIconfileDescriptorList(iconfiles []DyndbIconfile) []domain.IconfileDescri
 }
 
 type DyndbIcon struct {
-	IconName   string          `dynamodbav:"IconName"`
-	ModifiedBy string          `dynamodbav:"ModifiedBy"`
-	Iconfiles  []DyndbIconfile `dynamodbav:"Iconfiles"`
-	Tags       []string        `dynamodbav:"Tags"`
+	IconName    string          `dynamodbav:"IconName"`
+	ModifiedBy  string          `dynamodbav:"ModifiedBy"`
+	Description string          `dynamodbav:"Description,omitempty"`
+	Iconfiles   []DyndbIconfile `dynamodbav:"Iconfiles"`
+	Tags        []string        `dynamodbav:"Tags"`
+	// Version is incremented with each write for the writes to be conditional on the version read
+	Version int64 `dynamodbav:"Version"`
+}
+
+func (dyIcon *DyndbIcon) clone() *DyndbIcon {
+	if dyIcon == nil {
+		return nil
+	}
+	clone := *dyIcon
+	clone.Iconfiles = slices.Clone(dyIcon.Iconfiles)
+	clone.Tags = slices.Clone(dyIcon.Tags)
+	return &clone
 }
 
 func (dyIcon *DyndbIcon) GetKey(ctx context.Context) (map[string]types.AttributeValue, error) {
@@ -76,14 +101,31 @@ func (dyIcon *DyndbIcon) unmarshal(attrmap map[string]types.AttributeValue) erro
 func (dyIcon *DyndbIcon) toIconDescriptor() domain.IconDescriptor {
 	return domain.IconDescriptor{
 		IconAttributes: domain.IconAttributes{
-			Name:       dyIcon.IconName,
-			ModifiedBy: dyIcon.ModifiedBy,
-			Tags:       dyIcon.Tags,
+			Name:        dyIcon.IconName,
+			ModifiedBy:  dyIcon.ModifiedBy,
+			Description: dyIcon.Description,
+			Tags:        dyIcon.Tags,
 		},
 		Iconfiles: toIconfileDescriptorList(dyIcon.Iconfiles),
 	}
 }
 
+func (dyIcon *DyndbIcon) fromIconDescriptor(descriptor domain.IconDescriptor) {
+	iconfiles := []DyndbIconfile{}
+	for _, iconfileDescriptor := range descriptor.Iconfiles {
+		iconfile := DyndbIconfile{}
+		iconfile.fromIconfileDescriptor(iconfileDescriptor)
+		iconfiles = append(iconfiles, iconfile)
+	}
+	*dyIcon = DyndbIcon{
+		IconName:    descriptor.Name,
+		ModifiedBy:  descriptor.ModifiedBy,
+		Description: descriptor.Description,
+		Iconfiles:   iconfiles,
+		Tags:        descriptor.Tags,
+	}
+}
+
 type DyndbTag struct {
 	Tag            string `dynamodbav:"Tag"`
 	ReferenceCount int64  `dynamodbav:"ReferenceCount"`
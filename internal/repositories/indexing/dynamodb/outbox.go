@@ -0,0 +1,157 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	aws_dyndb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rs/xid"
+)
+
+// DyndbOutboxEntry is keyed by an entry id starting with the timestamp of the entry, so that the entries sort in chronological order.
+// The changes are kept as JSON.
+type DyndbOutboxEntry struct {
+	EntryID    string `dynamodbav:"EntryID"`
+	RecordedAt string `dynamodbav:"RecordedAt"`
+	HeldUntil  string `dynamodbav:"HeldUntil,omitempty"`
+	Actor      string `dynamodbav:"Actor"`
+	Changes    string `dynamodbav:"Changes"`
+	Attempts   int    `dynamodbav:"Attempts"`
+	LastError  string `dynamodbav:"LastError,omitempty"`
+}
+
+func outboxEntryKey(entryID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		auditEntryIDAttribute: &types.AttributeValueMemberS{Value: entryID},
+	}
+}
+
+func (dyEntry *DyndbOutboxEntry) GetKey(ctx context.Context) (map[string]types.AttributeValue, error) {
+	return outboxEntryKey(dyEntry.EntryID), nil
+}
+
+func (dyEntry *DyndbOutboxEntry) unmarshal(attrmap map[string]types.AttributeValue) error {
+	unmarshalErr := attributevalue.UnmarshalMap(attrmap, dyEntry)
+	if unmarshalErr != nil {
+		return fmt.Errorf("failed to unmarshal %T: %w", DyndbOutboxEntry{}, unmarshalErr)
+	}
+	return nil
+}
+
+func (dyEntry *DyndbOutboxEntry) toOutboxEntry() (domain.OutboxEntry, error) {
+	recordedAt, parseErr := time.Parse(auditTimestampFormat, dyEntry.RecordedAt)
+	if parseErr != nil {
+		return domain.OutboxEntry{}, fmt.Errorf("failed to parse timestamp of outbox entry %s: %w", dyEntry.EntryID, parseErr)
+	}
+	entry := domain.OutboxEntry{
+		ID:         dyEntry.EntryID,
+		RecordedAt: recordedAt,
+		Actor:      dyEntry.Actor,
+		Attempts:   dyEntry.Attempts,
+		LastError:  dyEntry.LastError,
+	}
+	if dyEntry.HeldUntil != "" {
+		if entry.HeldUntil, parseErr = time.Parse(auditTimestampFormat, dyEntry.HeldUntil); parseErr != nil {
+			return domain.OutboxEntry{}, fmt.Errorf("failed to parse deadline of outbox entry %s: %w", dyEntry.EntryID, parseErr)
+		}
+	}
+	if unmarshalErr := json.Unmarshal([]byte(dyEntry.Changes), &entry.Changes); unmarshalErr != nil {
+		return domain.OutboxEntry{}, fmt.Errorf("failed to unmarshal changes of outbox entry %s: %w", dyEntry.EntryID, unmarshalErr)
+	}
+	return entry, nil
+}
+
+func (repo *DynamodbRepository) AddOutboxEntry(ctx context.Context, entry domain.OutboxEntry) (string, error) {
+	changes, marshalErr := json.Marshal(entry.Changes)
+	if marshalErr != nil {
+		return "", fmt.Errorf("failed to marshal changes of outbox entry: %w", marshalErr)
+	}
+	timestamp := formatAuditTimestamp(entry.RecordedAt)
+	dyEntry := DyndbOutboxEntry{
+		EntryID:    timestamp + "#" + xid.New().String(),
+		RecordedAt: timestamp,
+		Actor:      entry.Actor,
+		Changes:    string(changes),
+	}
+	if !entry.HeldUntil.IsZero() {
+		dyEntry.HeldUntil = formatAuditTimestamp(entry.HeldUntil)
+	}
+
+	item, marshalItemErr := attributevalue.MarshalMap(dyEntry)
+	if marshalItemErr != nil {
+		return "", fmt.Errorf("failed to marshal outbox entry: %w", marshalItemErr)
+	}
+	_, putErr := repo.awsClient.PutItem(ctx, &aws_dyndb.PutItemInput{
+		TableName: aws.String(IconOutboxTableName),
+		Item:      item,
+	})
+	if putErr != nil {
+		return "", fmt.Errorf("failed to add outbox entry: %w", Unwrap(ctx, putErr))
+	}
+	return dyEntry.EntryID, nil
+}
+
+func (repo *DynamodbRepository) RemoveOutboxEntry(ctx context.Context, id string) error {
+	_, deleteErr := repo.awsClient.DeleteItem(ctx, &aws_dyndb.DeleteItemInput{
+		TableName: aws.String(IconOutboxTableName),
+		Key:       outboxEntryKey(id),
+	})
+	if deleteErr != nil {
+		return fmt.Errorf("failed to remove outbox entry %s: %w", id, Unwrap(ctx, deleteErr))
+	}
+	return nil
+}
+
+// GetOutboxEntries returns the entries recorded before the time given and no longer held by then, the oldest first
+func (repo *DynamodbRepository) GetOutboxEntries(ctx context.Context, before time.Time) ([]domain.OutboxEntry, error) {
+	filters := []string{"RecordedAt < :before", "(attribute_not_exists(HeldUntil) OR HeldUntil < :before)"}
+	items, scanErr := repo.scanFilteredItems(ctx, IconOutboxTableName, filters, map[string]types.AttributeValue{
+		":before": &types.AttributeValueMemberS{Value: formatAuditTimestamp(before)},
+	}, nil)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	entries := []domain.OutboxEntry{}
+	for _, item := range items {
+		dyEntry := DyndbOutboxEntry{}
+		if unmarshalErr := dyEntry.unmarshal(item); unmarshalErr != nil {
+			return nil, unmarshalErr
+		}
+		entry, convErr := dyEntry.toOutboxEntry()
+		if convErr != nil {
+			return nil, convErr
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// RecordOutboxAttempt leaves alone entries which have been removed meanwhile
+func (repo *DynamodbRepository) RecordOutboxAttempt(ctx context.Context, id string, attemptErr error) error {
+	_, updateErr := repo.awsClient.UpdateItem(ctx, &aws_dyndb.UpdateItemInput{
+		TableName:           aws.String(IconOutboxTableName),
+		Key:                 outboxEntryKey(id),
+		UpdateExpression:    aws.String("SET Attempts = Attempts + :one, LastError = :lastError"),
+		ConditionExpression: aws.String("attribute_exists(EntryID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":       &types.AttributeValueMemberN{Value: strconv.Itoa(1)},
+			":lastError": &types.AttributeValueMemberS{Value: attemptErr.Error()},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if updateErr != nil && !errors.As(updateErr, &conditionFailed) {
+		return fmt.Errorf("failed to record attempt on outbox entry %s: %w", id, Unwrap(ctx, updateErr))
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_dyndb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// IconMetaTableName records the upgrade steps applied, as the `meta` table of the Postgres index does
+	IconMetaTableName    string = "icon_meta"
+	upgradeDateAttribute string = "UpgradeDate"
+
+	// The capacity of the tables created with the PROVISIONED billing mode, the same as in deployments/aws/dynamodb
+	provisionedCapacityUnits int64 = 5
+	tableCreationTimeout           = 2 * time.Minute
+)
+
+type keySchema struct {
+	hashKey  string
+	rangeKey string
+}
+
+func (keys keySchema) toKeySchemaElements() []types.KeySchemaElement {
+	elements := []types.KeySchemaElement{{AttributeName: aws.String(keys.hashKey), KeyType: types.KeyTypeHash}}
+	if keys.rangeKey != "" {
+		elements = append(elements, types.KeySchemaElement{AttributeName: aws.String(keys.rangeKey), KeyType: types.KeyTypeRange})
+	}
+	return elements
+}
+
+type globalSecondaryIndex struct {
+	name string
+	keys keySchema
+}
+
+// tableDefinition describes a table of the index. All key attributes are strings.
+type tableDefinition struct {
+	name    string
+	keys    keySchema
+	indexes []globalSecondaryIndex
+}
+
+var tableDefinitions = []tableDefinition{
+	{name: IconsTableName, keys: keySchema{hashKey: iconNameAttribute}},
+	{name: IconTagsTableName, keys: keySchema{hashKey: tagAttribute}},
+	{name: IconAuditTableName, keys: keySchema{hashKey: iconNameAttribute, rangeKey: auditEntryIDAttribute}, indexes: []globalSecondaryIndex{
+		{name: AuditByActorIndexName, keys: keySchema{hashKey: actorAttribute, rangeKey: auditEntryIDAttribute}},
+		{name: AuditByTimeIndexName, keys: keySchema{hashKey: auditLogAttribute, rangeKey: auditEntryIDAttribute}},
+	}},
+	{name: IconOutboxTableName, keys: keySchema{hashKey: auditEntryIDAttribute}},
+	{name: IconMetaTableName, keys: keySchema{hashKey: versionAttribute}},
+	{name: IconTagMembersTableName, keys: keySchema{hashKey: tagAttribute, rangeKey: iconNameAttribute}},
+}
+
+func (table tableDefinition) attributeDefinitions() []types.AttributeDefinition {
+	attributes := []string{}
+	for _, keys := range append([]keySchema{table.keys}, indexKeys(table.indexes)...) {
+		for _, attribute := range []string{keys.hashKey, keys.rangeKey} {
+			if attribute != "" && !slices.Contains(attributes, attribute) {
+				attributes = append(attributes, attribute)
+			}
+		}
+	}
+	definitions := []types.AttributeDefinition{}
+	for _, attribute := range attributes {
+		definitions = append(definitions, types.AttributeDefinition{AttributeName: aws.String(attribute), AttributeType: types.ScalarAttributeTypeS})
+	}
+	return definitions
+}
+
+func indexKeys(indexes []globalSecondaryIndex) []keySchema {
+	keys := []keySchema{}
+	for _, index := range indexes {
+		keys = append(keys, index.keys)
+	}
+	return keys
+}
+
+type upgradeStep struct {
+	version string
+	// upgrade migrates the items written before the version. It may be run more than once, by servers starting
+	// at the same time, and must leave the items it has already migrated as they are.
+	upgrade func(ctx context.Context, awsClient *aws_dyndb.Client) error
+}
+
+var upgradeSteps = []upgradeStep{
+	{
+		version: "2026-10-19/0 - first version",
+	},
+	{
+		version: "2026-10-19/1 - tag members",
+		upgrade: addTagMembers,
+	},
+	{
+		version: "2026-10-19/2 - audit log index",
+		upgrade: addEntriesToAuditLogIndex,
+	},
+}
+
+type dyndbSchema struct {
+	awsClient   *aws_dyndb.Client
+	billingMode types.BillingMode
+}
+
+// errNoMetaTable is returned when there is no record of the upgrades applied
+var errNoMetaTable = errors.New("meta table not found")
+
+// OpenSchema creates the tables and the global secondary indexes missing, if createTables is set, and applies
+// the upgrade steps not yet recorded in the meta table. Without the meta table, the items can't be told to be
+// upgraded or not, and opening the schema fails.
+func OpenSchema(ctx context.Context, awsClient *aws_dyndb.Client, createTables bool, billingMode string) error {
+	schema := dyndbSchema{awsClient: awsClient, billingMode: types.BillingMode(billingMode)}
+	if !slices.Contains(schema.billingMode.Values(), schema.billingMode) {
+		return fmt.Errorf("invalid DynamoDB billing mode %q, expected one of %v", billingMode, schema.billingMode.Values())
+	}
+
+	if createTables {
+		for _, table := range tableDefinitions {
+			if createErr := schema.createMissing(ctx, table); createErr != nil {
+				return createErr
+			}
+		}
+	}
+
+	upgradeErr := schema.executeUpgrade(ctx)
+	if errors.Is(upgradeErr, errNoMetaTable) {
+		return fmt.Errorf("no record of the upgrades applied to the DynamoDB tables, the table %s is to be created: %w", IconMetaTableName, upgradeErr)
+	}
+	if upgradeErr != nil {
+		return fmt.Errorf("failed to upgrade the DynamoDB tables: %w", upgradeErr)
+	}
+	return nil
+}
+
+// createMissing creates the table or the global secondary indexes of it missing
+func (schema *dyndbSchema) createMissing(ctx context.Context, table tableDefinition) error {
+	description, describeErr := schema.describeTable(ctx, table.name)
+	if describeErr != nil {
+		return describeErr
+	}
+	if description == nil {
+		return schema.createTable(ctx, table)
+	}
+	return schema.createMissingIndexes(ctx, table, description)
+}
+
+// describeTable returns nil if the table doesn't exist
+func (schema *dyndbSchema) describeTable(ctx context.Context, tableName string) (*types.TableDescription, error) {
+	output, describeErr := schema.awsClient.DescribeTable(ctx, &aws_dyndb.DescribeTableInput{TableName: aws.String(tableName)})
+	if describeErr != nil {
+		var notFoundErr *types.ResourceNotFoundException
+		if errors.As(describeErr, &notFoundErr) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to describe table %s: %w", tableName, Unwrap(ctx, describeErr))
+	}
+	return output.Table, nil
+}
+
+func (schema *dyndbSchema) provisionedThroughput() *types.ProvisionedThroughput {
+	if schema.billingMode != types.BillingModeProvisioned {
+		return nil
+	}
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(provisionedCapacityUnits),
+		WriteCapacityUnits: aws.Int64(provisionedCapacityUnits),
+	}
+}
+
+func (schema *dyndbSchema) toGlobalSecondaryIndex(index globalSecondaryIndex) types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName:             aws.String(index.name),
+		KeySchema:             index.keys.toKeySchemaElements(),
+		Projection:            &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		ProvisionedThroughput: schema.provisionedThroughput(),
+	}
+}
+
+// createTable waits for the table to be created, also if it's being created by another server
+func (schema *dyndbSchema) createTable(ctx context.Context, table tableDefinition) error {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "dynamodb-schema").Str("method", "createTable").Str("table", table.name).Logger()
+
+	input := &aws_dyndb.CreateTableInput{
+		TableName:             aws.String(table.name),
+		KeySchema:             table.keys.toKeySchemaElements(),
+		AttributeDefinitions:  table.attributeDefinitions(),
+		BillingMode:           schema.billingMode,
+		ProvisionedThroughput: schema.provisionedThroughput(),
+	}
+	for _, index := range table.indexes {
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, schema.toGlobalSecondaryIndex(index))
+	}
+
+	logger.Info().Str("billingMode", string(schema.billingMode)).Msg("creating table...")
+	_, createErr := schema.awsClient.CreateTable(ctx, input)
+	if createErr != nil {
+		var inUseErr *types.ResourceInUseException
+		if !errors.As(createErr, &inUseErr) {
+			return fmt.Errorf("failed to create table %s: %w", table.name, Unwrap(ctx, createErr))
+		}
+		logger.Info().Msg("table is being created by someone else")
+	}
+
+	return schema.waitForTable(ctx, table.name)
+}
+
+func (schema *dyndbSchema) waitForTable(ctx context.Context, tableName string) error {
+	waiter := aws_dyndb.NewTableExistsWaiter(schema.awsClient, func(options *aws_dyndb.TableExistsWaiterOptions) {
+		options.MinDelay = time.Second
+	})
+	waitErr := waiter.Wait(ctx, &aws_dyndb.DescribeTableInput{TableName: aws.String(tableName)}, tableCreationTimeout)
+	if waitErr != nil {
+		return fmt.Errorf("failed to wait for table %s to be created: %w", tableName, waitErr)
+	}
+	return nil
+}
+
+// createMissingIndexes adds the global secondary indexes the table doesn't have yet, one per call to UpdateTable.
+// DynamoDB fills in the indexes in the background.
+func (schema *dyndbSchema) createMissingIndexes(ctx context.Context, table tableDefinition, description *types.TableDescription) error {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "dynamodb-schema").Str("method", "createMissingIndexes").Str("table", table.name).Logger()
+
+	existing := []string{}
+	for _, index := range description.GlobalSecondaryIndexes {
+		existing = append(existing, aws.ToString(index.IndexName))
+	}
+
+	for _, index := range table.indexes {
+		if slices.Contains(existing, index.name) {
+			continue
+		}
+		gsi := schema.toGlobalSecondaryIndex(index)
+		logger.Info().Str("index", index.name).Msg("creating global secondary index...")
+		_, updateErr := schema.awsClient.UpdateTable(ctx, &aws_dyndb.UpdateTableInput{
+			TableName:            aws.String(table.name),
+			AttributeDefinitions: table.attributeDefinitions(),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Create: &types.CreateGlobalSecondaryIndexAction{
+				IndexName:             gsi.IndexName,
+				KeySchema:             gsi.KeySchema,
+				Projection:            gsi.Projection,
+				ProvisionedThroughput: gsi.ProvisionedThroughput,
+			}}},
+		})
+		if updateErr != nil {
+			return fmt.Errorf("failed to create index %s of table %s: %w", index.name, table.name, Unwrap(ctx, updateErr))
+		}
+		if waitErr := schema.waitForTable(ctx, table.name); waitErr != nil {
+			return waitErr
+		}
+	}
+	return nil
+}
+
+func (schema *dyndbSchema) isUpgradeApplied(ctx context.Context, version string) (bool, error) {
+	output, getItemErr := schema.awsClient.GetItem(ctx, &aws_dyndb.GetItemInput{
+		TableName:      aws.String(IconMetaTableName),
+		Key:            map[string]types.AttributeValue{versionAttribute: &types.AttributeValueMemberS{Value: version}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if getItemErr != nil {
+		var notFoundErr *types.ResourceNotFoundException
+		if errors.As(getItemErr, &notFoundErr) {
+			return false, errNoMetaTable
+		}
+		return false, fmt.Errorf("failed to determine whether schema upgrade %v has been applied or not: %w", version, Unwrap(ctx, getItemErr))
+	}
+	return output.Item != nil, nil
+}
+
+// recordUpgrade leaves the record of another server having applied the same upgrade as it is
+func (schema *dyndbSchema) recordUpgrade(ctx context.Context, version string) error {
+	_, putErr := schema.awsClient.PutItem(ctx, &aws_dyndb.PutItemInput{
+		TableName: aws.String(IconMetaTableName),
+		Item: map[string]types.AttributeValue{
+			versionAttribute:     &types.AttributeValueMemberS{Value: version},
+			upgradeDateAttribute: &types.AttributeValueMemberS{Value: formatAuditTimestamp(time.Now())},
+		},
+		ConditionExpression:      aws.String("attribute_not_exists(#version)"),
+		ExpressionAttributeNames: map[string]string{"#version": versionAttribute},
+	})
+	if putErr != nil && !isWriteConflict(putErr) {
+		return fmt.Errorf("failed to make a record of the schema upgrade to %s: %w", version, Unwrap(ctx, putErr))
+	}
+	return nil
+}
+
+func (schema *dyndbSchema) executeUpgrade(ctx context.Context) error {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "dynamodb-schema").Str("method", "executeUpgrade").Logger()
+
+	sort.Slice(upgradeSteps, func(i int, j int) bool { return strings.Compare(upgradeSteps[i].version, upgradeSteps[j].version) < 0 })
+
+	for _, upgrStep := range upgradeSteps {
+		applied, appliedErr := schema.isUpgradeApplied(ctx, upgrStep.version)
+		if appliedErr != nil {
+			return appliedErr
+		}
+		if applied {
+			logger.Info().Str("version", upgrStep.version).Msg("already applied version found")
+			continue
+		}
+		logger.Info().Str("version", upgrStep.version).Msg("Applying upgrade...")
+		if upgrStep.upgrade != nil {
+			if upgradeErr := upgrStep.upgrade(ctx, schema.awsClient); upgradeErr != nil {
+				return fmt.Errorf("failed to apply upgrade step '%s': %w", upgrStep.version, upgradeErr)
+			}
+		}
+		if recordErr := schema.recordUpgrade(ctx, upgrStep.version); recordErr != nil {
+			return recordErr
+		}
+	}
+	return nil
+}
@@ -55,30 +55,58 @@ func NewDyndbIconTagsTable(awsClient *aws_dyndb.Client) *DyndbIconTagsTable {
 	return &DyndbIconTagsTable{awsClient: awsClient}
 }
 
-func DeleteLockItems(ctx context.Context, awsClient *aws_dyndb.Client) error {
-	err := deleteLockItemsFromTable(ctx, awsClient, iconsLockTableName)
+type DyndbIconAuditTable struct {
+	awsClient *aws_dyndb.Client
+}
+
+func (auditTable *DyndbIconAuditTable) GetItems(ctx context.Context) ([]*DyndbAuditEntry, error) {
+	items, err := GetItems(ctx, auditTable.awsClient, IconAuditTableName, func() *DyndbAuditEntry {
+		return &DyndbAuditEntry{}
+	})
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to get %T items: %w", DyndbAuditEntry{}, err)
 	}
-	return deleteLockItemsFromTable(ctx, awsClient, iconTagsLockTableName)
+	return items, nil
+}
+
+func NewDyndbIconAuditTable(awsClient *aws_dyndb.Client) *DyndbIconAuditTable {
+	return &DyndbIconAuditTable{awsClient: awsClient}
 }
 
-func deleteLockItemsFromTable(ctx context.Context, awsClient *aws_dyndb.Client, tableName string) error {
-	items, iconsScanErr := scanTable(ctx, awsClient, tableName)
-	if iconsScanErr != nil {
-		return fmt.Errorf("failed to scan %s: %w", tableName, iconsScanErr)
+type DyndbIconOutboxTable struct {
+	awsClient *aws_dyndb.Client
+}
+
+func (outboxTable *DyndbIconOutboxTable) GetItems(ctx context.Context) ([]*DyndbOutboxEntry, error) {
+	items, err := GetItems(ctx, outboxTable.awsClient, IconOutboxTableName, func() *DyndbOutboxEntry {
+		return &DyndbOutboxEntry{}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %T items: %w", DyndbOutboxEntry{}, err)
 	}
-	for _, item := range items {
-		deleteItemInput := aws_dyndb.DeleteItemInput{
-			TableName: &tableName,
-			Key:       map[string]types.AttributeValue{"key": item["key"]},
-		}
-		_, deleteErr := awsClient.DeleteItem(ctx, &deleteItemInput)
-		if deleteErr != nil {
-			return fmt.Errorf("failed to delete item %v from %s: %w", item["key"], tableName, deleteErr)
-		}
+	return items, nil
+}
+
+func NewDyndbIconOutboxTable(awsClient *aws_dyndb.Client) *DyndbIconOutboxTable {
+	return &DyndbIconOutboxTable{awsClient: awsClient}
+}
+
+type DyndbIconTagMembersTable struct {
+	awsClient *aws_dyndb.Client
+}
+
+func (membersTable *DyndbIconTagMembersTable) GetItems(ctx context.Context) ([]*DyndbTagMember, error) {
+	items, err := GetItems(ctx, membersTable.awsClient, IconTagMembersTableName, func() *DyndbTagMember {
+		return &DyndbTagMember{}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %T items: %w", DyndbTagMember{}, err)
 	}
-	return nil
+	return items, nil
+}
+
+func NewDyndbIconTagMembersTable(awsClient *aws_dyndb.Client) *DyndbIconTagMembersTable {
+	return &DyndbIconTagMembersTable{awsClient: awsClient}
 }
 
 func scanTable(ctx context.Context, awsClient *aws_dyndb.Client, tableName string) ([]map[string]types.AttributeValue, error) {
@@ -95,7 +123,7 @@ func scanTable(ctx context.Context, awsClient *aws_dyndb.Client, tableName strin
 // The need for the interface and the explicitly added `unmarshal` method is a work-around
 // for this go issue: https://stackoverflow.com/a/71378366/1194266
 func GetItems[T interface {
-	*DyndbIcon | *DyndbTag
+	*DyndbIcon | *DyndbTag | *DyndbAuditEntry | *DyndbOutboxEntry | *DyndbTagMember
 	unmarshal(attribs map[string]types.AttributeValue) error
 }](
 	ctx context.Context,
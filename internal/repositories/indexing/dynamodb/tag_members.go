@@ -0,0 +1,181 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"slices"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	aws_dyndb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rs/zerolog"
+)
+
+// maxBatchGetItems is the number of items DynamoDB accepts in a single BatchGetItem call
+const maxBatchGetItems = 100
+
+// DyndbTagMember records that the icon has the tag. Keyed by the tag and the icon name,
+// the icons with a tag are found by querying the tag.
+type DyndbTagMember struct {
+	Tag      string `dynamodbav:"Tag"`
+	IconName string `dynamodbav:"IconName"`
+}
+
+func (dyMember *DyndbTagMember) GetKey(ctx context.Context) (map[string]types.AttributeValue, error) {
+	return map[string]types.AttributeValue{
+		tagAttribute:      &types.AttributeValueMemberS{Value: dyMember.Tag},
+		iconNameAttribute: &types.AttributeValueMemberS{Value: dyMember.IconName},
+	}, nil
+}
+
+func (dyMember *DyndbTagMember) unmarshal(attrmap map[string]types.AttributeValue) error {
+	unmarshalErr := attributevalue.UnmarshalMap(attrmap, dyMember)
+	if unmarshalErr != nil {
+		return fmt.Errorf("failed to unmarshal %T: %w", DyndbTagMember{}, unmarshalErr)
+	}
+	return nil
+}
+
+// tagMemberWriteItem puts the member item of a tag added to the icon or deletes that of a tag removed
+func tagMemberWriteItem(ctx context.Context, tag string, iconName string, added bool) (types.TransactWriteItem, error) {
+	member := &DyndbTagMember{Tag: tag, IconName: iconName}
+	if added {
+		item, marshalErr := attributevalue.MarshalMap(member)
+		if marshalErr != nil {
+			return types.TransactWriteItem{}, fmt.Errorf("failed to marshal member item of tag %s for %s: %w", tag, iconName, marshalErr)
+		}
+		return types.TransactWriteItem{Put: &types.Put{
+			TableName: aws.String(IconTagMembersTableName),
+			Item:      item,
+		}}, nil
+	}
+	key, keyErr := member.GetKey(ctx)
+	if keyErr != nil {
+		return types.TransactWriteItem{}, keyErr
+	}
+	return types.TransactWriteItem{Delete: &types.Delete{
+		TableName: aws.String(IconTagMembersTableName),
+		Key:       key,
+	}}, nil
+}
+
+// GetIconsWithTag queries the member items of the tag and reads the icons they refer to
+func (repo *DynamodbRepository) GetIconsWithTag(ctx context.Context, tag string) ([]domain.IconDescriptor, error) {
+	iconNames, queryErr := repo.queryTagMembers(ctx, tag)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to query the icons with tag %s: %w", tag, queryErr)
+	}
+
+	icons, getErr := repo.batchGetIconItems(ctx, iconNames)
+	if getErr != nil {
+		return nil, fmt.Errorf("failed to read the icons with tag %s: %w", tag, getErr)
+	}
+
+	iconDescriptors := []domain.IconDescriptor{}
+	for _, icon := range icons {
+		// A member item added by the upgrade while the icon was losing the tag is stale
+		if slices.Contains(icon.Tags, tag) {
+			iconDescriptors = append(iconDescriptors, icon.toIconDescriptor())
+		}
+	}
+	sort.Slice(iconDescriptors, func(i, j int) bool { return iconDescriptors[i].Name < iconDescriptors[j].Name })
+	return iconDescriptors, nil
+}
+
+func (repo *DynamodbRepository) queryTagMembers(ctx context.Context, tag string) ([]string, error) {
+	input := &aws_dyndb.QueryInput{
+		TableName:                aws.String(IconTagMembersTableName),
+		KeyConditionExpression:   aws.String("#tag = :tag"),
+		ExpressionAttributeNames: map[string]string{"#tag": tagAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":tag": &types.AttributeValueMemberS{Value: tag},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	iconNames := []string{}
+	for {
+		output, queryErr := repo.awsClient.Query(ctx, input)
+		if queryErr != nil {
+			return nil, Unwrap(ctx, queryErr)
+		}
+		for _, item := range output.Items {
+			member := &DyndbTagMember{}
+			if unmarshalErr := member.unmarshal(item); unmarshalErr != nil {
+				return nil, unmarshalErr
+			}
+			iconNames = append(iconNames, member.IconName)
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return iconNames, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// batchGetIconItems reads the icons in batches, asking again for those DynamoDB left unprocessed.
+// Icons not found are left out.
+func (repo *DynamodbRepository) batchGetIconItems(ctx context.Context, iconNames []string) ([]*DyndbIcon, error) {
+	logger := zerolog.Ctx(ctx).With().Str("unit", "DynamodbRepository").Str("method", "batchGetIconItems").Logger()
+
+	icons := []*DyndbIcon{}
+	for start := 0; start < len(iconNames); start += maxBatchGetItems {
+		keys := []map[string]types.AttributeValue{}
+		for _, iconName := range iconNames[start:min(start+maxBatchGetItems, len(iconNames))] {
+			key, keyErr := (&DyndbIcon{IconName: iconName}).GetKey(ctx)
+			if keyErr != nil {
+				return nil, keyErr
+			}
+			keys = append(keys, key)
+		}
+
+		requestItems := map[string]types.KeysAndAttributes{
+			IconsTableName: {Keys: keys, ConsistentRead: aws.Bool(true)},
+		}
+		for len(requestItems) > 0 {
+			output, getErr := repo.awsClient.BatchGetItem(ctx, &aws_dyndb.BatchGetItemInput{RequestItems: requestItems})
+			if getErr != nil {
+				return nil, Unwrap(ctx, getErr)
+			}
+			for _, item := range output.Responses[IconsTableName] {
+				icon := &DyndbIcon{}
+				if unmarshalErr := icon.unmarshal(item); unmarshalErr != nil {
+					return nil, unmarshalErr
+				}
+				icons = append(icons, icon)
+			}
+			requestItems = output.UnprocessedKeys
+			if len(requestItems) > 0 {
+				logger.Debug().Int("unprocessedKeyCount", len(requestItems[IconsTableName].Keys)).Msg("reading unprocessed keys again")
+			}
+		}
+	}
+	return icons, nil
+}
+
+// addTagMembers writes the member items of the tags of the icons written before tag members were kept track of
+func addTagMembers(ctx context.Context, awsClient *aws_dyndb.Client) error {
+	icons, scanErr := NewDyndbIconsTable(awsClient).GetItems(ctx)
+	if scanErr != nil {
+		return fmt.Errorf("failed to read icons for adding tag members: %w", scanErr)
+	}
+	for _, icon := range icons {
+		for _, tag := range icon.Tags {
+			writeItem, writeItemErr := tagMemberWriteItem(ctx, tag, icon.IconName, true)
+			if writeItemErr != nil {
+				return writeItemErr
+			}
+			_, putErr := awsClient.PutItem(ctx, &aws_dyndb.PutItemInput{
+				TableName: writeItem.Put.TableName,
+				Item:      writeItem.Put.Item,
+			})
+			if putErr != nil {
+				return fmt.Errorf("failed to add member item of tag %s for %s: %w", tag, icon.IconName, Unwrap(ctx, putErr))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"iconrepo/internal/app/domain"
+)
+
+func (index *Index) RecordAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	index.auditMutex.Lock()
+	defer index.auditMutex.Unlock()
+
+	index.lastAuditID++
+	entry.ID = strconv.FormatInt(index.lastAuditID, 10)
+	index.audit = append(index.audit, entry)
+	return nil
+}
+
+func matchesAuditQuery(entry domain.AuditEntry, query domain.AuditQuery) bool {
+	if len(query.IconName) > 0 && entry.IconName != query.IconName {
+		return false
+	}
+	if len(query.Actor) > 0 && entry.Actor != query.Actor {
+		return false
+	}
+	if !query.Since.IsZero() && entry.Timestamp.Before(query.Since) {
+		return false
+	}
+	if !query.Until.IsZero() && !entry.Timestamp.Before(query.Until) {
+		return false
+	}
+	return true
+}
+
+// QueryAuditEntries returns the entries matching the query, the most recent first.
+// The cursor is the id of the last entry on the previous page.
+func (index *Index) QueryAuditEntries(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	index.auditMutex.Lock()
+	defer index.auditMutex.Unlock()
+
+	// The ids are the positions of the entries counted from 1
+	last := len(index.audit)
+	if len(query.Cursor) > 0 {
+		lastID, parseErr := strconv.Atoi(query.Cursor)
+		if parseErr != nil || lastID < 1 {
+			return domain.AuditPage{}, fmt.Errorf("invalid audit cursor %s: %w", query.Cursor, domain.ErrInvalidAuditCursor)
+		}
+		last = min(lastID-1, last)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = domain.DefaultAuditPageSize
+	}
+
+	page := domain.AuditPage{Entries: []domain.AuditEntry{}}
+	for position := last - 1; position >= 0; position-- {
+		entry := index.audit[position]
+		if !matchesAuditQuery(entry, query) {
+			continue
+		}
+		if len(page.Entries) == limit {
+			page.NextCursor = page.Entries[limit-1].ID
+			break
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	return page, nil
+}
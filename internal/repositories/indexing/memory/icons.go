@@ -0,0 +1,352 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/repositories/indexing"
+)
+
+// Index keeps the index in memory. It is meant for development and tests: the data is lost when the process exits.
+// A change is applied to a copy of the icon which replaces the original only after the side-effect has succeeded,
+// so a failed side-effect leaves no trace in the index.
+type Index struct {
+	mutex sync.Mutex
+	icons map[string]domain.IconDescriptor
+	// The audit log has a lock of its own, as entries are recorded by the side-effects of the changes to the icons
+	auditMutex   sync.Mutex
+	audit        []domain.AuditEntry
+	lastAuditID  int64
+	outbox       map[string]domain.OutboxEntry
+	lastOutboxID int64
+}
+
+func NewIndex() *Index {
+	return &Index{icons: map[string]domain.IconDescriptor{}, outbox: map[string]domain.OutboxEntry{}}
+}
+
+var (
+	sharedIndexesMutex sync.Mutex
+	sharedIndexes      = map[string]*Index{}
+)
+
+// Shared returns the index registered in the process under the name, creating it on first use. Servers started
+// one after the other in the same process with the same name see the same data, the way they would with a database.
+func Shared(name string) *Index {
+	sharedIndexesMutex.Lock()
+	defer sharedIndexesMutex.Unlock()
+	index, found := sharedIndexes[name]
+	if !found {
+		index = NewIndex()
+		sharedIndexes[name] = index
+	}
+	return index
+}
+
+// Reset drops all the data in the index
+func (index *Index) Reset() {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.icons = map[string]domain.IconDescriptor{}
+	index.outbox = map[string]domain.OutboxEntry{}
+	index.lastOutboxID = 0
+
+	index.auditMutex.Lock()
+	defer index.auditMutex.Unlock()
+	index.audit = nil
+	index.lastAuditID = 0
+}
+
+// Close has nothing to release: the data is kept for the next server started in the process
+func (index *Index) Close() error {
+	return nil
+}
+
+func copyIcon(icon domain.IconDescriptor) domain.IconDescriptor {
+	icon.Tags = append([]string{}, icon.Tags...)
+	icon.Iconfiles = append([]domain.IconfileDescriptor{}, icon.Iconfiles...)
+	return icon
+}
+
+func compareIconfiles(iconfile1 domain.IconfileDescriptor, iconfile2 domain.IconfileDescriptor) int {
+	if formatOrder := strings.Compare(iconfile1.Format, iconfile2.Format); formatOrder != 0 {
+		return formatOrder
+	}
+	return strings.Compare(iconfile1.Size, iconfile2.Size)
+}
+
+func findIconfile(icon domain.IconDescriptor, iconfile domain.IconfileDescriptor) int {
+	return slices.IndexFunc(icon.Iconfiles, func(candidate domain.IconfileDescriptor) bool {
+		return candidate.Equals(iconfile)
+	})
+}
+
+// getIcon returns a copy of the icon to be changed
+func (index *Index) getIcon(iconName string) (domain.IconDescriptor, error) {
+	icon, found := index.icons[iconName]
+	if !found {
+		return domain.IconDescriptor{}, fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
+	}
+	return copyIcon(icon), nil
+}
+
+// commit stores the changed icon, or removes the icon if it has no iconfiles left, once the side-effect has succeeded
+func (index *Index) commit(ctx context.Context, icon domain.IconDescriptor, createSideEffect func(ctx context.Context) error) error {
+	if createSideEffect != nil {
+		if sideEffectErr := createSideEffect(indexing.ContextWithCommittedIcons(ctx, copyIcon(icon))); sideEffectErr != nil {
+			return sideEffectErr
+		}
+	}
+	if len(icon.Iconfiles) == 0 {
+		delete(index.icons, icon.Name)
+		return nil
+	}
+	index.icons[icon.Name] = icon
+	return nil
+}
+
+func (index *Index) DescribeIcon(ctx context.Context, iconName string) (domain.IconDescriptor, error) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	return index.getIcon(iconName)
+}
+
+func (index *Index) DescribeAllIcons(ctx context.Context) ([]domain.IconDescriptor, error) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	result := []domain.IconDescriptor{}
+	for _, icon := range index.icons {
+		result = append(result, copyIcon(icon))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// GetIconsWithTag describes the icons having the tag
+func (index *Index) GetIconsWithTag(ctx context.Context, tag string) ([]domain.IconDescriptor, error) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	result := []domain.IconDescriptor{}
+	for _, icon := range index.icons {
+		if slices.Contains(icon.Tags, tag) {
+			result = append(result, copyIcon(icon))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// GetExistingTags returns the tags in use
+func (index *Index) GetExistingTags(ctx context.Context) ([]string, error) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	tags := []string{}
+	for _, icon := range index.icons {
+		for _, tag := range icon.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (index *Index) CreateIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	if _, found := index.icons[iconName]; found {
+		return fmt.Errorf("failed to create icon %v: %w", iconName, domain.ErrIconAlreadyExists)
+	}
+	icon := domain.IconDescriptor{
+		IconAttributes: domain.IconAttributes{Name: iconName, ModifiedBy: modifiedBy, Tags: []string{}},
+		Iconfiles:      []domain.IconfileDescriptor{iconfile},
+	}
+	if err := index.commit(ctx, icon, createSideEffect); err != nil {
+		return fmt.Errorf("failed to create icon %s due to error while creating side-effect: %w", iconName, err)
+	}
+	return nil
+}
+
+func (index *Index) AddIconfileToIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	icon, getErr := index.getIcon(iconName)
+	if getErr != nil {
+		return fmt.Errorf("failed to add iconfile %v to icon %s: %w", iconfile, iconName, getErr)
+	}
+	if findIconfile(icon, iconfile) >= 0 {
+		return domain.ErrIconfileAlreadyExists
+	}
+	icon.Iconfiles = append(icon.Iconfiles, iconfile)
+	slices.SortFunc(icon.Iconfiles, compareIconfiles)
+	icon.ModifiedBy = modifiedBy
+	if err := index.commit(ctx, icon, createSideEffect); err != nil {
+		return fmt.Errorf("failed to create icon file %s due to error while creating side-effect: %w", iconName, err)
+	}
+	return nil
+}
+
+func (index *Index) AddTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	icon, getErr := index.getIcon(iconName)
+	if getErr != nil {
+		return fmt.Errorf("failed to add tag '%s' to icon '%s': %w", tag, iconName, getErr)
+	}
+	if slices.Contains(icon.Tags, tag) {
+		return nil
+	}
+	icon.Tags = append(icon.Tags, tag)
+	icon.ModifiedBy = modifiedBy
+	if err := index.commit(ctx, icon, createSideEffect); err != nil {
+		return fmt.Errorf("failed to add tag '%s' to icon '%s' due to error while creating side-effect: %w", tag, iconName, err)
+	}
+	return nil
+}
+
+func (index *Index) RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	icon, getErr := index.getIcon(iconName)
+	if getErr != nil {
+		return fmt.Errorf("failed to remove tag '%s' from icon '%s': %w", tag, iconName, getErr)
+	}
+	if !slices.Contains(icon.Tags, tag) {
+		return nil
+	}
+	icon.Tags = slices.DeleteFunc(icon.Tags, func(iconTag string) bool { return iconTag == tag })
+	icon.ModifiedBy = modifiedBy
+	if err := index.commit(ctx, icon, createSideEffect); err != nil {
+		return fmt.Errorf("failed to remove tag '%s' from icon '%s' due to error while creating side-effect: %w", tag, iconName, err)
+	}
+	return nil
+}
+
+func (index *Index) DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	icon, getErr := index.getIcon(iconName)
+	if getErr != nil {
+		return fmt.Errorf("failed to describe icon %v: %w", iconName, getErr)
+	}
+	icon.Iconfiles = nil
+	if err := index.commit(ctx, icon, createSideEffect); err != nil {
+		return fmt.Errorf("failed to execute side effect while deleting icon %v: %w", iconName, err)
+	}
+	return nil
+}
+
+func (index *Index) DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	icon, getErr := index.getIcon(iconName)
+	if getErr != nil {
+		return fmt.Errorf("failed to delete iconfile %v from %s: %w", iconfile, iconName, getErr)
+	}
+	position := findIconfile(icon, iconfile)
+	if position < 0 {
+		return domain.ErrIconfileNotFound
+	}
+	icon.Iconfiles = slices.Delete(icon.Iconfiles, position, position+1)
+	icon.ModifiedBy = modifiedBy
+	if err := index.commit(ctx, icon, createSideEffect); err != nil {
+		return fmt.Errorf("failed to create side-effect for removing iconfile %v from %s: %w", iconfile, iconName, err)
+	}
+	return nil
+}
+
+func (index *Index) SetIconfileReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	icon, getErr := index.getIcon(iconName)
+	if getErr != nil {
+		return fmt.Errorf("failed to set the review status of %v of %s: %w", iconfile, iconName, getErr)
+	}
+	position := findIconfile(icon, iconfile)
+	if position < 0 {
+		return domain.ErrIconfileNotFound
+	}
+	icon.Iconfiles[position].ReviewStatus = status
+	icon.ModifiedBy = modifiedBy
+	if err := index.commit(ctx, icon, createSideEffect); err != nil {
+		return fmt.Errorf("failed to set the review status of %v of %s due to error while creating side-effect: %w", iconfile, iconName, err)
+	}
+	return nil
+}
+
+func (index *Index) SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	icon, getErr := index.getIcon(iconName)
+	if getErr != nil {
+		return fmt.Errorf("failed to set the description of %s: %w", iconName, getErr)
+	}
+	icon.Description = description
+	icon.ModifiedBy = modifiedBy
+	if err := index.commit(ctx, icon, createSideEffect); err != nil {
+		return fmt.Errorf("failed to set the description of %s due to error while creating side-effect: %w", iconName, err)
+	}
+	return nil
+}
+
+// ApplyChangeset applies the operations to copies of the icons involved, which replace the originals
+// only after the side-effect has succeeded
+func (index *Index) ApplyChangeset(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	changed := map[string]*domain.IconDescriptor{}
+	for _, op := range ops {
+		icon, seen := changed[op.IconName]
+		if !seen {
+			if original, found := index.icons[op.IconName]; found {
+				originalCopy := copyIcon(original)
+				icon = &originalCopy
+			}
+		}
+		var applyErr error
+		changed[op.IconName], applyErr = op.ApplyTo(icon, modifiedBy)
+		if applyErr != nil {
+			return fmt.Errorf("failed to apply changeset: %w", applyErr)
+		}
+	}
+
+	if createSideEffect != nil {
+		committed := []domain.IconDescriptor{}
+		for iconName, icon := range changed {
+			if icon == nil {
+				committed = append(committed, domain.IconDescriptor{IconAttributes: domain.IconAttributes{Name: iconName, Tags: []string{}}})
+				continue
+			}
+			committed = append(committed, copyIcon(*icon))
+		}
+		if sideEffectErr := createSideEffect(indexing.ContextWithCommittedIcons(ctx, committed...)); sideEffectErr != nil {
+			return fmt.Errorf("failed to apply changeset due to error while creating side-effect: %w", sideEffectErr)
+		}
+	}
+	for iconName, icon := range changed {
+		if icon == nil {
+			delete(index.icons, iconName)
+			continue
+		}
+		index.icons[iconName] = *icon
+	}
+	return nil
+}
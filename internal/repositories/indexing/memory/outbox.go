@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"iconrepo/internal/app/domain"
+)
+
+func (index *Index) AddOutboxEntry(ctx context.Context, entry domain.OutboxEntry) (string, error) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.lastOutboxID++
+	entry.ID = strconv.FormatInt(index.lastOutboxID, 10)
+	index.outbox[entry.ID] = entry
+	return entry.ID, nil
+}
+
+func (index *Index) RemoveOutboxEntry(ctx context.Context, id string) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	delete(index.outbox, id)
+	return nil
+}
+
+// GetOutboxEntries returns the entries recorded before the time given and no longer held by then, the oldest first
+func (index *Index) GetOutboxEntries(ctx context.Context, before time.Time) ([]domain.OutboxEntry, error) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	entries := []domain.OutboxEntry{}
+	for _, entry := range index.outbox {
+		if entry.RecordedAt.Before(before) && entry.HeldUntil.Before(before) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RecordedAt.Before(entries[j].RecordedAt) })
+	return entries, nil
+}
+
+func (index *Index) RecordOutboxAttempt(ctx context.Context, id string, attemptErr error) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	entry, found := index.outbox[id]
+	if !found {
+		return nil
+	}
+	entry.Attempts++
+	entry.LastError = attemptErr.Error()
+	index.outbox[id] = entry
+	return nil
+}
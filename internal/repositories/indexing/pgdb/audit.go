@@ -0,0 +1,167 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"strconv"
+	"strings"
+)
+
+func marshalAuditedIcon(iconDesc *domain.IconDescriptor) (sql.NullString, error) {
+	if iconDesc == nil {
+		return sql.NullString{}, nil
+	}
+	iconJSON, err := json.Marshal(iconDesc)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(iconJSON), Valid: true}, nil
+}
+
+func unmarshalAuditedIcon(iconJSON sql.NullString) (*domain.IconDescriptor, error) {
+	if !iconJSON.Valid {
+		return nil, nil
+	}
+	iconDesc := domain.IconDescriptor{}
+	err := json.Unmarshal([]byte(iconJSON.String), &iconDesc)
+	if err != nil {
+		return nil, err
+	}
+	return &iconDesc, nil
+}
+
+func toNullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: len(value) > 0}
+}
+
+// RecordAuditEntry records the entry in the transaction of the change to the index in progress, if any
+func (repo PgRepository) RecordAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	before, marshalBeforeErr := marshalAuditedIcon(entry.Before)
+	if marshalBeforeErr != nil {
+		return fmt.Errorf("failed to marshal icon state before %v: %w", entry, marshalBeforeErr)
+	}
+	after, marshalAfterErr := marshalAuditedIcon(entry.After)
+	if marshalAfterErr != nil {
+		return fmt.Errorf("failed to marshal icon state after %v: %w", entry, marshalAfterErr)
+	}
+
+	var iconfileFormat, iconfileSize sql.NullString
+	if entry.Iconfile != nil {
+		iconfileFormat = toNullString(entry.Iconfile.Format)
+		iconfileSize = toNullString(entry.Iconfile.Size)
+	}
+
+	const insertAuditSQL = "INSERT INTO audit(recorded_at, actor, action, icon_name, iconfile_format, iconfile_size, tag, before, after, request_id, comment) " +
+		"VALUES($1, $2, $3, $4, $5, $6, $7, $8::jsonb, $9::jsonb, $10, $11)"
+	_, err := repo.db(ctx).ExecContext(
+		ctx,
+		insertAuditSQL,
+		entry.Timestamp,
+		entry.Actor,
+		string(entry.Action),
+		entry.IconName,
+		iconfileFormat,
+		iconfileSize,
+		toNullString(entry.Tag),
+		before,
+		after,
+		toNullString(entry.RequestID),
+		toNullString(entry.Comment),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry %v: %w", entry, err)
+	}
+	return nil
+}
+
+// QueryAuditEntries returns the entries matching the query, the most recent first.
+// The cursor is the id of the last entry on the previous page.
+func (repo PgRepository) QueryAuditEntries(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	conditions := []string{}
+	args := []any{}
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if len(query.IconName) > 0 {
+		addCondition("icon_name = $%d", query.IconName)
+	}
+	if len(query.Actor) > 0 {
+		addCondition("actor = $%d", query.Actor)
+	}
+	if !query.Since.IsZero() {
+		addCondition("recorded_at >= $%d", query.Since)
+	}
+	if !query.Until.IsZero() {
+		addCondition("recorded_at < $%d", query.Until)
+	}
+	if len(query.Cursor) > 0 {
+		lastID, parseErr := strconv.ParseInt(query.Cursor, 10, 64)
+		if parseErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("invalid audit cursor %s: %w", query.Cursor, domain.ErrInvalidAuditCursor)
+		}
+		addCondition("id < $%d", lastID)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = domain.DefaultAuditPageSize
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit+1)
+	querySQL := "SELECT id, recorded_at, actor, action, icon_name, iconfile_format, iconfile_size, tag, before, after, request_id, comment FROM audit" +
+		whereClause +
+		fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, queryErr := repo.Conn.Pool.QueryContext(ctx, querySQL, args...)
+	if queryErr != nil {
+		return domain.AuditPage{}, fmt.Errorf("failed to query audit entries with %#v: %w", query, queryErr)
+	}
+	defer rows.Close()
+
+	entries := []domain.AuditEntry{}
+	for rows.Next() {
+		var id int64
+		var action string
+		var iconfileFormat, iconfileSize, tag, before, after, requestID, comment sql.NullString
+		entry := domain.AuditEntry{}
+		scanErr := rows.Scan(&id, &entry.Timestamp, &entry.Actor, &action, &entry.IconName, &iconfileFormat, &iconfileSize, &tag, &before, &after, &requestID, &comment)
+		if scanErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("failed to read audit entry: %w", scanErr)
+		}
+		entry.ID = strconv.FormatInt(id, 10)
+		entry.Action = domain.AuditAction(action)
+		entry.Tag = tag.String
+		entry.RequestID = requestID.String
+		entry.Comment = comment.String
+		if iconfileFormat.Valid || iconfileSize.Valid {
+			entry.Iconfile = &domain.IconfileDescriptor{Format: iconfileFormat.String, Size: iconfileSize.String}
+		}
+		var unmarshalErr error
+		if entry.Before, unmarshalErr = unmarshalAuditedIcon(before); unmarshalErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("failed to unmarshal icon state before in audit entry %d: %w", id, unmarshalErr)
+		}
+		if entry.After, unmarshalErr = unmarshalAuditedIcon(after); unmarshalErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("failed to unmarshal icon state after in audit entry %d: %w", id, unmarshalErr)
+		}
+		entries = append(entries, entry)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return domain.AuditPage{}, fmt.Errorf("error while processing audit entries: %w", rowsErr)
+	}
+
+	page := domain.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = page.Entries[limit-1].ID
+	}
+	return page, nil
+}
@@ -1,6 +1,7 @@
 package pgdb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"iconrepo/internal/config"
@@ -43,3 +44,29 @@ func open(connProps config.DbConnectionProperties, logger zerolog.Logger) (*sql.
 	db.Ping()
 	return db, nil
 }
+
+type txContextKey struct{}
+
+// ContextWithTx hands the transaction of a change to the index over to the side-effect of the change,
+// so that a blobstore in the same database can take part in the transaction
+func ContextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the transaction handed over by ContextWithTx, or nil if there is none
+func TxFromContext(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(txContextKey{}).(*sql.Tx)
+	return tx
+}
+
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// db returns the transaction handed over by ContextWithTx, if any, or the connection pool
+func (repo PgRepository) db(ctx context.Context) dbExecutor {
+	if tx := TxFromContext(ctx); tx != nil {
+		return tx
+	}
+	return repo.Conn.Pool
+}
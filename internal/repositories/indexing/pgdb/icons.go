@@ -46,8 +46,8 @@ func describeIconInTx(tx *sql.Tx, iconName string, forUpdate bool) (domain.IconD
 	if forUpdate {
 		forUpdateClause = " FOR UPDATE"
 	}
-	var iconSQL = "SELECT id, modified_by FROM icon WHERE name = $1" + forUpdateClause
-	var iconfilesSQL = "SELECT file_format, icon_size FROM icon_file " +
+	var iconSQL = "SELECT id, modified_by, coalesce(description, '') FROM icon WHERE name = $1" + forUpdateClause
+	var iconfilesSQL = "SELECT file_format, icon_size, review_status FROM icon_file " +
 		"WHERE icon_id = $1 " +
 		"ORDER BY file_format, icon_size" + forUpdateClause
 	var tagsSQL = "SELECT text FROM tag, icon_to_tags " +
@@ -56,7 +56,8 @@ func describeIconInTx(tx *sql.Tx, iconName string, forUpdate bool) (domain.IconD
 
 	var iconId int
 	var modifiedBy string
-	err = tx.QueryRow(iconSQL, iconName).Scan(&iconId, &modifiedBy)
+	var description string
+	err = tx.QueryRow(iconSQL, iconName).Scan(&iconId, &modifiedBy, &description)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return domain.IconDescriptor{}, fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
@@ -75,14 +76,16 @@ func describeIconInTx(tx *sql.Tx, iconName string, forUpdate bool) (domain.IconD
 		defer rows.Close()
 		var format string
 		var size string
+		var reviewStatus sql.NullString
 		for rows.Next() {
-			err = rows.Scan(&format, &size)
+			err = rows.Scan(&format, &size, &reviewStatus)
 			if err != nil {
 				return fmt.Errorf("error while retrieving iconfiles for '%s' from database: %w", iconName, err)
 			}
 			iconfiles = append(iconfiles, domain.IconfileDescriptor{
-				Format: format,
-				Size:   size,
+				Format:       format,
+				Size:         size,
+				ReviewStatus: domain.ReviewStatus(reviewStatus.String),
 			})
 		}
 		return nil
@@ -114,9 +117,10 @@ func describeIconInTx(tx *sql.Tx, iconName string, forUpdate bool) (domain.IconD
 
 	return domain.IconDescriptor{
 		IconAttributes: domain.IconAttributes{
-			Name:       iconName,
-			ModifiedBy: modifiedBy,
-			Tags:       tags,
+			Name:        iconName,
+			ModifiedBy:  modifiedBy,
+			Description: description,
+			Tags:        tags,
 		},
 		Iconfiles: iconfiles,
 	}, nil
@@ -148,15 +152,30 @@ func (repo PgRepository) DescribeIcon(ctx context.Context, iconName string) (dom
 }
 
 func (repo PgRepository) DescribeAllIcons(ctx context.Context) ([]domain.IconDescriptor, error) {
+	return repo.describeIcons("SELECT name FROM icon")
+}
+
+// GetIconsWithTag describes the icons having the tag
+func (repo PgRepository) GetIconsWithTag(ctx context.Context, tag string) ([]domain.IconDescriptor, error) {
+	const iconsWithTagSQL = "SELECT icon.name FROM icon, icon_to_tags, tag " +
+		"WHERE tag.text = $1 " +
+		"AND icon_to_tags.tag_id = tag.id " +
+		"AND icon_to_tags.icon_id = icon.id " +
+		"ORDER BY icon.name"
+	return repo.describeIcons(iconsWithTagSQL, tag)
+}
+
+// describeIcons describes the icons the names of which the query selects
+func (repo PgRepository) describeIcons(iconNamesSQL string, args ...any) ([]domain.IconDescriptor, error) {
 	tx, err := repo.Conn.Pool.Begin()
 	if err != nil {
 		return []domain.IconDescriptor{}, err
 	}
 	defer tx.Rollback()
 
-	rows, errQuery := tx.Query("SELECT name FROM icon")
+	rows, errQuery := tx.Query(iconNamesSQL, args...)
 	if errQuery != nil {
-		return []domain.IconDescriptor{}, fmt.Errorf("failed to retrieve all icon names: %w", errQuery)
+		return []domain.IconDescriptor{}, fmt.Errorf("failed to retrieve icon names: %w", errQuery)
 	}
 	defer rows.Close()
 
@@ -183,7 +202,7 @@ func (repo PgRepository) DescribeAllIcons(ctx context.Context) ([]domain.IconDes
 	return result, nil
 }
 
-func (repo PgRepository) CreateIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func() error) error {
+func (repo PgRepository) CreateIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
 	var tx *sql.Tx
 	var err error
 	tx, err = repo.Conn.Pool.Begin()
@@ -192,8 +211,37 @@ func (repo PgRepository) CreateIcon(ctx context.Context, iconName string, iconfi
 	}
 	defer tx.Rollback()
 
+	err = createIconInTx(tx, iconName, iconfile, modifiedBy)
+	if err != nil {
+		return err
+	}
+
+	if createSideEffect != nil {
+		err = runSideEffect(ctx, tx, createSideEffect, iconName)
+		if err != nil {
+			return fmt.Errorf("failed to create iconfile %s due to error while creating side-effect, %w", iconName, err)
+		}
+	}
+
+	repo.logger.Info().Str("icon-name", iconName).Interface("iconfile", iconfile).Msg("Icon created")
+	tx.Commit()
+	return nil
+}
+
+// runSideEffect hands the transaction and the state of the icons changed in it over to the side-effect
+func runSideEffect(ctx context.Context, tx *sql.Tx, createSideEffect func(ctx context.Context) error, iconNames ...string) error {
+	icons, describeErr := indexing.DescribeCommittedIcons(iconNames, func(iconName string) (domain.IconDescriptor, error) {
+		return describeIconInTx(tx, iconName, false)
+	})
+	if describeErr != nil {
+		return describeErr
+	}
+	return createSideEffect(indexing.ContextWithCommittedIcons(ContextWithTx(ctx, tx), icons...))
+}
+
+func createIconInTx(tx *sql.Tx, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string) error {
 	const insertIconSQL string = "INSERT INTO icon(name, modified_by) VALUES($1, $2) RETURNING id"
-	_, err = tx.Exec(insertIconSQL, iconName, modifiedBy)
+	_, err := tx.Exec(insertIconSQL, iconName, modifiedBy)
 	if err != nil {
 		reportErr := err
 		if IsDBError(err, ErrDuplicateRows) {
@@ -206,16 +254,6 @@ func (repo PgRepository) CreateIcon(ctx context.Context, iconName string, iconfi
 	if err != nil {
 		return fmt.Errorf("failed to create iconfile for %v: %w", iconName, err)
 	}
-
-	if createSideEffect != nil {
-		err = createSideEffect()
-		if err != nil {
-			return fmt.Errorf("failed to create iconfile %s due to error while creating side-effect, %w", iconName, err)
-		}
-	}
-
-	repo.logger.Info().Str("icon-name", iconName).Interface("iconfile", iconfile).Msg("Icon created")
-	tx.Commit()
 	return nil
 }
 
@@ -227,7 +265,7 @@ func updateModifier(tx *sql.Tx, iconName string, modifiedBy string) error {
 	return nil
 }
 
-func (repo PgRepository) AddIconfileToIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func() error) error {
+func (repo PgRepository) AddIconfileToIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
 	var tx *sql.Tx
 	var err error
 
@@ -237,18 +275,13 @@ func (repo PgRepository) AddIconfileToIcon(ctx context.Context, iconName string,
 	}
 	defer tx.Rollback()
 
-	err = insertIconfile(tx, iconName, iconfile)
+	err = addIconfileInTx(tx, iconName, iconfile, modifiedBy)
 	if err != nil {
-		return fmt.Errorf("failed to create iconfile %v: %w", iconName, err)
-	}
-
-	err = updateModifier(tx, iconName, modifiedBy)
-	if err != nil {
-		return fmt.Errorf("failed to add iconfile '%v' to icon '%s': %w", iconfile, iconName, err)
+		return err
 	}
 
 	if createSideEffect != nil {
-		err = createSideEffect()
+		err = runSideEffect(ctx, tx, createSideEffect, iconName)
 		if err != nil {
 			return fmt.Errorf("failed to create icon file %s due to error while creating side-effect: %w", iconName, err)
 		}
@@ -258,10 +291,23 @@ func (repo PgRepository) AddIconfileToIcon(ctx context.Context, iconName string,
 	return nil
 }
 
+func addIconfileInTx(tx *sql.Tx, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string) error {
+	err := insertIconfile(tx, iconName, iconfile)
+	if err != nil {
+		return fmt.Errorf("failed to create iconfile %v: %w", iconName, err)
+	}
+
+	err = updateModifier(tx, iconName, modifiedBy)
+	if err != nil {
+		return fmt.Errorf("failed to add iconfile '%v' to icon '%s': %w", iconfile, iconName, err)
+	}
+	return nil
+}
+
 func insertIconfile(tx *sql.Tx, iconName string, iconfile domain.IconfileDescriptor) error {
-	const insertIconfileSQL = "INSERT INTO icon_file(icon_id, file_format, icon_size) " +
-		"SELECT id, $2, $3 FROM icon WHERE name = $1 RETURNING id"
-	_, err := tx.Exec(insertIconfileSQL, iconName, iconfile.Format, iconfile.Size)
+	const insertIconfileSQL = "INSERT INTO icon_file(icon_id, file_format, icon_size, review_status) " +
+		"SELECT id, $2, $3, $4 FROM icon WHERE name = $1 RETURNING id"
+	_, err := tx.Exec(insertIconfileSQL, iconName, iconfile.Format, iconfile.Size, toNullString(string(iconfile.ReviewStatus)))
 	if err != nil {
 		if IsDBError(err, ErrDuplicateRows) {
 			return domain.ErrIconfileAlreadyExists
@@ -337,13 +383,7 @@ func GetTagId(tx *sql.Tx, tag string) (int64, error) {
 	return tagId, nil
 }
 
-func (repo PgRepository) AddTag(ctx context.Context, iconName string, tag string, modifiedBy string) error {
-	tx, trError := repo.Conn.Pool.Begin()
-	if trError != nil {
-		return fmt.Errorf("failed to obtain transaction for adding tag '%s' to '%s': %w", tag, iconName, trError)
-	}
-	defer tx.Rollback()
-
+func addTagInTx(tx *sql.Tx, iconName string, tag string, modifiedBy string) error {
 	tagId, insertTagErr := GetTagId(tx, tag)
 	if insertTagErr != nil {
 		return fmt.Errorf("failed to insert tag '%s' for '%s': %w", tag, iconName, insertTagErr)
@@ -357,18 +397,10 @@ func (repo PgRepository) AddTag(ctx context.Context, iconName string, tag string
 	if err != nil {
 		return fmt.Errorf("failed to add tag '%s' to icon '%s': %w", tag, iconName, err)
 	}
-
-	tx.Commit()
 	return nil
 }
 
-func (repo PgRepository) RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy string) error {
-	tx, trError := repo.Conn.Pool.Begin()
-	if trError != nil {
-		return fmt.Errorf("failed to obtain transaction for removing tag '%s' to '%s': %w", tag, iconName, trError)
-	}
-	defer tx.Rollback()
-
+func removeTagInTx(tx *sql.Tx, iconName string, tag string, modifiedBy string) error {
 	tagId, insertTagErr := GetTagId(tx, tag)
 	if insertTagErr != nil {
 		return fmt.Errorf("failed to insert tag '%s' for '%s': %w", tag, iconName, insertTagErr)
@@ -382,6 +414,50 @@ func (repo PgRepository) RemoveTag(ctx context.Context, iconName string, tag str
 	if err != nil {
 		return fmt.Errorf("failed to remove tag '%s' from icon '%s': %w", tag, iconName, err)
 	}
+	return nil
+}
+
+func (repo PgRepository) AddTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	tx, trError := repo.Conn.Pool.Begin()
+	if trError != nil {
+		return fmt.Errorf("failed to obtain transaction for adding tag '%s' to '%s': %w", tag, iconName, trError)
+	}
+	defer tx.Rollback()
+
+	err := addTagInTx(tx, iconName, tag, modifiedBy)
+	if err != nil {
+		return err
+	}
+
+	if createSideEffect != nil {
+		err = runSideEffect(ctx, tx, createSideEffect, iconName)
+		if err != nil {
+			return fmt.Errorf("failed to add tag '%s' to icon '%s' due to error while creating side-effect: %w", tag, iconName, err)
+		}
+	}
+
+	tx.Commit()
+	return nil
+}
+
+func (repo PgRepository) RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	tx, trError := repo.Conn.Pool.Begin()
+	if trError != nil {
+		return fmt.Errorf("failed to obtain transaction for removing tag '%s' to '%s': %w", tag, iconName, trError)
+	}
+	defer tx.Rollback()
+
+	err := removeTagInTx(tx, iconName, tag, modifiedBy)
+	if err != nil {
+		return err
+	}
+
+	if createSideEffect != nil {
+		err = runSideEffect(ctx, tx, createSideEffect, iconName)
+		if err != nil {
+			return fmt.Errorf("failed to remove tag '%s' from icon '%s' due to error while creating side-effect: %w", tag, iconName, err)
+		}
+	}
 
 	tx.Commit()
 	return nil
@@ -426,7 +502,22 @@ func deleteIconfileBare(tx *sql.Tx, iconName string, iconfile domain.IconfileDes
 	return sqlResult, nil
 }
 
-func (repo PgRepository) DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func() error) error {
+func deleteIconInTx(tx *sql.Tx, iconName string) error {
+	iconDesc, err := describeIconInTx(tx, iconName, true)
+	if err != nil {
+		return fmt.Errorf("failed to describe icon %v: %w", iconName, err)
+	}
+
+	for _, iconFile := range iconDesc.Iconfiles {
+		_, err = deleteIconfileBare(tx, iconName, iconFile)
+		if err != nil {
+			return fmt.Errorf("failed to delete iconfile %v: %w", iconFile, err)
+		}
+	}
+	return nil
+}
+
+func (repo PgRepository) DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
 	var tx *sql.Tx
 	var err error
 
@@ -436,21 +527,13 @@ func (repo PgRepository) DeleteIcon(ctx context.Context, iconName string, modifi
 	}
 	defer tx.Rollback()
 
-	var iconDesc domain.IconDescriptor
-	iconDesc, err = describeIconInTx(tx, iconName, true)
+	err = deleteIconInTx(tx, iconName)
 	if err != nil {
-		return fmt.Errorf("failed to describe icon %v: %w", iconName, err)
-	}
-
-	for _, iconFile := range iconDesc.Iconfiles {
-		_, err = deleteIconfileBare(tx, iconName, iconFile)
-		if err != nil {
-			return fmt.Errorf("failed to delete iconfile %v: %w", iconFile, err)
-		}
+		return err
 	}
 
 	if createSideEffect != nil {
-		err = createSideEffect()
+		err = runSideEffect(ctx, tx, createSideEffect, iconName)
 		if err != nil {
 			return fmt.Errorf("failed to execute side effect while deleting icon %v: %w", iconName, err)
 		}
@@ -460,11 +543,29 @@ func (repo PgRepository) DeleteIcon(ctx context.Context, iconName string, modifi
 	return nil
 }
 
-func (repo PgRepository) DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func() error) error {
+func deleteIconfileInTx(tx *sql.Tx, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string) error {
+	sqlResult, err := deleteIconfileBare(tx, iconName, iconfile)
+	if err != nil {
+		return fmt.Errorf("failed to delete iconfile %v from %s: %w", iconfile, iconName, err)
+	}
+	rowsAffected, err := sqlResult.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected by deleting iconfile %v from %s: %w", iconfile, iconName, err)
+	}
+	if rowsAffected < 1 {
+		return domain.ErrIconfileNotFound
+	}
+
+	err = updateModifier(tx, iconName, modifiedBy)
+	if err != nil {
+		return fmt.Errorf("failed to delete iconfile %v from icon '%s': %w", iconfile, iconName, err)
+	}
+	return nil
+}
+
+func (repo PgRepository) DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
 	var err error
 	var tx *sql.Tx
-	var sqlResult sql.Result
-	var rowsAffected int64
 
 	tx, err = repo.Conn.Pool.Begin()
 	if err != nil {
@@ -472,13 +573,86 @@ func (repo PgRepository) DeleteIconfile(ctx context.Context, iconName string, ic
 	}
 	defer tx.Rollback()
 
-	sqlResult, err = deleteIconfileBare(tx, iconName, iconfile)
+	err = deleteIconfileInTx(tx, iconName, iconfile, modifiedBy)
 	if err != nil {
-		return fmt.Errorf("failed to delete iconfile %v from %s: %w", iconfile, iconName, err)
+		return err
+	}
+
+	if createSideEffect != nil {
+		err = runSideEffect(ctx, tx, createSideEffect, iconName)
+		if err != nil {
+			return fmt.Errorf("failed to create side-effect for removing iconfile %v from %s: %w", iconfile, iconName, err)
+		}
+	}
+
+	tx.Commit()
+	return nil
+}
+
+func applyOperationInTx(tx *sql.Tx, op domain.ChangesetOperation, modifiedBy string) error {
+	switch op.Action {
+	case domain.AuditActionCreateIcon:
+		return createIconInTx(tx, op.IconName, op.Iconfile.IconfileDescriptor, modifiedBy)
+	case domain.AuditActionAddIconfile:
+		return addIconfileInTx(tx, op.IconName, op.Iconfile.IconfileDescriptor, modifiedBy)
+	case domain.AuditActionDeleteIcon:
+		return deleteIconInTx(tx, op.IconName)
+	case domain.AuditActionDeleteIconfile:
+		return deleteIconfileInTx(tx, op.IconName, op.Iconfile.IconfileDescriptor, modifiedBy)
+	case domain.AuditActionAddTag:
+		return addTagInTx(tx, op.IconName, op.Tag, modifiedBy)
+	case domain.AuditActionRemoveTag:
+		return removeTagInTx(tx, op.IconName, op.Tag, modifiedBy)
+	default:
+		return fmt.Errorf("%s is not a changeset operation: %w", op.Action, domain.ErrInvalidChangeset)
+	}
+}
+
+// ApplyChangeset applies the operations in a single transaction along with the side-effect
+func (repo PgRepository) ApplyChangeset(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	tx, err := repo.Conn.Pool.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction for applying changeset: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, op := range ops {
+		err = applyOperationInTx(tx, op, modifiedBy)
+		if err != nil {
+			return fmt.Errorf("failed to apply changeset: failed to %s: %w", op, err)
+		}
+	}
+
+	if createSideEffect != nil {
+		err = runSideEffect(ctx, tx, createSideEffect, indexing.ChangesetIconNames(ops)...)
+		if err != nil {
+			return fmt.Errorf("failed to apply changeset due to error while creating side-effect: %w", err)
+		}
 	}
-	rowsAffected, err = sqlResult.RowsAffected()
+
+	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("failed to retrieve rows affected by deleting iconfile %v from %s: %w", iconfile, iconName, err)
+		return fmt.Errorf("failed to commit changeset: %w", err)
+	}
+	return nil
+}
+
+func (repo PgRepository) SetIconfileReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	tx, err := repo.Conn.Pool.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction for setting the review status of %v of %s: %w", iconfile, iconName, err)
+	}
+	defer tx.Rollback()
+
+	const updateStatusSQL = "UPDATE icon_file SET review_status = $4 " +
+		"WHERE icon_id = (SELECT id FROM icon WHERE name = $1) AND file_format = $2 AND icon_size = $3"
+	sqlResult, err := tx.Exec(updateStatusSQL, iconName, iconfile.Format, iconfile.Size, toNullString(string(status)))
+	if err != nil {
+		return fmt.Errorf("failed to set the review status of %v of %s: %w", iconfile, iconName, err)
+	}
+	rowsAffected, err := sqlResult.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected by setting the review status of %v of %s: %w", iconfile, iconName, err)
 	}
 	if rowsAffected < 1 {
 		return domain.ErrIconfileNotFound
@@ -486,13 +660,43 @@ func (repo PgRepository) DeleteIconfile(ctx context.Context, iconName string, ic
 
 	err = updateModifier(tx, iconName, modifiedBy)
 	if err != nil {
-		return fmt.Errorf("failed to delete iconfile %v from icon '%s': %w", iconfile, iconName, err)
+		return fmt.Errorf("failed to set the review status of %v of %s: %w", iconfile, iconName, err)
 	}
 
 	if createSideEffect != nil {
-		err = createSideEffect()
+		err = runSideEffect(ctx, tx, createSideEffect, iconName)
 		if err != nil {
-			return fmt.Errorf("failed to create side-effect for removing iconfile %v from %s: %w", iconfile, iconName, err)
+			return fmt.Errorf("failed to set the review status of %v of %s due to error while creating side-effect: %w", iconfile, iconName, err)
+		}
+	}
+
+	tx.Commit()
+	return nil
+}
+
+func (repo PgRepository) SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	tx, err := repo.Conn.Pool.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction for setting the description of %s: %w", iconName, err)
+	}
+	defer tx.Rollback()
+
+	sqlResult, err := tx.Exec("UPDATE icon SET description = $1, modified_by = $2 WHERE name = $3", toNullString(description), modifiedBy, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to set the description of %s: %w", iconName, err)
+	}
+	rowsAffected, err := sqlResult.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected by setting the description of %s: %w", iconName, err)
+	}
+	if rowsAffected < 1 {
+		return fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
+	}
+
+	if createSideEffect != nil {
+		err = runSideEffect(ctx, tx, createSideEffect, iconName)
+		if err != nil {
+			return fmt.Errorf("failed to set the description of %s due to error while creating side-effect: %w", iconName, err)
 		}
 	}
 
@@ -0,0 +1,101 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"strconv"
+	"time"
+)
+
+func (repo PgRepository) AddOutboxEntry(ctx context.Context, entry domain.OutboxEntry) (string, error) {
+	changes, marshalErr := json.Marshal(entry.Changes)
+	if marshalErr != nil {
+		return "", fmt.Errorf("failed to marshal changes of outbox entry: %w", marshalErr)
+	}
+	var id int64
+	err := repo.Conn.Pool.QueryRowContext(
+		ctx,
+		"INSERT INTO outbox(recorded_at, held_until, actor, changes) VALUES($1, $2, $3, $4::jsonb) RETURNING id",
+		entry.RecordedAt,
+		sql.NullTime{Time: entry.HeldUntil, Valid: !entry.HeldUntil.IsZero()},
+		entry.Actor,
+		string(changes),
+	).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to add outbox entry: %w", err)
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+func parseOutboxID(id string) (int64, error) {
+	parsed, parseErr := strconv.ParseInt(id, 10, 64)
+	if parseErr != nil {
+		return 0, fmt.Errorf("invalid outbox entry id %s: %w", id, parseErr)
+	}
+	return parsed, nil
+}
+
+func (repo PgRepository) RemoveOutboxEntry(ctx context.Context, id string) error {
+	entryID, parseErr := parseOutboxID(id)
+	if parseErr != nil {
+		return parseErr
+	}
+	_, err := repo.Conn.Pool.ExecContext(ctx, "DELETE FROM outbox WHERE id = $1", entryID)
+	if err != nil {
+		return fmt.Errorf("failed to remove outbox entry %s: %w", id, err)
+	}
+	return nil
+}
+
+// GetOutboxEntries returns the entries recorded before the time given and no longer held by then, the oldest first
+func (repo PgRepository) GetOutboxEntries(ctx context.Context, before time.Time) ([]domain.OutboxEntry, error) {
+	rows, queryErr := repo.Conn.Pool.QueryContext(
+		ctx,
+		"SELECT id, recorded_at, held_until, actor, changes, attempts, last_error FROM outbox "+
+			"WHERE recorded_at < $1 AND (held_until IS NULL OR held_until < $1) ORDER BY recorded_at, id",
+		before,
+	)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to query outbox entries: %w", queryErr)
+	}
+	defer rows.Close()
+
+	entries := []domain.OutboxEntry{}
+	for rows.Next() {
+		var id int64
+		var changes string
+		var heldUntil sql.NullTime
+		var lastError sql.NullString
+		entry := domain.OutboxEntry{}
+		scanErr := rows.Scan(&id, &entry.RecordedAt, &heldUntil, &entry.Actor, &changes, &entry.Attempts, &lastError)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to read outbox entry: %w", scanErr)
+		}
+		if unmarshalErr := json.Unmarshal([]byte(changes), &entry.Changes); unmarshalErr != nil {
+			return nil, fmt.Errorf("failed to unmarshal changes of outbox entry %d: %w", id, unmarshalErr)
+		}
+		entry.ID = strconv.FormatInt(id, 10)
+		entry.HeldUntil = heldUntil.Time
+		entry.LastError = lastError.String
+		entries = append(entries, entry)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, fmt.Errorf("error while processing outbox entries: %w", rowsErr)
+	}
+	return entries, nil
+}
+
+func (repo PgRepository) RecordOutboxAttempt(ctx context.Context, id string, attemptErr error) error {
+	entryID, parseErr := parseOutboxID(id)
+	if parseErr != nil {
+		return parseErr
+	}
+	_, err := repo.Conn.Pool.ExecContext(ctx, "UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE id = $2", attemptErr.Error(), entryID)
+	if err != nil {
+		return fmt.Errorf("failed to record attempt on outbox entry %s: %w", id, err)
+	}
+	return nil
+}
@@ -51,6 +51,74 @@ var upgradeSteps = []upgradeStep{
 			"ALTER TABLE icon_file DROP content",
 		},
 	},
+	{
+		version: "2026-10-19/1 - audit log",
+		sqls: []string{
+			`CREATE TABLE audit(
+				id              bigserial primary key,
+				recorded_at     timestamptz NOT NULL,
+				actor           text NOT NULL,
+				action          text NOT NULL,
+				icon_name       text NOT NULL,
+				iconfile_format text,
+				iconfile_size   text,
+				tag             text,
+				before          jsonb,
+				after           jsonb,
+				request_id      text
+			)`,
+			"CREATE INDEX audit_icon_name_idx ON audit(icon_name, id)",
+			"CREATE INDEX audit_actor_idx ON audit(actor, id)",
+		},
+	},
+	{
+		version: "2026-10-19/2 - review workflow",
+		sqls: []string{
+			// NULL stands for "published"
+			"ALTER TABLE icon_file ADD review_status text",
+			"ALTER TABLE audit ADD comment text",
+		},
+	},
+	{
+		version: "2026-10-19/3 - content in postgres",
+		sqls: []string{
+			// Used by the postgres blobstore only. Keyed by the path the file would have in the git repository,
+			// independently of the index tables, so that the index can be rebuilt from it.
+			"CREATE SEQUENCE blob_version_seq",
+			`CREATE TABLE blob(
+				path        text primary key,
+				content     bytea NOT NULL,
+				modified_by text NOT NULL,
+				modified_at timestamptz NOT NULL DEFAULT now(),
+				version     bigint NOT NULL DEFAULT nextval('blob_version_seq')
+			)`,
+		},
+	},
+	{
+		version: "2026-10-19/4 - outbox",
+		sqls: []string{
+			`CREATE TABLE outbox(
+				id          bigserial primary key,
+				recorded_at timestamptz NOT NULL,
+				actor       text NOT NULL,
+				changes     jsonb NOT NULL,
+				attempts    int NOT NULL DEFAULT 0,
+				last_error  text
+			)`,
+		},
+	},
+	{
+		version: "2026-10-19/5 - icon description",
+		sqls: []string{
+			"ALTER TABLE icon ADD description text",
+		},
+	},
+	{
+		version: "2026-10-19/6 - outbox hold",
+		sqls: []string{
+			"ALTER TABLE outbox ADD held_until timestamptz",
+		},
+	},
 }
 
 type dbSchema struct {
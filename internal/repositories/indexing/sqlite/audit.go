@@ -0,0 +1,175 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditTimestampFormat is fixed-width so that the timestamps stored as text compare the way the times do
+const auditTimestampFormat = "2006-01-02T15:04:05.000000000Z"
+
+func formatAuditTimestamp(timestamp time.Time) string {
+	return timestamp.UTC().Format(auditTimestampFormat)
+}
+
+func marshalAuditedIcon(iconDesc *domain.IconDescriptor) (sql.NullString, error) {
+	if iconDesc == nil {
+		return sql.NullString{}, nil
+	}
+	iconJSON, err := json.Marshal(iconDesc)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(iconJSON), Valid: true}, nil
+}
+
+func unmarshalAuditedIcon(iconJSON sql.NullString) (*domain.IconDescriptor, error) {
+	if !iconJSON.Valid {
+		return nil, nil
+	}
+	iconDesc := domain.IconDescriptor{}
+	err := json.Unmarshal([]byte(iconJSON.String), &iconDesc)
+	if err != nil {
+		return nil, err
+	}
+	return &iconDesc, nil
+}
+
+// RecordAuditEntry records the entry in the transaction of the change to the index in progress, if any
+func (repo SQLiteRepository) RecordAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	before, marshalBeforeErr := marshalAuditedIcon(entry.Before)
+	if marshalBeforeErr != nil {
+		return fmt.Errorf("failed to marshal icon state before %v: %w", entry, marshalBeforeErr)
+	}
+	after, marshalAfterErr := marshalAuditedIcon(entry.After)
+	if marshalAfterErr != nil {
+		return fmt.Errorf("failed to marshal icon state after %v: %w", entry, marshalAfterErr)
+	}
+
+	var iconfileFormat, iconfileSize sql.NullString
+	if entry.Iconfile != nil {
+		iconfileFormat = toNullString(entry.Iconfile.Format)
+		iconfileSize = toNullString(entry.Iconfile.Size)
+	}
+
+	const insertAuditSQL = "INSERT INTO audit(recorded_at, actor, action, icon_name, iconfile_format, iconfile_size, tag, before, after, request_id, comment) " +
+		"VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := repo.writer(ctx).ExecContext(
+		ctx,
+		insertAuditSQL,
+		formatAuditTimestamp(entry.Timestamp),
+		entry.Actor,
+		string(entry.Action),
+		entry.IconName,
+		iconfileFormat,
+		iconfileSize,
+		toNullString(entry.Tag),
+		before,
+		after,
+		toNullString(entry.RequestID),
+		toNullString(entry.Comment),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry %v: %w", entry, err)
+	}
+	return nil
+}
+
+// QueryAuditEntries returns the entries matching the query, the most recent first.
+// The cursor is the id of the last entry on the previous page.
+func (repo SQLiteRepository) QueryAuditEntries(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	conditions := []string{}
+	args := []any{}
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, condition)
+	}
+
+	if len(query.IconName) > 0 {
+		addCondition("icon_name = ?", query.IconName)
+	}
+	if len(query.Actor) > 0 {
+		addCondition("actor = ?", query.Actor)
+	}
+	if !query.Since.IsZero() {
+		addCondition("recorded_at >= ?", formatAuditTimestamp(query.Since))
+	}
+	if !query.Until.IsZero() {
+		addCondition("recorded_at < ?", formatAuditTimestamp(query.Until))
+	}
+	if len(query.Cursor) > 0 {
+		lastID, parseErr := strconv.ParseInt(query.Cursor, 10, 64)
+		if parseErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("invalid audit cursor %s: %w", query.Cursor, domain.ErrInvalidAuditCursor)
+		}
+		addCondition("id < ?", lastID)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = domain.DefaultAuditPageSize
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit+1)
+	querySQL := "SELECT id, recorded_at, actor, action, icon_name, iconfile_format, iconfile_size, tag, before, after, request_id, comment FROM audit" +
+		whereClause +
+		" ORDER BY id DESC LIMIT ?"
+
+	rows, queryErr := repo.Conn.Reader.QueryContext(ctx, querySQL, args...)
+	if queryErr != nil {
+		return domain.AuditPage{}, fmt.Errorf("failed to query audit entries with %#v: %w", query, queryErr)
+	}
+	defer rows.Close()
+
+	entries := []domain.AuditEntry{}
+	for rows.Next() {
+		var id int64
+		var recordedAt, action string
+		var iconfileFormat, iconfileSize, tag, before, after, requestID, comment sql.NullString
+		entry := domain.AuditEntry{}
+		scanErr := rows.Scan(&id, &recordedAt, &entry.Actor, &action, &entry.IconName, &iconfileFormat, &iconfileSize, &tag, &before, &after, &requestID, &comment)
+		if scanErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("failed to read audit entry: %w", scanErr)
+		}
+		var parseErr error
+		if entry.Timestamp, parseErr = time.Parse(auditTimestampFormat, recordedAt); parseErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("failed to parse the timestamp of audit entry %d: %w", id, parseErr)
+		}
+		entry.ID = strconv.FormatInt(id, 10)
+		entry.Action = domain.AuditAction(action)
+		entry.Tag = tag.String
+		entry.RequestID = requestID.String
+		entry.Comment = comment.String
+		if iconfileFormat.Valid || iconfileSize.Valid {
+			entry.Iconfile = &domain.IconfileDescriptor{Format: iconfileFormat.String, Size: iconfileSize.String}
+		}
+		var unmarshalErr error
+		if entry.Before, unmarshalErr = unmarshalAuditedIcon(before); unmarshalErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("failed to unmarshal icon state before in audit entry %d: %w", id, unmarshalErr)
+		}
+		if entry.After, unmarshalErr = unmarshalAuditedIcon(after); unmarshalErr != nil {
+			return domain.AuditPage{}, fmt.Errorf("failed to unmarshal icon state after in audit entry %d: %w", id, unmarshalErr)
+		}
+		entries = append(entries, entry)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return domain.AuditPage{}, fmt.Errorf("error while processing audit entries: %w", rowsErr)
+	}
+
+	page := domain.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = page.Entries[limit-1].ID
+	}
+	return page, nil
+}
@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iconrepo/internal/logging"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// connection holds two pools on the same database file. SQLite lets a single writer in at a time, so the
+// writer pool has a single connection and begins its transactions with the write lock taken. Readers go
+// through the other pool and, the database being in WAL mode, aren't held up by the writer.
+type connection struct {
+	Writer *sql.DB
+	Reader *sql.DB
+	file   string
+}
+
+// NewDBConnection opens the database file, creating it if it doesn't exist
+func NewDBConnection(file string) (connection, error) {
+	writer, writerErr := open(file, "immediate")
+	if writerErr != nil {
+		return connection{}, writerErr
+	}
+	writer.SetMaxOpenConns(1)
+
+	reader, readerErr := open(file, "deferred")
+	if readerErr != nil {
+		writer.Close()
+		return connection{}, readerErr
+	}
+
+	return connection{Writer: writer, Reader: reader, file: file}, nil
+}
+
+func open(file string, txLock string) (*sql.DB, error) {
+	logger := logging.Get()
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_txlock", txLock)
+	dsn := "file:" + file + "?" + params.Encode()
+	logger.Debug().Str("dsn", dsn).Msg("opening SQLite database...")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %s: %w", file, err)
+	}
+	if pingErr := db.Ping(); pingErr != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite database %s: %w", file, pingErr)
+	}
+	return db, nil
+}
+
+func (conn connection) Close() error {
+	readerErr := conn.Reader.Close()
+	if writerErr := conn.Writer.Close(); writerErr != nil {
+		return writerErr
+	}
+	return readerErr
+}
+
+type txContextKey struct{}
+
+// contextWithTx hands the write transaction of a change to the index over to the side-effect of the change.
+// The writer pool having a single connection, writes made by the side-effect to the database have to go through it.
+func contextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// writer returns the transaction handed over by contextWithTx, if any, or the writer pool
+func (repo SQLiteRepository) writer(ctx context.Context) dbExecutor {
+	if tx, _ := ctx.Value(txContextKey{}).(*sql.Tx); tx != nil {
+		return tx
+	}
+	return repo.Conn.Writer
+}
@@ -0,0 +1,530 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/repositories/indexing"
+	"strings"
+
+	"github.com/rs/zerolog"
+	sqlite "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	ErrDuplicateRows = errors.New("duplicate rows")
+)
+
+func MapDBError(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return ErrDuplicateRows
+	case sqlite3.SQLITE_ERROR:
+		if strings.Contains(sqliteErr.Error(), "no such table") {
+			return indexing.ErrTableNotFound
+		}
+	}
+	return nil
+}
+
+func IsDBError(err error, target error) bool {
+	if knownDBError := MapDBError(err); errors.Is(knownDBError, target) {
+		return true
+	}
+	return false
+}
+
+func toNullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: len(value) > 0}
+}
+
+func describeIconInTx(tx *sql.Tx, iconName string) (domain.IconDescriptor, error) {
+	var err error
+	var rows *sql.Rows
+
+	const iconSQL = "SELECT id, modified_by, coalesce(description, '') FROM icon WHERE name = ?"
+	const iconfilesSQL = "SELECT file_format, icon_size, review_status FROM icon_file " +
+		"WHERE icon_id = ? " +
+		"ORDER BY file_format, icon_size"
+	// The tags are listed in the order they have been added to the icon
+	const tagsSQL = "SELECT text FROM tag, icon_to_tags " +
+		"WHERE icon_to_tags.icon_id = ? " +
+		"AND icon_to_tags.tag_id = tag.id " +
+		"ORDER BY icon_to_tags.rowid"
+
+	var iconId int64
+	var modifiedBy string
+	var description string
+	err = tx.QueryRow(iconSQL, iconName).Scan(&iconId, &modifiedBy, &description)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.IconDescriptor{}, fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
+		}
+		return domain.IconDescriptor{}, fmt.Errorf("error while retrieving icon '%s' from database: %w", iconName, err)
+	}
+
+	iconfiles := make([]domain.IconfileDescriptor, 0, 10)
+	emptyIcon := domain.IconDescriptor{}
+	err = func() error {
+		rows, err = tx.Query(iconfilesSQL, iconId)
+		if err != nil {
+			return fmt.Errorf("error while retrieving iconfiles for '%s' from database: %w", iconName, err)
+		}
+		defer rows.Close()
+		var format string
+		var size string
+		var reviewStatus sql.NullString
+		for rows.Next() {
+			err = rows.Scan(&format, &size, &reviewStatus)
+			if err != nil {
+				return fmt.Errorf("error while retrieving iconfiles for '%s' from database: %w", iconName, err)
+			}
+			iconfiles = append(iconfiles, domain.IconfileDescriptor{
+				Format:       format,
+				Size:         size,
+				ReviewStatus: domain.ReviewStatus(reviewStatus.String),
+			})
+		}
+		return rows.Err()
+	}()
+	if err != nil {
+		return emptyIcon, err
+	}
+
+	tags := make([]string, 0, 50)
+	err = func() error {
+		rows, err = tx.Query(tagsSQL, iconId)
+		if err != nil {
+			return fmt.Errorf("error while retrieving tags for '%s' from database: %w", iconName, err)
+		}
+		defer rows.Close()
+		var tag string
+		for rows.Next() {
+			err = rows.Scan(&tag)
+			if err != nil {
+				return fmt.Errorf("error while retrieving tags for '%s' from database: %w", iconName, err)
+			}
+			tags = append(tags, tag)
+		}
+		return rows.Err()
+	}()
+	if err != nil {
+		return emptyIcon, err
+	}
+
+	return domain.IconDescriptor{
+		IconAttributes: domain.IconAttributes{
+			Name:        iconName,
+			ModifiedBy:  modifiedBy,
+			Description: description,
+			Tags:        tags,
+		},
+		Iconfiles: iconfiles,
+	}, nil
+}
+
+// SQLiteRepository keeps the index in an SQLite database file, for single-node deployments
+// which can do without a database server
+type SQLiteRepository struct {
+	logger zerolog.Logger
+	Conn   connection
+}
+
+func NewSQLiteRepository(conn connection) SQLiteRepository {
+	return SQLiteRepository{
+		Conn: conn,
+	}
+}
+
+func (repo SQLiteRepository) Close() error {
+	return repo.Conn.Close()
+}
+
+// DescribeIcon returns the attributes of the icon having the specified name, "attributes" meaning here the entire icon without iconfiles' contents
+func (repo SQLiteRepository) DescribeIcon(ctx context.Context, iconName string) (domain.IconDescriptor, error) {
+	tx, err := repo.Conn.Reader.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.IconDescriptor{}, err
+	}
+	defer tx.Rollback()
+	return describeIconInTx(tx, iconName)
+}
+
+func (repo SQLiteRepository) DescribeAllIcons(ctx context.Context) ([]domain.IconDescriptor, error) {
+	return repo.describeIcons(ctx, "SELECT name FROM icon ORDER BY name")
+}
+
+// GetIconsWithTag describes the icons having the tag
+func (repo SQLiteRepository) GetIconsWithTag(ctx context.Context, tag string) ([]domain.IconDescriptor, error) {
+	const iconsWithTagSQL = "SELECT icon.name FROM icon, icon_to_tags, tag " +
+		"WHERE tag.text = ? " +
+		"AND icon_to_tags.tag_id = tag.id " +
+		"AND icon_to_tags.icon_id = icon.id " +
+		"ORDER BY icon.name"
+	return repo.describeIcons(ctx, iconsWithTagSQL, tag)
+}
+
+// describeIcons describes the icons the names of which the query selects
+func (repo SQLiteRepository) describeIcons(ctx context.Context, iconNamesSQL string, args ...any) ([]domain.IconDescriptor, error) {
+	tx, err := repo.Conn.Reader.BeginTx(ctx, nil)
+	if err != nil {
+		return []domain.IconDescriptor{}, err
+	}
+	defer tx.Rollback()
+
+	iconNames, errQuery := func() ([]string, error) {
+		rows, errQuery := tx.Query(iconNamesSQL, args...)
+		if errQuery != nil {
+			return nil, fmt.Errorf("failed to retrieve icon names: %w", errQuery)
+		}
+		defer rows.Close()
+
+		iconNames := []string{}
+		for rows.Next() {
+			var name string
+			if scanErr := rows.Scan(&name); scanErr != nil {
+				return nil, fmt.Errorf("failed to retrieve icon names: %w", scanErr)
+			}
+			iconNames = append(iconNames, name)
+		}
+		if errProcessRows := rows.Err(); errProcessRows != nil {
+			return nil, fmt.Errorf("error while processing rows: %w", errProcessRows)
+		}
+		return iconNames, nil
+	}()
+	if errQuery != nil {
+		return []domain.IconDescriptor{}, errQuery
+	}
+
+	result := []domain.IconDescriptor{}
+	for _, iconName := range iconNames {
+		icon, errIconDesc := describeIconInTx(tx, iconName)
+		if errIconDesc != nil {
+			return []domain.IconDescriptor{}, fmt.Errorf("failed to retrieve icon %s: %w", iconName, errIconDesc)
+		}
+		result = append(result, icon)
+	}
+
+	return result, nil
+}
+
+// runInTx runs the change and then the side-effect in a write transaction, committing only if both succeed.
+// The state of the icons changed is handed over to the side-effect.
+func (repo SQLiteRepository) runInTx(ctx context.Context, change func(tx *sql.Tx) error, createSideEffect func(ctx context.Context) error, iconNames ...string) error {
+	tx, err := repo.Conn.Writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = change(tx); err != nil {
+		return err
+	}
+
+	if createSideEffect != nil {
+		icons, describeErr := indexing.DescribeCommittedIcons(iconNames, func(iconName string) (domain.IconDescriptor, error) {
+			return describeIconInTx(tx, iconName)
+		})
+		if describeErr != nil {
+			return describeErr
+		}
+		if err = createSideEffect(indexing.ContextWithCommittedIcons(contextWithTx(ctx, tx), icons...)); err != nil {
+			return fmt.Errorf("error while creating side-effect: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func createIconInTx(tx *sql.Tx, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string) error {
+	_, err := tx.Exec("INSERT INTO icon(name, modified_by) VALUES(?, ?)", iconName, modifiedBy)
+	if err != nil {
+		if IsDBError(err, ErrDuplicateRows) {
+			return domain.ErrIconAlreadyExists
+		}
+		return err
+	}
+	return insertIconfile(tx, iconName, iconfile)
+}
+
+func (repo SQLiteRepository) CreateIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	err := repo.runInTx(ctx, func(tx *sql.Tx) error {
+		return createIconInTx(tx, iconName, iconfile, modifiedBy)
+	}, createSideEffect, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to create icon %v: %w", iconName, err)
+	}
+
+	repo.logger.Info().Str("icon-name", iconName).Interface("iconfile", iconfile).Msg("Icon created")
+	return nil
+}
+
+func updateModifier(tx *sql.Tx, iconName string, modifiedBy string) error {
+	sqlResult, err := tx.Exec("UPDATE icon SET modified_by = ?, modified_at = current_timestamp WHERE name = ?", modifiedBy, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to update icon %s with the modifier %s: %w", iconName, modifiedBy, err)
+	}
+	rowsAffected, err := sqlResult.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected by updating icon %s with the modifier %s: %w", iconName, modifiedBy, err)
+	}
+	if rowsAffected < 1 {
+		return fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
+	}
+	return nil
+}
+
+func addIconfileInTx(tx *sql.Tx, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string) error {
+	if err := updateModifier(tx, iconName, modifiedBy); err != nil {
+		return err
+	}
+	return insertIconfile(tx, iconName, iconfile)
+}
+
+func (repo SQLiteRepository) AddIconfileToIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	err := repo.runInTx(ctx, func(tx *sql.Tx) error {
+		return addIconfileInTx(tx, iconName, iconfile, modifiedBy)
+	}, createSideEffect, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to add iconfile %v to icon %s: %w", iconfile, iconName, err)
+	}
+	return nil
+}
+
+func insertIconfile(tx *sql.Tx, iconName string, iconfile domain.IconfileDescriptor) error {
+	const insertIconfileSQL = "INSERT INTO icon_file(icon_id, file_format, icon_size, review_status) " +
+		"SELECT id, ?, ?, ? FROM icon WHERE name = ?"
+	_, err := tx.Exec(insertIconfileSQL, iconfile.Format, iconfile.Size, toNullString(string(iconfile.ReviewStatus)), iconName)
+	if err != nil {
+		if IsDBError(err, ErrDuplicateRows) {
+			return domain.ErrIconfileAlreadyExists
+		}
+		return fmt.Errorf("failed to insert iconfile %v: %w", iconName, err)
+	}
+	return nil
+}
+
+// GetExistingTags returns the tags ever added to any of the icons
+func (repo SQLiteRepository) GetExistingTags(ctx context.Context) ([]string, error) {
+	rows, err := repo.Conn.Reader.QueryContext(ctx, "SELECT text FROM tag ORDER BY text")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0, 50)
+	for rows.Next() {
+		var tag string
+		err := rows.Scan(&tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve all tags: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve all tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+func addTagInTx(tx *sql.Tx, iconName string, tag string, modifiedBy string) error {
+	if err := updateModifier(tx, iconName, modifiedBy); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT OR IGNORE INTO tag(text) VALUES(?)", tag); err != nil {
+		return fmt.Errorf("failed to insert tag: %w", err)
+	}
+	_, err := tx.Exec("INSERT OR IGNORE INTO icon_to_tags(icon_id, tag_id) "+
+		"SELECT icon.id, tag.id FROM icon, tag WHERE icon.name = ? AND tag.text = ?", iconName, tag)
+	if err != nil {
+		return fmt.Errorf("failed to connect tag to icon: %w", err)
+	}
+	return nil
+}
+
+func (repo SQLiteRepository) AddTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	err := repo.runInTx(ctx, func(tx *sql.Tx) error {
+		return addTagInTx(tx, iconName, tag, modifiedBy)
+	}, createSideEffect, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to add tag '%s' to icon '%s': %w", tag, iconName, err)
+	}
+	return nil
+}
+
+func removeTagInTx(tx *sql.Tx, iconName string, tag string, modifiedBy string) error {
+	if err := updateModifier(tx, iconName, modifiedBy); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		DELETE FROM icon_to_tags
+		WHERE icon_id = (SELECT id FROM icon WHERE name = ?)
+			AND tag_id = (SELECT id FROM tag WHERE text = ?)
+	`, iconName, tag)
+	if err != nil {
+		return fmt.Errorf("failed to disconnect tag from icon: %w", err)
+	}
+	return nil
+}
+
+func (repo SQLiteRepository) RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	err := repo.runInTx(ctx, func(tx *sql.Tx) error {
+		return removeTagInTx(tx, iconName, tag, modifiedBy)
+	}, createSideEffect, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to remove tag '%s' from icon '%s': %w", tag, iconName, err)
+	}
+	return nil
+}
+
+// deleteIconIfEmpty deletes the icon once its last iconfile is gone
+func deleteIconIfEmpty(tx *sql.Tx, iconName string) error {
+	const deleteIconSQL = "DELETE FROM icon WHERE name = ? " +
+		"AND NOT EXISTS (SELECT 1 FROM icon_file WHERE icon_file.icon_id = icon.id)"
+	if _, err := tx.Exec(deleteIconSQL, iconName); err != nil {
+		return fmt.Errorf("failed to delete icon %v: %w", iconName, err)
+	}
+	return nil
+}
+
+func deleteIconInTx(tx *sql.Tx, iconName string) error {
+	sqlResult, err := tx.Exec("DELETE FROM icon WHERE name = ?", iconName)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := sqlResult.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected < 1 {
+		return fmt.Errorf("icon %s not found: %w", iconName, domain.ErrIconNotFound)
+	}
+	return nil
+}
+
+func (repo SQLiteRepository) DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	err := repo.runInTx(ctx, func(tx *sql.Tx) error {
+		return deleteIconInTx(tx, iconName)
+	}, createSideEffect, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to delete icon %v: %w", iconName, err)
+	}
+	return nil
+}
+
+func deleteIconfileInTx(tx *sql.Tx, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string) error {
+	if err := updateModifier(tx, iconName, modifiedBy); err != nil {
+		return err
+	}
+	const deleteIconfileSQL = "DELETE FROM icon_file " +
+		"WHERE icon_id = (SELECT id FROM icon WHERE name = ?) AND file_format = ? AND icon_size = ?"
+	sqlResult, err := tx.Exec(deleteIconfileSQL, iconName, iconfile.Format, iconfile.Size)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := sqlResult.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected < 1 {
+		return domain.ErrIconfileNotFound
+	}
+	return deleteIconIfEmpty(tx, iconName)
+}
+
+func (repo SQLiteRepository) DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	err := repo.runInTx(ctx, func(tx *sql.Tx) error {
+		return deleteIconfileInTx(tx, iconName, iconfile, modifiedBy)
+	}, createSideEffect, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to delete iconfile %v from %s: %w", iconfile, iconName, err)
+	}
+	return nil
+}
+
+func (repo SQLiteRepository) SetIconfileReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	err := repo.runInTx(ctx, func(tx *sql.Tx) error {
+		if err := updateModifier(tx, iconName, modifiedBy); err != nil {
+			return err
+		}
+		const updateStatusSQL = "UPDATE icon_file SET review_status = ? " +
+			"WHERE icon_id = (SELECT id FROM icon WHERE name = ?) AND file_format = ? AND icon_size = ?"
+		sqlResult, err := tx.Exec(updateStatusSQL, toNullString(string(status)), iconName, iconfile.Format, iconfile.Size)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := sqlResult.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected < 1 {
+			return domain.ErrIconfileNotFound
+		}
+		return nil
+	}, createSideEffect, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to set the review status of %v of %s: %w", iconfile, iconName, err)
+	}
+	return nil
+}
+
+func (repo SQLiteRepository) SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	err := repo.runInTx(ctx, func(tx *sql.Tx) error {
+		if err := updateModifier(tx, iconName, modifiedBy); err != nil {
+			return err
+		}
+		_, err := tx.Exec("UPDATE icon SET description = ? WHERE name = ?", toNullString(description), iconName)
+		return err
+	}, createSideEffect, iconName)
+	if err != nil {
+		return fmt.Errorf("failed to set the description of %s: %w", iconName, err)
+	}
+	return nil
+}
+
+func applyOperationInTx(tx *sql.Tx, op domain.ChangesetOperation, modifiedBy string) error {
+	switch op.Action {
+	case domain.AuditActionCreateIcon:
+		return createIconInTx(tx, op.IconName, op.Iconfile.IconfileDescriptor, modifiedBy)
+	case domain.AuditActionAddIconfile:
+		return addIconfileInTx(tx, op.IconName, op.Iconfile.IconfileDescriptor, modifiedBy)
+	case domain.AuditActionDeleteIcon:
+		return deleteIconInTx(tx, op.IconName)
+	case domain.AuditActionDeleteIconfile:
+		return deleteIconfileInTx(tx, op.IconName, op.Iconfile.IconfileDescriptor, modifiedBy)
+	case domain.AuditActionAddTag:
+		return addTagInTx(tx, op.IconName, op.Tag, modifiedBy)
+	case domain.AuditActionRemoveTag:
+		return removeTagInTx(tx, op.IconName, op.Tag, modifiedBy)
+	default:
+		return fmt.Errorf("%s is not a changeset operation: %w", op.Action, domain.ErrInvalidChangeset)
+	}
+}
+
+// ApplyChangeset applies the operations in a single transaction along with the side-effect
+func (repo SQLiteRepository) ApplyChangeset(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	err := repo.runInTx(ctx, func(tx *sql.Tx) error {
+		for _, op := range ops {
+			if err := applyOperationInTx(tx, op, modifiedBy); err != nil {
+				return fmt.Errorf("failed to %s: %w", op, err)
+			}
+		}
+		return nil
+	}, createSideEffect, indexing.ChangesetIconNames(ops)...)
+	if err != nil {
+		return fmt.Errorf("failed to apply changeset: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"strconv"
+	"time"
+)
+
+func (repo SQLiteRepository) AddOutboxEntry(ctx context.Context, entry domain.OutboxEntry) (string, error) {
+	changes, marshalErr := json.Marshal(entry.Changes)
+	if marshalErr != nil {
+		return "", fmt.Errorf("failed to marshal changes of outbox entry: %w", marshalErr)
+	}
+	result, err := repo.Conn.Writer.ExecContext(
+		ctx,
+		"INSERT INTO outbox(recorded_at, held_until, actor, changes) VALUES(?, ?, ?, ?)",
+		formatAuditTimestamp(entry.RecordedAt),
+		sql.NullString{String: formatAuditTimestamp(entry.HeldUntil), Valid: !entry.HeldUntil.IsZero()},
+		entry.Actor,
+		string(changes),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to add outbox entry: %w", err)
+	}
+	id, idErr := result.LastInsertId()
+	if idErr != nil {
+		return "", fmt.Errorf("failed to get the id of the outbox entry added: %w", idErr)
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+func parseOutboxID(id string) (int64, error) {
+	parsed, parseErr := strconv.ParseInt(id, 10, 64)
+	if parseErr != nil {
+		return 0, fmt.Errorf("invalid outbox entry id %s: %w", id, parseErr)
+	}
+	return parsed, nil
+}
+
+func (repo SQLiteRepository) RemoveOutboxEntry(ctx context.Context, id string) error {
+	entryID, parseErr := parseOutboxID(id)
+	if parseErr != nil {
+		return parseErr
+	}
+	_, err := repo.Conn.Writer.ExecContext(ctx, "DELETE FROM outbox WHERE id = ?", entryID)
+	if err != nil {
+		return fmt.Errorf("failed to remove outbox entry %s: %w", id, err)
+	}
+	return nil
+}
+
+// GetOutboxEntries returns the entries recorded before the time given and no longer held by then, the oldest first
+func (repo SQLiteRepository) GetOutboxEntries(ctx context.Context, before time.Time) ([]domain.OutboxEntry, error) {
+	rows, queryErr := repo.Conn.Reader.QueryContext(
+		ctx,
+		"SELECT id, recorded_at, held_until, actor, changes, attempts, last_error FROM outbox "+
+			"WHERE recorded_at < ?1 AND (held_until IS NULL OR held_until < ?1) ORDER BY recorded_at, id",
+		formatAuditTimestamp(before),
+	)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to query outbox entries: %w", queryErr)
+	}
+	defer rows.Close()
+
+	entries := []domain.OutboxEntry{}
+	for rows.Next() {
+		var id int64
+		var recordedAt, changes string
+		var heldUntil, lastError sql.NullString
+		entry := domain.OutboxEntry{}
+		scanErr := rows.Scan(&id, &recordedAt, &heldUntil, &entry.Actor, &changes, &entry.Attempts, &lastError)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to read outbox entry: %w", scanErr)
+		}
+		var parseErr error
+		if entry.RecordedAt, parseErr = time.Parse(auditTimestampFormat, recordedAt); parseErr != nil {
+			return nil, fmt.Errorf("failed to parse the timestamp of outbox entry %d: %w", id, parseErr)
+		}
+		if heldUntil.Valid {
+			if entry.HeldUntil, parseErr = time.Parse(auditTimestampFormat, heldUntil.String); parseErr != nil {
+				return nil, fmt.Errorf("failed to parse the deadline of outbox entry %d: %w", id, parseErr)
+			}
+		}
+		if unmarshalErr := json.Unmarshal([]byte(changes), &entry.Changes); unmarshalErr != nil {
+			return nil, fmt.Errorf("failed to unmarshal changes of outbox entry %d: %w", id, unmarshalErr)
+		}
+		entry.ID = strconv.FormatInt(id, 10)
+		entry.LastError = lastError.String
+		entries = append(entries, entry)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, fmt.Errorf("error while processing outbox entries: %w", rowsErr)
+	}
+	return entries, nil
+}
+
+func (repo SQLiteRepository) RecordOutboxAttempt(ctx context.Context, id string, attemptErr error) error {
+	entryID, parseErr := parseOutboxID(id)
+	if parseErr != nil {
+		return parseErr
+	}
+	_, err := repo.Conn.Writer.ExecContext(ctx, "UPDATE outbox SET attempts = attempts + 1, last_error = ? WHERE id = ?", attemptErr.Error(), entryID)
+	if err != nil {
+		return fmt.Errorf("failed to record attempt on outbox entry %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"iconrepo/internal/logging"
+	"sort"
+	"strings"
+)
+
+type upgradeStep struct {
+	version string
+	sqls    []string
+}
+
+// upgradeSteps are applied the same way as those of the Postgres index. The tables mirror the Postgres ones
+// as of the time SQLite support was added.
+var upgradeSteps = []upgradeStep{
+	{
+		version: "2026-10-19/0 - first version",
+		sqls: []string{
+			`CREATE TABLE icon(
+				id          integer primary key,
+				name        text NOT NULL,
+				modified_by text,
+				modified_at text DEFAULT current_timestamp,
+				UNIQUE(name)
+			)`,
+			`CREATE TABLE icon_file(
+				id            integer primary key,
+				icon_id       integer NOT NULL REFERENCES icon(id) ON DELETE CASCADE,
+				file_format   text NOT NULL,
+				icon_size     text NOT NULL,
+				review_status text,
+				UNIQUE (icon_id, file_format, icon_size)
+			)`,
+			"CREATE TABLE tag(id integer primary key, text text NOT NULL, UNIQUE(text))",
+			"CREATE TABLE icon_to_tags (" +
+				"icon_id integer REFERENCES icon(id) ON DELETE CASCADE, " +
+				"tag_id  integer REFERENCES tag(id)  ON DELETE RESTRICT, " +
+				"PRIMARY KEY (icon_id, tag_id)" +
+				")",
+			// AUTOINCREMENT keeps ids from being reused, the ids being the cursors of the audit pages
+			`CREATE TABLE audit(
+				id              integer primary key autoincrement,
+				recorded_at     text NOT NULL,
+				actor           text NOT NULL,
+				action          text NOT NULL,
+				icon_name       text NOT NULL,
+				iconfile_format text,
+				iconfile_size   text,
+				tag             text,
+				before          text,
+				after           text,
+				request_id      text,
+				comment         text
+			)`,
+			"CREATE INDEX audit_icon_name_idx ON audit(icon_name, id)",
+			"CREATE INDEX audit_actor_idx ON audit(actor, id)",
+		},
+	},
+	{
+		version: "2026-10-19/1 - outbox",
+		sqls: []string{
+			`CREATE TABLE outbox(
+				id          integer primary key autoincrement,
+				recorded_at text NOT NULL,
+				actor       text NOT NULL,
+				changes     text NOT NULL,
+				attempts    integer NOT NULL DEFAULT 0,
+				last_error  text
+			)`,
+		},
+	},
+	{
+		version: "2026-10-19/2 - icon description",
+		sqls: []string{
+			"ALTER TABLE icon ADD description text",
+		},
+	},
+	{
+		version: "2026-10-19/3 - outbox hold",
+		sqls: []string{
+			"ALTER TABLE outbox ADD held_until text",
+		},
+	},
+}
+
+type dbSchema struct {
+	conn connection
+}
+
+// OpenSchema creates and upgrades the tables as necessary
+// Returns true if the tables already existed.
+func OpenSchema(dbConn connection) (bool, error) {
+	schema := dbSchema{
+		conn: dbConn,
+	}
+
+	schemaExists, schemaExistErr := schema.doesExist()
+	if schemaExistErr != nil {
+		return false, fmt.Errorf("failed to open database schema: %w", schemaExistErr)
+	}
+
+	upgradeErr := schema.executeUpgrade()
+	if upgradeErr != nil {
+		return false, fmt.Errorf("failed upgrade the schema: %w", upgradeErr)
+	}
+
+	return schemaExists, nil
+}
+
+func compareVersions(upgrStep1 upgradeStep, upgrStep2 upgradeStep) int {
+	return strings.Compare(upgrStep1.version, upgrStep2.version)
+}
+
+func makeSureMetaExists(tx *sql.Tx) error {
+	const sql = "CREATE TABLE IF NOT EXISTS meta (version TEXT primary key, upgrade_date TIMESTAMP)"
+	_, err := tx.Exec(sql)
+	if err != nil {
+		return fmt.Errorf("failed to make sure that meta table exist: %w", err)
+	}
+	return nil
+}
+
+func isUpgradeApplied(tx *sql.Tx, version string) (bool, error) {
+	var err error
+	err = makeSureMetaExists(tx)
+	if err != nil {
+		return false, fmt.Errorf("failed to determine whether schema upgrade %v has been applied or not: %w", version, err)
+	}
+	const sql = "SELECT count(*) as upgrade_count FROM meta WHERE version = ?"
+	var upgradeCount = 0
+	err = tx.QueryRow(sql, version).Scan(&upgradeCount)
+	if err != nil {
+		return false, fmt.Errorf("failed to determine whether schema upgrade %v has been applied or not: %w", version, err)
+	}
+	return upgradeCount > 0, nil
+}
+
+func createMetaRecord(tx *sql.Tx, version string) error {
+	_, err := tx.Exec("INSERT INTO meta(version, upgrade_date) VALUES(?, current_timestamp)", version)
+	if err != nil {
+		return fmt.Errorf("failed to make a record of the schema upgrade to %s: %w", version, err)
+	}
+	return nil
+}
+
+func applyUpgrade(tx *sql.Tx, upgrStep upgradeStep) error {
+	var err error
+	for _, uStatement := range upgrStep.sqls {
+		_, err = tx.Exec(uStatement)
+		if err != nil {
+			return fmt.Errorf("failed to execute schema upgrade step %s for version %s: %w", uStatement, upgrStep.version, err)
+		}
+	}
+	err = createMetaRecord(tx, upgrStep.version)
+	if err != nil {
+		return fmt.Errorf("failed to apply schema upgrade to %s: %w", upgrStep.version, err)
+	}
+	return nil
+}
+
+func (schema *dbSchema) executeUpgrade() error {
+	var err error
+
+	logger := logging.Get().With().Str(logging.UnitLogger, "sqlite-schema").Str(logging.MethodLogger, "executeUpgrade").Logger()
+
+	sort.Slice(upgradeSteps, func(i int, j int) bool { return compareVersions(upgradeSteps[i], upgradeSteps[j]) < 0 })
+
+	var tx *sql.Tx
+	tx, err = schema.conn.Writer.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to execute schema upgrade: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, upgrStep := range upgradeSteps {
+		var applied bool
+		applied, err = isUpgradeApplied(tx, upgrStep.version)
+		if err != nil {
+			return fmt.Errorf("failed to execute schema upgrade: %w", err)
+		}
+		if applied {
+			logger.Info().Str("version", upgrStep.version).Msg("already applied version found")
+		} else {
+			logger.Info().Str("version", upgrStep.version).Msg("Applying upgrade...")
+			err = applyUpgrade(tx, upgrStep)
+			if err != nil {
+				return fmt.Errorf("failed to apply upgrade step '%s': %w", upgrStep.version, err)
+			}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit schema upgrade: %w", err)
+	}
+	return nil
+}
+
+// doesExist tells whether the tables have been created in the database file, the meta table being created first
+func (schema *dbSchema) doesExist() (bool, error) {
+	logger := logging.Get().With().Str(logging.UnitLogger, "sqlite-schema").Str(logging.MethodLogger, "doesExist").Logger()
+
+	var tableCount int
+	err := schema.conn.Reader.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'meta'").Scan(&tableCount)
+	if err != nil {
+		return false, fmt.Errorf("failed to query whether the tables in %s exist or not: %w", schema.conn.file, err)
+	}
+
+	logger.Info().Str("file", schema.conn.file).Bool("exists", tableCount > 0).Msg("schema checked")
+	return tableCount > 0, nil
+}
@@ -0,0 +1,308 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/logging"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const outboxRepairComment = "pending blobstore change settled"
+
+// outboxWriteTimeout is the deadline given to the writes through the outbox which have none
+const outboxWriteTimeout = 15 * time.Minute
+
+// writeThroughOutbox changes the index along with the blobstore, the changes to the blobstore being made by the side-effect.
+// The changes are recorded in the outbox first, the entry being held until the deadline of the write: it is not settled while
+// the write may still be in progress. The entry is removed once the index change has gone through, or if it failed before
+// the side-effect ran; otherwise the blobstore may have been changed without the index (or the other way around) and the entry
+// is left for RetryPendingChanges to settle.
+func (combo *RepoCombo) writeThroughOutbox(
+	ctx context.Context,
+	actor string,
+	changes []domain.BlobstoreChange,
+	write func(sideEffect func(ctx context.Context) error) error,
+	sideEffect func(ctx context.Context) error,
+) error {
+	defer combo.forgetCachedIconfiles(changes)
+	defer combo.refreshCatalog(ctx, changedIconNames(changes))
+
+	if combo.Outbox == nil || len(changes) == 0 {
+		return write(sideEffect)
+	}
+
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, outboxWriteTimeout)
+		defer cancel()
+	}
+	heldUntil, _ := ctx.Deadline()
+
+	entryID, addErr := combo.Outbox.AddOutboxEntry(ctx, domain.OutboxEntry{RecordedAt: time.Now(), HeldUntil: heldUntil, Actor: actor, Changes: changes})
+	if addErr != nil {
+		return fmt.Errorf("failed to record pending blobstore changes: %w", addErr)
+	}
+
+	sideEffectRun := false
+	writeErr := write(func(ctx context.Context) error {
+		sideEffectRun = true
+		return sideEffect(ctx)
+	})
+	if writeErr != nil && sideEffectRun {
+		zerolog.Ctx(ctx).Warn().Err(writeErr).Str("outboxEntry", entryID).Msg("change failed after the blobstore was written to, leaving it to be settled")
+		return writeErr
+	}
+
+	if removeErr := combo.Outbox.RemoveOutboxEntry(ctx, entryID); removeErr != nil {
+		// Harmless: settling the entry later finds both stores in line
+		zerolog.Ctx(ctx).Error().Err(removeErr).Str("outboxEntry", entryID).Msg("failed to remove outbox entry")
+	}
+	return writeErr
+}
+
+// RetryPendingChanges settles the outbox entries recorded before the time given, leaving alone those held by writes which
+// may still be in progress by then. The blobstore is brought in line with the index for the icons the entries are about:
+// the index is taken to be right, the iconfiles it lists being written to the blobstore with the content kept in the entry.
+// An iconfile whose content is nowhere to be found is deleted from the index instead.
+// Settling an entry is idempotent: entries failing to be settled are retried on the next call.
+func (combo *RepoCombo) RetryPendingChanges(ctx context.Context, before time.Time) (domain.OutboxReport, error) {
+	report := domain.OutboxReport{}
+	if combo.Outbox == nil {
+		return report, nil
+	}
+	logger := zerolog.Ctx(ctx).With().Str("method", "RetryPendingChanges").Logger()
+
+	entries, getErr := combo.Outbox.GetOutboxEntries(ctx, before)
+	if getErr != nil {
+		return report, fmt.Errorf("failed to get pending blobstore changes: %w", getErr)
+	}
+	if len(entries) == 0 {
+		return report, nil
+	}
+
+	stored, _, listErr := combo.listStoredIconfiles(ctx)
+	if listErr != nil {
+		return report, fmt.Errorf("failed to list iconfiles to settle pending blobstore changes: %w", listErr)
+	}
+	storedIconfiles := map[string]bool{}
+	for _, ref := range stored {
+		storedIconfiles[ref.String()] = true
+	}
+
+	for _, entry := range entries {
+		entryLogger := logger.With().Str("outboxEntry", entry.ID).Time("recordedAt", entry.RecordedAt).Logger()
+		settleErr := combo.settleOutboxEntry(ctx, entry, storedIconfiles)
+		if settleErr != nil {
+			report.Failed++
+			entryLogger.Error().Err(settleErr).Int("attempts", entry.Attempts+1).Msg("failed to settle pending blobstore changes")
+			if recordErr := combo.Outbox.RecordOutboxAttempt(ctx, entry.ID, settleErr); recordErr != nil {
+				entryLogger.Error().Err(recordErr).Msg("failed to record attempt at settling pending blobstore changes")
+			}
+			continue
+		}
+		if removeErr := combo.Outbox.RemoveOutboxEntry(ctx, entry.ID); removeErr != nil {
+			return report, fmt.Errorf("failed to remove settled outbox entry %s: %w", entry.ID, removeErr)
+		}
+		report.Settled++
+		entryLogger.Info().Msg("pending blobstore changes settled")
+	}
+	return report, nil
+}
+
+// settleOutboxEntry brings the iconfiles and the metadata the entry is about in line with the index.
+// storedIconfiles is kept up to date with the changes made to the blobstore.
+func (combo *RepoCombo) settleOutboxEntry(ctx context.Context, entry domain.OutboxEntry, storedIconfiles map[string]bool) error {
+	modifiedBy := authr.UserInfo{UserId: authn.LocalDomain.CreateUserID(entry.Actor)}
+	defer combo.forgetCachedIconfiles(entry.Changes)
+	defer combo.refreshCatalog(ctx, changedIconNames(entry.Changes))
+
+	iconfiles := []domain.IconfileRef{}
+	contents := map[string][]byte{}
+	iconNames := []string{}
+	for _, change := range entry.Changes {
+		switch change.Kind {
+		case domain.BlobstoreChangeAddIconfile, domain.BlobstoreChangeDeleteIconfile:
+			ref := domain.IconfileRef{IconName: change.IconName, Iconfile: change.Iconfile.IconfileDescriptor}
+			iconfiles = append(iconfiles, ref)
+			if len(change.Iconfile.Content) > 0 {
+				contents[ref.String()] = change.Iconfile.Content
+			}
+		case domain.BlobstoreChangeDeleteIcon:
+			for _, iconfile := range change.Icon.Iconfiles {
+				iconfiles = append(iconfiles, domain.IconfileRef{IconName: change.IconName, Iconfile: iconfile})
+			}
+		}
+		if !slices.Contains(iconNames, change.IconName) {
+			iconNames = append(iconNames, change.IconName)
+		}
+	}
+
+	for _, ref := range iconfiles {
+		if settleErr := combo.settleIconfile(ctx, ref, contents[ref.String()], storedIconfiles, modifiedBy); settleErr != nil {
+			return fmt.Errorf("failed to settle %v: %w", ref, settleErr)
+		}
+	}
+	for _, iconName := range iconNames {
+		if settleErr := combo.settleIconMetadata(ctx, iconName, modifiedBy); settleErr != nil {
+			return fmt.Errorf("failed to settle metadata of icon \"%s\": %w", iconName, settleErr)
+		}
+	}
+	return nil
+}
+
+// describeIfExists returns nil if the icon is not in the index
+func (combo *RepoCombo) describeIfExists(ctx context.Context, iconName string) (*domain.IconDescriptor, error) {
+	iconDesc, describeErr := combo.Index.DescribeIcon(ctx, iconName)
+	if errors.Is(describeErr, domain.ErrIconNotFound) {
+		return nil, nil
+	}
+	if describeErr != nil {
+		return nil, describeErr
+	}
+	return &iconDesc, nil
+}
+
+func (combo *RepoCombo) settleIconfile(ctx context.Context, ref domain.IconfileRef, content []byte, storedIconfiles map[string]bool, modifiedBy authr.UserInfo) error {
+	iconDesc, describeErr := combo.describeIfExists(ctx, ref.IconName)
+	if describeErr != nil {
+		return describeErr
+	}
+	var indexed *domain.IconfileDescriptor
+	if iconDesc != nil {
+		if found, findErr := iconDesc.FindIconfile(ref.Iconfile); findErr == nil {
+			indexed = &found
+		}
+	}
+	// Iconfiles proposed in merge requests are not on the main branch yet
+	if indexed != nil && combo.mergeRequests() != nil && indexed.ReviewStatus == domain.ReviewStatusInReview {
+		return nil
+	}
+
+	stored := storedIconfiles[ref.String()]
+	switch {
+	case indexed != nil && !stored && len(content) > 0:
+		addErr := combo.Blobstore.AddIconfile(ctx, ref.IconName, domain.Iconfile{IconfileDescriptor: *indexed, Content: content}, modifiedBy.UserId.String())
+		if addErr != nil {
+			return addErr
+		}
+		storedIconfiles[ref.String()] = true
+	case indexed != nil && !stored:
+		return combo.deleteDanglingIconfile(ctx, ref, outboxRepairComment, modifiedBy)
+	case indexed == nil && stored:
+		deleteErr := combo.Blobstore.DeleteIconfile(ctx, ref.IconName, ref.Iconfile, modifiedBy.UserId)
+		if deleteErr != nil {
+			return deleteErr
+		}
+		delete(storedIconfiles, ref.String())
+	}
+	return nil
+}
+
+// settleIconMetadata has the metadata of the icon in the blobstore match the index, writing the metadata of
+// icons which have none. The metadata of an icon no longer in the index is deleted.
+func (combo *RepoCombo) settleIconMetadata(ctx context.Context, iconName string, modifiedBy authr.UserInfo) error {
+	iconDesc, describeErr := combo.describeIfExists(ctx, iconName)
+	if describeErr != nil {
+		return describeErr
+	}
+	metadata, getErr := combo.Blobstore.GetIconMetadata(ctx, iconName)
+	if getErr != nil && !errors.Is(getErr, domain.ErrIconMetadataNotFound) {
+		return getErr
+	}
+	metadataFound := getErr == nil
+
+	if iconDesc == nil {
+		if !metadataFound {
+			return nil
+		}
+		return combo.Blobstore.DeleteIcon(ctx, domain.IconDescriptor{IconAttributes: domain.IconAttributes{Name: iconName}}, modifiedBy.UserId)
+	}
+
+	if metadataFound && metadataInLine(*iconDesc, metadata) {
+		return nil
+	}
+	return combo.Blobstore.UpdateIconMetadata(ctx, iconName, iconDesc.Metadata(), modifiedBy.UserId.String())
+}
+
+// metadataInLine tells whether the metadata records the tags, the description and the review status of the iconfiles of the icon
+func metadataInLine(iconDesc domain.IconDescriptor, metadata domain.IconMetadata) bool {
+	indexedTags := slices.Clone(iconDesc.Tags)
+	slices.Sort(indexedTags)
+	storedTags := slices.Clone(metadata.Tags)
+	slices.Sort(storedTags)
+	if !slices.Equal(indexedTags, storedTags) || iconDesc.Description != metadata.Description {
+		return false
+	}
+	for _, iconfile := range iconDesc.Iconfiles {
+		if metadata.ReviewStatusOf(iconfile) != iconfile.ReviewStatus {
+			return false
+		}
+	}
+	return true
+}
+
+// OutboxWorker settles the changes left pending in the outbox in the background
+type OutboxWorker struct {
+	combo *RepoCombo
+	// delay is how long the entries are left alone once recorded, or once no longer held by the change they go along with;
+	// it is also the time between two passes
+	delay   time.Duration
+	logger  zerolog.Logger
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func NewOutboxWorker(combo *RepoCombo, delay time.Duration) *OutboxWorker {
+	return &OutboxWorker{
+		combo:  combo,
+		delay:  delay,
+		logger: logging.Get().With().Str(logging.UnitLogger, "outbox-worker").Logger(),
+	}
+}
+
+// Start starts settling the pending changes, the first pass being made right away
+func (worker *OutboxWorker) Start() {
+	worker.stop = make(chan struct{})
+	worker.stopped.Add(1)
+	go worker.run()
+}
+
+// Stop stops the worker, waiting for the pass in progress to complete
+func (worker *OutboxWorker) Stop() {
+	if worker.stop == nil {
+		return
+	}
+	close(worker.stop)
+	worker.stopped.Wait()
+	worker.stop = nil
+}
+
+func (worker *OutboxWorker) run() {
+	defer worker.stopped.Done()
+	ticker := time.NewTicker(worker.delay)
+	defer ticker.Stop()
+
+	for {
+		ctx := worker.logger.WithContext(context.Background())
+		report, retryErr := worker.combo.RetryPendingChanges(ctx, time.Now().Add(-worker.delay))
+		if retryErr != nil {
+			worker.logger.Error().Err(retryErr).Msg("failed to settle pending blobstore changes")
+		} else if report.Settled > 0 || report.Failed > 0 {
+			worker.logger.Info().Int("settled", report.Settled).Int("failed", report.Failed).Msg("pending blobstore changes processed")
+		}
+
+		select {
+		case <-worker.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
+
+	"github.com/rs/zerolog"
+)
+
+// Reindex rebuilds the index from the blobstore, which holds every iconfile. The index must be empty.
+// Tags, the description and the review status of the iconfiles are restored from the icon metadata in the blobstore.
+// Iconfiles the metadata doesn't record, as is the case with metadata written before review statuses were, are indexed as published.
+// No audit entries are recorded: the index is restored, not changed.
+func (combo *RepoCombo) Reindex(ctx context.Context, modifiedBy authr.UserInfo) (domain.ReindexReport, error) {
+	logger := zerolog.Ctx(ctx).With().Str("method", "Reindex").Logger()
+	if combo.Catalog != nil {
+		defer combo.Catalog.Invalidate()
+	}
+	report := domain.ReindexReport{UnrecognizedFiles: []string{}}
+
+	icons, describeErr := combo.Index.DescribeAllIcons(ctx)
+	if describeErr != nil {
+		return report, fmt.Errorf("failed to describe icons before reindexing: %w", describeErr)
+	}
+	if len(icons) > 0 {
+		return report, fmt.Errorf("failed to reindex: %d icons found: %w", len(icons), domain.ErrIndexNotEmpty)
+	}
+
+	refs, unrecognized, listErr := combo.listStoredIconfiles(ctx)
+	if listErr != nil {
+		return report, fmt.Errorf("failed to list iconfiles for reindexing: %w", listErr)
+	}
+	report.UnrecognizedFiles = unrecognized
+
+	// refs are sorted, so the iconfiles of an icon come together
+	iconNames := []string{}
+	iconfilesByIcon := map[string][]domain.IconfileDescriptor{}
+	for _, ref := range refs {
+		if _, ok := iconfilesByIcon[ref.IconName]; !ok {
+			iconNames = append(iconNames, ref.IconName)
+		}
+		iconfilesByIcon[ref.IconName] = append(iconfilesByIcon[ref.IconName], ref.Iconfile)
+	}
+
+	for _, iconName := range iconNames {
+		metadata, metadataErr := combo.Blobstore.GetIconMetadata(ctx, iconName)
+		if metadataErr != nil {
+			if !errors.Is(metadataErr, domain.ErrIconMetadataNotFound) {
+				return report, fmt.Errorf("failed to read metadata of icon \"%s\": %w", iconName, metadataErr)
+			}
+			// The icon has never been tagged
+			metadata = domain.IconMetadata{ModifiedBy: modifiedBy.UserId.String()}
+		}
+
+		iconfiles := iconfilesByIcon[iconName]
+		for i := range iconfiles {
+			iconfiles[i].ReviewStatus = metadata.ReviewStatusOf(iconfiles[i])
+		}
+		createErr := combo.Index.CreateIcon(ctx, iconName, iconfiles[0], metadata.ModifiedBy, noSideEffect)
+		if createErr != nil {
+			return report, fmt.Errorf("failed to reindex icon \"%s\": %w", iconName, createErr)
+		}
+		for _, iconfile := range iconfiles[1:] {
+			addErr := combo.Index.AddIconfileToIcon(ctx, iconName, iconfile, metadata.ModifiedBy, noSideEffect)
+			if addErr != nil {
+				return report, fmt.Errorf("failed to reindex iconfile %v of \"%s\": %w", iconfile, iconName, addErr)
+			}
+		}
+		if len(metadata.Description) > 0 {
+			descriptionErr := combo.Index.SetIconDescription(ctx, iconName, metadata.Description, metadata.ModifiedBy, noSideEffect)
+			if descriptionErr != nil {
+				return report, fmt.Errorf("failed to restore the description of \"%s\": %w", iconName, descriptionErr)
+			}
+		}
+		for _, tag := range metadata.Tags {
+			tagErr := combo.Index.AddTag(ctx, iconName, tag, metadata.ModifiedBy, noSideEffect)
+			if tagErr != nil {
+				return report, fmt.Errorf("failed to restore tag \"%s\" of \"%s\": %w", tag, iconName, tagErr)
+			}
+		}
+
+		report.Icons++
+		report.Iconfiles += len(iconfiles)
+		report.Tags += len(metadata.Tags)
+	}
+
+	logger.Info().Int("icons", report.Icons).Int("iconfiles", report.Iconfiles).Int("tags", report.Tags).Msg("index rebuilt")
+	return report, nil
+}
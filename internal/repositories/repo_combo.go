@@ -2,10 +2,19 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"iconrepo/internal/app/domain"
 	"iconrepo/internal/app/security/authn"
 	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/logging"
+	"iconrepo/internal/repositories/blobstore/cache"
+	"iconrepo/internal/repositories/indexing"
+	"iconrepo/internal/repositories/indexing/catalog"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog"
 )
 
 type IndexRepository interface {
@@ -13,12 +22,17 @@ type IndexRepository interface {
 	DescribeAllIcons(ctx context.Context) ([]domain.IconDescriptor, error)
 	DescribeIcon(ctx context.Context, iconName string) (domain.IconDescriptor, error)
 	GetExistingTags(tx context.Context) ([]string, error)
-	CreateIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func() error) error
-	AddIconfileToIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func() error) error
-	AddTag(ctx context.Context, iconName string, tag string, modifiedBy string) error
-	RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy string) error
-	DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func() error) error
-	DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func() error) error
+	GetIconsWithTag(ctx context.Context, tag string) ([]domain.IconDescriptor, error)
+	CreateIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error
+	AddIconfileToIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error
+	AddTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error
+	RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error
+	DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func(ctx context.Context) error) error
+	DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error
+	SetIconfileReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, modifiedBy string, createSideEffect func(ctx context.Context) error) error
+	SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy string, createSideEffect func(ctx context.Context) error) error
+	// ApplyChangeset applies all the operations, in order, and the side-effect or none of them
+	ApplyChangeset(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy string, createSideEffect func(ctx context.Context) error) error
 }
 
 type BlobstoreRepository interface {
@@ -28,15 +42,175 @@ type BlobstoreRepository interface {
 	GetIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor) ([]byte, error)
 	DeleteIcon(ctx context.Context, iconDesc domain.IconDescriptor, modifiedBy authn.UserID) error
 	DeleteIconfile(ctx context.Context, iconName string, iconfileDesc domain.IconfileDescriptor, modifiedBy authn.UserID) error
+	// GetIconfiles lists the paths of the iconfiles in the blobstore
+	GetIconfiles(ctx context.Context) ([]string, error)
+	// UpdateIconMetadata stores the attributes of the icon next to its iconfiles, so that the icon can be restored from the blobstore
+	UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error
+	// GetIconMetadata returns domain.ErrIconMetadataNotFound if no metadata has been stored for the icon
+	GetIconMetadata(ctx context.Context, iconName string) (domain.IconMetadata, error)
+}
+
+// MergeRequestBlobstore is implemented by blobstores able to have iconfiles reviewed in merge requests
+// before they are added to the main branch
+type MergeRequestBlobstore interface {
+	ReviewsInMergeRequests() bool
+	ParseChangeBranch(branch string) (string, domain.IconfileDescriptor, error)
+}
+
+// MirroredBlobstore is implemented by blobstores pushing their content to remote mirrors
+type MirroredBlobstore interface {
+	MirrorStatus() []domain.MirrorStatus
+}
+
+// ScheduledBlobstore is implemented by blobstores scheduling their writes themselves
+type ScheduledBlobstore interface {
+	WriteQueueStats() domain.WriteQueueStats
+}
+
+// ChangesetBlobstore is implemented by blobstores able to make all the changes of a changeset at once;
+// the changes are made one by one in the other blobstores
+type ChangesetBlobstore interface {
+	ApplyChangeset(ctx context.Context, changes []domain.BlobstoreChange, modifiedBy string) error
+}
+
+// AuditRepository stores the audit log of changes made to the repository
+type AuditRepository interface {
+	RecordAuditEntry(ctx context.Context, entry domain.AuditEntry) error
+	QueryAuditEntries(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error)
+}
+
+// OutboxRepository keeps the changes to the blobstore which go along with the changes to the index
+// until they have gone through; it is kept in the same store as the index
+type OutboxRepository interface {
+	AddOutboxEntry(ctx context.Context, entry domain.OutboxEntry) (string, error)
+	RemoveOutboxEntry(ctx context.Context, id string) error
+	// GetOutboxEntries returns the entries recorded before the time given and no longer held by then, the oldest first
+	GetOutboxEntries(ctx context.Context, before time.Time) ([]domain.OutboxEntry, error)
+	// RecordOutboxAttempt records a failed attempt at settling the entry
+	RecordOutboxAttempt(ctx context.Context, id string, attemptErr error) error
 }
 
 type RepoCombo struct {
 	Index     IndexRepository
 	Blobstore BlobstoreRepository
+	// Audit is optional; no audit log is kept if it is nil
+	Audit AuditRepository
+	// Outbox is optional; without it, a change failing halfway may leave the index and the blobstore apart
+	Outbox OutboxRepository
+	// IconfileCache is optional; without it, each iconfile is read from the blobstore
+	IconfileCache *cache.IconfileCache
+	// Catalog is optional; without it, the index is read each time the icons are listed
+	Catalog *catalog.Snapshot
+}
+
+// describeForAudit returns the current state of the icon or nil if the icon doesn't exist (or we are not auditing)
+func (combo *RepoCombo) describeForAudit(ctx context.Context, iconName string) *domain.IconDescriptor {
+	if combo.Audit == nil {
+		return nil
+	}
+	iconDesc, describeErr := combo.Index.DescribeIcon(ctx, iconName)
+	if describeErr != nil {
+		if !errors.Is(describeErr, domain.ErrIconNotFound) {
+			zerolog.Ctx(ctx).Error().Err(describeErr).Str("icon_name", iconName).Msg("failed to describe icon for the audit log")
+		}
+		return nil
+	}
+	return &iconDesc
+}
+
+// audited has the audit entries recorded by the side-effect of a change to the index, once the rest of the side-effect
+// has succeeded. The entries are thus recorded in the transaction of the index, where the index has one, and the change fails
+// if they can't be recorded. The state of the icons after the change is the one handed over to the side-effect.
+func (combo *RepoCombo) audited(sideEffect func(ctx context.Context) error, entries ...domain.AuditEntry) func(ctx context.Context) error {
+	if combo.Audit == nil {
+		return sideEffect
+	}
+	return func(ctx context.Context) error {
+		if sideEffectErr := sideEffect(ctx); sideEffectErr != nil {
+			return sideEffectErr
+		}
+		for _, entry := range entries {
+			if recordErr := combo.recordAuditEntry(ctx, entry); recordErr != nil {
+				return recordErr
+			}
+		}
+		return nil
+	}
+}
+
+func (combo *RepoCombo) recordAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	entry.Timestamp = time.Now()
+	entry.RequestID = logging.GetRequestID(ctx)
+	entry.After = entry.Before
+	if icon, found := indexing.CommittedIcon(ctx, entry.IconName); found {
+		entry.After = nil
+		if len(icon.Iconfiles) > 0 {
+			entry.After = &icon
+		}
+	}
+	if recordErr := combo.Audit.RecordAuditEntry(ctx, entry); recordErr != nil {
+		return fmt.Errorf("failed to record audit entry: %w", recordErr)
+	}
+	return nil
+}
+
+// mergeRequests returns the blobstore if it reviews iconfiles in merge requests, nil otherwise
+func (combo *RepoCombo) mergeRequests() MergeRequestBlobstore {
+	mrBlobstore, ok := combo.Blobstore.(MergeRequestBlobstore)
+	if !ok || !mrBlobstore.ReviewsInMergeRequests() {
+		return nil
+	}
+	return mrBlobstore
+}
+
+// indexedReviewStatus overrides the review status of new iconfiles which are proposed in a merge request:
+// they are in review until the merge request is merged
+func (combo *RepoCombo) indexedReviewStatus(iconfile domain.Iconfile) domain.Iconfile {
+	if combo.mergeRequests() != nil {
+		iconfile.ReviewStatus = domain.ReviewStatusInReview
+	}
+	return iconfile
 }
 
 func (combo *RepoCombo) DescribeAllIcons(ctx context.Context) ([]domain.IconDescriptor, error) {
-	return combo.Index.DescribeAllIcons(ctx)
+	if combo.Catalog == nil {
+		return combo.Index.DescribeAllIcons(ctx)
+	}
+	iconCatalog, err := combo.Catalog.GetCatalog(ctx, "", combo.Index.DescribeAllIcons)
+	return iconCatalog.Icons, err
+}
+
+// GetIconCatalog lists the icons changed since the revision given, all of them if the revision is unknown.
+// Without a catalog snapshot, all the icons are listed, with no revision.
+func (combo *RepoCombo) GetIconCatalog(ctx context.Context, since string) (domain.IconCatalog, error) {
+	if combo.Catalog == nil {
+		icons, err := combo.Index.DescribeAllIcons(ctx)
+		if err != nil {
+			return domain.IconCatalog{}, err
+		}
+		return domain.IconCatalog{Full: true, Icons: icons, Deleted: []string{}}, nil
+	}
+	return combo.Catalog.GetCatalog(ctx, since, combo.Index.DescribeAllIcons)
+}
+
+// changedIconNames lists the icons the changes are about, each once
+func changedIconNames(changes []domain.BlobstoreChange) []string {
+	iconNames := []string{}
+	for _, change := range changes {
+		if !slices.Contains(iconNames, change.IconName) {
+			iconNames = append(iconNames, change.IconName)
+		}
+	}
+	return iconNames
+}
+
+// refreshCatalog has the icons changed in the index described anew in the catalog snapshot. As with forgetCachedIconfiles,
+// it is to be called once the changes have been made or have failed, since a failed change may have reached the index.
+func (combo *RepoCombo) refreshCatalog(ctx context.Context, iconNames []string) {
+	if combo.Catalog == nil {
+		return
+	}
+	combo.Catalog.RefreshIcons(ctx, iconNames, combo.Index.DescribeIcon)
 }
 
 func (combo *RepoCombo) DescribeIcon(ctx context.Context, iconName string) (domain.IconDescriptor, error) {
@@ -44,9 +218,19 @@ func (combo *RepoCombo) DescribeIcon(ctx context.Context, iconName string) (doma
 }
 
 func (combo *RepoCombo) CreateIcon(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy authr.UserInfo) error {
-	return combo.Index.CreateIcon(ctx, iconName, iconfile.IconfileDescriptor, modifiedBy.UserId.String(), func() error {
-		return combo.Blobstore.AddIconfile(ctx, iconName, iconfile, modifiedBy.UserId.String())
-	})
+	iconfile = combo.indexedReviewStatus(iconfile)
+	changes := []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeAddIconfile, IconName: iconName, Iconfile: iconfile}}
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   domain.AuditActionCreateIcon,
+		IconName: iconName,
+		Iconfile: &iconfile.IconfileDescriptor,
+	}
+	return combo.writeThroughOutbox(ctx, modifiedBy.UserId.String(), changes, func(sideEffect func(ctx context.Context) error) error {
+		return combo.Index.CreateIcon(ctx, iconName, iconfile.IconfileDescriptor, modifiedBy.UserId.String(), sideEffect)
+	}, combo.audited(func(ctx context.Context) error {
+		return combo.applyBlobstoreChanges(ctx, withCommittedMetadata(ctx, changes), modifiedBy)
+	}, entry))
 }
 
 func (combo *RepoCombo) DeleteIcon(ctx context.Context, iconName string, modifiedBy authr.UserInfo) error {
@@ -55,35 +239,244 @@ func (combo *RepoCombo) DeleteIcon(ctx context.Context, iconName string, modifie
 		return fmt.Errorf("failed to have to-be-deleted icon \"%s\" described: %w", iconName, describeErr)
 	}
 
-	return combo.Index.DeleteIcon(ctx, iconName, modifiedBy.UserId.String(), func() error {
+	changes := []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeDeleteIcon, IconName: iconName, Icon: iconDesc}}
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   domain.AuditActionDeleteIcon,
+		IconName: iconName,
+		Before:   &iconDesc,
+	}
+	return combo.writeThroughOutbox(ctx, modifiedBy.UserId.String(), changes, func(sideEffect func(ctx context.Context) error) error {
+		return combo.Index.DeleteIcon(ctx, iconName, modifiedBy.UserId.String(), sideEffect)
+	}, combo.audited(func(ctx context.Context) error {
 		return combo.Blobstore.DeleteIcon(ctx, iconDesc, modifiedBy.UserId)
-	})
+	}, entry))
 }
 
 func (combo *RepoCombo) AddIconfile(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy authr.UserInfo) error {
-	return combo.Index.AddIconfileToIcon(ctx, iconName, iconfile.IconfileDescriptor, modifiedBy.UserId.String(), func() error {
-		return combo.Blobstore.AddIconfile(ctx, iconName, iconfile, modifiedBy.UserId.String())
-	})
+	iconfile = combo.indexedReviewStatus(iconfile)
+	before := combo.describeForAudit(ctx, iconName)
+	changes := []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeAddIconfile, IconName: iconName, Iconfile: iconfile}}
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   domain.AuditActionAddIconfile,
+		IconName: iconName,
+		Iconfile: &iconfile.IconfileDescriptor,
+		Before:   before,
+	}
+	return combo.writeThroughOutbox(ctx, modifiedBy.UserId.String(), changes, func(sideEffect func(ctx context.Context) error) error {
+		return combo.Index.AddIconfileToIcon(ctx, iconName, iconfile.IconfileDescriptor, modifiedBy.UserId.String(), sideEffect)
+	}, combo.audited(func(ctx context.Context) error {
+		return combo.applyBlobstoreChanges(ctx, withCommittedMetadata(ctx, changes), modifiedBy)
+	}, entry))
 }
 
 func (combo *RepoCombo) GetIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor) ([]byte, error) {
-	return combo.Blobstore.GetIconfile(ctx, iconName, iconfile)
+	if combo.IconfileCache == nil {
+		return combo.Blobstore.GetIconfile(ctx, iconName, iconfile)
+	}
+	return combo.IconfileCache.GetIconfile(iconName, iconfile, func() ([]byte, error) {
+		return combo.Blobstore.GetIconfile(ctx, iconName, iconfile)
+	})
+}
+
+// forgetCachedIconfiles drops the iconfiles of the icons changed from the cache. It is to be called once the changes
+// have been made (or have failed), so that content read while they were being made isn't kept.
+func (combo *RepoCombo) forgetCachedIconfiles(changes []domain.BlobstoreChange) {
+	if combo.IconfileCache == nil {
+		return
+	}
+	for _, iconName := range changedIconNames(changes) {
+		combo.IconfileCache.InvalidateIcon(iconName)
+	}
 }
 
 func (combo *RepoCombo) DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy authr.UserInfo) error {
-	return combo.Index.DeleteIconfile(ctx, iconName, iconfile, modifiedBy.UserId.String(), func() error {
-		return combo.Blobstore.DeleteIconfile(ctx, iconName, iconfile, modifiedBy.UserId)
-	})
+	before := combo.describeForAudit(ctx, iconName)
+	// The blobstore needs the review status of the iconfile to know where the iconfile is to be deleted from
+	indexed := iconfile
+	if combo.mergeRequests() != nil {
+		iconDesc, describeErr := combo.Index.DescribeIcon(ctx, iconName)
+		if describeErr != nil {
+			return fmt.Errorf("failed to have icon \"%s\" of to-be-deleted iconfile described: %w", iconName, describeErr)
+		}
+		var findErr error
+		indexed, findErr = iconDesc.FindIconfile(iconfile)
+		if findErr != nil {
+			return fmt.Errorf("failed to find to-be-deleted iconfile %v of \"%s\": %w", iconfile, iconName, findErr)
+		}
+	}
+	changes := []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeDeleteIconfile, IconName: iconName, Iconfile: domain.Iconfile{IconfileDescriptor: indexed}}}
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   domain.AuditActionDeleteIconfile,
+		IconName: iconName,
+		Iconfile: &iconfile,
+		Before:   before,
+	}
+	return combo.writeThroughOutbox(ctx, modifiedBy.UserId.String(), changes, func(sideEffect func(ctx context.Context) error) error {
+		return combo.Index.DeleteIconfile(ctx, iconName, iconfile, modifiedBy.UserId.String(), sideEffect)
+	}, combo.audited(func(ctx context.Context) error {
+		return combo.applyBlobstoreChanges(ctx, withCommittedMetadata(ctx, changes), modifiedBy)
+	}, entry))
 }
 
 func (combo *RepoCombo) GetTags(ctx context.Context) ([]string, error) {
 	return combo.Index.GetExistingTags(ctx)
 }
 
+func (combo *RepoCombo) GetIconsWithTag(ctx context.Context, tag string) ([]domain.IconDescriptor, error) {
+	return combo.Index.GetIconsWithTag(ctx, tag)
+}
+
+// AddTag has the icon metadata in the blobstore updated along with the index
 func (combo *RepoCombo) AddTag(ctx context.Context, iconName string, tag string, modifiedBy authr.UserInfo) error {
-	return combo.Index.AddTag(ctx, iconName, tag, modifiedBy.UserId.String())
+	before := combo.describeForAudit(ctx, iconName)
+	changes := []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeUpdateMetadata, IconName: iconName}}
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   domain.AuditActionAddTag,
+		IconName: iconName,
+		Tag:      tag,
+		Before:   before,
+	}
+	return combo.writeThroughOutbox(ctx, modifiedBy.UserId.String(), changes, func(sideEffect func(ctx context.Context) error) error {
+		return combo.Index.AddTag(ctx, iconName, tag, modifiedBy.UserId.String(), sideEffect)
+	}, combo.audited(func(ctx context.Context) error {
+		return combo.applyBlobstoreChanges(ctx, withCommittedMetadata(ctx, changes), modifiedBy)
+	}, entry))
 }
 
+// RemoveTag has the icon metadata in the blobstore updated along with the index
 func (combo *RepoCombo) RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy authr.UserInfo) error {
-	return combo.Index.RemoveTag(ctx, iconName, tag, modifiedBy.UserId.String())
+	before := combo.describeForAudit(ctx, iconName)
+	changes := []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeUpdateMetadata, IconName: iconName}}
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   domain.AuditActionRemoveTag,
+		IconName: iconName,
+		Tag:      tag,
+		Before:   before,
+	}
+	return combo.writeThroughOutbox(ctx, modifiedBy.UserId.String(), changes, func(sideEffect func(ctx context.Context) error) error {
+		return combo.Index.RemoveTag(ctx, iconName, tag, modifiedBy.UserId.String(), sideEffect)
+	}, combo.audited(func(ctx context.Context) error {
+		return combo.applyBlobstoreChanges(ctx, withCommittedMetadata(ctx, changes), modifiedBy)
+	}, entry))
+}
+
+// SetIconDescription has the icon metadata in the blobstore updated along with the index
+func (combo *RepoCombo) SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy authr.UserInfo) error {
+	before := combo.describeForAudit(ctx, iconName)
+	changes := []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeUpdateMetadata, IconName: iconName}}
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   domain.AuditActionSetDescription,
+		IconName: iconName,
+		Before:   before,
+	}
+	return combo.writeThroughOutbox(ctx, modifiedBy.UserId.String(), changes, func(sideEffect func(ctx context.Context) error) error {
+		return combo.Index.SetIconDescription(ctx, iconName, description, modifiedBy.UserId.String(), sideEffect)
+	}, combo.audited(func(ctx context.Context) error {
+		return combo.applyBlobstoreChanges(ctx, withCommittedMetadata(ctx, changes), modifiedBy)
+	}, entry))
+}
+
+func reviewAuditAction(status domain.ReviewStatus) domain.AuditAction {
+	switch status {
+	case domain.ReviewStatusPublished:
+		return domain.AuditActionPublish
+	case domain.ReviewStatusRejected:
+		return domain.AuditActionReject
+	default:
+		return domain.AuditActionSubmitReview
+	}
+}
+
+// SetIconfileReviewStatus changes the review status in the index and in the icon metadata only: the content in the blobstore
+// is the same in every stage. With merge requests, the review status follows the merge request instead.
+func (combo *RepoCombo) SetIconfileReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, comment string, modifiedBy authr.UserInfo) error {
+	if combo.mergeRequests() != nil {
+		return fmt.Errorf("review status of %v of \"%s\" is managed in merge requests: %w", iconfile, iconName, domain.ErrInvalidReviewChange)
+	}
+	return combo.setIconfileReviewStatus(ctx, iconName, iconfile, status, comment, modifiedBy)
+}
+
+func (combo *RepoCombo) setIconfileReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, comment string, modifiedBy authr.UserInfo) error {
+	before := combo.describeForAudit(ctx, iconName)
+	changes := []domain.BlobstoreChange{{Kind: domain.BlobstoreChangeUpdateMetadata, IconName: iconName}}
+	entry := domain.AuditEntry{
+		Actor:    modifiedBy.UserId.String(),
+		Action:   reviewAuditAction(status),
+		IconName: iconName,
+		Iconfile: &iconfile,
+		Comment:  comment,
+		Before:   before,
+	}
+	return combo.writeThroughOutbox(ctx, modifiedBy.UserId.String(), changes, func(sideEffect func(ctx context.Context) error) error {
+		return combo.Index.SetIconfileReviewStatus(ctx, iconName, iconfile, status, modifiedBy.UserId.String(), sideEffect)
+	}, combo.audited(func(ctx context.Context) error {
+		return combo.applyBlobstoreChanges(ctx, withCommittedMetadata(ctx, changes), modifiedBy)
+	}, entry))
+}
+
+// CompleteMergeRequest finalizes the index once the merge request proposing an iconfile is done with:
+// the iconfile is published if the merge request was merged, it is discarded otherwise.
+func (combo *RepoCombo) CompleteMergeRequest(ctx context.Context, sourceBranch string, merged bool, modifiedBy authr.UserInfo) error {
+	mrBlobstore := combo.mergeRequests()
+	if mrBlobstore == nil {
+		return fmt.Errorf("failed to complete merge request of %s: %w", sourceBranch, domain.ErrNoMergeRequests)
+	}
+	iconName, iconfile, parseErr := mrBlobstore.ParseChangeBranch(sourceBranch)
+	if parseErr != nil {
+		return fmt.Errorf("failed to complete merge request: %w", parseErr)
+	}
+	iconDesc, describeErr := combo.Index.DescribeIcon(ctx, iconName)
+	if describeErr != nil {
+		return fmt.Errorf("failed to have icon \"%s\" of merge request %s described: %w", iconName, sourceBranch, describeErr)
+	}
+	indexed, findErr := iconDesc.FindIconfile(iconfile)
+	if findErr != nil {
+		return fmt.Errorf("failed to find iconfile %v of \"%s\" proposed in %s: %w", iconfile, iconName, sourceBranch, findErr)
+	}
+	if indexed.ReviewStatus != domain.ReviewStatusInReview {
+		return fmt.Errorf("iconfile %v of \"%s\" proposed in %s is %s: %w", iconfile, iconName, sourceBranch, indexed.ReviewStatus, domain.ErrInvalidReviewChange)
+	}
+	if merged {
+		return combo.setIconfileReviewStatus(ctx, iconName, iconfile, domain.ReviewStatusPublished, "merge request merged", modifiedBy)
+	}
+	return combo.DeleteIconfile(ctx, iconName, iconfile, modifiedBy)
+}
+
+func (combo *RepoCombo) GetAuditEntries(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	if combo.Audit == nil {
+		return domain.AuditPage{Entries: []domain.AuditEntry{}}, nil
+	}
+	return combo.Audit.QueryAuditEntries(ctx, query)
+}
+
+// GetMirrorStatus reports on the pushes of the blobstore to its remote mirrors; the list is empty if there are none
+func (combo *RepoCombo) GetMirrorStatus(ctx context.Context) ([]domain.MirrorStatus, error) {
+	mirrored, ok := combo.Blobstore.(MirroredBlobstore)
+	if !ok {
+		return []domain.MirrorStatus{}, nil
+	}
+	return mirrored.MirrorStatus(), nil
+}
+
+// GetIconfileCacheStats reports on the cache of iconfiles; the stats are empty if iconfiles are not cached
+func (combo *RepoCombo) GetIconfileCacheStats(ctx context.Context) (domain.IconfileCacheStats, error) {
+	if combo.IconfileCache == nil {
+		return domain.IconfileCacheStats{}, nil
+	}
+	return combo.IconfileCache.Stats(), nil
+}
+
+// GetWriteQueueStats reports on the writes to the blobstore; the stats are empty if the blobstore doesn't schedule its writes
+func (combo *RepoCombo) GetWriteQueueStats(ctx context.Context) (domain.WriteQueueStats, error) {
+	scheduled, ok := combo.Blobstore.(ScheduledBlobstore)
+	if !ok {
+		return domain.WriteQueueStats{Blobstore: combo.Blobstore.String()}, nil
+	}
+	return scheduled.WriteQueueStats(), nil
 }
@@ -99,6 +99,29 @@ func (s *readConfigurationTestSuite) TestGetDefaultConfiguration() {
 	s.Equal(5432, opts.DBPort)
 	s.Equal(false, opts.EnableBackdoors)
 	s.Equal(config.DefaultIconDataLocationGit, opts.LocalGitRepo)
+	s.Equal(config.BlobstoreTypeGit, opts.BlobstoreType)
+	s.Equal(config.DefaultIconDataLocationFilesystem, opts.FilesystemBlobstoreRoot)
+	s.Equal("iconrepo", opts.S3Bucket)
+	s.Equal("", opts.Storage)
+	s.Equal("", opts.SQLiteFile)
+	s.Equal(false, opts.DynamodbCreateTables)
+	s.Equal("PAY_PER_REQUEST", opts.DynamodbBillingMode)
+	s.Equal("", opts.GitMirrorRemotes)
+	s.Equal(0, opts.GitMirrorInterval)
+	s.Equal(0, opts.BlobstoreWriteTimeout)
+	s.Equal(300, opts.OutboxRetryDelay)
+	s.Equal(64, opts.IconfileCacheSize)
+	s.Equal("", opts.IconfileCacheDir)
+	s.Equal(512, opts.IconfileCacheDiskSize)
+	s.Equal(300, opts.IconCatalogMaxAge)
+	s.Equal(10, opts.GitlabRequestTimeout)
+	s.Equal(3, opts.GitlabMaxRetries)
+	s.Equal(500, opts.GitlabRetryDelayMs)
+	s.Equal(30, opts.GitlabMaxRetryDelay)
+	s.Equal(5, opts.GitlabBreakerThreshold)
+	s.Equal(30, opts.GitlabBreakerCooldown)
+	s.Equal("iconrepo", opts.GiteaRepository)
+	s.Equal("main", opts.GiteaMainBranch)
 }
 
 func (s *readConfigurationTestSuite) TestFailOnMissingConfigFile() {
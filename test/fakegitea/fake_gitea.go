@@ -0,0 +1,346 @@
+// Package fakegitea is an in-process stand-in for the parts of the Gitea REST API used by the Gitea blobstore,
+// so that the blobstore can be tested without a Gitea server.
+package fakegitea
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Owner is the user of the token accepted by the fake, owning the repositories of the tests
+const Owner = "testing-with-fake-gitea"
+
+// Token is accepted by the fake, as is any other non-empty token
+const Token = "fake-token"
+
+const apiPrefix = "/api/v1"
+
+// FakeGitea serves the repositories of a single user from memory
+type FakeGitea struct {
+	server       *httptest.Server
+	owner        string
+	mux          sync.Mutex
+	repositories map[string]*fakeRepository
+	requestCount int
+}
+
+func New(owner string) *FakeGitea {
+	fake := &FakeGitea{
+		owner:        owner,
+		repositories: map[string]*fakeRepository{},
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	return fake
+}
+
+var shared *FakeGitea
+var sharedOnce sync.Once
+
+// Shared returns a fake shared by the tests of the process, serving the repositories of Owner.
+// The tests keep apart by using repositories of their own.
+func Shared() *FakeGitea {
+	sharedOnce.Do(func() {
+		shared = New(Owner)
+	})
+	return shared
+}
+
+// URL is the base URL of the fake API
+func (fake *FakeGitea) URL() string {
+	return fake.server.URL + apiPrefix
+}
+
+func (fake *FakeGitea) Close() {
+	fake.server.Close()
+}
+
+// File returns the content of the file on the branch of the repository, nil if there is no such file
+func (fake *FakeGitea) File(repoName string, branch string, filePath string) []byte {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	repo, exists := fake.repositories[repoName]
+	if !exists {
+		return nil
+	}
+	return repo.files(branch)[filePath]
+}
+
+// CommitCount returns the number of commits on the branch of the repository
+func (fake *FakeGitea) CommitCount(repoName string, branch string) int {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	repo, exists := fake.repositories[repoName]
+	if !exists {
+		return 0
+	}
+	return len(repo.history(branch))
+}
+
+// RequestCount is the number of requests received so far
+func (fake *FakeGitea) RequestCount() int {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	return fake.requestCount
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeMessage(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]string{"message": message})
+}
+
+func (fake *FakeGitea) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "token ") {
+		writeMessage(w, http.StatusUnauthorized, "token is required")
+		return
+	}
+
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	fake.requestCount++
+
+	if !strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		writeMessage(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, apiPrefix+"/"), "/")
+
+	switch {
+	case len(segments) == 1 && segments[0] == "user" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"id": 1, "login": fake.owner})
+		return
+	case len(segments) == 2 && segments[0] == "user" && segments[1] == "repos" && r.Method == http.MethodPost:
+		fake.createRepository(w, r)
+		return
+	case len(segments) == 3 && segments[0] == "orgs" && segments[2] == "repos":
+		writeMessage(w, http.StatusNotFound, "GetOrgByName")
+		return
+	case len(segments) < 3 || segments[0] != "repos" || segments[1] != fake.owner:
+		writeMessage(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	repo, exists := fake.repositories[segments[2]]
+	if !exists {
+		writeMessage(w, http.StatusNotFound, "GetRepositoryByName")
+		return
+	}
+	rest := segments[3:]
+
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodDelete:
+		delete(fake.repositories, repo.name)
+		w.WriteHeader(http.StatusNoContent)
+	case len(rest) == 1 && rest[0] == "contents" && r.Method == http.MethodPost:
+		fake.changeFiles(w, r, repo)
+	case len(rest) > 1 && rest[0] == "contents" && r.Method == http.MethodGet:
+		fake.contents(w, r, repo, strings.Join(rest[1:], "/"))
+	case len(rest) == 1 && rest[0] == "commits" && r.Method == http.MethodGet:
+		fake.listCommits(w, r, repo)
+	case len(rest) == 3 && rest[0] == "git" && rest[1] == "commits" && r.Method == http.MethodGet:
+		commit, exists := repo.commits[rest[2]]
+		if !exists {
+			writeMessage(w, http.StatusNotFound, "commit does not exist")
+			return
+		}
+		writeJSON(w, http.StatusOK, commit.toJSON())
+	case len(rest) == 3 && rest[0] == "git" && rest[1] == "trees" && r.Method == http.MethodGet:
+		fake.tree(w, r, repo, rest[2])
+	default:
+		writeMessage(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (fake *FakeGitea) createRepository(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Name          string `json:"name"`
+		DefaultBranch string `json:"default_branch"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeMessage(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if _, exists := fake.repositories[request.Name]; exists {
+		writeMessage(w, http.StatusConflict, "The repository with the same name already exists.")
+		return
+	}
+	if len(request.DefaultBranch) == 0 {
+		request.DefaultBranch = "main"
+	}
+	fake.repositories[request.Name] = newFakeRepository(request.Name, request.DefaultBranch)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"name":           request.Name,
+		"full_name":      fake.owner + "/" + request.Name,
+		"default_branch": request.DefaultBranch,
+		"empty":          true,
+	})
+}
+
+type changeFilesRequest struct {
+	Branch    string   `json:"branch"`
+	Message   string   `json:"message"`
+	Author    identity `json:"author"`
+	Committer identity `json:"committer"`
+	Files     []struct {
+		Operation string `json:"operation"`
+		Path      string `json:"path"`
+		Content   string `json:"content"`
+		SHA       string `json:"sha"`
+	} `json:"files"`
+}
+
+func (fake *FakeGitea) changeFiles(w http.ResponseWriter, r *http.Request, repo *fakeRepository) {
+	request := changeFilesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeMessage(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if len(request.Branch) == 0 {
+		request.Branch = repo.defaultBranch
+	}
+	if _, branchExists := repo.branches[request.Branch]; !branchExists && len(repo.branches) > 0 {
+		writeMessage(w, http.StatusNotFound, "branch does not exist ["+request.Branch+"]")
+		return
+	}
+
+	files := map[string][]byte{}
+	for filePath, content := range repo.files(request.Branch) {
+		files[filePath] = content
+	}
+	for _, file := range request.Files {
+		current, fileExists := files[file.Path]
+		switch file.Operation {
+		case "create", "update":
+			if file.Operation == "create" && fileExists {
+				writeMessage(w, http.StatusUnprocessableEntity, "repository file already exists [path: "+file.Path+"]")
+				return
+			}
+			if file.Operation == "update" && (!fileExists || file.SHA != blobSHA(current)) {
+				writeMessage(w, http.StatusConflict, "sha does not match [given: "+file.SHA+"]")
+				return
+			}
+			content, decodeErr := base64.StdEncoding.DecodeString(file.Content)
+			if decodeErr != nil {
+				writeMessage(w, http.StatusUnprocessableEntity, decodeErr.Error())
+				return
+			}
+			files[file.Path] = content
+		case "delete":
+			if !fileExists {
+				writeMessage(w, http.StatusNotFound, "file does not exist [path: "+file.Path+"]")
+				return
+			}
+			if file.SHA != blobSHA(current) {
+				writeMessage(w, http.StatusConflict, "sha does not match [given: "+file.SHA+"]")
+				return
+			}
+			delete(files, file.Path)
+		default:
+			writeMessage(w, http.StatusUnprocessableEntity, "unsupported operation "+file.Operation)
+			return
+		}
+	}
+
+	commit := repo.commit(request.Branch, files, request.Message, request.Author, request.Committer)
+	writeJSON(w, http.StatusCreated, map[string]any{"commit": commit.toJSON()})
+}
+
+func (fake *FakeGitea) contents(w http.ResponseWriter, r *http.Request, repo *fakeRepository, filePath string) {
+	ref := r.URL.Query().Get("ref")
+	if len(ref) == 0 {
+		ref = repo.defaultBranch
+	}
+	content, exists := repo.files(ref)[filePath]
+	if !exists {
+		writeMessage(w, http.StatusNotFound, "object does not exist [id: , rel_path: "+filePath+"]")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"name":     filePath[strings.LastIndex(filePath, "/")+1:],
+		"path":     filePath,
+		"sha":      blobSHA(content),
+		"type":     "file",
+		"size":     len(content),
+		"encoding": "base64",
+		"content":  base64.StdEncoding.EncodeToString(content),
+	})
+}
+
+// listCommits lists the commits of the branch, the most recent first, only those changing the path if one is given
+func (fake *FakeGitea) listCommits(w http.ResponseWriter, r *http.Request, repo *fakeRepository) {
+	if len(repo.branches) == 0 {
+		writeMessage(w, http.StatusConflict, "Git Repository is empty.")
+		return
+	}
+	branch := r.URL.Query().Get("sha")
+	if len(branch) == 0 {
+		branch = repo.defaultBranch
+	}
+	if _, exists := repo.branches[branch]; !exists {
+		writeMessage(w, http.StatusNotFound, "object does not exist [id: "+branch+"]")
+		return
+	}
+	limit, limitErr := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limitErr != nil || limit <= 0 {
+		limit = 30
+	}
+	filePath := r.URL.Query().Get("path")
+
+	commits := []map[string]any{}
+	for _, commit := range repo.history(branch) {
+		if len(commits) == limit {
+			break
+		}
+		if len(filePath) == 0 || repo.changes(commit, filePath) {
+			commits = append(commits, commit.toJSON())
+		}
+	}
+	writeJSON(w, http.StatusOK, commits)
+}
+
+// tree lists the files of the branch (the blobstore always asks for the whole tree), page by page
+func (fake *FakeGitea) tree(w http.ResponseWriter, r *http.Request, repo *fakeRepository, ref string) {
+	files := repo.files(ref)
+	if files == nil {
+		writeMessage(w, http.StatusNotFound, "sha not provided")
+		return
+	}
+	paths := []string{}
+	for filePath := range files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	perPage, perPageErr := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPageErr != nil || perPage <= 0 {
+		perPage = 1000
+	}
+	page, pageErr := strconv.Atoi(r.URL.Query().Get("page"))
+	if pageErr != nil || page <= 0 {
+		page = 1
+	}
+	start := min((page-1)*perPage, len(paths))
+	end := min(start+perPage, len(paths))
+
+	entries := []map[string]any{}
+	for _, filePath := range paths[start:end] {
+		entries = append(entries, map[string]any{"path": filePath, "type": "blob", "mode": "100644", "sha": blobSHA(files[filePath])})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sha":         repo.branches[ref],
+		"tree":        entries,
+		"truncated":   end < len(paths),
+		"page":        page,
+		"total_count": len(paths),
+	})
+}
@@ -0,0 +1,117 @@
+package fakegitea
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+type identity struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Date  string `json:"date,omitempty"`
+}
+
+// fakeCommit keeps the whole tree it results in, which is plenty for the small repositories of tests
+type fakeCommit struct {
+	id            string
+	parentID      string
+	message       string
+	author        identity
+	committer     identity
+	committedDate time.Time
+	files         map[string][]byte
+}
+
+// fakeRepository is the in-memory repository: each branch points to its head commit
+type fakeRepository struct {
+	name          string
+	defaultBranch string
+	branches      map[string]string
+	commits       map[string]*fakeCommit
+}
+
+func newFakeRepository(name string, defaultBranch string) *fakeRepository {
+	return &fakeRepository{
+		name:          name,
+		defaultBranch: defaultBranch,
+		branches:      map[string]string{},
+		commits:       map[string]*fakeCommit{},
+	}
+}
+
+// blobSHA is the SHA git gives the content
+func blobSHA(content []byte) string {
+	hash := sha1.Sum(append([]byte(fmt.Sprintf("blob %d\x00", len(content))), content...))
+	return hex.EncodeToString(hash[:])
+}
+
+// files returns the files on the branch, nil if there is no such branch. The map returned is not to be modified.
+func (repo *fakeRepository) files(branch string) map[string][]byte {
+	head, exists := repo.branches[branch]
+	if !exists {
+		return nil
+	}
+	return repo.commits[head].files
+}
+
+func (repo *fakeRepository) commit(branch string, files map[string][]byte, message string, author identity, committer identity) *fakeCommit {
+	now := time.Now().UTC()
+	parentID := repo.branches[branch]
+	hash := sha1.Sum([]byte(fmt.Sprintf("%s\n%s\n%s\n%d\n%d", parentID, branch, message, now.UnixNano(), len(repo.commits))))
+	commit := &fakeCommit{
+		id:            hex.EncodeToString(hash[:]),
+		parentID:      parentID,
+		message:       message,
+		author:        author,
+		committer:     committer,
+		committedDate: now,
+		files:         files,
+	}
+	repo.commits[commit.id] = commit
+	repo.branches[branch] = commit.id
+	return commit
+}
+
+// history returns the commits of the branch, the most recent first
+func (repo *fakeRepository) history(branch string) []*fakeCommit {
+	history := []*fakeCommit{}
+	for id := repo.branches[branch]; len(id) > 0; id = repo.commits[id].parentID {
+		history = append(history, repo.commits[id])
+	}
+	return history
+}
+
+// changes tells whether the commit changed the file, compared to its parent
+func (repo *fakeRepository) changes(commit *fakeCommit, filePath string) bool {
+	content, inCommit := commit.files[filePath]
+	var parentFiles map[string][]byte
+	if parent, hasParent := repo.commits[commit.parentID]; hasParent {
+		parentFiles = parent.files
+	}
+	parentContent, inParent := parentFiles[filePath]
+	return inCommit != inParent || !bytes.Equal(content, parentContent)
+}
+
+func (commit *fakeCommit) toJSON() map[string]any {
+	date := commit.committedDate.Format(time.RFC3339)
+	author := commit.author
+	author.Date = date
+	committer := commit.committer
+	committer.Date = date
+	parents := []map[string]string{}
+	if len(commit.parentID) > 0 {
+		parents = append(parents, map[string]string{"sha": commit.parentID})
+	}
+	return map[string]any{
+		"sha": commit.id,
+		"commit": map[string]any{
+			"message":   commit.message,
+			"author":    author,
+			"committer": committer,
+		},
+		"parents": parents,
+	}
+}
@@ -0,0 +1,517 @@
+// Package fakegitlab is an in-process stand-in for the parts of the GitLab REST API used by the GitLab blobstore,
+// so that the blobstore can be tested without a GitLab account.
+package fakegitlab
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Namespace is the namespace the fake shared by the tests is set up with
+const Namespace = "testing-with-fake-gitlab"
+
+// Token is accepted by the fake, as is any other non-empty token
+const Token = "fake-token"
+
+// FakeGitlab serves the projects of a single namespace from memory
+type FakeGitlab struct {
+	server        *httptest.Server
+	namespacePath string
+	mux           sync.Mutex
+	projects      map[string]*fakeProject
+	lastProjectID int
+	// injected are answered, in order, to the next requests instead of serving them
+	injected     []Response
+	requestCount int
+}
+
+// Response is a response to send instead of serving a request, to simulate a failing GitLab.
+// With a zero StatusCode, the request is served as usual after the delay.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Delay      time.Duration
+}
+
+type MergeRequest struct {
+	IID                int    `json:"iid"`
+	State              string `json:"state"`
+	SourceBranch       string `json:"source_branch"`
+	TargetBranch       string `json:"target_branch"`
+	Title              string `json:"title"`
+	RemoveSourceBranch bool   `json:"remove_source_branch"`
+	WebURL             string `json:"web_url"`
+}
+
+func New(namespacePath string) *FakeGitlab {
+	fake := &FakeGitlab{
+		namespacePath: namespacePath,
+		projects:      map[string]*fakeProject{},
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	return fake
+}
+
+var shared *FakeGitlab
+var sharedOnce sync.Once
+
+// Shared returns a fake shared by the tests of the process, serving the Namespace namespace.
+// The tests keep apart by using projects of their own.
+func Shared() *FakeGitlab {
+	sharedOnce.Do(func() {
+		shared = New(Namespace)
+	})
+	return shared
+}
+
+// URL is the base URL of the fake API
+func (fake *FakeGitlab) URL() string {
+	return fake.server.URL
+}
+
+func (fake *FakeGitlab) Close() {
+	fake.server.Close()
+}
+
+// File returns the content of the file on the branch of the project, nil if there is no such file
+func (fake *FakeGitlab) File(projectPath string, branch string, filePath string) []byte {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	project, exists := fake.projects[projectPath]
+	if !exists {
+		return nil
+	}
+	return project.files(branch)[filePath]
+}
+
+func (fake *FakeGitlab) HasBranch(projectPath string, branch string) bool {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	project, exists := fake.projects[projectPath]
+	if !exists {
+		return false
+	}
+	_, exists = project.branches[branch]
+	return exists
+}
+
+// CommitCount returns the number of commits on the branch of the project
+func (fake *FakeGitlab) CommitCount(projectPath string, branch string) int {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	project, exists := fake.projects[projectPath]
+	if !exists {
+		return 0
+	}
+	return len(project.history(branch))
+}
+
+// MergeRequests returns a copy of the merge requests opened so far in the project
+func (fake *FakeGitlab) MergeRequests(projectPath string) []MergeRequest {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	result := []MergeRequest{}
+	if project, exists := fake.projects[projectPath]; exists {
+		for _, mr := range project.mergeRequests {
+			result = append(result, *mr)
+		}
+	}
+	return result
+}
+
+// Merge merges the open merge request of the source branch the way GitLab would
+func (fake *FakeGitlab) Merge(projectPath string, sourceBranch string) error {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	project, exists := fake.projects[projectPath]
+	if !exists {
+		return fmt.Errorf("no project %s", projectPath)
+	}
+	for _, mr := range project.mergeRequests {
+		if mr.SourceBranch != sourceBranch || mr.State != "opened" {
+			continue
+		}
+		files := map[string][]byte{}
+		for filePath, content := range project.files(mr.TargetBranch) {
+			files[filePath] = content
+		}
+		for filePath, content := range project.files(sourceBranch) {
+			files[filePath] = content
+		}
+		project.commit(mr.TargetBranch, mr.TargetBranch, files, fmt.Sprintf("Merge branch '%s' into '%s'", sourceBranch, mr.TargetBranch), "fake-gitlab")
+		mr.State = "merged"
+		if mr.RemoveSourceBranch {
+			delete(project.branches, sourceBranch)
+		}
+		return nil
+	}
+	return fmt.Errorf("no open merge request for %s", sourceBranch)
+}
+
+// InjectResponses has the next requests answered with the responses given
+func (fake *FakeGitlab) InjectResponses(responses ...Response) {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	fake.injected = append(fake.injected, responses...)
+}
+
+// RequestCount is the number of requests received so far
+func (fake *FakeGitlab) RequestCount() int {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	return fake.requestCount
+}
+
+// nextInjected pops the response to send instead of serving the request, if any
+func (fake *FakeGitlab) nextInjected() (Response, bool) {
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+	fake.requestCount++
+	if len(fake.injected) == 0 {
+		return Response{}, false
+	}
+	response := fake.injected[0]
+	fake.injected = fake.injected[1:]
+	return response, true
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeMessage(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]string{"message": message})
+}
+
+func (fake *FakeGitlab) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(r.Header.Get("PRIVATE-TOKEN")) == 0 {
+		writeMessage(w, http.StatusUnauthorized, "401 Unauthorized")
+		return
+	}
+
+	if injected, ok := fake.nextInjected(); ok {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(injected.Delay):
+		}
+		if injected.StatusCode != 0 {
+			for key, values := range injected.Header {
+				w.Header()[key] = values
+			}
+			writeMessage(w, injected.StatusCode, http.StatusText(injected.StatusCode))
+			return
+		}
+	}
+
+	fake.mux.Lock()
+	defer fake.mux.Unlock()
+
+	// The project and file path segments come URL-escaped, so we route on the escaped path
+	segments := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	unescape := func(segment string) string {
+		unescaped, _ := url.PathUnescape(segment)
+		return unescaped
+	}
+
+	switch {
+	case len(segments) == 1 && segments[0] == "namespaces":
+		writeJSON(w, http.StatusOK, []map[string]any{{"id": 1, "path": fake.namespacePath}})
+		return
+	case len(segments) == 1 && segments[0] == "projects" && r.Method == http.MethodPost:
+		fake.createProject(w, r)
+		return
+	case len(segments) < 2 || segments[0] != "projects":
+		writeMessage(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+
+	project := fake.findProject(unescape(segments[1]))
+	if project == nil {
+		writeMessage(w, http.StatusNotFound, "404 Project Not Found")
+		return
+	}
+
+	switch {
+	case len(segments) == 2 && r.Method == http.MethodDelete:
+		delete(fake.projects, project.path)
+		writeMessage(w, http.StatusAccepted, "202 Accepted")
+	case len(segments) == 4 && segments[2] == "repository" && segments[3] == "commits" && r.Method == http.MethodPost:
+		fake.commit(w, r, project)
+	case len(segments) == 4 && segments[2] == "repository" && segments[3] == "commits":
+		commits := []map[string]any{}
+		for _, commit := range project.history(r.URL.Query().Get("ref")) {
+			commits = append(commits, commit.toJSON())
+		}
+		writeJSON(w, http.StatusOK, commits)
+	case len(segments) == 5 && segments[2] == "repository" && segments[3] == "commits":
+		commit, exists := project.commits[unescape(segments[4])]
+		if !exists {
+			writeMessage(w, http.StatusNotFound, "404 Commit Not Found")
+			return
+		}
+		writeJSON(w, http.StatusOK, commit.toJSON())
+	case len(segments) == 4 && segments[2] == "repository" && segments[3] == "tree":
+		fake.tree(w, r, project)
+	case len(segments) == 5 && segments[2] == "repository" && segments[3] == "files":
+		fake.file(w, r, project, unescape(segments[4]))
+	case len(segments) == 5 && segments[2] == "repository" && segments[3] == "branches" && r.Method == http.MethodDelete:
+		branch := unescape(segments[4])
+		if _, exists := project.branches[branch]; !exists {
+			writeMessage(w, http.StatusNotFound, "404 Branch Not Found")
+			return
+		}
+		delete(project.branches, branch)
+		w.WriteHeader(http.StatusNoContent)
+	case len(segments) == 3 && segments[2] == "merge_requests" && r.Method == http.MethodPost:
+		fake.openMergeRequest(w, r, project)
+	case len(segments) == 3 && segments[2] == "merge_requests" && r.Method == http.MethodGet:
+		result := []MergeRequest{}
+		for _, mr := range project.mergeRequests {
+			if (r.URL.Query().Get("state") == "" || r.URL.Query().Get("state") == mr.State) &&
+				(r.URL.Query().Get("source_branch") == "" || r.URL.Query().Get("source_branch") == mr.SourceBranch) {
+				result = append(result, *mr)
+			}
+		}
+		writeJSON(w, http.StatusOK, result)
+	case len(segments) == 4 && segments[2] == "merge_requests" && r.Method == http.MethodPut:
+		fake.updateMergeRequest(w, r, project, segments[3])
+	default:
+		writeMessage(w, http.StatusNotFound, "404 Not Found")
+	}
+}
+
+// findProject finds the project by its numeric ID or by its path within the namespace
+func (fake *FakeGitlab) findProject(idOrPath string) *fakeProject {
+	if id, parseErr := strconv.Atoi(idOrPath); parseErr == nil {
+		for _, project := range fake.projects {
+			if project.id == id {
+				return project
+			}
+		}
+		return nil
+	}
+	namespace, projectPath, found := strings.Cut(idOrPath, "/")
+	if !found || namespace != fake.namespacePath {
+		return nil
+	}
+	return fake.projects[projectPath]
+}
+
+func (fake *FakeGitlab) createProject(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		NamespaceID int    `json:"namespace_id"`
+		Path        string `json:"path"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, exists := fake.projects[request.Path]; exists {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"message": map[string][]string{
+			"name": {"has already been taken"},
+			"path": {"has already been taken"},
+		}})
+		return
+	}
+	fake.lastProjectID++
+	project := newFakeProject(fake.lastProjectID, request.Path)
+	fake.projects[request.Path] = project
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"id":                  project.id,
+		"path":                project.path,
+		"path_with_namespace": fake.namespacePath + "/" + project.path,
+	})
+}
+
+type commitRequest struct {
+	Branch        string `json:"branch"`
+	StartBranch   string `json:"start_branch"`
+	AuthorName    string `json:"author_name"`
+	CommitMessage string `json:"commit_message"`
+	Actions       []struct {
+		Action   string  `json:"action"`
+		FilePath string  `json:"file_path"`
+		Content  *string `json:"content"`
+		Encoding *string `json:"encoding"`
+	} `json:"actions"`
+}
+
+func (fake *FakeGitlab) commit(w http.ResponseWriter, r *http.Request, project *fakeProject) {
+	request := commitRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, branchExists := project.branches[request.Branch]
+	startBranch := request.Branch
+	if len(request.StartBranch) > 0 {
+		if branchExists {
+			writeMessage(w, http.StatusBadRequest, "A branch called '"+request.Branch+"' already exists")
+			return
+		}
+		startBranch = request.StartBranch
+	} else if !branchExists && len(project.branches) > 0 {
+		// Only the first commit of an empty repository may create a branch out of nothing
+		writeMessage(w, http.StatusBadRequest, "You can only create or edit files when you are on a branch")
+		return
+	}
+
+	files := map[string][]byte{}
+	for filePath, content := range project.files(startBranch) {
+		files[filePath] = content
+	}
+	for _, action := range request.Actions {
+		_, fileExists := files[action.FilePath]
+		switch action.Action {
+		case "create", "update":
+			if action.Action == "create" && fileExists {
+				writeMessage(w, http.StatusBadRequest, "A file with this name already exists")
+				return
+			}
+			if action.Action == "update" && !fileExists {
+				writeMessage(w, http.StatusBadRequest, "A file with this name doesn't exist")
+				return
+			}
+			content, decodeErr := decodeContent(action.Content, action.Encoding)
+			if decodeErr != nil {
+				writeMessage(w, http.StatusBadRequest, decodeErr.Error())
+				return
+			}
+			files[action.FilePath] = content
+		case "delete":
+			if !fileExists {
+				writeMessage(w, http.StatusBadRequest, "A file with this name doesn't exist")
+				return
+			}
+			delete(files, action.FilePath)
+		default:
+			writeMessage(w, http.StatusBadRequest, "unsupported action "+action.Action)
+			return
+		}
+	}
+
+	commit := project.commit(request.Branch, startBranch, files, request.CommitMessage, request.AuthorName)
+	writeJSON(w, http.StatusCreated, commit.toJSON())
+}
+
+func decodeContent(content *string, encoding *string) ([]byte, error) {
+	if content == nil {
+		return []byte{}, nil
+	}
+	if encoding != nil && *encoding == "base64" {
+		return base64.StdEncoding.DecodeString(*content)
+	}
+	return []byte(*content), nil
+}
+
+// tree lists the files of the branch (the blobstore always asks for the whole tree), page by page
+func (fake *FakeGitlab) tree(w http.ResponseWriter, r *http.Request, project *fakeProject) {
+	files := project.files(r.URL.Query().Get("ref"))
+	if files == nil {
+		writeMessage(w, http.StatusNotFound, "404 Tree Not Found")
+		return
+	}
+	paths := []string{}
+	for filePath := range files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	perPage, perPageErr := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPageErr != nil || perPage <= 0 {
+		perPage = 20
+	}
+	page, pageErr := strconv.Atoi(r.URL.Query().Get("page"))
+	if pageErr != nil || page <= 0 {
+		page = 1
+	}
+	start := min((page-1)*perPage, len(paths))
+	end := min(start+perPage, len(paths))
+	if end < len(paths) {
+		w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+
+	items := []map[string]string{}
+	for _, filePath := range paths[start:end] {
+		name := filePath[strings.LastIndex(filePath, "/")+1:]
+		items = append(items, map[string]string{"type": "blob", "path": filePath, "name": name, "mode": "100644"})
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (fake *FakeGitlab) file(w http.ResponseWriter, r *http.Request, project *fakeProject, filePath string) {
+	ref := r.URL.Query().Get("ref")
+	content, exists := project.files(ref)[filePath]
+	if !exists {
+		writeMessage(w, http.StatusNotFound, "404 File Not Found")
+		return
+	}
+	head := project.branches[ref]
+	lastCommitID := project.lastCommitOf(ref, filePath)
+	w.Header().Set("X-Gitlab-Commit-Id", head)
+	w.Header().Set("X-Gitlab-Last-Commit-Id", lastCommitID)
+	w.Header().Set("X-Gitlab-File-Path", filePath)
+	w.Header().Set("X-Gitlab-Ref", ref)
+	w.Header().Set("X-Gitlab-Size", strconv.Itoa(len(content)))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"file_name":      filePath[strings.LastIndex(filePath, "/")+1:],
+		"file_path":      filePath,
+		"size":           len(content),
+		"encoding":       "base64",
+		"content":        base64.StdEncoding.EncodeToString(content),
+		"ref":            ref,
+		"commit_id":      head,
+		"last_commit_id": lastCommitID,
+	})
+}
+
+func (fake *FakeGitlab) openMergeRequest(w http.ResponseWriter, r *http.Request, project *fakeProject) {
+	mr := MergeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&mr); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, exists := project.branches[mr.SourceBranch]; !exists {
+		writeMessage(w, http.StatusNotFound, "404 Source Branch Not Found")
+		return
+	}
+	mr.IID = len(project.mergeRequests) + 1
+	mr.State = "opened"
+	mr.WebURL = fmt.Sprintf("%s/%s/%s/-/merge_requests/%d", fake.server.URL, fake.namespacePath, project.path, mr.IID)
+	project.mergeRequests = append(project.mergeRequests, &mr)
+	writeJSON(w, http.StatusCreated, mr)
+}
+
+func (fake *FakeGitlab) updateMergeRequest(w http.ResponseWriter, r *http.Request, project *fakeProject, iidSegment string) {
+	iid, _ := strconv.Atoi(iidSegment)
+	request := struct {
+		StateEvent string `json:"state_event"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, mr := range project.mergeRequests {
+		if mr.IID == iid {
+			if request.StateEvent == "close" {
+				mr.State = "closed"
+			}
+			writeJSON(w, http.StatusOK, mr)
+			return
+		}
+	}
+	writeMessage(w, http.StatusNotFound, "404 Not found")
+}
@@ -0,0 +1,109 @@
+package fakegitlab
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// fakeCommit keeps the whole tree it results in, which is plenty for the small repositories of tests
+type fakeCommit struct {
+	id            string
+	parentID      string
+	message       string
+	authorName    string
+	committedDate time.Time
+	files         map[string][]byte
+}
+
+// fakeProject is the in-memory repository of a project: each branch points to its head commit
+type fakeProject struct {
+	id            int
+	path          string
+	branches      map[string]string
+	commits       map[string]*fakeCommit
+	mergeRequests []*MergeRequest
+}
+
+func newFakeProject(id int, path string) *fakeProject {
+	return &fakeProject{
+		id:       id,
+		path:     path,
+		branches: map[string]string{},
+		commits:  map[string]*fakeCommit{},
+	}
+}
+
+// files returns the files on the branch, nil if there is no such branch. The map returned is not to be modified.
+func (project *fakeProject) files(branch string) map[string][]byte {
+	head, exists := project.branches[branch]
+	if !exists {
+		return nil
+	}
+	return project.commits[head].files
+}
+
+// commit records a commit on the branch, with the branch it starts from as parent
+func (project *fakeProject) commit(branch string, startBranch string, files map[string][]byte, message string, authorName string) *fakeCommit {
+	now := time.Now().UTC()
+	parentID := project.branches[startBranch]
+	hash := sha1.Sum([]byte(fmt.Sprintf("%s\n%s\n%s\n%d\n%d", parentID, branch, message, now.UnixNano(), len(project.commits))))
+	commit := &fakeCommit{
+		id:            hex.EncodeToString(hash[:]),
+		parentID:      parentID,
+		message:       message,
+		authorName:    authorName,
+		committedDate: now,
+		files:         files,
+	}
+	project.commits[commit.id] = commit
+	project.branches[branch] = commit.id
+	return commit
+}
+
+// history returns the commits of the branch, the most recent first
+func (project *fakeProject) history(branch string) []*fakeCommit {
+	history := []*fakeCommit{}
+	for id := project.branches[branch]; len(id) > 0; id = project.commits[id].parentID {
+		history = append(history, project.commits[id])
+	}
+	return history
+}
+
+// lastCommitOf returns the most recent commit of the branch which changed the file
+func (project *fakeProject) lastCommitOf(branch string, filePath string) string {
+	history := project.history(branch)
+	for i, commit := range history {
+		if i+1 == len(history) {
+			return commit.id
+		}
+		parentContent, inParent := history[i+1].files[filePath]
+		if !inParent || !bytes.Equal(parentContent, commit.files[filePath]) {
+			return commit.id
+		}
+	}
+	return ""
+}
+
+func (commit *fakeCommit) toJSON() map[string]any {
+	date := commit.committedDate.Format(time.RFC3339)
+	parentIDs := []string{}
+	if len(commit.parentID) > 0 {
+		parentIDs = append(parentIDs, commit.parentID)
+	}
+	return map[string]any{
+		"id":              commit.id,
+		"short_id":        commit.id[:8],
+		"title":           commit.message,
+		"message":         commit.message,
+		"parent_ids":      parentIDs,
+		"author_name":     commit.authorName,
+		"author_email":    commit.authorName + "@fake-gitlab",
+		"authored_date":   date,
+		"committer_name":  commit.authorName,
+		"committer_email": commit.authorName + "@fake-gitlab",
+		"committed_date":  date,
+	}
+}
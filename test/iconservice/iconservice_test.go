@@ -61,7 +61,7 @@ func (s *appTestSuite) TestCreateIconNoPerm() {
 	iconName := "test-icon"
 	iconfile := getTestIconfile()
 	mockRepo := mocks.Repository{}
-	api := services.NewIconService(&mockRepo)
+	api := services.NewIconService(&mockRepo, false)
 	_, err := api.CreateIcon(s.ctx, iconName, iconfile.Content, testUser)
 	s.Error(err)
 	s.ErrorIs(err, authr.ErrPermission)
@@ -82,7 +82,7 @@ func (s *appTestSuite) TestCreateIcon() {
 	}
 	mockRepo := mocks.Repository{}
 	mockRepo.On("CreateIcon", mock.AnythingOfType("*context.emptyCtx"), iconName, iconfile, testUser).Return(nil)
-	api := services.NewIconService(&mockRepo)
+	api := services.NewIconService(&mockRepo, false)
 	icon, err := api.CreateIcon(s.ctx, iconName, iconfile.Content, testUser)
 	s.NoError(err)
 	s.Equal(expectedResponseIcon, icon)
@@ -0,0 +1,116 @@
+package iconservice
+
+import (
+	"context"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/app/services"
+	"iconrepo/test/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type reviewTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func TestReviewTestSuite(t *testing.T) {
+	suite.Run(t, &reviewTestSuite{ctx: context.Background()})
+}
+
+var iconInReview = domain.IconDescriptor{
+	IconAttributes: domain.IconAttributes{Name: "dock", ModifiedBy: "editor", Tags: []string{}},
+	Iconfiles: []domain.IconfileDescriptor{
+		{Format: "png", Size: "36px"},
+		{Format: "svg", Size: "18px", ReviewStatus: domain.ReviewStatusInReview},
+	},
+}
+
+var draftOnlyIcon = domain.IconDescriptor{
+	IconAttributes: domain.IconAttributes{Name: "cast", ModifiedBy: "editor", Tags: []string{}},
+	Iconfiles: []domain.IconfileDescriptor{
+		{Format: "png", Size: "36px", ReviewStatus: domain.ReviewStatusDraft},
+	},
+}
+
+func (s *reviewTestSuite) TestConsumersSeeOnlyPublishedIconfiles() {
+	mockRepo := mocks.Repository{}
+	mockRepo.On("DescribeAllIcons", mock.Anything).Return([]domain.IconDescriptor{iconInReview, draftOnlyIcon}, nil)
+	api := services.NewIconService(&mockRepo, true)
+
+	icons, err := api.DescribeAllIcons(s.ctx, createUserInfo(nil))
+	s.NoError(err)
+	s.Equal(1, len(icons))
+	s.Equal(iconInReview.Name, icons[0].Name)
+	s.Equal([]domain.IconfileDescriptor{{Format: "png", Size: "36px"}}, icons[0].Iconfiles)
+
+	icons, err = api.DescribeAllIcons(s.ctx, createUserInfo([]authr.PermissionID{authr.APPROVE_ICON}))
+	s.NoError(err)
+	s.Equal([]domain.IconDescriptor{iconInReview, draftOnlyIcon}, icons)
+	mockRepo.AssertExpectations(s.T())
+}
+
+func (s *reviewTestSuite) TestConsumersSeeOnlyPublishedIconsWithTag() {
+	const tag = "used-in-marvinjs"
+	mockRepo := mocks.Repository{}
+	mockRepo.On("GetIconsWithTag", mock.Anything, tag).Return([]domain.IconDescriptor{iconInReview, draftOnlyIcon}, nil)
+	api := services.NewIconService(&mockRepo, true)
+
+	icons, err := api.GetIconsWithTag(s.ctx, tag, createUserInfo(nil))
+	s.NoError(err)
+	s.Equal(1, len(icons))
+	s.Equal(iconInReview.Name, icons[0].Name)
+
+	icons, err = api.GetIconsWithTag(s.ctx, tag, createUserInfo([]authr.PermissionID{authr.APPROVE_ICON}))
+	s.NoError(err)
+	s.Equal([]domain.IconDescriptor{iconInReview, draftOnlyIcon}, icons)
+	mockRepo.AssertExpectations(s.T())
+}
+
+func (s *reviewTestSuite) TestConsumersCannotGetUnpublishedIconfile() {
+	mockRepo := mocks.Repository{}
+	mockRepo.On("DescribeIcon", mock.Anything, draftOnlyIcon.Name).Return(draftOnlyIcon, nil)
+	api := services.NewIconService(&mockRepo, true)
+
+	_, err := api.DescribeIcon(s.ctx, draftOnlyIcon.Name, createUserInfo(nil))
+	s.ErrorIs(err, domain.ErrIconNotFound)
+
+	_, err = api.GetIconfile(s.ctx, draftOnlyIcon.Name, draftOnlyIcon.Iconfiles[0], createUserInfo(nil))
+	s.ErrorIs(err, domain.ErrIconfileNotFound)
+	mockRepo.AssertNotCalled(s.T(), "GetIconfile", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *reviewTestSuite) TestOnlyApproversCanPublish() {
+	iconfile := domain.IconfileDescriptor{Format: "svg", Size: "18px"}
+	editor := createUserInfo(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	approver := createUserInfo([]authr.PermissionID{authr.APPROVE_ICON})
+
+	mockRepo := mocks.Repository{}
+	mockRepo.On("DescribeIcon", mock.Anything, iconInReview.Name).Return(iconInReview, nil)
+	mockRepo.On("SetIconfileReviewStatus", mock.Anything, iconInReview.Name, iconfile, domain.ReviewStatusPublished, "looks good", approver).Return(nil)
+	api := services.NewIconService(&mockRepo, true)
+
+	err := api.UpdateReviewStatus(s.ctx, iconInReview.Name, iconfile, domain.ReviewStatusPublished, "looks good", editor)
+	s.ErrorIs(err, authr.ErrPermission)
+
+	err = api.UpdateReviewStatus(s.ctx, iconInReview.Name, iconfile, domain.ReviewStatusPublished, "looks good", approver)
+	s.NoError(err)
+	mockRepo.AssertExpectations(s.T())
+}
+
+func (s *reviewTestSuite) TestDraftMustBeSubmittedBeforePublishing() {
+	iconfile := draftOnlyIcon.Iconfiles[0]
+	approver := createUserInfo([]authr.PermissionID{authr.APPROVE_ICON})
+
+	mockRepo := mocks.Repository{}
+	mockRepo.On("DescribeIcon", mock.Anything, draftOnlyIcon.Name).Return(draftOnlyIcon, nil)
+	api := services.NewIconService(&mockRepo, true)
+
+	err := api.UpdateReviewStatus(s.ctx, draftOnlyIcon.Name, iconfile, domain.ReviewStatusPublished, "", approver)
+	s.ErrorIs(err, domain.ErrInvalidReviewChange)
+	mockRepo.AssertNotCalled(s.T(), "SetIconfileReviewStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
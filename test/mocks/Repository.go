@@ -122,6 +122,173 @@ func (_c *Repository_AddTag_Call) RunAndReturn(run func(context.Context, string,
 	return _c
 }
 
+// ApplyChangeset provides a mock function with given fields: ctx, ops, modifiedBy
+func (_m *Repository) ApplyChangeset(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy authr.UserInfo) ([]domain.IconDescriptor, error) {
+	ret := _m.Called(ctx, ops, modifiedBy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ApplyChangeset")
+	}
+
+	var r0 []domain.IconDescriptor
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []domain.ChangesetOperation, authr.UserInfo) ([]domain.IconDescriptor, error)); ok {
+		return rf(ctx, ops, modifiedBy)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []domain.ChangesetOperation, authr.UserInfo) []domain.IconDescriptor); ok {
+		r0 = rf(ctx, ops, modifiedBy)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.IconDescriptor)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []domain.ChangesetOperation, authr.UserInfo) error); ok {
+		r1 = rf(ctx, ops, modifiedBy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_ApplyChangeset_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ApplyChangeset'
+type Repository_ApplyChangeset_Call struct {
+	*mock.Call
+}
+
+// ApplyChangeset is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ops []domain.ChangesetOperation
+//   - modifiedBy authr.UserInfo
+func (_e *Repository_Expecter) ApplyChangeset(ctx interface{}, ops interface{}, modifiedBy interface{}) *Repository_ApplyChangeset_Call {
+	return &Repository_ApplyChangeset_Call{Call: _e.mock.On("ApplyChangeset", ctx, ops, modifiedBy)}
+}
+
+func (_c *Repository_ApplyChangeset_Call) Run(run func(ctx context.Context, ops []domain.ChangesetOperation, modifiedBy authr.UserInfo)) *Repository_ApplyChangeset_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]domain.ChangesetOperation), args[2].(authr.UserInfo))
+	})
+	return _c
+}
+
+func (_c *Repository_ApplyChangeset_Call) Return(_a0 []domain.IconDescriptor, _a1 error) *Repository_ApplyChangeset_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_ApplyChangeset_Call) RunAndReturn(run func(context.Context, []domain.ChangesetOperation, authr.UserInfo) ([]domain.IconDescriptor, error)) *Repository_ApplyChangeset_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CheckConsistency provides a mock function with given fields: ctx, repair, modifiedBy
+func (_m *Repository) CheckConsistency(ctx context.Context, repair bool, modifiedBy authr.UserInfo) (domain.ConsistencyReport, error) {
+	ret := _m.Called(ctx, repair, modifiedBy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CheckConsistency")
+	}
+
+	var r0 domain.ConsistencyReport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, bool, authr.UserInfo) (domain.ConsistencyReport, error)); ok {
+		return rf(ctx, repair, modifiedBy)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, bool, authr.UserInfo) domain.ConsistencyReport); ok {
+		r0 = rf(ctx, repair, modifiedBy)
+	} else {
+		r0 = ret.Get(0).(domain.ConsistencyReport)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, bool, authr.UserInfo) error); ok {
+		r1 = rf(ctx, repair, modifiedBy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_CheckConsistency_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CheckConsistency'
+type Repository_CheckConsistency_Call struct {
+	*mock.Call
+}
+
+// CheckConsistency is a helper method to define mock.On call
+//   - ctx context.Context
+//   - repair bool
+//   - modifiedBy authr.UserInfo
+func (_e *Repository_Expecter) CheckConsistency(ctx interface{}, repair interface{}, modifiedBy interface{}) *Repository_CheckConsistency_Call {
+	return &Repository_CheckConsistency_Call{Call: _e.mock.On("CheckConsistency", ctx, repair, modifiedBy)}
+}
+
+func (_c *Repository_CheckConsistency_Call) Run(run func(ctx context.Context, repair bool, modifiedBy authr.UserInfo)) *Repository_CheckConsistency_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(bool), args[2].(authr.UserInfo))
+	})
+	return _c
+}
+
+func (_c *Repository_CheckConsistency_Call) Return(_a0 domain.ConsistencyReport, _a1 error) *Repository_CheckConsistency_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_CheckConsistency_Call) RunAndReturn(run func(context.Context, bool, authr.UserInfo) (domain.ConsistencyReport, error)) *Repository_CheckConsistency_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CompleteMergeRequest provides a mock function with given fields: ctx, sourceBranch, merged, modifiedBy
+func (_m *Repository) CompleteMergeRequest(ctx context.Context, sourceBranch string, merged bool, modifiedBy authr.UserInfo) error {
+	ret := _m.Called(ctx, sourceBranch, merged, modifiedBy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CompleteMergeRequest")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool, authr.UserInfo) error); ok {
+		r0 = rf(ctx, sourceBranch, merged, modifiedBy)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_CompleteMergeRequest_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CompleteMergeRequest'
+type Repository_CompleteMergeRequest_Call struct {
+	*mock.Call
+}
+
+// CompleteMergeRequest is a helper method to define mock.On call
+//   - ctx context.Context
+//   - sourceBranch string
+//   - merged bool
+//   - modifiedBy authr.UserInfo
+func (_e *Repository_Expecter) CompleteMergeRequest(ctx interface{}, sourceBranch interface{}, merged interface{}, modifiedBy interface{}) *Repository_CompleteMergeRequest_Call {
+	return &Repository_CompleteMergeRequest_Call{Call: _e.mock.On("CompleteMergeRequest", ctx, sourceBranch, merged, modifiedBy)}
+}
+
+func (_c *Repository_CompleteMergeRequest_Call) Run(run func(ctx context.Context, sourceBranch string, merged bool, modifiedBy authr.UserInfo)) *Repository_CompleteMergeRequest_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(bool), args[3].(authr.UserInfo))
+	})
+	return _c
+}
+
+func (_c *Repository_CompleteMergeRequest_Call) Return(_a0 error) *Repository_CompleteMergeRequest_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_CompleteMergeRequest_Call) RunAndReturn(run func(context.Context, string, bool, authr.UserInfo) error) *Repository_CompleteMergeRequest_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // CreateIcon provides a mock function with given fields: ctx, iconName, iconfile, modifiedBy
 func (_m *Repository) CreateIcon(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy authr.UserInfo) error {
 	ret := _m.Called(ctx, iconName, iconfile, modifiedBy)
@@ -383,6 +550,120 @@ func (_c *Repository_DescribeIcon_Call) RunAndReturn(run func(context.Context, s
 	return _c
 }
 
+// GetAuditEntries provides a mock function with given fields: ctx, query
+func (_m *Repository) GetAuditEntries(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAuditEntries")
+	}
+
+	var r0 domain.AuditPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.AuditQuery) (domain.AuditPage, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.AuditQuery) domain.AuditPage); ok {
+		r0 = rf(ctx, query)
+	} else {
+		r0 = ret.Get(0).(domain.AuditPage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.AuditQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetAuditEntries_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetAuditEntries'
+type Repository_GetAuditEntries_Call struct {
+	*mock.Call
+}
+
+// GetAuditEntries is a helper method to define mock.On call
+//   - ctx context.Context
+//   - query domain.AuditQuery
+func (_e *Repository_Expecter) GetAuditEntries(ctx interface{}, query interface{}) *Repository_GetAuditEntries_Call {
+	return &Repository_GetAuditEntries_Call{Call: _e.mock.On("GetAuditEntries", ctx, query)}
+}
+
+func (_c *Repository_GetAuditEntries_Call) Run(run func(ctx context.Context, query domain.AuditQuery)) *Repository_GetAuditEntries_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(domain.AuditQuery))
+	})
+	return _c
+}
+
+func (_c *Repository_GetAuditEntries_Call) Return(_a0 domain.AuditPage, _a1 error) *Repository_GetAuditEntries_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetAuditEntries_Call) RunAndReturn(run func(context.Context, domain.AuditQuery) (domain.AuditPage, error)) *Repository_GetAuditEntries_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetIconCatalog provides a mock function with given fields: ctx, since
+func (_m *Repository) GetIconCatalog(ctx context.Context, since string) (domain.IconCatalog, error) {
+	ret := _m.Called(ctx, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetIconCatalog")
+	}
+
+	var r0 domain.IconCatalog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.IconCatalog, error)); ok {
+		return rf(ctx, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.IconCatalog); ok {
+		r0 = rf(ctx, since)
+	} else {
+		r0 = ret.Get(0).(domain.IconCatalog)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetIconCatalog_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetIconCatalog'
+type Repository_GetIconCatalog_Call struct {
+	*mock.Call
+}
+
+// GetIconCatalog is a helper method to define mock.On call
+//   - ctx context.Context
+//   - since string
+func (_e *Repository_Expecter) GetIconCatalog(ctx interface{}, since interface{}) *Repository_GetIconCatalog_Call {
+	return &Repository_GetIconCatalog_Call{Call: _e.mock.On("GetIconCatalog", ctx, since)}
+}
+
+func (_c *Repository_GetIconCatalog_Call) Run(run func(ctx context.Context, since string)) *Repository_GetIconCatalog_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_GetIconCatalog_Call) Return(_a0 domain.IconCatalog, _a1 error) *Repository_GetIconCatalog_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetIconCatalog_Call) RunAndReturn(run func(context.Context, string) (domain.IconCatalog, error)) *Repository_GetIconCatalog_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetIconfile provides a mock function with given fields: ctx, iconName, iconfile
 func (_m *Repository) GetIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor) ([]byte, error) {
 	ret := _m.Called(ctx, iconName, iconfile)
@@ -443,6 +724,179 @@ func (_c *Repository_GetIconfile_Call) RunAndReturn(run func(context.Context, st
 	return _c
 }
 
+// GetIconfileCacheStats provides a mock function with given fields: ctx
+func (_m *Repository) GetIconfileCacheStats(ctx context.Context) (domain.IconfileCacheStats, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetIconfileCacheStats")
+	}
+
+	var r0 domain.IconfileCacheStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (domain.IconfileCacheStats, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) domain.IconfileCacheStats); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(domain.IconfileCacheStats)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetIconfileCacheStats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetIconfileCacheStats'
+type Repository_GetIconfileCacheStats_Call struct {
+	*mock.Call
+}
+
+// GetIconfileCacheStats is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Repository_Expecter) GetIconfileCacheStats(ctx interface{}) *Repository_GetIconfileCacheStats_Call {
+	return &Repository_GetIconfileCacheStats_Call{Call: _e.mock.On("GetIconfileCacheStats", ctx)}
+}
+
+func (_c *Repository_GetIconfileCacheStats_Call) Run(run func(ctx context.Context)) *Repository_GetIconfileCacheStats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *Repository_GetIconfileCacheStats_Call) Return(_a0 domain.IconfileCacheStats, _a1 error) *Repository_GetIconfileCacheStats_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetIconfileCacheStats_Call) RunAndReturn(run func(context.Context) (domain.IconfileCacheStats, error)) *Repository_GetIconfileCacheStats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetIconsWithTag provides a mock function with given fields: ctx, tag
+func (_m *Repository) GetIconsWithTag(ctx context.Context, tag string) ([]domain.IconDescriptor, error) {
+	ret := _m.Called(ctx, tag)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetIconsWithTag")
+	}
+
+	var r0 []domain.IconDescriptor
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]domain.IconDescriptor, error)); ok {
+		return rf(ctx, tag)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []domain.IconDescriptor); ok {
+		r0 = rf(ctx, tag)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.IconDescriptor)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, tag)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetIconsWithTag_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetIconsWithTag'
+type Repository_GetIconsWithTag_Call struct {
+	*mock.Call
+}
+
+// GetIconsWithTag is a helper method to define mock.On call
+//   - ctx context.Context
+//   - tag string
+func (_e *Repository_Expecter) GetIconsWithTag(ctx interface{}, tag interface{}) *Repository_GetIconsWithTag_Call {
+	return &Repository_GetIconsWithTag_Call{Call: _e.mock.On("GetIconsWithTag", ctx, tag)}
+}
+
+func (_c *Repository_GetIconsWithTag_Call) Run(run func(ctx context.Context, tag string)) *Repository_GetIconsWithTag_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_GetIconsWithTag_Call) Return(_a0 []domain.IconDescriptor, _a1 error) *Repository_GetIconsWithTag_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetIconsWithTag_Call) RunAndReturn(run func(context.Context, string) ([]domain.IconDescriptor, error)) *Repository_GetIconsWithTag_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetMirrorStatus provides a mock function with given fields: ctx
+func (_m *Repository) GetMirrorStatus(ctx context.Context) ([]domain.MirrorStatus, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMirrorStatus")
+	}
+
+	var r0 []domain.MirrorStatus
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]domain.MirrorStatus, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []domain.MirrorStatus); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.MirrorStatus)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetMirrorStatus_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetMirrorStatus'
+type Repository_GetMirrorStatus_Call struct {
+	*mock.Call
+}
+
+// GetMirrorStatus is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Repository_Expecter) GetMirrorStatus(ctx interface{}) *Repository_GetMirrorStatus_Call {
+	return &Repository_GetMirrorStatus_Call{Call: _e.mock.On("GetMirrorStatus", ctx)}
+}
+
+func (_c *Repository_GetMirrorStatus_Call) Run(run func(ctx context.Context)) *Repository_GetMirrorStatus_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *Repository_GetMirrorStatus_Call) Return(_a0 []domain.MirrorStatus, _a1 error) *Repository_GetMirrorStatus_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetMirrorStatus_Call) RunAndReturn(run func(context.Context) ([]domain.MirrorStatus, error)) *Repository_GetMirrorStatus_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetTags provides a mock function with given fields: ctx
 func (_m *Repository) GetTags(ctx context.Context) ([]string, error) {
 	ret := _m.Called(ctx)
@@ -501,6 +955,119 @@ func (_c *Repository_GetTags_Call) RunAndReturn(run func(context.Context) ([]str
 	return _c
 }
 
+// GetWriteQueueStats provides a mock function with given fields: ctx
+func (_m *Repository) GetWriteQueueStats(ctx context.Context) (domain.WriteQueueStats, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetWriteQueueStats")
+	}
+
+	var r0 domain.WriteQueueStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (domain.WriteQueueStats, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) domain.WriteQueueStats); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(domain.WriteQueueStats)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_GetWriteQueueStats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetWriteQueueStats'
+type Repository_GetWriteQueueStats_Call struct {
+	*mock.Call
+}
+
+// GetWriteQueueStats is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Repository_Expecter) GetWriteQueueStats(ctx interface{}) *Repository_GetWriteQueueStats_Call {
+	return &Repository_GetWriteQueueStats_Call{Call: _e.mock.On("GetWriteQueueStats", ctx)}
+}
+
+func (_c *Repository_GetWriteQueueStats_Call) Run(run func(ctx context.Context)) *Repository_GetWriteQueueStats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *Repository_GetWriteQueueStats_Call) Return(_a0 domain.WriteQueueStats, _a1 error) *Repository_GetWriteQueueStats_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_GetWriteQueueStats_Call) RunAndReturn(run func(context.Context) (domain.WriteQueueStats, error)) *Repository_GetWriteQueueStats_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Reindex provides a mock function with given fields: ctx, modifiedBy
+func (_m *Repository) Reindex(ctx context.Context, modifiedBy authr.UserInfo) (domain.ReindexReport, error) {
+	ret := _m.Called(ctx, modifiedBy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reindex")
+	}
+
+	var r0 domain.ReindexReport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, authr.UserInfo) (domain.ReindexReport, error)); ok {
+		return rf(ctx, modifiedBy)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, authr.UserInfo) domain.ReindexReport); ok {
+		r0 = rf(ctx, modifiedBy)
+	} else {
+		r0 = ret.Get(0).(domain.ReindexReport)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, authr.UserInfo) error); ok {
+		r1 = rf(ctx, modifiedBy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_Reindex_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Reindex'
+type Repository_Reindex_Call struct {
+	*mock.Call
+}
+
+// Reindex is a helper method to define mock.On call
+//   - ctx context.Context
+//   - modifiedBy authr.UserInfo
+func (_e *Repository_Expecter) Reindex(ctx interface{}, modifiedBy interface{}) *Repository_Reindex_Call {
+	return &Repository_Reindex_Call{Call: _e.mock.On("Reindex", ctx, modifiedBy)}
+}
+
+func (_c *Repository_Reindex_Call) Run(run func(ctx context.Context, modifiedBy authr.UserInfo)) *Repository_Reindex_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(authr.UserInfo))
+	})
+	return _c
+}
+
+func (_c *Repository_Reindex_Call) Return(_a0 domain.ReindexReport, _a1 error) *Repository_Reindex_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_Reindex_Call) RunAndReturn(run func(context.Context, authr.UserInfo) (domain.ReindexReport, error)) *Repository_Reindex_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // RemoveTag provides a mock function with given fields: ctx, iconName, tag, modifiedBy
 func (_m *Repository) RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy authr.UserInfo) error {
 	ret := _m.Called(ctx, iconName, tag, modifiedBy)
@@ -550,6 +1117,106 @@ func (_c *Repository_RemoveTag_Call) RunAndReturn(run func(context.Context, stri
 	return _c
 }
 
+// SetIconDescription provides a mock function with given fields: ctx, iconName, description, modifiedBy
+func (_m *Repository) SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy authr.UserInfo) error {
+	ret := _m.Called(ctx, iconName, description, modifiedBy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetIconDescription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, authr.UserInfo) error); ok {
+		r0 = rf(ctx, iconName, description, modifiedBy)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_SetIconDescription_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetIconDescription'
+type Repository_SetIconDescription_Call struct {
+	*mock.Call
+}
+
+// SetIconDescription is a helper method to define mock.On call
+//   - ctx context.Context
+//   - iconName string
+//   - description string
+//   - modifiedBy authr.UserInfo
+func (_e *Repository_Expecter) SetIconDescription(ctx interface{}, iconName interface{}, description interface{}, modifiedBy interface{}) *Repository_SetIconDescription_Call {
+	return &Repository_SetIconDescription_Call{Call: _e.mock.On("SetIconDescription", ctx, iconName, description, modifiedBy)}
+}
+
+func (_c *Repository_SetIconDescription_Call) Run(run func(ctx context.Context, iconName string, description string, modifiedBy authr.UserInfo)) *Repository_SetIconDescription_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].(authr.UserInfo))
+	})
+	return _c
+}
+
+func (_c *Repository_SetIconDescription_Call) Return(_a0 error) *Repository_SetIconDescription_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_SetIconDescription_Call) RunAndReturn(run func(context.Context, string, string, authr.UserInfo) error) *Repository_SetIconDescription_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetIconfileReviewStatus provides a mock function with given fields: ctx, iconName, iconfile, status, comment, modifiedBy
+func (_m *Repository) SetIconfileReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, comment string, modifiedBy authr.UserInfo) error {
+	ret := _m.Called(ctx, iconName, iconfile, status, comment, modifiedBy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetIconfileReviewStatus")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.IconfileDescriptor, domain.ReviewStatus, string, authr.UserInfo) error); ok {
+		r0 = rf(ctx, iconName, iconfile, status, comment, modifiedBy)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_SetIconfileReviewStatus_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetIconfileReviewStatus'
+type Repository_SetIconfileReviewStatus_Call struct {
+	*mock.Call
+}
+
+// SetIconfileReviewStatus is a helper method to define mock.On call
+//   - ctx context.Context
+//   - iconName string
+//   - iconfile domain.IconfileDescriptor
+//   - status domain.ReviewStatus
+//   - comment string
+//   - modifiedBy authr.UserInfo
+func (_e *Repository_Expecter) SetIconfileReviewStatus(ctx interface{}, iconName interface{}, iconfile interface{}, status interface{}, comment interface{}, modifiedBy interface{}) *Repository_SetIconfileReviewStatus_Call {
+	return &Repository_SetIconfileReviewStatus_Call{Call: _e.mock.On("SetIconfileReviewStatus", ctx, iconName, iconfile, status, comment, modifiedBy)}
+}
+
+func (_c *Repository_SetIconfileReviewStatus_Call) Run(run func(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, comment string, modifiedBy authr.UserInfo)) *Repository_SetIconfileReviewStatus_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(domain.IconfileDescriptor), args[3].(domain.ReviewStatus), args[4].(string), args[5].(authr.UserInfo))
+	})
+	return _c
+}
+
+func (_c *Repository_SetIconfileReviewStatus_Call) Return(_a0 error) *Repository_SetIconfileReviewStatus_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_SetIconfileReviewStatus_Call) RunAndReturn(run func(context.Context, string, domain.IconfileDescriptor, domain.ReviewStatus, string, authr.UserInfo) error) *Repository_SetIconfileReviewStatus_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRepository(t interface {
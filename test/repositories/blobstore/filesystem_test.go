@@ -0,0 +1,58 @@
+package blobstore
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/repositories/blobstore/filesystem"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listFilesystemBlobstore(t *testing.T, location string) []string {
+	files := []string{}
+	walkErr := filepath.WalkDir(location, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, filepath.Base(path))
+		}
+		return err
+	})
+	require.NoError(t, walkErr)
+	return files
+}
+
+func TestFilesystemDeleteIconFailingLeavesTheIconAsItWas(t *testing.T) {
+	ctx := context.Background()
+	location := t.TempDir()
+	repo := filesystem.NewFilesystemBlobstore(location)
+	require.NoError(t, repo.CreateRepository(ctx))
+	icon := test_commons.TestData[0]
+	user := authn.LocalDomain.CreateUserID(icon.ModifiedBy)
+	require.NoError(t, repo.AddIconfile(ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+	require.NoError(t, repo.UpdateIconMetadata(ctx, icon.Name, domain.IconMetadata{Tags: icon.Tags}, icon.ModifiedBy))
+	filesBefore := listFilesystemBlobstore(t, location)
+
+	// The second iconfile isn't in the blobstore
+	iconDesc := domain.IconDescriptor{
+		IconAttributes: icon.IconAttributes,
+		Iconfiles:      []domain.IconfileDescriptor{icon.Iconfiles[0].IconfileDescriptor, icon.Iconfiles[1].IconfileDescriptor},
+	}
+	assert.ErrorIs(t, repo.DeleteIcon(ctx, iconDesc, user), domain.ErrIconfileNotFound)
+
+	assert.ElementsMatch(t, filesBefore, listFilesystemBlobstore(t, location))
+	content, getErr := repo.GetIconfile(ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor)
+	assert.NoError(t, getErr)
+	assert.Equal(t, icon.Iconfiles[0].Content, content)
+	_, getMetadataErr := repo.GetIconMetadata(ctx, icon.Name)
+	assert.NoError(t, getMetadataErr)
+
+	iconDesc.Iconfiles = iconDesc.Iconfiles[:1]
+	assert.NoError(t, repo.DeleteIcon(ctx, iconDesc, user))
+	assert.Empty(t, listFilesystemBlobstore(t, location))
+}
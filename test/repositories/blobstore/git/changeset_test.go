@@ -0,0 +1,87 @@
+package git
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type localChangesetTestSuite struct {
+	suite.Suite
+	ctx   context.Context
+	local *git.Local
+}
+
+func TestLocalChangesetTestSuite(t *testing.T) {
+	suite.Run(t, &localChangesetTestSuite{ctx: context.Background()})
+}
+
+func (s *localChangesetTestSuite) BeforeTest(suiteName string, testName string) {
+	local := git.NewLocalGitRepository(filepath.Join(s.T().TempDir(), "local"))
+	s.local = &local
+	s.Require().NoError(s.local.CreateRepository(s.ctx))
+}
+
+func (s *localChangesetTestSuite) commitCount() int {
+	cmd := exec.Command("git", "rev-list", "--count", "HEAD")
+	cmd.Dir = s.local.Location
+	out, err := cmd.CombinedOutput()
+	s.Require().NoError(err, string(out))
+	count, parseErr := strconv.Atoi(strings.TrimSpace(string(out)))
+	s.Require().NoError(parseErr)
+	return count
+}
+
+func (s *localChangesetTestSuite) TestChangesAreMadeInOneCommit() {
+	icon := test_commons.TestData[0]
+	s.Require().NoError(s.local.AddIconfile(s.ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+	commitsBefore := s.commitCount()
+
+	otherIcon := test_commons.TestData[1]
+	changes := []domain.BlobstoreChange{
+		{Kind: domain.BlobstoreChangeDeleteIconfile, IconName: icon.Name, Iconfile: icon.Iconfiles[0]},
+		{Kind: domain.BlobstoreChangeAddIconfile, IconName: icon.Name, Iconfile: icon.Iconfiles[1]},
+		{Kind: domain.BlobstoreChangeAddIconfile, IconName: otherIcon.Name, Iconfile: otherIcon.Iconfiles[0]},
+		{Kind: domain.BlobstoreChangeUpdateMetadata, IconName: otherIcon.Name, Metadata: domain.IconMetadata{ModifiedBy: "ux", Tags: otherIcon.Tags}},
+	}
+	s.NoError(s.local.ApplyChangeset(s.ctx, changes, "ux"))
+
+	s.Equal(commitsBefore+1, s.commitCount())
+
+	_, getErr := s.local.GetIconfile(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor)
+	s.Error(getErr)
+	content, getErr := s.local.GetIconfile(s.ctx, icon.Name, icon.Iconfiles[1].IconfileDescriptor)
+	s.NoError(getErr)
+	s.Equal(icon.Iconfiles[1].Content, content)
+	content, getErr = s.local.GetIconfile(s.ctx, otherIcon.Name, otherIcon.Iconfiles[0].IconfileDescriptor)
+	s.NoError(getErr)
+	s.Equal(otherIcon.Iconfiles[0].Content, content)
+	metadata, metadataErr := s.local.GetIconMetadata(s.ctx, otherIcon.Name)
+	s.NoError(metadataErr)
+	s.Equal(otherIcon.Tags, metadata.Tags)
+}
+
+func (s *localChangesetTestSuite) TestNothingIsCommittedWhenAChangeFails() {
+	icon := test_commons.TestData[0]
+	s.Require().NoError(s.local.AddIconfile(s.ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+	commitsBefore := s.commitCount()
+
+	changes := []domain.BlobstoreChange{
+		{Kind: domain.BlobstoreChangeAddIconfile, IconName: icon.Name, Iconfile: icon.Iconfiles[1]},
+		{Kind: domain.BlobstoreChangeDeleteIconfile, IconName: "no-such-icon", Iconfile: icon.Iconfiles[0]},
+	}
+	s.Error(s.local.ApplyChangeset(s.ctx, changes, "ux"))
+
+	s.Equal(commitsBefore, s.commitCount())
+	_, getErr := s.local.GetIconfile(s.ctx, icon.Name, icon.Iconfiles[1].IconfileDescriptor)
+	s.Error(getErr)
+}
@@ -0,0 +1,25 @@
+package git
+
+import (
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/repositories/blobstore/git"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIconfilePath(t *testing.T) {
+	iconfile := domain.IconfileDescriptor{Format: "svg", Size: "18px"}
+	path := git.NewGitFilePaths("").GetPathToIconfileInRepo("attach_money", iconfile)
+
+	iconName, parsed, err := git.ParseIconfilePath(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "attach_money", iconName)
+	assert.Equal(t, iconfile, parsed)
+
+	for _, notAnIconfile := range []string{"README.md", "svg/18px/attach_money.svg", "svg/18px/@18px.svg", "svg/24px/attach_money@18px.svg", "_meta/attach_money.json"} {
+		_, _, err = git.ParseIconfilePath(notAnIconfile)
+		assert.Error(t, err, notAnIconfile)
+	}
+}
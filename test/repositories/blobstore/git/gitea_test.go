@@ -0,0 +1,141 @@
+package git
+
+import (
+	"context"
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/config"
+	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/test/fakegitea"
+	"iconrepo/test/test_commons"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type giteaRepoTestSuite struct {
+	suite.Suite
+	ctx     context.Context
+	conf    config.Options
+	gitRepo *git.Gitea
+}
+
+func TestGiteaRepoTestSuite(t *testing.T) {
+	suite.Run(t, &giteaRepoTestSuite{ctx: context.Background()})
+}
+
+func (s *giteaRepoTestSuite) BeforeTest(suiteName string, testName string) {
+	s.conf = test_commons.CloneConfig(test_commons.GetTestConfig())
+	SetupGitlabTestCaseConfig(&s.conf, "gitea", testName)
+	var createClientErr error
+	s.gitRepo, createClientErr = NewFakeGiteaTestRepoClient(&s.conf)
+	s.Require().NoError(createClientErr)
+	s.Require().NoError(s.gitRepo.ResetRepository(s.ctx))
+}
+
+func (s *giteaRepoTestSuite) AfterTest(suiteName string, testName string) {
+	s.NoError(s.gitRepo.DeleteRepository(s.ctx))
+}
+
+func (s *giteaRepoTestSuite) fileInRepo(iconName string, iconfile domain.IconfileDescriptor) []byte {
+	return fakegitea.Shared().File(s.conf.GiteaRepository, s.conf.GiteaMainBranch, git.NewGitFilePaths("").GetPathToIconfileInRepo(iconName, iconfile))
+}
+
+func (s *giteaRepoTestSuite) commitCount() int {
+	return fakegitea.Shared().CommitCount(s.conf.GiteaRepository, s.conf.GiteaMainBranch)
+}
+
+func (s *giteaRepoTestSuite) TestAddIconfile() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	s.NoError(s.gitRepo.AddIconfile(s.ctx, icon.Name, iconfile, icon.ModifiedBy))
+
+	s.Equal(iconfile.Content, s.fileInRepo(icon.Name, iconfile.IconfileDescriptor))
+	content, err := s.gitRepo.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(err)
+	s.Equal(iconfile.Content, content)
+
+	stateID, err := s.gitRepo.GetStateID(s.ctx)
+	s.NoError(err)
+	commitID, err := s.gitRepo.GetVersionFor(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(err)
+	s.Equal(stateID, commitID)
+	meta, err := s.gitRepo.GetVersionMetadata(s.ctx, commitID)
+	s.NoError(err)
+	s.Contains(meta.Author, icon.ModifiedBy)
+}
+
+func (s *giteaRepoTestSuite) TestIconfilesAreListedWithoutMetadata() {
+	icon := test_commons.TestData[0]
+	for _, iconfile := range icon.Iconfiles {
+		s.Require().NoError(s.gitRepo.AddIconfile(s.ctx, icon.Name, iconfile, icon.ModifiedBy))
+	}
+	s.Require().NoError(s.gitRepo.UpdateIconMetadata(s.ctx, icon.Name, domain.IconMetadata{ModifiedBy: icon.ModifiedBy, Tags: []string{"tag1"}}, icon.ModifiedBy))
+
+	files, err := s.gitRepo.GetIconfiles(s.ctx)
+	s.NoError(err)
+	s.Len(files, len(icon.Iconfiles))
+
+	metadata, err := s.gitRepo.GetIconMetadata(s.ctx, icon.Name)
+	s.NoError(err)
+	s.Equal([]string{"tag1"}, metadata.Tags)
+}
+
+func (s *giteaRepoTestSuite) TestUnchangedMetadataIsNotCommitted() {
+	icon := test_commons.TestData[0]
+	metadata := domain.IconMetadata{ModifiedBy: icon.ModifiedBy, Tags: []string{"tag1"}}
+	s.Require().NoError(s.gitRepo.UpdateIconMetadata(s.ctx, icon.Name, metadata, icon.ModifiedBy))
+	metadata.Tags = []string{"tag1", "tag2"}
+	s.Require().NoError(s.gitRepo.UpdateIconMetadata(s.ctx, icon.Name, metadata, icon.ModifiedBy))
+	s.Require().NoError(s.gitRepo.UpdateIconMetadata(s.ctx, icon.Name, metadata, icon.ModifiedBy))
+
+	s.Equal(2, s.commitCount())
+}
+
+func (s *giteaRepoTestSuite) TestDeleteIconfile() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	s.Require().NoError(s.gitRepo.AddIconfile(s.ctx, icon.Name, iconfile, icon.ModifiedBy))
+
+	s.NoError(s.gitRepo.DeleteIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor, authn.LocalDomain.CreateUserID(icon.ModifiedBy)))
+
+	s.Nil(s.fileInRepo(icon.Name, iconfile.IconfileDescriptor))
+	commitID, err := s.gitRepo.GetVersionFor(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(err)
+	s.Equal("", commitID)
+}
+
+func (s *giteaRepoTestSuite) TestDeleteIconInOneCommit() {
+	icon := test_commons.TestData[0]
+	iconDesc := domain.IconDescriptor{IconAttributes: icon.IconAttributes}
+	for _, iconfile := range icon.Iconfiles {
+		s.Require().NoError(s.gitRepo.AddIconfile(s.ctx, icon.Name, iconfile, icon.ModifiedBy))
+		iconDesc.Iconfiles = append(iconDesc.Iconfiles, iconfile.IconfileDescriptor)
+	}
+	s.Require().NoError(s.gitRepo.UpdateIconMetadata(s.ctx, icon.Name, domain.IconMetadata{ModifiedBy: icon.ModifiedBy, Tags: []string{"tag1"}}, icon.ModifiedBy))
+	commitsBefore := s.commitCount()
+
+	s.NoError(s.gitRepo.DeleteIcon(s.ctx, iconDesc, authn.LocalDomain.CreateUserID(icon.ModifiedBy)))
+
+	s.Equal(commitsBefore+1, s.commitCount())
+	files, err := s.gitRepo.GetIconfiles(s.ctx)
+	s.NoError(err)
+	s.Empty(files)
+	_, err = s.gitRepo.GetIconMetadata(s.ctx, icon.Name)
+	s.ErrorIs(err, domain.ErrIconMetadataNotFound)
+}
+
+func (s *giteaRepoTestSuite) TestChangesetInOneCommit() {
+	icon := test_commons.TestData[0]
+	changes := []domain.BlobstoreChange{}
+	for _, iconfile := range icon.Iconfiles {
+		changes = append(changes, domain.BlobstoreChange{Kind: domain.BlobstoreChangeAddIconfile, IconName: icon.Name, Iconfile: iconfile})
+	}
+
+	s.NoError(s.gitRepo.ApplyChangeset(s.ctx, changes, icon.ModifiedBy))
+
+	s.Equal(1, s.commitCount())
+	for _, iconfile := range icon.Iconfiles {
+		s.Equal(iconfile.Content, s.fileInRepo(icon.Name, iconfile.IconfileDescriptor))
+	}
+}
@@ -0,0 +1,231 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/test/fakegitlab"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var testGitlabClientOptions = git.GitlabClientOptions{
+	RequestTimeout: time.Second,
+	MaxRetries:     3,
+	RetryDelay:     10 * time.Millisecond,
+	MaxRetryDelay:  2 * time.Second,
+}
+
+type gitlabClientTestSuite struct {
+	suite.Suite
+	ctx        context.Context
+	fakeGitlab *fakegitlab.FakeGitlab
+}
+
+func TestGitlabClientTestSuite(t *testing.T) {
+	suite.Run(t, &gitlabClientTestSuite{ctx: context.Background()})
+}
+
+func (s *gitlabClientTestSuite) BeforeTest(suiteName string, testName string) {
+	s.fakeGitlab = fakegitlab.New(fakegitlab.Namespace)
+}
+
+func (s *gitlabClientTestSuite) AfterTest(suiteName string, testName string) {
+	s.fakeGitlab.Close()
+}
+
+func (s *gitlabClientTestSuite) createClient(options git.GitlabClientOptions) *git.Gitlab {
+	gitRepo, err := git.NewGitlabRepositoryClient(s.ctx, s.fakeGitlab.URL(), fakegitlab.Namespace, defaultGitlabProjectPath, "main", fakegitlab.Token, false, options)
+	s.Require().NoError(err)
+	s.Require().NoError(gitRepo.CreateRepository(s.ctx))
+	return gitRepo
+}
+
+// addIconfile adds an iconfile to be read back and returns the number of requests sent so far
+func (s *gitlabClientTestSuite) addIconfile(gitRepo *git.Gitlab) int {
+	icon := test_commons.TestData[0]
+	s.Require().NoError(gitRepo.AddIconfile(s.ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+	return s.fakeGitlab.RequestCount()
+}
+
+func (s *gitlabClientTestSuite) getIconfile(gitRepo *git.Gitlab) error {
+	icon := test_commons.TestData[0]
+	content, err := gitRepo.GetIconfile(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor)
+	if err == nil {
+		s.Equal(icon.Iconfiles[0].Content, content)
+	}
+	return err
+}
+
+func (s *gitlabClientTestSuite) TestTransientErrorsAreRetried() {
+	gitRepo := s.createClient(testGitlabClientOptions)
+	s.fakeGitlab.InjectResponses(
+		fakegitlab.Response{StatusCode: http.StatusServiceUnavailable},
+		fakegitlab.Response{StatusCode: http.StatusTooManyRequests},
+	)
+
+	icon := test_commons.TestData[0]
+	s.NoError(gitRepo.AddIconfile(s.ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+	s.fakeGitlab.InjectResponses(
+		fakegitlab.Response{StatusCode: http.StatusServiceUnavailable},
+		fakegitlab.Response{StatusCode: http.StatusBadGateway},
+	)
+	s.NoError(s.getIconfile(gitRepo))
+}
+
+func (s *gitlabClientTestSuite) TestCommitIsNotRetriedWhenItMayHaveBeenCarriedOut() {
+	gitRepo := s.createClient(testGitlabClientOptions)
+	requestsBefore := s.addIconfile(gitRepo)
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{StatusCode: http.StatusBadGateway})
+
+	icon := test_commons.TestData[1]
+	s.Error(gitRepo.AddIconfile(s.ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+	s.Equal(requestsBefore+1, s.fakeGitlab.RequestCount())
+}
+
+func (s *gitlabClientTestSuite) TestCommitIsNotRetriedAfterTimeout() {
+	options := testGitlabClientOptions
+	options.RequestTimeout = 50 * time.Millisecond
+	gitRepo := s.createClient(options)
+	requestsBefore := s.addIconfile(gitRepo)
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{Delay: time.Second})
+
+	icon := test_commons.TestData[1]
+	s.Error(gitRepo.AddIconfile(s.ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+	s.Equal(requestsBefore+1, s.fakeGitlab.RequestCount())
+}
+
+func (s *gitlabClientTestSuite) TestGivesUpAfterMaxRetries() {
+	gitRepo := s.createClient(testGitlabClientOptions)
+	requestsBefore := s.addIconfile(gitRepo)
+	for i := 0; i <= testGitlabClientOptions.MaxRetries; i++ {
+		s.fakeGitlab.InjectResponses(fakegitlab.Response{StatusCode: http.StatusInternalServerError})
+	}
+
+	s.Error(s.getIconfile(gitRepo))
+	s.Equal(requestsBefore+testGitlabClientOptions.MaxRetries+1, s.fakeGitlab.RequestCount())
+}
+
+func (s *gitlabClientTestSuite) TestClientErrorsAreNotRetried() {
+	gitRepo := s.createClient(testGitlabClientOptions)
+	requestsBefore := s.addIconfile(gitRepo)
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{StatusCode: http.StatusForbidden})
+
+	s.Error(s.getIconfile(gitRepo))
+	s.Equal(requestsBefore+1, s.fakeGitlab.RequestCount())
+}
+
+func (s *gitlabClientTestSuite) TestRetryAfterIsRespected() {
+	gitRepo := s.createClient(testGitlabClientOptions)
+	s.addIconfile(gitRepo)
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"1"}}})
+
+	start := time.Now()
+	s.NoError(s.getIconfile(gitRepo))
+	s.GreaterOrEqual(time.Since(start), time.Second)
+}
+
+func (s *gitlabClientTestSuite) TestRetryAfterTooLongIsNotWaitedFor() {
+	gitRepo := s.createClient(testGitlabClientOptions)
+	requestsBefore := s.addIconfile(gitRepo)
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"120"}}})
+
+	start := time.Now()
+	s.Error(s.getIconfile(gitRepo))
+	s.Less(time.Since(start), time.Second)
+	s.Equal(requestsBefore+1, s.fakeGitlab.RequestCount())
+}
+
+func (s *gitlabClientTestSuite) TestSlowRequestTimesOutAndIsRetried() {
+	options := testGitlabClientOptions
+	options.RequestTimeout = 50 * time.Millisecond
+	gitRepo := s.createClient(options)
+	requestsBefore := s.addIconfile(gitRepo)
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{Delay: time.Second})
+
+	start := time.Now()
+	s.NoError(s.getIconfile(gitRepo))
+	s.Less(time.Since(start), time.Second)
+	s.Equal(requestsBefore+2, s.fakeGitlab.RequestCount())
+}
+
+func (s *gitlabClientTestSuite) TestCircuitBreakerOpensAfterConsecutiveFailures() {
+	options := testGitlabClientOptions
+	options.MaxRetries = 0
+	options.BreakerThreshold = 2
+	options.BreakerCooldown = 100 * time.Millisecond
+	gitRepo := s.createClient(options)
+	requestsBefore := s.addIconfile(gitRepo)
+	s.fakeGitlab.InjectResponses(
+		fakegitlab.Response{StatusCode: http.StatusServiceUnavailable},
+		fakegitlab.Response{StatusCode: http.StatusServiceUnavailable},
+	)
+
+	s.Error(s.getIconfile(gitRepo))
+	s.Error(s.getIconfile(gitRepo))
+	s.ErrorIs(s.getIconfile(gitRepo), git.ErrGitlabUnavailable)
+	s.Equal(requestsBefore+2, s.fakeGitlab.RequestCount())
+
+	time.Sleep(options.BreakerCooldown)
+	s.NoError(s.getIconfile(gitRepo))
+	s.NoError(s.getIconfile(gitRepo))
+}
+
+// openCircuitBreaker has the breaker opened by a failure and waits for the cooldown to be over
+func (s *gitlabClientTestSuite) openCircuitBreaker(gitRepo *git.Gitlab, cooldown time.Duration) {
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{StatusCode: http.StatusServiceUnavailable})
+	s.Error(s.getIconfile(gitRepo))
+	s.ErrorIs(s.getIconfile(gitRepo), git.ErrGitlabUnavailable)
+	time.Sleep(cooldown)
+}
+
+func (s *gitlabClientTestSuite) breakerOptions() git.GitlabClientOptions {
+	options := testGitlabClientOptions
+	options.MaxRetries = 0
+	options.BreakerThreshold = 1
+	options.BreakerCooldown = 50 * time.Millisecond
+	return options
+}
+
+func (s *gitlabClientTestSuite) TestRateLimitedProbeLetsNextRequestThrough() {
+	options := s.breakerOptions()
+	gitRepo := s.createClient(options)
+	s.addIconfile(gitRepo)
+	s.openCircuitBreaker(gitRepo, options.BreakerCooldown)
+
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{StatusCode: http.StatusTooManyRequests})
+	s.Error(s.getIconfile(gitRepo))
+	s.NoError(s.getIconfile(gitRepo))
+}
+
+func (s *gitlabClientTestSuite) TestProbeGivenUpByCallerLetsNextRequestThrough() {
+	options := s.breakerOptions()
+	gitRepo := s.createClient(options)
+	s.addIconfile(gitRepo)
+	s.openCircuitBreaker(gitRepo, options.BreakerCooldown)
+
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{Delay: time.Second})
+	ctx, cancel := context.WithTimeout(s.ctx, 20*time.Millisecond)
+	defer cancel()
+	icon := test_commons.TestData[0]
+	_, err := gitRepo.GetIconfile(ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor)
+	s.Error(err)
+	s.NoError(s.getIconfile(gitRepo))
+}
+
+func (s *gitlabClientTestSuite) TestFailedProbeOpensCircuitBreakerAgain() {
+	options := s.breakerOptions()
+	gitRepo := s.createClient(options)
+	s.addIconfile(gitRepo)
+	s.openCircuitBreaker(gitRepo, options.BreakerCooldown)
+
+	s.fakeGitlab.InjectResponses(fakegitlab.Response{StatusCode: http.StatusServiceUnavailable})
+	s.Error(s.getIconfile(gitRepo))
+	s.ErrorIs(s.getIconfile(gitRepo), git.ErrGitlabUnavailable)
+	time.Sleep(options.BreakerCooldown)
+	s.NoError(s.getIconfile(gitRepo))
+}
@@ -0,0 +1,179 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/test/fakegitlab"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type gitlabMergeRequestTestSuite struct {
+	suite.Suite
+	ctx        context.Context
+	fakeGitlab *fakegitlab.FakeGitlab
+	gitRepo    *git.Gitlab
+}
+
+func TestGitlabMergeRequestTestSuite(t *testing.T) {
+	suite.Run(t, &gitlabMergeRequestTestSuite{ctx: context.Background()})
+}
+
+func (s *gitlabMergeRequestTestSuite) BeforeTest(suiteName string, testName string) {
+	s.fakeGitlab = fakegitlab.New(fakegitlab.Namespace)
+	var err error
+	s.gitRepo, err = git.NewGitlabRepositoryClient(s.ctx, s.fakeGitlab.URL(), fakegitlab.Namespace, defaultGitlabProjectPath, "main", fakegitlab.Token, true, git.DefaultGitlabClientOptions)
+	s.Require().NoError(err)
+	s.Require().NoError(s.gitRepo.CreateRepository(s.ctx))
+}
+
+func (s *gitlabMergeRequestTestSuite) AfterTest(suiteName string, testName string) {
+	s.fakeGitlab.Close()
+}
+
+func (s *gitlabMergeRequestTestSuite) pathInRepo(iconName string, iconfile domain.IconfileDescriptor) string {
+	return git.NewGitFilePaths("").GetPathToIconfileInRepo(iconName, iconfile)
+}
+
+func (s *gitlabMergeRequestTestSuite) TestAddIconfileOpensMergeRequest() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	branch := git.ChangeBranch(icon.Name, iconfile.IconfileDescriptor)
+
+	err := s.gitRepo.AddIconfile(s.ctx, icon.Name, iconfile, icon.ModifiedBy)
+	s.NoError(err)
+
+	filePath := s.pathInRepo(icon.Name, iconfile.IconfileDescriptor)
+	s.Nil(s.fakeGitlab.File(defaultGitlabProjectPath, "main", filePath))
+	s.Equal(iconfile.Content, s.fakeGitlab.File(defaultGitlabProjectPath, branch, filePath))
+
+	mergeRequests := s.fakeGitlab.MergeRequests(defaultGitlabProjectPath)
+	s.Equal(1, len(mergeRequests))
+	s.Equal("opened", mergeRequests[0].State)
+	s.Equal(branch, mergeRequests[0].SourceBranch)
+	s.Equal("main", mergeRequests[0].TargetBranch)
+	s.True(mergeRequests[0].RemoveSourceBranch)
+
+	content, getErr := s.gitRepo.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(getErr)
+	s.Equal(iconfile.Content, content)
+}
+
+func (s *gitlabMergeRequestTestSuite) TestMergedIconfileIsOnMainBranch() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	branch := git.ChangeBranch(icon.Name, iconfile.IconfileDescriptor)
+
+	s.NoError(s.gitRepo.AddIconfile(s.ctx, icon.Name, iconfile, icon.ModifiedBy))
+	s.NoError(s.fakeGitlab.Merge(defaultGitlabProjectPath, branch))
+
+	s.False(s.fakeGitlab.HasBranch(defaultGitlabProjectPath, branch))
+	s.Equal(iconfile.Content, s.fakeGitlab.File(defaultGitlabProjectPath, "main", s.pathInRepo(icon.Name, iconfile.IconfileDescriptor)))
+	content, getErr := s.gitRepo.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(getErr)
+	s.Equal(iconfile.Content, content)
+
+	// Once merged, the iconfile is deleted from the main branch
+	published := iconfile.IconfileDescriptor
+	published.ReviewStatus = domain.ReviewStatusPublished
+	s.NoError(s.gitRepo.DeleteIconfile(s.ctx, icon.Name, published, authn.LocalDomain.CreateUserID(icon.ModifiedBy)))
+	s.Nil(s.fakeGitlab.File(defaultGitlabProjectPath, "main", s.pathInRepo(icon.Name, iconfile.IconfileDescriptor)))
+}
+
+func (s *gitlabMergeRequestTestSuite) TestDeletingProposedIconfileClosesMergeRequest() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	branch := git.ChangeBranch(icon.Name, iconfile.IconfileDescriptor)
+
+	s.NoError(s.gitRepo.AddIconfile(s.ctx, icon.Name, iconfile, icon.ModifiedBy))
+
+	proposed := iconfile.IconfileDescriptor
+	proposed.ReviewStatus = domain.ReviewStatusInReview
+	s.NoError(s.gitRepo.DeleteIconfile(s.ctx, icon.Name, proposed, authn.LocalDomain.CreateUserID(icon.ModifiedBy)))
+
+	s.False(s.fakeGitlab.HasBranch(defaultGitlabProjectPath, branch))
+	mergeRequests := s.fakeGitlab.MergeRequests(defaultGitlabProjectPath)
+	s.Equal(1, len(mergeRequests))
+	s.Equal("closed", mergeRequests[0].State)
+
+	// The same iconfile can be proposed again
+	s.NoError(s.gitRepo.AddIconfile(s.ctx, icon.Name, iconfile, icon.ModifiedBy))
+	s.True(s.fakeGitlab.HasBranch(defaultGitlabProjectPath, branch))
+}
+
+func (s *gitlabMergeRequestTestSuite) TestDeleteIconDiscardsProposedIconfiles() {
+	icon := test_commons.TestData[0]
+	publishedIconfile := icon.Iconfiles[0]
+	proposedIconfile := icon.Iconfiles[1]
+
+	s.NoError(s.gitRepo.AddIconfile(s.ctx, icon.Name, publishedIconfile, icon.ModifiedBy))
+	s.NoError(s.fakeGitlab.Merge(defaultGitlabProjectPath, git.ChangeBranch(icon.Name, publishedIconfile.IconfileDescriptor)))
+	s.NoError(s.gitRepo.AddIconfile(s.ctx, icon.Name, proposedIconfile, icon.ModifiedBy))
+
+	published := publishedIconfile.IconfileDescriptor
+	proposed := proposedIconfile.IconfileDescriptor
+	proposed.ReviewStatus = domain.ReviewStatusInReview
+	iconDesc := domain.IconDescriptor{
+		IconAttributes: icon.IconAttributes,
+		Iconfiles:      []domain.IconfileDescriptor{published, proposed},
+	}
+	s.NoError(s.gitRepo.DeleteIcon(s.ctx, iconDesc, authn.LocalDomain.CreateUserID(icon.ModifiedBy)))
+
+	s.Nil(s.fakeGitlab.File(defaultGitlabProjectPath, "main", s.pathInRepo(icon.Name, published)))
+	s.False(s.fakeGitlab.HasBranch(defaultGitlabProjectPath, git.ChangeBranch(icon.Name, proposed)))
+	mergeRequests := s.fakeGitlab.MergeRequests(defaultGitlabProjectPath)
+	s.Equal(2, len(mergeRequests))
+	s.Equal("merged", mergeRequests[0].State)
+	s.Equal("closed", mergeRequests[1].State)
+}
+
+func (s *gitlabMergeRequestTestSuite) TestParseChangeBranch() {
+	iconfile := domain.IconfileDescriptor{Format: "svg", Size: "18px"}
+	iconName, parsed, err := s.gitRepo.ParseChangeBranch(git.ChangeBranch("attach_money", iconfile))
+	s.NoError(err)
+	s.Equal("attach_money", iconName)
+	s.Equal(iconfile, parsed)
+
+	_, _, err = s.gitRepo.ParseChangeBranch("feature/something-else")
+	s.ErrorIs(err, domain.ErrUnknownChangeBranch)
+}
+
+func (s *gitlabMergeRequestTestSuite) TestCommitsToMainBranchWithoutMergeRequests() {
+	directRepo, err := git.NewGitlabRepositoryClient(s.ctx, s.fakeGitlab.URL(), fakegitlab.Namespace, defaultGitlabProjectPath, "main", fakegitlab.Token, false, git.DefaultGitlabClientOptions)
+	s.Require().NoError(err)
+	s.False(directRepo.ReviewsInMergeRequests())
+
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	s.NoError(directRepo.AddIconfile(s.ctx, icon.Name, iconfile, icon.ModifiedBy))
+
+	s.Equal(iconfile.Content, s.fakeGitlab.File(defaultGitlabProjectPath, "main", s.pathInRepo(icon.Name, iconfile.IconfileDescriptor)))
+	s.Empty(s.fakeGitlab.MergeRequests(defaultGitlabProjectPath))
+}
+
+func (s *gitlabMergeRequestTestSuite) TestIconMetadataIsCommittedToMainBranch() {
+	icon := test_commons.TestData[0]
+	metadataPath := git.IconMetadataDir + "/" + icon.Name + ".json"
+
+	s.NoError(s.gitRepo.UpdateIconMetadata(s.ctx, icon.Name, domain.IconMetadata{ModifiedBy: icon.ModifiedBy, Tags: []string{"cancel"}}, icon.ModifiedBy))
+	s.NotNil(s.fakeGitlab.File(defaultGitlabProjectPath, "main", metadataPath))
+	s.Empty(s.fakeGitlab.MergeRequests(defaultGitlabProjectPath))
+
+	s.NoError(s.gitRepo.UpdateIconMetadata(s.ctx, icon.Name, domain.IconMetadata{ModifiedBy: icon.ModifiedBy, Tags: []string{"cancel", "close"}}, icon.ModifiedBy))
+	metadata, getErr := s.gitRepo.GetIconMetadata(s.ctx, icon.Name)
+	s.NoError(getErr)
+	s.Equal([]string{"cancel", "close"}, metadata.Tags)
+
+	iconfiles, listErr := s.gitRepo.GetIconfiles(s.ctx)
+	s.NoError(listErr)
+	s.Empty(iconfiles)
+
+	iconDesc := domain.IconDescriptor{IconAttributes: icon.IconAttributes, Iconfiles: []domain.IconfileDescriptor{}}
+	s.NoError(s.gitRepo.DeleteIcon(s.ctx, iconDesc, authn.LocalDomain.CreateUserID(icon.ModifiedBy)))
+	s.Nil(s.fakeGitlab.File(defaultGitlabProjectPath, "main", metadataPath))
+}
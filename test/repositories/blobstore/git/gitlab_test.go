@@ -2,9 +2,10 @@ package git
 
 import (
 	"context"
+	"iconrepo/internal/config"
 	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/test/fakegitlab"
 	"iconrepo/test/test_commons"
-	"os"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -14,21 +15,19 @@ type gitlabRepoTestSuite struct {
 	suite.Suite
 	ctx     context.Context
 	t       *testing.T
+	conf    config.Options
 	gitRepo *git.Gitlab
 }
 
 func TestGitlabRepoTestSuite(t *testing.T) {
-	if len(os.Getenv("LOCAL_GIT_ONLY")) > 0 {
-		return
-	}
 	suite.Run(t, &gitlabRepoTestSuite{ctx: context.Background(), t: t})
 }
 
 func (testSuite *gitlabRepoTestSuite) BeforeTest(suiteName string, testName string) {
 	defaultTestConfig := test_commons.GetTestConfig()
-	conf := test_commons.CloneConfig(defaultTestConfig)
+	testSuite.conf = test_commons.CloneConfig(defaultTestConfig)
 	var createClientErr error
-	testSuite.gitRepo, createClientErr = NewGitlabTestRepoClient(&conf)
+	testSuite.gitRepo, createClientErr = NewFakeGitlabTestRepoClient(&testSuite.conf)
 	if createClientErr != nil {
 		testSuite.FailNow("", "%v", createClientErr)
 	}
@@ -49,10 +48,11 @@ func (testSuite *gitlabRepoTestSuite) TestAddIconfile() {
 	err = testSuite.gitRepo.AddIconfile(testSuite.ctx, icon.Name, iconfile, icon.ModifiedBy)
 	testSuite.NoError(err)
 
-	// var sha1 string
-	// sha1, err = testSuite.GetStateID()
+	var sha1 string
+	sha1, err = testSuite.gitRepo.GetStateID(testSuite.ctx)
 	testSuite.NoError(err)
-	// testSuite.Equal(len("8e9b80b5155dea01e5175bc819bbe364dbc07a66"), len(sha1))
-	// testSuite.assertGitCleanStatus()
-	// testSuite.assertFileInRepo(icon.Name, iconfile)
+	testSuite.Equal(len("8e9b80b5155dea01e5175bc819bbe364dbc07a66"), len(sha1))
+
+	filePath := git.NewGitFilePaths("").GetPathToIconfileInRepo(icon.Name, iconfile.IconfileDescriptor)
+	testSuite.Equal(iconfile.Content, fakegitlab.Shared().File(testSuite.conf.GitlabProjectPath, testSuite.conf.GitlabMainBranch, filePath))
 }
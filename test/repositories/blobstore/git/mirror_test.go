@@ -0,0 +1,224 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var testMirrorOptions = git.MirrorOptions{
+	RetryDelay:    10 * time.Millisecond,
+	MaxRetryDelay: 50 * time.Millisecond,
+}
+
+type gitMirrorTestSuite struct {
+	suite.Suite
+	ctx     context.Context
+	baseDir string
+	local   *git.Local
+}
+
+func TestGitMirrorTestSuite(t *testing.T) {
+	suite.Run(t, &gitMirrorTestSuite{ctx: context.Background()})
+}
+
+func (s *gitMirrorTestSuite) BeforeTest(suiteName string, testName string) {
+	s.baseDir = s.T().TempDir()
+	local := git.NewLocalGitRepository(filepath.Join(s.baseDir, "local"))
+	s.local = &local
+	s.Require().NoError(s.local.CreateRepository(s.ctx))
+}
+
+func (s *gitMirrorTestSuite) AfterTest(suiteName string, testName string) {
+	if s.local.Mirror != nil {
+		s.local.Mirror.Stop()
+	}
+}
+
+func (s *gitMirrorTestSuite) git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+func (s *gitMirrorTestSuite) createBareRepo(name string) string {
+	location := filepath.Join(s.baseDir, name)
+	s.Require().NoError(os.MkdirAll(location, 0700))
+	out, err := s.git(location, "init", "--bare")
+	s.Require().NoError(err, out)
+	return location
+}
+
+func (s *gitMirrorTestSuite) startMirror(remotes []string, options git.MirrorOptions) {
+	s.local.Mirror = git.NewMirror(s.local.Location, remotes, options)
+	s.local.Mirror.Start()
+}
+
+func (s *gitMirrorTestSuite) localHead() string {
+	head, err := s.git(s.local.Location, "rev-parse", "HEAD")
+	s.Require().NoError(err, head)
+	return head
+}
+
+func (s *gitMirrorTestSuite) remoteHead(remote string) string {
+	branch, branchErr := s.git(s.local.Location, "rev-parse", "--abbrev-ref", "HEAD")
+	s.Require().NoError(branchErr, branch)
+	head, err := s.git(remote, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	if err != nil {
+		return ""
+	}
+	return head
+}
+
+func (s *gitMirrorTestSuite) addIconfile(iconIndex int) {
+	icon := test_commons.TestData[iconIndex]
+	s.Require().NoError(s.local.AddIconfile(s.ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+}
+
+func (s *gitMirrorTestSuite) TestCommitsArePushedToEachRemote() {
+	remote1 := s.createBareRepo("remote1.git")
+	remote2 := s.createBareRepo("remote2.git")
+	s.startMirror([]string{remote1, remote2}, testMirrorOptions)
+
+	s.addIconfile(0)
+	s.addIconfile(1)
+
+	head := s.localHead()
+	s.Eventually(func() bool { return s.remoteHead(remote1) == head && s.remoteHead(remote2) == head }, 5*time.Second, 10*time.Millisecond)
+	s.Eventually(func() bool {
+		for _, status := range s.local.MirrorStatus() {
+			if status.Pending || status.LastPushedCommit != head {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	statuses := s.local.MirrorStatus()
+	s.Equal(2, len(statuses))
+	s.Equal(remote1, statuses[0].Remote)
+	s.Equal(remote2, statuses[1].Remote)
+	for _, status := range statuses {
+		s.NotNil(status.LastSuccess)
+		s.Equal("", status.LastError)
+		s.Equal(0, status.ConsecutiveFailures)
+	}
+}
+
+func (s *gitMirrorTestSuite) TestFailedPushIsRetried() {
+	remote := filepath.Join(s.baseDir, "not-yet-there.git")
+	s.startMirror([]string{remote}, testMirrorOptions)
+
+	s.addIconfile(0)
+
+	s.Eventually(func() bool { return s.local.MirrorStatus()[0].ConsecutiveFailures > 1 }, 5*time.Second, 10*time.Millisecond)
+	status := s.local.MirrorStatus()[0]
+	s.True(status.Pending)
+	s.NotEqual("", status.LastError)
+	s.Nil(status.LastSuccess)
+
+	s.createBareRepo("not-yet-there.git")
+
+	head := s.localHead()
+	s.Eventually(func() bool { return s.remoteHead(remote) == head }, 5*time.Second, 10*time.Millisecond)
+	s.Eventually(func() bool { return s.local.MirrorStatus()[0].ConsecutiveFailures == 0 }, 5*time.Second, 10*time.Millisecond)
+	s.Equal("", s.local.MirrorStatus()[0].LastError)
+}
+
+func (s *gitMirrorTestSuite) TestDivergedRemoteIsNotOverwritten() {
+	remote := s.createBareRepo("remote.git")
+	s.addIconfile(0)
+	out, pushErr := s.git(s.local.Location, "push", remote, "refs/heads/*:refs/heads/*")
+	s.Require().NoError(pushErr, out)
+	remoteHeadBefore := s.remoteHead(remote)
+
+	// The local repository is lost and created anew
+	s.Require().NoError(s.local.ResetRepository(s.ctx))
+	s.startMirror([]string{remote}, testMirrorOptions)
+	s.addIconfile(1)
+
+	s.Eventually(func() bool { return s.local.MirrorStatus()[0].ConsecutiveFailures > 0 }, 5*time.Second, 10*time.Millisecond)
+	s.Equal(remoteHeadBefore, s.remoteHead(remote))
+}
+
+func (s *gitMirrorTestSuite) TestScheduledPushes() {
+	remote := s.createBareRepo("remote.git")
+	options := testMirrorOptions
+	options.Interval = 50 * time.Millisecond
+	s.startMirror([]string{remote}, options)
+
+	s.addIconfile(0)
+
+	head := s.localHead()
+	s.Eventually(func() bool { return s.remoteHead(remote) == head }, 5*time.Second, 10*time.Millisecond)
+}
+
+// hangingRemote returns the URL, with credentials, of a remote which never answers, and a channel
+// receiving a value each time the remote is pushed to
+func (s *gitMirrorTestSuite) hangingRemote() (string, <-chan struct{}) {
+	requests := make(chan struct{}, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		<-r.Context().Done()
+	}))
+	s.T().Cleanup(server.Close)
+	remoteURL, parseErr := url.Parse(server.URL + "/remote.git")
+	s.Require().NoError(parseErr)
+	remoteURL.User = url.UserPassword("mirror", "s3cr3t")
+	return remoteURL.String(), requests
+}
+
+func (s *gitMirrorTestSuite) TestPushToHangingRemoteTimesOut() {
+	remote, _ := s.hangingRemote()
+	options := testMirrorOptions
+	options.PushTimeout = 50 * time.Millisecond
+	s.startMirror([]string{remote}, options)
+
+	s.addIconfile(0)
+
+	s.Eventually(func() bool { return s.local.MirrorStatus()[0].ConsecutiveFailures > 1 }, 5*time.Second, 10*time.Millisecond)
+	status := s.local.MirrorStatus()[0]
+	s.True(status.Pending)
+	s.NotEqual("", status.LastError)
+	s.NotContains(status.LastError, "s3cr3t")
+	s.NotContains(status.Remote, "s3cr3t")
+}
+
+func (s *gitMirrorTestSuite) TestStopInterruptsPushInProgress() {
+	remote, requests := s.hangingRemote()
+	options := testMirrorOptions
+	options.PushTimeout = time.Hour
+	s.startMirror([]string{remote}, options)
+
+	s.addIconfile(0)
+	select {
+	case <-requests:
+	case <-time.After(5 * time.Second):
+		s.FailNow("the remote wasn't pushed to")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.local.Mirror.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		s.FailNow("the mirror didn't stop")
+	}
+	s.True(s.local.MirrorStatus()[0].Pending)
+}
@@ -6,6 +6,8 @@ import (
 	"iconrepo/internal/config"
 	"iconrepo/internal/logging"
 	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/test/fakegitea"
+	"iconrepo/test/fakegitlab"
 	"os"
 	"regexp"
 )
@@ -43,8 +45,6 @@ func SetupGitlabTestCaseConfig(conf *config.Options, testSequenceId string, test
 }
 
 func NewGitlabTestRepoClient(conf *config.Options) (*git.Gitlab, error) {
-	conf.LocalGitRepo = "" // to guide the test app on which git provider to use
-
 	conf.GitlabNamespacePath = "testing-with-repositories"
 
 	var apiTokenErr error
@@ -53,12 +53,29 @@ func NewGitlabTestRepoClient(conf *config.Options) (*git.Gitlab, error) {
 		return nil, apiTokenErr
 	}
 
+	return newGitlabTestRepoClient(conf)
+}
+
+// NewFakeGitlabTestRepoClient connects to the fake GitLab shared by the tests of the process, so that no GitLab account is needed
+func NewFakeGitlabTestRepoClient(conf *config.Options) (*git.Gitlab, error) {
+	conf.GitlabNamespacePath = fakegitlab.Namespace
+	conf.GitlabAPIURL = fakegitlab.Shared().URL()
+	conf.GitlabAccessToken = fakegitlab.Token
+	return newGitlabTestRepoClient(conf)
+}
+
+func newGitlabTestRepoClient(conf *config.Options) (*git.Gitlab, error) {
+	conf.LocalGitRepo = "" // to guide the test app on which git provider to use
+
 	gitlab, err := git.NewGitlabRepositoryClient(
 		logging.CreateUnitLogger(logging.Get(), "test gitlab clienty").WithContext(context.Background()),
+		conf.GitlabAPIURL,
 		conf.GitlabNamespacePath,
 		conf.GitlabProjectPath,
 		conf.GitlabMainBranch,
 		conf.GitlabAccessToken,
+		conf.GitlabMergeRequests,
+		git.DefaultGitlabClientOptions,
 	)
 
 	if err != nil {
@@ -68,6 +85,18 @@ func NewGitlabTestRepoClient(conf *config.Options) (*git.Gitlab, error) {
 	return gitlab, nil
 }
 
+// NewFakeGiteaTestRepoClient connects to a repository of the fake Gitea shared by the tests of the process,
+// named after the GitLab project set up for the test case
+func NewFakeGiteaTestRepoClient(conf *config.Options) (*git.Gitea, error) {
+	conf.GitlabNamespacePath = ""
+	conf.BlobstoreType = config.BlobstoreTypeGitea
+	conf.GiteaAPIURL = fakegitea.Shared().URL()
+	conf.GiteaOwner = fakegitea.Owner
+	conf.GiteaRepository = conf.GitlabProjectPath
+	conf.GiteaAccessToken = fakegitea.Token
+	return git.NewGiteaRepositoryClient(conf.GiteaAPIURL, conf.GiteaOwner, conf.GiteaRepository, conf.GiteaMainBranch, conf.GiteaAccessToken)
+}
+
 type RepositoryResetter interface {
 	ResetRepository() error
 }
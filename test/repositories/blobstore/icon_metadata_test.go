@@ -0,0 +1,87 @@
+package blobstore
+
+import (
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/test/test_commons"
+)
+
+func (s *BlobstoreTestSuite) TestStoresIconMetadata() {
+	icon := test_commons.TestData[0]
+	s.NoError(s.RepoController.AddIconfile(s.Ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+
+	_, getErr := s.RepoController.GetIconMetadata(s.Ctx, icon.Name)
+	s.ErrorIs(getErr, domain.ErrIconMetadataNotFound)
+
+	metadata := domain.IconMetadata{ModifiedBy: icon.ModifiedBy, Tags: []string{"used-in-marvinjs", "cancel"}}
+	s.NoError(s.RepoController.UpdateIconMetadata(s.Ctx, icon.Name, metadata, icon.ModifiedBy))
+	stored, getErr := s.RepoController.GetIconMetadata(s.Ctx, icon.Name)
+	s.NoError(getErr)
+	s.Equal(domain.IconMetadata{ModifiedBy: icon.ModifiedBy, Tags: []string{"cancel", "used-in-marvinjs"}}, stored)
+	s.AssertBlobstoreCleanStatus()
+
+	// Rewriting the same metadata is a no-op
+	stateBefore, stateErr := s.GetStateID()
+	s.NoError(stateErr)
+	s.NoError(s.RepoController.UpdateIconMetadata(s.Ctx, icon.Name, metadata, icon.ModifiedBy))
+	stateAfter, stateErr := s.GetStateID()
+	s.NoError(stateErr)
+	s.Equal(stateBefore, stateAfter)
+
+	metadata.Tags = []string{}
+	s.NoError(s.RepoController.UpdateIconMetadata(s.Ctx, icon.Name, metadata, icon.ModifiedBy))
+	stored, getErr = s.RepoController.GetIconMetadata(s.Ctx, icon.Name)
+	s.NoError(getErr)
+	s.Empty(stored.Tags)
+}
+
+func (s *BlobstoreTestSuite) TestIconMetadataIsNotListedAsIconfile() {
+	icon := test_commons.TestData[0]
+	s.NoError(s.RepoController.AddIconfile(s.Ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+	s.NoError(s.RepoController.UpdateIconMetadata(s.Ctx, icon.Name, domain.IconMetadata{ModifiedBy: icon.ModifiedBy, Tags: []string{"cancel"}}, icon.ModifiedBy))
+
+	files, listErr := s.RepoController.GetIconfiles(s.Ctx)
+	s.NoError(listErr)
+	s.Equal(1, len(files))
+}
+
+func (s *BlobstoreTestSuite) TestDeleteIconDeletesIconMetadata() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	s.NoError(s.RepoController.AddIconfile(s.Ctx, icon.Name, iconfile, icon.ModifiedBy))
+	s.NoError(s.RepoController.UpdateIconMetadata(s.Ctx, icon.Name, domain.IconMetadata{ModifiedBy: icon.ModifiedBy, Tags: []string{"cancel"}}, icon.ModifiedBy))
+
+	iconDesc := domain.IconDescriptor{
+		IconAttributes: icon.IconAttributes,
+		Iconfiles:      []domain.IconfileDescriptor{iconfile.IconfileDescriptor},
+	}
+	s.NoError(s.RepoController.DeleteIcon(s.Ctx, iconDesc, authn.LocalDomain.CreateUserID(icon.ModifiedBy)))
+
+	_, getErr := s.RepoController.GetIconMetadata(s.Ctx, icon.Name)
+	s.ErrorIs(getErr, domain.ErrIconMetadataNotFound)
+	s.AssertBlobstoreCleanStatus()
+}
+
+func (s *BlobstoreTestSuite) TestStoresDescriptionAndReviewStatusInIconMetadata() {
+	icon := test_commons.TestData[0]
+	s.NoError(s.RepoController.AddIconfile(s.Ctx, icon.Name, icon.Iconfiles[0], icon.ModifiedBy))
+
+	draft := icon.Iconfiles[0].IconfileDescriptor
+	draft.ReviewStatus = domain.ReviewStatusDraft
+	published := domain.IconfileDescriptor{Format: "png", Size: "18px"}
+	metadata := domain.IconMetadata{
+		ModifiedBy:  icon.ModifiedBy,
+		Description: "a shopping cart",
+		Tags:        []string{},
+		Iconfiles:   []domain.IconfileDescriptor{published, draft},
+	}
+	s.NoError(s.RepoController.UpdateIconMetadata(s.Ctx, icon.Name, metadata, icon.ModifiedBy))
+
+	stored, getErr := s.RepoController.GetIconMetadata(s.Ctx, icon.Name)
+	s.NoError(getErr)
+	s.Equal("a shopping cart", stored.Description)
+	s.ElementsMatch(metadata.Iconfiles, stored.Iconfiles)
+	s.Equal(domain.ReviewStatusDraft, stored.ReviewStatusOf(draft))
+	s.Equal(domain.ReviewStatusPublished, stored.ReviewStatusOf(published))
+	s.Equal(domain.ReviewStatusPublished, stored.ReviewStatusOf(domain.IconfileDescriptor{Format: "svg", Size: "18px"}))
+}
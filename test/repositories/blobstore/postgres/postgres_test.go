@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/repositories/blobstore/postgres"
+	"iconrepo/internal/repositories/indexing/pgdb"
+	"iconrepo/test/test_commons"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/stretchr/testify/suite"
+)
+
+var errSideEffectTest = errors.New("error to test side effect")
+
+// transactionTestSuite checks that the Postgres blobstore writes in the transaction of a Postgres index
+type transactionTestSuite struct {
+	suite.Suite
+	ctx       context.Context
+	index     pgdb.PgRepository
+	blobstore *postgres.Postgres
+}
+
+func TestTransactionTestSuite(t *testing.T) {
+	suite.Run(t, &transactionTestSuite{ctx: context.Background()})
+}
+
+func (s *transactionTestSuite) SetupSuite() {
+	conf := test_commons.CloneConfig(test_commons.GetTestConfig())
+	conf.DBSchemaName = "itest_pg_blobstore"
+	connection, connErr := pgdb.NewDBConnection(conf)
+	s.Require().NoError(connErr)
+	_, schemaErr := pgdb.OpenSchema(conf, connection)
+	s.Require().NoError(schemaErr)
+	s.index = pgdb.NewPgRepository(connection)
+	s.blobstore = postgres.NewPostgresBlobstore(connection.Pool)
+}
+
+func (s *transactionTestSuite) TearDownSuite() {
+	s.index.Close()
+}
+
+func (s *transactionTestSuite) BeforeTest(suiteName, testName string) {
+	s.Require().NoError(s.blobstore.ResetRepository(s.ctx))
+	for _, table := range []string{"icon_to_tags", "tag", "icon_file", "icon"} {
+		_, err := s.index.Conn.Pool.Exec("DELETE FROM " + table)
+		s.Require().NoError(err)
+	}
+}
+
+func (s *transactionTestSuite) TestIconfileIsCommittedWithIndex() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+
+	err := s.index.CreateIcon(s.ctx, icon.Name, iconfile.IconfileDescriptor, icon.ModifiedBy, func(ctx context.Context) error {
+		return s.blobstore.AddIconfile(ctx, icon.Name, iconfile, icon.ModifiedBy)
+	})
+	s.NoError(err)
+
+	content, getErr := s.blobstore.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(getErr)
+	s.Equal(iconfile.Content, content)
+	_, describeErr := s.index.DescribeIcon(s.ctx, icon.Name)
+	s.NoError(describeErr)
+}
+
+func (s *transactionTestSuite) TestIconfileIsRolledBackWithIndex() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+
+	err := s.index.CreateIcon(s.ctx, icon.Name, iconfile.IconfileDescriptor, icon.ModifiedBy, func(ctx context.Context) error {
+		if addErr := s.blobstore.AddIconfile(ctx, icon.Name, iconfile, icon.ModifiedBy); addErr != nil {
+			return addErr
+		}
+		return errSideEffectTest
+	})
+	s.ErrorIs(err, errSideEffectTest)
+
+	_, getErr := s.blobstore.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.ErrorIs(getErr, domain.ErrIconfileNotFound)
+	_, describeErr := s.index.DescribeIcon(s.ctx, icon.Name)
+	s.ErrorIs(describeErr, domain.ErrIconNotFound)
+}
+
+func (s *transactionTestSuite) TestDeletionIsRolledBackWithIndex() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	s.NoError(s.index.CreateIcon(s.ctx, icon.Name, iconfile.IconfileDescriptor, icon.ModifiedBy, func(ctx context.Context) error {
+		return s.blobstore.AddIconfile(ctx, icon.Name, iconfile, icon.ModifiedBy)
+	}))
+
+	err := s.index.DeleteIcon(s.ctx, icon.Name, icon.ModifiedBy, func(ctx context.Context) error {
+		iconDesc := domain.IconDescriptor{IconAttributes: icon.IconAttributes, Iconfiles: []domain.IconfileDescriptor{iconfile.IconfileDescriptor}}
+		if deleteErr := s.blobstore.DeleteIcon(ctx, iconDesc, authn.LocalDomain.CreateUserID(icon.ModifiedBy)); deleteErr != nil {
+			return deleteErr
+		}
+		return errSideEffectTest
+	})
+	s.ErrorIs(err, errSideEffectTest)
+
+	content, getErr := s.blobstore.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(getErr)
+	s.Equal(iconfile.Content, content)
+}
@@ -0,0 +1,53 @@
+package s3
+
+import (
+	"net/http/httptest"
+	"os"
+	"sync"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+// FakeS3 is an in-memory, versioning S3 server for tests, so that the S3 blobstore can be tested without MinIO or AWS
+type FakeS3 struct {
+	server *httptest.Server
+}
+
+func NewFakeS3() *FakeS3 {
+	faker := gofakes3.New(s3mem.New())
+	return &FakeS3{server: httptest.NewServer(faker.Server())}
+}
+
+func (fake *FakeS3) URL() string {
+	return fake.server.URL
+}
+
+func (fake *FakeS3) Close() {
+	fake.server.Close()
+}
+
+var sharedFake *FakeS3
+var sharedFakeOnce sync.Once
+
+// TestS3URL returns the endpoint of the object store to test with: S3_URL (e.g. a local MinIO) if set,
+// or else that of an in-process fake shared by the tests of the package.
+// The fake takes any credentials, but the AWS SDK wants some to sign the requests with.
+func TestS3URL() string {
+	if url := os.Getenv("S3_URL"); len(url) > 0 {
+		return url
+	}
+	sharedFakeOnce.Do(func() {
+		sharedFake = NewFakeS3()
+		setEnvIfUnset("AWS_ACCESS_KEY_ID", "fake")
+		setEnvIfUnset("AWS_SECRET_ACCESS_KEY", "fake")
+		setEnvIfUnset("AWS_REGION", "eu-west-1")
+	})
+	return sharedFake.URL()
+}
+
+func setEnvIfUnset(name string, value string) {
+	if len(os.Getenv(name)) == 0 {
+		os.Setenv(name, value)
+	}
+}
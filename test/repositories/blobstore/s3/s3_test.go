@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"context"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/repositories/blobstore/s3"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type s3TestSuite struct {
+	suite.Suite
+	ctx  context.Context
+	repo *s3.S3
+}
+
+func TestS3TestSuite(t *testing.T) {
+	suite.Run(t, &s3TestSuite{ctx: context.Background()})
+}
+
+func (s *s3TestSuite) BeforeTest(suiteName, testName string) {
+	conf := test_commons.CloneConfig(test_commons.GetTestConfig())
+	conf.S3URL = TestS3URL()
+	conf.S3Bucket = "iconrepo-s3-test"
+	var createErr error
+	s.repo, createErr = s3.NewS3Blobstore(s.ctx, &conf)
+	s.Require().NoError(createErr)
+	s.Require().NoError(s.repo.CreateRepository(s.ctx))
+}
+
+func (s *s3TestSuite) AfterTest(suiteName, testName string) {
+	s.NoError(s.repo.DeleteRepository(s.ctx))
+}
+
+func (s *s3TestSuite) TestKeepsHistoryOfIconfile() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	s.NoError(s.repo.AddIconfile(s.ctx, icon.Name, iconfile, "first-editor"))
+	updated := domain.Iconfile{IconfileDescriptor: iconfile.IconfileDescriptor, Content: append([]byte{}, icon.Iconfiles[1].Content...)}
+	s.NoError(s.repo.AddIconfile(s.ctx, icon.Name, updated, "second-editor"))
+
+	history, historyErr := s.repo.GetIconfileHistory(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(historyErr)
+	s.Len(history, 2)
+	authors := []string{history[0].Author, history[1].Author}
+	s.ElementsMatch([]string{"first-editor", "second-editor"}, authors)
+
+	current, getErr := s.repo.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(getErr)
+	s.Equal(updated.Content, current)
+
+	// History survives the deletion of the iconfile
+	s.NoError(s.repo.DeleteIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor, authn.LocalDomain.CreateUserID("second-editor")))
+	history, historyErr = s.repo.GetIconfileHistory(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(historyErr)
+	s.Len(history, 2)
+
+	_, getErr = s.repo.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.ErrorIs(getErr, domain.ErrIconfileNotFound)
+}
+
+func (s *s3TestSuite) TestDeletingMissingIconfileFails() {
+	icon := test_commons.TestData[0]
+	deleteErr := s.repo.DeleteIconfile(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, authn.LocalDomain.CreateUserID(icon.ModifiedBy))
+	s.ErrorIs(deleteErr, domain.ErrIconfileNotFound)
+}
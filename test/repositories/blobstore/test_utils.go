@@ -2,18 +2,28 @@ package blobstore
 
 import (
 	"context"
-	"fmt"
+	"database/sql"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
 	"iconrepo/internal/config"
 	"iconrepo/internal/repositories"
+	"iconrepo/internal/repositories/blobstore/filesystem"
 	"iconrepo/internal/repositories/blobstore/git"
+	"iconrepo/internal/repositories/blobstore/memory"
+	"iconrepo/internal/repositories/blobstore/postgres"
+	"iconrepo/internal/repositories/blobstore/s3"
+	"iconrepo/internal/repositories/indexing/pgdb"
 	git_tests "iconrepo/test/repositories/blobstore/git"
+	s3_tests "iconrepo/test/repositories/blobstore/s3"
 	"iconrepo/test/test_commons"
 
+	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -43,6 +53,16 @@ func (ctl *TestBlobstoreController) String() string {
 	return ctl.repo.String()
 }
 
+// HasCommits tells whether the blobstore is backed by git, so that git commit failures can be simulated
+func (ctl *TestBlobstoreController) HasCommits() bool {
+	switch ctl.repo.(type) {
+	case *filesystem.Filesystem, *s3.S3, *postgres.Postgres, *memory.Blobstore:
+		return false
+	default:
+		return true
+	}
+}
+
 func (ctl *TestBlobstoreController) ResetRepository(ctx context.Context, conf *config.Options) error {
 	if ctl.repo != nil {
 		ctl.repo.DeleteRepository(ctx)
@@ -87,6 +107,18 @@ func (ctl *TestBlobstoreController) AddIconfile(ctx context.Context, iconName st
 	return ctl.repo.AddIconfile(ctx, iconName, iconfile, modifiedBy)
 }
 
+func (ctl *TestBlobstoreController) DeleteIcon(ctx context.Context, iconDesc domain.IconDescriptor, modifiedBy authn.UserID) error {
+	return ctl.repo.DeleteIcon(ctx, iconDesc, modifiedBy)
+}
+
+func (ctl *TestBlobstoreController) UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error {
+	return ctl.repo.UpdateIconMetadata(ctx, iconName, metadata, modifiedBy)
+}
+
+func (ctl *TestBlobstoreController) GetIconMetadata(ctx context.Context, iconName string) (domain.IconMetadata, error) {
+	return ctl.repo.GetIconMetadata(ctx, iconName)
+}
+
 type BlobstoreTestSuite struct {
 	suite.Suite
 	RepoController TestBlobstoreController
@@ -144,6 +176,89 @@ func NewLocalGitTestRepo(conf *config.Options) (*git.Local, error) {
 	return &repo, nil
 }
 
+func NewFilesystemTestBlobstore(conf *config.Options) (*filesystem.Filesystem, error) {
+	conf.GitlabNamespacePath = ""
+	conf.BlobstoreType = config.BlobstoreTypeFilesystem
+	conf.FilesystemBlobstoreRoot = conf.LocalGitRepo + "-fs"
+	return filesystem.NewFilesystemBlobstore(conf.FilesystemBlobstoreRoot), nil
+}
+
+var FilesystemBlobstoreController = TestBlobstoreController{
+	repoFactory: func(conf *config.Options) (TestBlobstoreClient, error) {
+		return NewFilesystemTestBlobstore(conf)
+	},
+}
+
+// testBucketNameRegexp matches the characters not allowed in bucket names
+var testBucketNameRegexp = regexp.MustCompile("[^a-z0-9-]")
+
+func NewS3TestBlobstore(conf *config.Options) (*s3.S3, error) {
+	conf.GitlabNamespacePath = ""
+	conf.BlobstoreType = config.BlobstoreTypeS3
+	conf.S3URL = s3_tests.TestS3URL()
+	// A bucket for each test case, named after the GitLab project set up for it
+	conf.S3Bucket = testBucketNameRegexp.ReplaceAllString(strings.ToLower(conf.GitlabProjectPath), "-")
+	return s3.NewS3Blobstore(context.Background(), conf)
+}
+
+var S3BlobstoreController = TestBlobstoreController{
+	repoFactory: func(conf *config.Options) (TestBlobstoreClient, error) {
+		return NewS3TestBlobstore(conf)
+	},
+}
+
+// postgresTestPools holds a connection pool per database schema, so that test cases don't each open one
+var postgresTestPools = map[string]*sql.DB{}
+
+func NewPostgresTestBlobstore(conf *config.Options) (*postgres.Postgres, error) {
+	conf.GitlabNamespacePath = ""
+	conf.BlobstoreType = config.BlobstoreTypePostgres
+	pool, ok := postgresTestPools[conf.DBSchemaName]
+	if !ok {
+		connection, connErr := pgdb.NewDBConnection(*conf)
+		if connErr != nil {
+			return nil, connErr
+		}
+		if _, schemaErr := pgdb.OpenSchema(*conf, connection); schemaErr != nil {
+			return nil, schemaErr
+		}
+		pool = connection.Pool
+		postgresTestPools[conf.DBSchemaName] = pool
+	}
+	return postgres.NewPostgresBlobstore(pool), nil
+}
+
+var PostgresBlobstoreController = TestBlobstoreController{
+	repoFactory: func(conf *config.Options) (TestBlobstoreClient, error) {
+		return NewPostgresTestBlobstore(conf)
+	},
+}
+
+// NewMemoryTestBlobstore returns the in-memory blobstore shared with the servers started by the test
+func NewMemoryTestBlobstore(conf *config.Options) (*memory.Blobstore, error) {
+	conf.GitlabNamespacePath = ""
+	conf.Storage = config.StorageMemory
+	return memory.Shared(conf.DBSchemaName), nil
+}
+
+var MemoryBlobstoreController = TestBlobstoreController{
+	repoFactory: func(conf *config.Options) (TestBlobstoreClient, error) {
+		return NewMemoryTestBlobstore(conf)
+	},
+}
+
+var FakeGitlabBlobstoreController = TestBlobstoreController{
+	repoFactory: func(conf *config.Options) (TestBlobstoreClient, error) {
+		return git_tests.NewFakeGitlabTestRepoClient(conf)
+	},
+}
+
+var FakeGiteaBlobstoreController = TestBlobstoreController{
+	repoFactory: func(conf *config.Options) (TestBlobstoreClient, error) {
+		return git_tests.NewFakeGiteaTestRepoClient(conf)
+	},
+}
+
 var DefaultBlobstoreController = TestBlobstoreController{
 	repoFactory: func(conf *config.Options) (TestBlobstoreClient, error) {
 		return NewLocalGitTestRepo(conf)
@@ -151,14 +266,21 @@ var DefaultBlobstoreController = TestBlobstoreController{
 }
 
 func BlobstoreProvidersToTest() []TestBlobstoreController {
-
-	fmt.Printf(">>>>>>>>>>>> LOCAL_GIT_ONLY: %v\n", os.Getenv("LOCAL_GIT_ONLY"))
+	if len(os.Getenv("MEMORY_ONLY")) > 0 {
+		// The in-memory blobstore comes with the in-memory index
+		return []TestBlobstoreController{MemoryBlobstoreController}
+	}
 	if len(os.Getenv("LOCAL_GIT_ONLY")) > 0 {
-		return []TestBlobstoreController{DefaultBlobstoreController}
+		return []TestBlobstoreController{DefaultBlobstoreController, FilesystemBlobstoreController, S3BlobstoreController, FakeGitlabBlobstoreController, FakeGiteaBlobstoreController}
 	}
 
 	return []TestBlobstoreController{
 		DefaultBlobstoreController,
+		FilesystemBlobstoreController,
+		S3BlobstoreController,
+		PostgresBlobstoreController,
+		FakeGitlabBlobstoreController,
+		FakeGiteaBlobstoreController,
 		{
 			repoFactory: func(conf *config.Options) (TestBlobstoreClient, error) {
 				repo, createClientErr := git_tests.NewGitlabTestRepoClient(conf)
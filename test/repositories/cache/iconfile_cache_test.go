@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/repositories"
+	"iconrepo/internal/repositories/blobstore/cache"
+	memory_blobstore "iconrepo/internal/repositories/blobstore/memory"
+	memory_index "iconrepo/internal/repositories/indexing/memory"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// countingBlobstore counts the iconfiles read from it
+type countingBlobstore struct {
+	*memory_blobstore.Blobstore
+	reads int
+}
+
+func (blobstore *countingBlobstore) GetIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor) ([]byte, error) {
+	blobstore.reads++
+	return blobstore.Blobstore.GetIconfile(ctx, iconName, iconfile)
+}
+
+type iconfileCacheTestSuite struct {
+	suite.Suite
+	ctx       context.Context
+	blobstore *countingBlobstore
+	combo     *repositories.RepoCombo
+	user      authr.UserInfo
+}
+
+func TestIconfileCacheTestSuite(t *testing.T) {
+	suite.Run(t, &iconfileCacheTestSuite{ctx: context.Background()})
+}
+
+func (s *iconfileCacheTestSuite) BeforeTest(suiteName string, testName string) {
+	iconfileCache, createCacheErr := cache.NewIconfileCache(1<<20, "", 0)
+	s.Require().NoError(createCacheErr)
+	s.blobstore = &countingBlobstore{Blobstore: memory_blobstore.NewBlobstore()}
+	index := memory_index.NewIndex()
+	s.combo = &repositories.RepoCombo{Index: index, Blobstore: s.blobstore, Outbox: index, IconfileCache: iconfileCache}
+	s.user = authr.UserInfo{UserId: authn.LocalDomain.CreateUserID("ux")}
+}
+
+func (s *iconfileCacheTestSuite) createTestIcon() domain.Icon {
+	icon := test_commons.TestData[0]
+	s.Require().NoError(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user))
+	return icon
+}
+
+func (s *iconfileCacheTestSuite) stats() domain.IconfileCacheStats {
+	stats, err := s.combo.GetIconfileCacheStats(s.ctx)
+	s.Require().NoError(err)
+	return stats
+}
+
+func (s *iconfileCacheTestSuite) TestIconfileIsReadFromTheBlobstoreOnce() {
+	icon := s.createTestIcon()
+	iconfile := icon.Iconfiles[0]
+
+	for i := 0; i < 3; i++ {
+		content, err := s.combo.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+		s.NoError(err)
+		s.Equal(iconfile.Content, content)
+	}
+
+	s.Equal(1, s.blobstore.reads)
+	stats := s.stats()
+	s.True(stats.Enabled)
+	s.Equal(uint64(2), stats.Hits)
+	s.Equal(uint64(1), stats.Misses)
+}
+
+func (s *iconfileCacheTestSuite) TestAddingAnIconfileInvalidatesTheIcon() {
+	icon := s.createTestIcon()
+	_, err := s.combo.GetIconfile(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.combo.AddIconfile(s.ctx, icon.Name, icon.Iconfiles[1], s.user))
+
+	_, err = s.combo.GetIconfile(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor)
+	s.NoError(err)
+	s.Equal(2, s.blobstore.reads)
+}
+
+func (s *iconfileCacheTestSuite) TestDeletedIconfileIsNotServedFromTheCache() {
+	icon := s.createTestIcon()
+	iconfile := icon.Iconfiles[0].IconfileDescriptor
+	s.Require().NoError(s.combo.AddIconfile(s.ctx, icon.Name, icon.Iconfiles[1], s.user))
+	_, err := s.combo.GetIconfile(s.ctx, icon.Name, iconfile)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.combo.DeleteIconfile(s.ctx, icon.Name, iconfile, s.user))
+
+	_, err = s.combo.GetIconfile(s.ctx, icon.Name, iconfile)
+	s.ErrorIs(err, domain.ErrIconfileNotFound)
+}
+
+func (s *iconfileCacheTestSuite) TestDeletedIconIsNotServedFromTheCache() {
+	icon := s.createTestIcon()
+	iconfile := icon.Iconfiles[0].IconfileDescriptor
+	_, err := s.combo.GetIconfile(s.ctx, icon.Name, iconfile)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.combo.DeleteIcon(s.ctx, icon.Name, s.user))
+
+	_, err = s.combo.GetIconfile(s.ctx, icon.Name, iconfile)
+	s.ErrorIs(err, domain.ErrIconfileNotFound)
+}
+
+func (s *iconfileCacheTestSuite) TestChangesetInvalidatesTheIconsChanged() {
+	icon := s.createTestIcon()
+	iconfile := icon.Iconfiles[0]
+	_, err := s.combo.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.Require().NoError(err)
+
+	_, err = s.combo.ApplyChangeset(s.ctx, []domain.ChangesetOperation{
+		{Action: domain.AuditActionAddIconfile, IconName: icon.Name, Iconfile: icon.Iconfiles[1]},
+		{Action: domain.AuditActionDeleteIconfile, IconName: icon.Name, Iconfile: iconfile},
+	}, s.user)
+	s.Require().NoError(err)
+
+	_, err = s.combo.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.ErrorIs(err, domain.ErrIconfileNotFound)
+}
+
+func (s *iconfileCacheTestSuite) TestStatsOfADisabledCache() {
+	s.combo.IconfileCache = nil
+
+	s.Equal(domain.IconfileCacheStats{}, s.stats())
+}
@@ -1,6 +1,7 @@
 package indexing
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -55,7 +56,7 @@ func (s *addIconToIndexTestSuite) TestRollbackOnFailedSideEffect() {
 	var count int
 	var err error
 
-	var createSideEffect = func() error {
+	var createSideEffect = func(ctx context.Context) error {
 		return errSideEffectTest
 	}
 
@@ -1,6 +1,7 @@
 package indexing
 
 import (
+	"context"
 	"testing"
 
 	"iconrepo/internal/app/domain"
@@ -85,7 +86,7 @@ func (s *addIconfileToIndexTestSuite) TestRollbackOnFailedSideEffect() {
 
 	cloneOfFirst := test_commons.CloneIcon(icon)
 
-	err = s.testRepoController.AddIconfileToIcon(s.ctx, icon.Name, iconfile2.IconfileDescriptor, secondUser, func() error {
+	err = s.testRepoController.AddIconfileToIcon(s.ctx, icon.Name, iconfile2.IconfileDescriptor, secondUser, func(ctx context.Context) error {
 		return errSideEffectTest
 	})
 	s.Error(err)
@@ -0,0 +1,166 @@
+package indexing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type auditLogTestSuite struct {
+	IndexingTestSuite
+}
+
+func TestAuditLogTestSuite(t *testing.T) {
+	for _, testSuite := range indexingTestSuites() {
+		suite.Run(t, &auditLogTestSuite{testSuite})
+	}
+}
+
+func (s *auditLogTestSuite) recordEntries(start time.Time) []domain.AuditEntry {
+	entries := []domain.AuditEntry{
+		{
+			Timestamp: start,
+			Actor:     "ux",
+			Action:    domain.AuditActionCreateIcon,
+			IconName:  "attach_money",
+			Iconfile:  &domain.IconfileDescriptor{Format: "png", Size: "36px"},
+			After: &domain.IconDescriptor{
+				IconAttributes: domain.IconAttributes{Name: "attach_money", ModifiedBy: "ux", Tags: []string{}},
+				Iconfiles:      []domain.IconfileDescriptor{{Format: "png", Size: "36px"}},
+			},
+			RequestID: "req-1",
+		},
+		{
+			Timestamp: start.Add(time.Second),
+			Actor:     "ux",
+			Action:    domain.AuditActionAddTag,
+			IconName:  "attach_money",
+			Tag:       "money",
+		},
+		{
+			Timestamp: start.Add(2 * time.Second),
+			Actor:     "dev",
+			Action:    domain.AuditActionCreateIcon,
+			IconName:  "cast_connected",
+		},
+		{
+			Timestamp: start.Add(3 * time.Second),
+			Actor:     "dev",
+			Action:    domain.AuditActionDeleteIcon,
+			IconName:  "attach_money",
+		},
+	}
+	for _, entry := range entries {
+		s.NoError(s.testRepoController.RecordAuditEntry(s.ctx, entry))
+	}
+	return entries
+}
+
+func (s *auditLogTestSuite) actions(page domain.AuditPage) []domain.AuditAction {
+	actions := []domain.AuditAction{}
+	for _, entry := range page.Entries {
+		actions = append(actions, entry.Action)
+	}
+	return actions
+}
+
+func (s *auditLogTestSuite) TestEntriesComeMostRecentFirst() {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	recorded := s.recordEntries(start)
+
+	page, err := s.testRepoController.QueryAuditEntries(s.ctx, domain.AuditQuery{IconName: "attach_money"})
+	s.NoError(err)
+	s.Empty(page.NextCursor)
+	s.Equal([]domain.AuditAction{domain.AuditActionDeleteIcon, domain.AuditActionAddTag, domain.AuditActionCreateIcon}, s.actions(page))
+
+	created := page.Entries[2]
+	s.NotEmpty(created.ID)
+	s.True(recorded[0].Timestamp.Equal(created.Timestamp))
+	s.Equal(recorded[0].Actor, created.Actor)
+	s.Equal(recorded[0].Iconfile, created.Iconfile)
+	s.Nil(created.Before)
+	s.Equal(recorded[0].After, created.After)
+	s.Equal("req-1", created.RequestID)
+	s.Equal("money", page.Entries[1].Tag)
+}
+
+func (s *auditLogTestSuite) TestFilterByActorAndTime() {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	s.recordEntries(start)
+
+	page, err := s.testRepoController.QueryAuditEntries(s.ctx, domain.AuditQuery{Actor: "dev"})
+	s.NoError(err)
+	s.Equal([]domain.AuditAction{domain.AuditActionDeleteIcon, domain.AuditActionCreateIcon}, s.actions(page))
+
+	page, err = s.testRepoController.QueryAuditEntries(s.ctx, domain.AuditQuery{
+		Since: start.Add(time.Second),
+		Until: start.Add(3 * time.Second),
+	})
+	s.NoError(err)
+	s.Equal(2, len(page.Entries))
+	s.Equal("cast_connected", page.Entries[0].IconName)
+	s.Equal(domain.AuditActionAddTag, page.Entries[1].Action)
+}
+
+func (s *auditLogTestSuite) TestPagination() {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	recorded := s.recordEntries(start)
+
+	for _, testCase := range []struct {
+		query         domain.AuditQuery
+		expectedCount int
+	}{
+		{domain.AuditQuery{Limit: 2}, len(recorded)},
+		{domain.AuditQuery{IconName: "attach_money", Limit: 2}, 3},
+		{domain.AuditQuery{Actor: "dev", Limit: 1}, 2},
+	} {
+		query := testCase.query
+		collected := []domain.AuditEntry{}
+		for {
+			page, err := s.testRepoController.QueryAuditEntries(s.ctx, query)
+			s.NoError(err)
+			s.LessOrEqual(len(page.Entries), query.Limit)
+			collected = append(collected, page.Entries...)
+			if len(page.NextCursor) == 0 {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+
+		s.Equal(testCase.expectedCount, len(collected))
+		for i := 1; i < len(collected); i++ {
+			s.True(collected[i-1].Timestamp.After(collected[i].Timestamp))
+		}
+	}
+}
+
+func (s *auditLogTestSuite) TestRejectsInvalidCursor() {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	s.recordEntries(start)
+
+	_, err := s.testRepoController.QueryAuditEntries(s.ctx, domain.AuditQuery{Cursor: "not a cursor"})
+	s.ErrorIs(err, domain.ErrInvalidAuditCursor)
+}
+
+// Entries are recorded by the side-effects of the changes they are about, so as to be part of the same transaction
+func (s *auditLogTestSuite) TestEntryIsRecordedInTheSideEffectOfAChange() {
+	icon := test_commons.TestData[0]
+	entry := domain.AuditEntry{
+		Timestamp: time.Now().Truncate(time.Second),
+		Actor:     icon.ModifiedBy,
+		Action:    domain.AuditActionCreateIcon,
+		IconName:  icon.Name,
+	}
+	s.NoError(s.testRepoController.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, func(ctx context.Context) error {
+		return s.testRepoController.RecordAuditEntry(ctx, entry)
+	}))
+
+	page, err := s.testRepoController.QueryAuditEntries(s.ctx, domain.AuditQuery{IconName: icon.Name})
+	s.NoError(err)
+	s.Equal([]domain.AuditAction{domain.AuditActionCreateIcon}, s.actions(page))
+}
@@ -0,0 +1,96 @@
+package indexing
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/repositories/indexing"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type committedIconsTestSuite struct {
+	IndexingTestSuite
+}
+
+func TestCommittedIconsTestSuite(t *testing.T) {
+	for _, testSuite := range indexingTestSuites() {
+		suite.Run(t, &committedIconsTestSuite{testSuite})
+	}
+}
+
+// captureCommittedIcon returns a side-effect recording the state of the icon handed over to it
+func captureCommittedIcon(iconName string, committed *domain.IconDescriptor, found *bool) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		*committed, *found = indexing.CommittedIcon(ctx, iconName)
+		return nil
+	}
+}
+
+func (s *committedIconsTestSuite) TestSideEffectIsHandedTheIconAsCommitted() {
+	icon := test_commons.TestData[0]
+	var committed domain.IconDescriptor
+	var found bool
+
+	err := s.testRepoController.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, captureCommittedIcon(icon.Name, &committed, &found))
+	s.Require().NoError(err)
+	s.True(found)
+	s.Equal([]domain.IconfileDescriptor{icon.Iconfiles[0].IconfileDescriptor}, committed.Iconfiles)
+
+	err = s.testRepoController.AddIconfileToIcon(s.ctx, icon.Name, icon.Iconfiles[1].IconfileDescriptor, "ux", captureCommittedIcon(icon.Name, &committed, &found))
+	s.Require().NoError(err)
+	s.True(found)
+	s.ElementsMatch([]domain.IconfileDescriptor{icon.Iconfiles[0].IconfileDescriptor, icon.Iconfiles[1].IconfileDescriptor}, committed.Iconfiles)
+	s.Equal("ux", committed.ModifiedBy)
+
+	err = s.testRepoController.AddTagWithSideEffect(s.ctx, icon.Name, "money", icon.ModifiedBy, captureCommittedIcon(icon.Name, &committed, &found))
+	s.Require().NoError(err)
+	s.True(found)
+	s.Equal([]string{"money"}, committed.Tags)
+
+	err = s.testRepoController.DeleteIconfile(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, captureCommittedIcon(icon.Name, &committed, &found))
+	s.Require().NoError(err)
+	s.True(found)
+	s.Equal([]domain.IconfileDescriptor{icon.Iconfiles[1].IconfileDescriptor}, committed.Iconfiles)
+	s.Equal([]string{"money"}, committed.Tags)
+
+	err = s.testRepoController.DeleteIcon(s.ctx, icon.Name, icon.ModifiedBy, captureCommittedIcon(icon.Name, &committed, &found))
+	s.Require().NoError(err)
+	s.True(found)
+	s.Equal(icon.Name, committed.Name)
+	s.Empty(committed.Iconfiles)
+}
+
+func (s *committedIconsTestSuite) TestSideEffectsOfConcurrentTagChangesAreHandedTheTagsCommitted() {
+	icon := test_commons.TestData[0]
+	err := s.testRepoController.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, nil)
+	s.Require().NoError(err)
+
+	var mutex sync.Mutex
+	handedOver := [][]string{}
+	errs := runConcurrently(func(writer int) error {
+		tag := fmt.Sprintf("tag-%d", writer)
+		return s.testRepoController.AddTagWithSideEffect(s.ctx, icon.Name, tag, icon.ModifiedBy, func(ctx context.Context) error {
+			committed, found := indexing.CommittedIcon(ctx, icon.Name)
+			if !found || !slices.Contains(committed.Tags, tag) {
+				return fmt.Errorf("tag %s not handed over: %v", tag, committed.Tags)
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			handedOver = append(handedOver, committed.Tags)
+			return nil
+		})
+	})
+	for _, err := range errs {
+		s.NoError(err)
+	}
+
+	// The side-effect of the change committed last is handed all the tags
+	mostTags := slices.MaxFunc(handedOver, func(tags1 []string, tags2 []string) int { return len(tags1) - len(tags2) })
+	s.Len(mostTags, concurrentWriterCount)
+}
@@ -0,0 +1,107 @@
+package indexing
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const concurrentWriterCount = 6
+
+type concurrentWritesTestSuite struct {
+	IndexingTestSuite
+}
+
+func TestConcurrentWritesTestSuite(t *testing.T) {
+	for _, testSuite := range indexingTestSuites() {
+		suite.Run(t, &concurrentWritesTestSuite{testSuite})
+	}
+}
+
+// runConcurrently calls write from concurrentWriterCount goroutines and returns the errors in the order of the writers
+func runConcurrently(write func(writer int) error) []error {
+	errs := make([]error, concurrentWriterCount)
+	var wg sync.WaitGroup
+	for writer := 0; writer < concurrentWriterCount; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			errs[writer] = write(writer)
+		}(writer)
+	}
+	wg.Wait()
+	return errs
+}
+
+func (s *concurrentWritesTestSuite) TestTagsAddedConcurrentlyAreAllKept() {
+	icon := test_commons.TestData[0]
+	err := s.testRepoController.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, nil)
+	s.Require().NoError(err)
+
+	errs := runConcurrently(func(writer int) error {
+		return s.testRepoController.AddTag(s.ctx, icon.Name, fmt.Sprintf("tag-%d", writer), icon.ModifiedBy)
+	})
+	for _, err := range errs {
+		s.NoError(err)
+	}
+
+	iconDesc, err := s.testRepoController.DescribeIcon(s.ctx, icon.Name)
+	s.NoError(err)
+	s.Len(iconDesc.Tags, concurrentWriterCount)
+	tags, err := s.testRepoController.GetExistingTags(s.ctx)
+	s.NoError(err)
+	s.Len(tags, concurrentWriterCount)
+	tagRelationCount, err := s.testRepoController.GetTagRelationCount(s.ctx)
+	s.NoError(err)
+	s.Equal(concurrentWriterCount, tagRelationCount)
+}
+
+func (s *concurrentWritesTestSuite) TestIconCreatedConcurrentlyIsCreatedOnce() {
+	icon := test_commons.TestData[0]
+
+	errs := runConcurrently(func(writer int) error {
+		return s.testRepoController.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, fmt.Sprintf("user-%d", writer), nil)
+	})
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		s.ErrorIs(err, domain.ErrIconAlreadyExists)
+	}
+	s.Equal(1, created)
+
+	iconCount, err := s.getIconCount(s.ctx)
+	s.NoError(err)
+	s.Equal(1, iconCount)
+}
+
+func (s *concurrentWritesTestSuite) TestTagOfIconsDeletedConcurrentlyIsNoLongerReferenced() {
+	const tag = "shared"
+	iconNames := []string{}
+	for writer := 0; writer < concurrentWriterCount; writer++ {
+		iconName := fmt.Sprintf("icon-%d", writer)
+		iconNames = append(iconNames, iconName)
+		err := s.testRepoController.CreateIcon(s.ctx, iconName, test_commons.TestData[0].Iconfiles[0].IconfileDescriptor, "ux", nil)
+		s.Require().NoError(err)
+		err = s.testRepoController.AddTag(s.ctx, iconName, tag, "ux")
+		s.Require().NoError(err)
+	}
+
+	errs := runConcurrently(func(writer int) error {
+		return s.testRepoController.DeleteIcon(s.ctx, iconNames[writer], "ux", nil)
+	})
+	for _, err := range errs {
+		s.NoError(err)
+	}
+
+	tagRelationCount, err := s.testRepoController.GetTagRelationCount(s.ctx)
+	s.NoError(err)
+	s.Equal(0, tagRelationCount)
+}
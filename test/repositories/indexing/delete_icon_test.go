@@ -1,6 +1,7 @@
 package indexing
 
 import (
+	"context"
 	"iconrepo/test/test_commons"
 	"testing"
 
@@ -52,7 +53,7 @@ func (s *deleteIconFromIndexTestSuite) TestRollbackOnFailedSideEffect() {
 	err = s.testRepoController.AddTag(s.ctx, icon.Name, icon.Tags[0], icon.ModifiedBy)
 	s.NoError(err)
 
-	err = s.testRepoController.DeleteIcon(s.ctx, icon.Name, icon.ModifiedBy, func() error {
+	err = s.testRepoController.DeleteIcon(s.ctx, icon.Name, icon.ModifiedBy, func(ctx context.Context) error {
 		return errSideEffectTest
 	})
 	s.Error(err)
@@ -1,6 +1,7 @@
 package indexing
 
 import (
+	"context"
 	"testing"
 
 	"iconrepo/internal/app/domain"
@@ -111,7 +112,7 @@ func (s *deleteIconfileFromDBTestSuite) TestRollbackOnFailedSideEffect() {
 	err = s.testRepoController.AddTag(s.ctx, icon.Name, icon.Tags[0], icon.ModifiedBy)
 	s.NoError(err)
 
-	err = s.testRepoController.DeleteIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor, icon.ModifiedBy, func() error {
+	err = s.testRepoController.DeleteIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor, icon.ModifiedBy, func(ctx context.Context) error {
 		return errSideEffectTest
 	})
 	s.Error(err)
@@ -0,0 +1,45 @@
+package indexing
+
+import (
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type descriptionTestSuite struct {
+	IndexingTestSuite
+}
+
+func TestDescriptionTestSuite(t *testing.T) {
+	for _, testSuite := range indexingTestSuites() {
+		suite.Run(t, &descriptionTestSuite{testSuite})
+	}
+}
+
+func (s *descriptionTestSuite) TestDescriptionIsSetAndRemoved() {
+	icon := test_commons.TestData[0]
+
+	err := s.testRepoController.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, nil)
+	s.NoError(err)
+
+	err = s.testRepoController.SetIconDescription(s.ctx, icon.Name, "a shopping cart", "editor")
+	s.NoError(err)
+	iconDesc, err := s.testRepoController.DescribeIcon(s.ctx, icon.Name)
+	s.NoError(err)
+	s.Equal("a shopping cart", iconDesc.Description)
+	s.Equal("editor", iconDesc.ModifiedBy)
+
+	err = s.testRepoController.SetIconDescription(s.ctx, icon.Name, "", "editor")
+	s.NoError(err)
+	iconDesc, err = s.testRepoController.DescribeIcon(s.ctx, icon.Name)
+	s.NoError(err)
+	s.Empty(iconDesc.Description)
+}
+
+func (s *descriptionTestSuite) TestSetDescriptionOfMissingIcon() {
+	err := s.testRepoController.SetIconDescription(s.ctx, "no-such-icon", "a shopping cart", "editor")
+	s.ErrorIs(err, domain.ErrIconNotFound)
+}
@@ -0,0 +1,101 @@
+package indexing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"iconrepo/internal/config"
+	"iconrepo/internal/logging"
+	"iconrepo/internal/repositories/indexing/dynamodb"
+	"iconrepo/test/test_commons"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_dyndb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type dynamodbSchemaTestSuite struct {
+	suite.Suite
+	config config.Options
+	ctx    context.Context
+	repo   *dynamodb.DynamodbRepository
+}
+
+func TestDynamodbSchemaTestSuite(t *testing.T) {
+	conf := test_commons.CloneConfig(test_commons.GetTestConfig())
+	if len(conf.DynamodbURL) == 0 {
+		t.Skip("the DynamoDB schema is tested with DynamoDB only")
+	}
+	conf.DynamodbCreateTables = true
+	ctx := logging.Get().With().Str("test_sequence_name", "dynamodb schema tests").Logger().WithContext(context.Background())
+	suite.Run(t, &dynamodbSchemaTestSuite{config: conf, ctx: ctx})
+}
+
+func (s *dynamodbSchemaTestSuite) SetupSuite() {
+	var err error
+	s.repo, err = dynamodb.NewDynamodbRepository(&s.config)
+	s.Require().NoError(err)
+}
+
+func (s *dynamodbSchemaTestSuite) TearDownSuite() {
+	s.repo.Close()
+}
+
+func (s *dynamodbSchemaTestSuite) TestTablesAreCreated() {
+	for _, tableName := range []string{dynamodb.IconsTableName, dynamodb.IconTagsTableName, dynamodb.IconAuditTableName, dynamodb.IconOutboxTableName, dynamodb.IconMetaTableName, dynamodb.IconTagMembersTableName} {
+		output, err := s.repo.GetAwsClient().DescribeTable(s.ctx, &aws_dyndb.DescribeTableInput{TableName: aws.String(tableName)})
+		s.NoError(err, tableName)
+		if err == nil {
+			s.Equal(types.TableStatusActive, output.Table.TableStatus, tableName)
+		}
+	}
+}
+
+func (s *dynamodbSchemaTestSuite) TestAuditIndexesAreCreated() {
+	output, err := s.repo.GetAwsClient().DescribeTable(s.ctx, &aws_dyndb.DescribeTableInput{TableName: aws.String(dynamodb.IconAuditTableName)})
+	s.Require().NoError(err)
+	indexNames := []string{}
+	for _, index := range output.Table.GlobalSecondaryIndexes {
+		indexNames = append(indexNames, aws.ToString(index.IndexName))
+	}
+	s.ElementsMatch([]string{dynamodb.AuditByActorIndexName, dynamodb.AuditByTimeIndexName}, indexNames)
+}
+
+func (s *dynamodbSchemaTestSuite) TestUpgradesAreRecorded() {
+	for _, version := range []string{"2026-10-19/0 - first version", "2026-10-19/1 - tag members", "2026-10-19/2 - audit log index"} {
+		output, err := s.repo.GetAwsClient().GetItem(s.ctx, &aws_dyndb.GetItemInput{
+			TableName:      aws.String(dynamodb.IconMetaTableName),
+			Key:            map[string]types.AttributeValue{"Version": &types.AttributeValueMemberS{Value: version}},
+			ConsistentRead: aws.Bool(true),
+		})
+		s.NoError(err, version)
+		if err == nil {
+			s.NotNil(output.Item, version)
+		}
+	}
+}
+
+func (s *dynamodbSchemaTestSuite) TestSchemaIsOpenedAgain() {
+	err := dynamodb.OpenSchema(s.ctx, s.repo.GetAwsClient(), true, s.config.DynamodbBillingMode)
+	s.NoError(err)
+}
+
+func (s *dynamodbSchemaTestSuite) TestMetaTableIsRequired() {
+	awsClient := s.repo.GetAwsClient()
+	_, deleteErr := awsClient.DeleteTable(s.ctx, &aws_dyndb.DeleteTableInput{TableName: aws.String(dynamodb.IconMetaTableName)})
+	s.Require().NoError(deleteErr)
+	waiter := aws_dyndb.NewTableNotExistsWaiter(awsClient)
+	s.Require().NoError(waiter.Wait(s.ctx, &aws_dyndb.DescribeTableInput{TableName: aws.String(dynamodb.IconMetaTableName)}, time.Minute))
+
+	s.Error(dynamodb.OpenSchema(s.ctx, awsClient, false, s.config.DynamodbBillingMode))
+
+	s.NoError(dynamodb.OpenSchema(s.ctx, awsClient, true, s.config.DynamodbBillingMode))
+}
+
+func TestInvalidDynamodbBillingMode(t *testing.T) {
+	err := dynamodb.OpenSchema(context.Background(), nil, true, "FREE")
+	assert.Error(t, err)
+}
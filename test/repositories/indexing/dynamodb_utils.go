@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iconrepo/internal/repositories/indexing"
 	"iconrepo/internal/repositories/indexing/dynamodb"
 
 	aws_dyndb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -87,7 +88,43 @@ func (testRepo *DynamodbTestRepository) ResetData(ctx context.Context) error {
 		}
 	}
 
-	return dynamodb.DeleteLockItems(ctx, testRepo.GetAwsClient())
+	tagMembers, getTagMembersErr := dynamodb.NewDyndbIconTagMembersTable(testRepo.GetAwsClient()).GetItems(ctx)
+	if getTagMembersErr != nil && !errors.Is(getTagMembersErr, indexing.ErrTableNotFound) {
+		return getTagMembersErr
+	}
+
+	for _, tagMember := range tagMembers {
+		deletErr := testRepo.DeleteAll(ctx, dynamodb.IconTagMembersTableName, tagMember)
+		if deletErr != nil {
+			return deletErr
+		}
+	}
+
+	auditEntries, getAuditEntriesErr := dynamodb.NewDyndbIconAuditTable(testRepo.GetAwsClient()).GetItems(ctx)
+	if getAuditEntriesErr != nil && !errors.Is(getAuditEntriesErr, indexing.ErrTableNotFound) {
+		return getAuditEntriesErr
+	}
+
+	for _, auditEntry := range auditEntries {
+		deletErr := testRepo.DeleteAll(ctx, dynamodb.IconAuditTableName, auditEntry)
+		if deletErr != nil {
+			return deletErr
+		}
+	}
+
+	outboxEntries, getOutboxEntriesErr := dynamodb.NewDyndbIconOutboxTable(testRepo.GetAwsClient()).GetItems(ctx)
+	if getOutboxEntriesErr != nil && !errors.Is(getOutboxEntriesErr, indexing.ErrTableNotFound) {
+		return getOutboxEntriesErr
+	}
+
+	for _, outboxEntry := range outboxEntries {
+		deletErr := testRepo.DeleteAll(ctx, dynamodb.IconOutboxTableName, outboxEntry)
+		if deletErr != nil {
+			return deletErr
+		}
+	}
+
+	return nil
 }
 
 type keyGetter interface {
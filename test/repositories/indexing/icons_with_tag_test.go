@@ -0,0 +1,108 @@
+package indexing
+
+import (
+	"testing"
+
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type iconsWithTagTestSuite struct {
+	IndexingTestSuite
+}
+
+func TestIconsWithTagTestSuite(t *testing.T) {
+	for _, testSuite := range indexingTestSuites() {
+		suite.Run(t, &iconsWithTagTestSuite{testSuite})
+	}
+}
+
+const untaggedIconName = "untagged-icon"
+
+func (s *iconsWithTagTestSuite) createIcons() {
+	for _, icon := range test_commons.TestData {
+		err := s.testRepoController.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, nil)
+		s.Require().NoError(err)
+	}
+	err := s.testRepoController.CreateIcon(s.ctx, untaggedIconName, test_commons.TestData[0].Iconfiles[0].IconfileDescriptor, "ux", nil)
+	s.Require().NoError(err)
+}
+
+func (s *iconsWithTagTestSuite) iconNamesWithTag(tag string) []string {
+	icons, err := s.testRepoController.GetIconsWithTag(s.ctx, tag)
+	s.Require().NoError(err)
+	iconNames := []string{}
+	for _, icon := range icons {
+		s.Contains(icon.Tags, tag)
+		iconNames = append(iconNames, icon.Name)
+	}
+	return iconNames
+}
+
+func (s *iconsWithTagTestSuite) TestNoIconsWithUnknownTag() {
+	s.createIcons()
+
+	s.Empty(s.iconNamesWithTag("no-such-tag"))
+}
+
+func (s *iconsWithTagTestSuite) TestOnlyIconsWithTagAreFound() {
+	const tag = "used-in-marvinjs"
+	s.createIcons()
+	icon1 := test_commons.TestData[0]
+	icon2 := test_commons.TestData[1]
+
+	err := s.testRepoController.AddTag(s.ctx, icon2.Name, tag, icon2.ModifiedBy)
+	s.NoError(err)
+	err = s.testRepoController.AddTag(s.ctx, icon1.Name, tag, icon1.ModifiedBy)
+	s.NoError(err)
+	err = s.testRepoController.AddTag(s.ctx, icon1.Name, "other-tag", icon1.ModifiedBy)
+	s.NoError(err)
+
+	expected := []string{icon1.Name, icon2.Name}
+	if icon2.Name < icon1.Name {
+		expected = []string{icon2.Name, icon1.Name}
+	}
+	s.Equal(expected, s.iconNamesWithTag(tag))
+	s.Equal([]string{icon1.Name}, s.iconNamesWithTag("other-tag"))
+}
+
+func (s *iconsWithTagTestSuite) TestIconIsNoLongerFoundOnceTagRemoved() {
+	const tag = "used-in-marvinjs"
+	s.createIcons()
+	icon1 := test_commons.TestData[0]
+	icon2 := test_commons.TestData[1]
+
+	err := s.testRepoController.AddTag(s.ctx, icon1.Name, tag, icon1.ModifiedBy)
+	s.NoError(err)
+	err = s.testRepoController.AddTag(s.ctx, icon2.Name, tag, icon2.ModifiedBy)
+	s.NoError(err)
+
+	err = s.testRepoController.RemoveTag(s.ctx, icon1.Name, tag, icon1.ModifiedBy)
+	s.NoError(err)
+
+	s.Equal([]string{icon2.Name}, s.iconNamesWithTag(tag))
+	tagRelationCount, err := s.testRepoController.GetTagRelationCount(s.ctx)
+	s.NoError(err)
+	s.Equal(1, tagRelationCount)
+}
+
+func (s *iconsWithTagTestSuite) TestIconIsNoLongerFoundOnceDeleted() {
+	const tag = "used-in-marvinjs"
+	s.createIcons()
+	icon1 := test_commons.TestData[0]
+	icon2 := test_commons.TestData[1]
+
+	err := s.testRepoController.AddTag(s.ctx, icon1.Name, tag, icon1.ModifiedBy)
+	s.NoError(err)
+	err = s.testRepoController.AddTag(s.ctx, icon2.Name, tag, icon2.ModifiedBy)
+	s.NoError(err)
+
+	err = s.testRepoController.DeleteIcon(s.ctx, icon2.Name, icon2.ModifiedBy, nil)
+	s.NoError(err)
+
+	s.Equal([]string{icon1.Name}, s.iconNamesWithTag(tag))
+	tagRelationCount, err := s.testRepoController.GetTagRelationCount(s.ctx)
+	s.NoError(err)
+	s.Equal(1, tagRelationCount)
+}
@@ -0,0 +1,48 @@
+package indexing
+
+import (
+	"context"
+
+	"iconrepo/internal/repositories/indexing/memory"
+)
+
+type MemoryTestRepository struct {
+	*memory.Index
+}
+
+func (testRepo *MemoryTestRepository) GetIconCount(ctx context.Context) (int, error) {
+	icons, err := testRepo.DescribeAllIcons(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(icons), nil
+}
+
+func (testRepo *MemoryTestRepository) GetIconFileCount(ctx context.Context) (int, error) {
+	icons, err := testRepo.DescribeAllIcons(ctx)
+	if err != nil {
+		return 0, err
+	}
+	iconfileCount := 0
+	for _, icon := range icons {
+		iconfileCount += len(icon.Iconfiles)
+	}
+	return iconfileCount, nil
+}
+
+func (testRepo *MemoryTestRepository) GetTagRelationCount(ctx context.Context) (int, error) {
+	icons, err := testRepo.DescribeAllIcons(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tagRelationCount := 0
+	for _, icon := range icons {
+		tagRelationCount += len(icon.Tags)
+	}
+	return tagRelationCount, nil
+}
+
+func (testRepo *MemoryTestRepository) ResetData(ctx context.Context) error {
+	testRepo.Reset()
+	return nil
+}
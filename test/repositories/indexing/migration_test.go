@@ -0,0 +1,113 @@
+package indexing
+
+import (
+	"context"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/config"
+	"iconrepo/internal/logging"
+	"iconrepo/internal/repositories"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type indexMigrationTestSuite struct {
+	suite.Suite
+	config config.Options
+	ctx    context.Context
+	source IndexTestRepoController
+	target IndexTestRepoController
+}
+
+func TestIndexMigrationTestSuite(t *testing.T) {
+	conf := test_commons.CloneConfig(test_commons.GetTestConfig())
+	if len(conf.DynamodbURL) == 0 {
+		t.Skip("migrating the index needs both Postgres and DynamoDB")
+	}
+	conf.DBSchemaName = "itest_index_migration"
+	ctx := logging.Get().With().Str("test_sequence_name", "index migration tests").Logger().WithContext(context.Background())
+
+	pg := func() IndexTestRepoController {
+		return IndexTestRepoController{repoFactory: PgIndexTestRepoController.repoFactory}
+	}
+	dyndb := func() IndexTestRepoController {
+		return IndexTestRepoController{repoFactory: DynamodbIndexTestRepoController.repoFactory}
+	}
+	suite.Run(t, &indexMigrationTestSuite{config: conf, ctx: ctx, source: pg(), target: dyndb()})
+	suite.Run(t, &indexMigrationTestSuite{config: conf, ctx: ctx, source: dyndb(), target: pg()})
+}
+
+func (s *indexMigrationTestSuite) BeforeTest(suiteName, testName string) {
+	s.Require().NoError(s.source.ResetRepo(s.ctx, &s.config))
+	s.Require().NoError(s.target.ResetRepo(s.ctx, &s.config))
+}
+
+func (s *indexMigrationTestSuite) TearDownSuite() {
+	s.source.Close()
+	s.target.Close()
+}
+
+func (s *indexMigrationTestSuite) addTestData(index *IndexTestRepoController) {
+	for _, icon := range test_commons.TestData {
+		s.Require().NoError(index.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, nil))
+		for _, iconfile := range icon.Iconfiles[1:] {
+			s.Require().NoError(index.AddIconfileToIcon(s.ctx, icon.Name, iconfile.IconfileDescriptor, icon.ModifiedBy, nil))
+		}
+		for _, tag := range icon.Tags {
+			s.Require().NoError(index.AddTag(s.ctx, icon.Name, tag, icon.ModifiedBy))
+		}
+	}
+}
+
+func (s *indexMigrationTestSuite) TestCopiesIconsIconfilesAndTags() {
+	s.addTestData(&s.source)
+
+	report, err := repositories.MigrateIndex(s.ctx, s.source.repo, s.target.repo, 1)
+	s.NoError(err)
+	s.True(report.Verified)
+	s.Equal(len(test_commons.TestData), report.Copied)
+	s.Equal(len(test_commons.TestData), report.Target.Icons)
+	s.Equal(report.Source.Checksum, report.Target.Checksum)
+
+	for _, icon := range test_commons.TestData {
+		migrated, describeErr := s.target.DescribeIcon(s.ctx, icon.Name)
+		s.NoError(describeErr)
+		s.Equal(icon.ModifiedBy, migrated.ModifiedBy)
+		s.Equal(len(icon.Iconfiles), len(migrated.Iconfiles))
+		s.ElementsMatch(icon.Tags, migrated.Tags)
+	}
+}
+
+func (s *indexMigrationTestSuite) TestResumesInterruptedMigration() {
+	s.addTestData(&s.source)
+
+	// An earlier run stopped half-way through the first icon
+	icon := test_commons.TestData[0]
+	s.NoError(s.target.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, nil))
+
+	report, err := repositories.MigrateIndex(s.ctx, s.source.repo, s.target.repo, 10)
+	s.NoError(err)
+	s.True(report.Verified)
+	s.Equal(len(test_commons.TestData), report.Copied)
+
+	// Nothing is left to be copied
+	report, err = repositories.MigrateIndex(s.ctx, s.source.repo, s.target.repo, 10)
+	s.NoError(err)
+	s.True(report.Verified)
+	s.Equal(0, report.Copied)
+}
+
+func (s *indexMigrationTestSuite) TestVerificationFailsOnDifferentTarget() {
+	s.addTestData(&s.source)
+
+	extra := domain.IconfileDescriptor{Format: "png", Size: "1024px"}
+	icon := test_commons.TestData[1]
+	s.NoError(s.target.CreateIcon(s.ctx, icon.Name, extra, icon.ModifiedBy, nil))
+
+	report, err := repositories.MigrateIndex(s.ctx, s.source.repo, s.target.repo, 10)
+	s.NoError(err)
+	s.False(report.Verified)
+	s.NotEqual(report.Source.Checksum, report.Target.Checksum)
+}
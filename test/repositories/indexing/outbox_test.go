@@ -0,0 +1,126 @@
+package indexing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"iconrepo/internal/app/domain"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type outboxTestSuite struct {
+	IndexingTestSuite
+}
+
+func TestOutboxTestSuite(t *testing.T) {
+	for _, testSuite := range indexingTestSuites() {
+		suite.Run(t, &outboxTestSuite{testSuite})
+	}
+}
+
+func (s *outboxTestSuite) addEntry(recordedAt time.Time, iconName string) string {
+	return s.addHeldEntry(recordedAt, time.Time{}, iconName)
+}
+
+func (s *outboxTestSuite) addHeldEntry(recordedAt time.Time, heldUntil time.Time, iconName string) string {
+	id, err := s.testRepoController.AddOutboxEntry(s.ctx, domain.OutboxEntry{
+		RecordedAt: recordedAt,
+		HeldUntil:  heldUntil,
+		Actor:      "ux",
+		Changes: []domain.BlobstoreChange{
+			{
+				Kind:     domain.BlobstoreChangeAddIconfile,
+				IconName: iconName,
+				Iconfile: domain.Iconfile{
+					IconfileDescriptor: domain.IconfileDescriptor{Format: "png", Size: "36px"},
+					Content:            []byte{0x89, 0x50, 0x4e, 0x47},
+				},
+			},
+		},
+	})
+	s.Require().NoError(err)
+	s.NotEmpty(id)
+	return id
+}
+
+func (s *outboxTestSuite) iconNames(entries []domain.OutboxEntry) []string {
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Changes[0].IconName)
+	}
+	return names
+}
+
+func (s *outboxTestSuite) TestEntriesComeOldestFirst() {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	s.addEntry(start.Add(time.Second), "cast_connected")
+	s.addEntry(start, "attach_money")
+
+	entries, err := s.testRepoController.GetOutboxEntries(s.ctx, time.Now())
+	s.NoError(err)
+	s.Equal([]string{"attach_money", "cast_connected"}, s.iconNames(entries))
+
+	entry := entries[0]
+	s.True(start.Equal(entry.RecordedAt))
+	s.Equal("ux", entry.Actor)
+	s.Equal(0, entry.Attempts)
+	s.Equal("", entry.LastError)
+	s.Equal(domain.BlobstoreChangeAddIconfile, entry.Changes[0].Kind)
+	s.Equal(domain.IconfileDescriptor{Format: "png", Size: "36px"}, entry.Changes[0].Iconfile.IconfileDescriptor)
+	s.Equal([]byte{0x89, 0x50, 0x4e, 0x47}, entry.Changes[0].Iconfile.Content)
+}
+
+func (s *outboxTestSuite) TestOnlyEntriesRecordedBeforeAreReturned() {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	s.addEntry(start, "attach_money")
+	s.addEntry(start.Add(time.Minute), "cast_connected")
+
+	entries, err := s.testRepoController.GetOutboxEntries(s.ctx, start.Add(time.Second))
+	s.NoError(err)
+	s.Equal([]string{"attach_money"}, s.iconNames(entries))
+}
+
+func (s *outboxTestSuite) TestHeldEntriesAreLeftAlone() {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	s.addHeldEntry(start, start.Add(30*time.Minute), "attach_money")
+	s.addEntry(start.Add(time.Second), "cast_connected")
+
+	entries, err := s.testRepoController.GetOutboxEntries(s.ctx, start.Add(time.Minute))
+	s.NoError(err)
+	s.Equal([]string{"cast_connected"}, s.iconNames(entries))
+
+	entries, err = s.testRepoController.GetOutboxEntries(s.ctx, time.Now())
+	s.NoError(err)
+	s.Equal([]string{"attach_money", "cast_connected"}, s.iconNames(entries))
+	s.True(start.Add(30 * time.Minute).Equal(entries[0].HeldUntil))
+	s.True(entries[1].HeldUntil.IsZero())
+}
+
+func (s *outboxTestSuite) TestAttemptsAreRecorded() {
+	id := s.addEntry(time.Now().Add(-time.Hour).Truncate(time.Second), "attach_money")
+
+	s.NoError(s.testRepoController.RecordOutboxAttempt(s.ctx, id, errors.New("blobstore unavailable")))
+	s.NoError(s.testRepoController.RecordOutboxAttempt(s.ctx, id, errors.New("still unavailable")))
+
+	entries, err := s.testRepoController.GetOutboxEntries(s.ctx, time.Now())
+	s.NoError(err)
+	s.Equal(1, len(entries))
+	s.Equal(2, entries[0].Attempts)
+	s.Equal("still unavailable", entries[0].LastError)
+}
+
+func (s *outboxTestSuite) TestRemovedEntriesAreGone() {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	id := s.addEntry(start, "attach_money")
+	s.addEntry(start.Add(time.Second), "cast_connected")
+
+	s.NoError(s.testRepoController.RemoveOutboxEntry(s.ctx, id))
+	// Removing an entry no longer there is not an error: it may have been settled in the meantime
+	s.NoError(s.testRepoController.RemoveOutboxEntry(s.ctx, id))
+
+	entries, err := s.testRepoController.GetOutboxEntries(s.ctx, time.Now())
+	s.NoError(err)
+	s.Equal([]string{"cast_connected"}, s.iconNames(entries))
+}
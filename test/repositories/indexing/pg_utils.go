@@ -56,7 +56,7 @@ func (pgTestRepo *PgTestRepository) ResetData(ctx context.Context) error {
 	}
 	defer tx.Rollback()
 
-	tables := []string{"icon", "icon_file", "tag", "icon_to_tags"}
+	tables := []string{"icon", "icon_file", "tag", "icon_to_tags", "audit", "outbox"}
 	for _, table := range tables {
 		_, err = tx.Exec("DELETE FROM " + table)
 		if err != nil {
@@ -0,0 +1,55 @@
+package indexing
+
+import (
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type reviewStatusTestSuite struct {
+	IndexingTestSuite
+}
+
+func TestReviewStatusTestSuite(t *testing.T) {
+	for _, testSuite := range indexingTestSuites() {
+		suite.Run(t, &reviewStatusTestSuite{testSuite})
+	}
+}
+
+func (s *reviewStatusTestSuite) TestReviewStatusIsKeptPerIconfile() {
+	icon := test_commons.TestData[0]
+	draft := icon.Iconfiles[0].IconfileDescriptor
+	draft.ReviewStatus = domain.ReviewStatusDraft
+	published := icon.Iconfiles[1].IconfileDescriptor
+
+	err := s.testRepoController.CreateIcon(s.ctx, icon.Name, draft, icon.ModifiedBy, nil)
+	s.NoError(err)
+	err = s.testRepoController.AddIconfileToIcon(s.ctx, icon.Name, published, icon.ModifiedBy, nil)
+	s.NoError(err)
+
+	err = s.testRepoController.SetIconfileReviewStatus(s.ctx, icon.Name, draft, domain.ReviewStatusInReview, "approver")
+	s.NoError(err)
+
+	iconDesc, err := s.testRepoController.DescribeIcon(s.ctx, icon.Name)
+	s.NoError(err)
+	s.Equal("approver", iconDesc.ModifiedBy)
+	inReview, err := iconDesc.FindIconfile(draft)
+	s.NoError(err)
+	s.Equal(domain.ReviewStatusInReview, inReview.ReviewStatus)
+	stillPublished, err := iconDesc.FindIconfile(published)
+	s.NoError(err)
+	s.Equal(domain.ReviewStatusPublished, stillPublished.ReviewStatus)
+}
+
+func (s *reviewStatusTestSuite) TestSetReviewStatusOfMissingIconfile() {
+	icon := test_commons.TestData[0]
+
+	err := s.testRepoController.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, icon.ModifiedBy, nil)
+	s.NoError(err)
+
+	err = s.testRepoController.SetIconfileReviewStatus(s.ctx, icon.Name, domain.IconfileDescriptor{Format: "gif", Size: "1px"}, domain.ReviewStatusPublished, "approver")
+	s.ErrorIs(err, domain.ErrIconfileNotFound)
+}
@@ -0,0 +1,55 @@
+package indexing
+
+import (
+	"context"
+	"fmt"
+	"iconrepo/internal/repositories/indexing"
+	"iconrepo/internal/repositories/indexing/sqlite"
+)
+
+type SQLiteTestRepository struct {
+	*sqlite.SQLiteRepository
+}
+
+func (sqliteTestRepo *SQLiteTestRepository) countRows(ctx context.Context, table string) (int, error) {
+	var rowCount int
+	err := sqliteTestRepo.Conn.Reader.QueryRowContext(ctx, "select count(*) as row_count from "+table).Scan(&rowCount)
+	if err != nil {
+		return 0, err
+	}
+	return rowCount, nil
+}
+
+func (sqliteTestRepo *SQLiteTestRepository) GetIconCount(ctx context.Context) (int, error) {
+	return sqliteTestRepo.countRows(ctx, "icon")
+}
+
+func (sqliteTestRepo *SQLiteTestRepository) GetIconFileCount(ctx context.Context) (int, error) {
+	return sqliteTestRepo.countRows(ctx, "icon_file")
+}
+
+func (sqliteTestRepo *SQLiteTestRepository) GetTagRelationCount(ctx context.Context) (int, error) {
+	return sqliteTestRepo.countRows(ctx, "icon_to_tags")
+}
+
+func (sqliteTestRepo *SQLiteTestRepository) ResetData(ctx context.Context) error {
+	tx, err := sqliteTestRepo.Conn.Writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start Tx for deleting test data: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The icons go first, taking their references to the tags with them
+	tables := []string{"icon", "icon_file", "tag", "icon_to_tags", "audit", "outbox"}
+	for _, table := range tables {
+		_, err = tx.Exec("DELETE FROM " + table)
+		if err != nil {
+			if sqlite.IsDBError(err, indexing.ErrTableNotFound) {
+				continue
+			}
+			return fmt.Errorf("failed to delete test data from table %s: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
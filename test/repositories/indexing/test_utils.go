@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"iconrepo/internal/app/domain"
 	"iconrepo/internal/config"
 	"iconrepo/internal/logging"
 	"iconrepo/internal/repositories"
 	"iconrepo/internal/repositories/indexing/dynamodb"
+	"iconrepo/internal/repositories/indexing/memory"
 	"iconrepo/internal/repositories/indexing/pgdb"
+	"iconrepo/internal/repositories/indexing/sqlite"
 	"iconrepo/test/test_commons"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
@@ -27,6 +31,8 @@ type IndexRepoTestExtension interface {
 
 type TestIndexRepository interface {
 	repositories.IndexRepository
+	repositories.AuditRepository
+	repositories.OutboxRepository
 	IndexRepoTestExtension
 }
 
@@ -67,23 +73,35 @@ func (ctl *IndexTestRepoController) DescribeAllIcons(ctx context.Context) ([]dom
 	return ctl.repo.DescribeAllIcons(ctx)
 }
 
-func (ctl *IndexTestRepoController) CreateIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func() error) error {
+func (ctl *IndexTestRepoController) CreateIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
 	return ctl.repo.CreateIcon(ctx, iconName, iconfile, modifiedBy, createSideEffect)
 }
 
-func (ctl *IndexTestRepoController) AddIconfileToIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func() error) error {
+func (ctl *IndexTestRepoController) AddIconfileToIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
 	return ctl.repo.AddIconfileToIcon(ctx, iconName, iconfile, modifiedBy, createSideEffect)
 }
 
 func (ctl *IndexTestRepoController) AddTag(ctx context.Context, iconName string, tag string, modifiedBy string) error {
-	return ctl.repo.AddTag(ctx, iconName, tag, modifiedBy)
+	return ctl.repo.AddTag(ctx, iconName, tag, modifiedBy, nil)
+}
+
+func (ctl *IndexTestRepoController) AddTagWithSideEffect(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	return ctl.repo.AddTag(ctx, iconName, tag, modifiedBy, createSideEffect)
+}
+
+func (ctl *IndexTestRepoController) RemoveTag(ctx context.Context, iconName string, tag string, modifiedBy string) error {
+	return ctl.repo.RemoveTag(ctx, iconName, tag, modifiedBy, nil)
 }
 
 func (ctl *IndexTestRepoController) GetExistingTags(ctx context.Context) ([]string, error) {
 	return ctl.repo.GetExistingTags(ctx)
 }
 
-func (ctl *IndexTestRepoController) DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func() error) error {
+func (ctl *IndexTestRepoController) GetIconsWithTag(ctx context.Context, tag string) ([]domain.IconDescriptor, error) {
+	return ctl.repo.GetIconsWithTag(ctx, tag)
+}
+
+func (ctl *IndexTestRepoController) DeleteIcon(ctx context.Context, iconName string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
 	return ctl.repo.DeleteIcon(ctx, iconName, modifiedBy, createSideEffect)
 }
 
@@ -95,10 +113,42 @@ func (ctl *IndexTestRepoController) GetTagRelationCount(ctx context.Context) (in
 	return ctl.repo.GetTagRelationCount(ctx)
 }
 
-func (ctl *IndexTestRepoController) DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func() error) error {
+func (ctl *IndexTestRepoController) DeleteIconfile(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
 	return ctl.repo.DeleteIconfile(ctx, iconName, iconfile, modifiedBy, createSideEffect)
 }
 
+func (ctl *IndexTestRepoController) SetIconfileReviewStatus(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, status domain.ReviewStatus, modifiedBy string) error {
+	return ctl.repo.SetIconfileReviewStatus(ctx, iconName, iconfile, status, modifiedBy, nil)
+}
+
+func (ctl *IndexTestRepoController) SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy string) error {
+	return ctl.repo.SetIconDescription(ctx, iconName, description, modifiedBy, nil)
+}
+
+func (ctl *IndexTestRepoController) RecordAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	return ctl.repo.RecordAuditEntry(ctx, entry)
+}
+
+func (ctl *IndexTestRepoController) QueryAuditEntries(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	return ctl.repo.QueryAuditEntries(ctx, query)
+}
+
+func (ctl *IndexTestRepoController) AddOutboxEntry(ctx context.Context, entry domain.OutboxEntry) (string, error) {
+	return ctl.repo.AddOutboxEntry(ctx, entry)
+}
+
+func (ctl *IndexTestRepoController) RemoveOutboxEntry(ctx context.Context, id string) error {
+	return ctl.repo.RemoveOutboxEntry(ctx, id)
+}
+
+func (ctl *IndexTestRepoController) GetOutboxEntries(ctx context.Context, before time.Time) ([]domain.OutboxEntry, error) {
+	return ctl.repo.GetOutboxEntries(ctx, before)
+}
+
+func (ctl *IndexTestRepoController) RecordOutboxAttempt(ctx context.Context, id string, attemptErr error) error {
+	return ctl.repo.RecordOutboxAttempt(ctx, id, attemptErr)
+}
+
 func NewTestPgRepo(conf *config.Options) (TestIndexRepository, error) {
 	connection, err := pgdb.NewDBConnection(*conf)
 	if err != nil {
@@ -115,7 +165,36 @@ func NewTestPgRepo(conf *config.Options) (TestIndexRepository, error) {
 	}, nil
 }
 
+// NewTestMemoryRepo returns the in-memory index shared with the servers started by the test
+func NewTestMemoryRepo(conf *config.Options) (TestIndexRepository, error) {
+	conf.Storage = config.StorageMemory
+	return &MemoryTestRepository{memory.Shared(conf.DBSchemaName)}, nil
+}
+
+// NewTestSQLiteRepo opens a database file of the test process in the temporary directory
+func NewTestSQLiteRepo(conf *config.Options) (TestIndexRepository, error) {
+	conf.SQLiteFile = filepath.Join(os.TempDir(), "tmp-iconrepo-test", fmt.Sprintf("%d-%s.sqlite", os.Getpid(), conf.DBSchemaName))
+	if mkdirErr := os.MkdirAll(filepath.Dir(conf.SQLiteFile), 0700); mkdirErr != nil {
+		return nil, mkdirErr
+	}
+	connection, err := sqlite.NewDBConnection(conf.SQLiteFile)
+	if err != nil {
+		return nil, err
+	}
+	_, schemaErr := sqlite.OpenSchema(connection)
+	if schemaErr != nil {
+		return nil, schemaErr
+	}
+
+	sqliteRepo := sqlite.NewSQLiteRepository(connection)
+	return &SQLiteTestRepository{
+		SQLiteRepository: &sqliteRepo,
+	}, nil
+}
+
+// NewTestDynamodbRepo creates the tables missing, so that the tests can run against an empty dynamodb-local
 func NewTestDynamodbRepo(conf *config.Options) (TestIndexRepository, error) {
+	conf.DynamodbCreateTables = true
 	connection, err := dynamodb.NewDynamodbRepository(conf)
 	if err != nil {
 		return nil, err
@@ -167,13 +246,31 @@ var PgIndexTestRepoController IndexTestRepoController = IndexTestRepoController{
 	},
 }
 
+var SQLiteIndexTestRepoController IndexTestRepoController = IndexTestRepoController{
+	repoFactory: func(conf *config.Options) (TestIndexRepository, error) {
+		return NewTestSQLiteRepo(conf)
+	},
+}
+
 var DynamodbIndexTestRepoController IndexTestRepoController = IndexTestRepoController{
 	repoFactory: func(conf *config.Options) (TestIndexRepository, error) {
 		return NewTestDynamodbRepo(conf)
 	},
 }
 
+var MemoryIndexTestRepoController IndexTestRepoController = IndexTestRepoController{
+	repoFactory: func(conf *config.Options) (TestIndexRepository, error) {
+		return NewTestMemoryRepo(conf)
+	},
+}
+
 func DefaultIndexTestRepoController() *IndexTestRepoController {
+	if len(os.Getenv("MEMORY_ONLY")) > 0 {
+		return &MemoryIndexTestRepoController
+	}
+	if len(os.Getenv("SQLITE_ONLY")) > 0 {
+		return &SQLiteIndexTestRepoController
+	}
 	if len(os.Getenv("PG_ONLY")) > 0 {
 		fmt.Print(">>>>>>>>>>> Indexing provider: PG_ONLY\n")
 		return &PgIndexTestRepoController
@@ -192,6 +289,7 @@ func IndexProvidersToTest() []IndexTestRepoController {
 		return []IndexTestRepoController{
 			PgIndexTestRepoController,
 			DynamodbIndexTestRepoController,
+			SQLiteIndexTestRepoController,
 		}
 	}
 	return []IndexTestRepoController{*defaultCtrl}
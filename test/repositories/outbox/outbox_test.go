@@ -0,0 +1,368 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authn"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/repositories"
+	memory_blobstore "iconrepo/internal/repositories/blobstore/memory"
+	memory_index "iconrepo/internal/repositories/indexing/memory"
+	"iconrepo/test/test_commons"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var errSimulated = errors.New("simulated failure")
+
+type failureMode int
+
+const (
+	noFailure failureMode = iota
+	// the blobstore is written to, but the index is not
+	failIndexAfterSideEffect
+	// the index keeps the change, though the side-effect has failed and the change is reported as failed
+	failAfterIndexCommitted
+)
+
+// unreliableIndex simulates the index and the blobstore parting ways during a change
+type unreliableIndex struct {
+	*memory_index.Index
+	failure failureMode
+}
+
+func (index *unreliableIndex) write(ctx context.Context, createSideEffect func(ctx context.Context) error, write func(createSideEffect func(ctx context.Context) error) error) error {
+	switch index.failure {
+	case failIndexAfterSideEffect:
+		if sideEffectErr := createSideEffect(ctx); sideEffectErr != nil {
+			return sideEffectErr
+		}
+		return errSimulated
+	case failAfterIndexCommitted:
+		writeErr := write(func(ctx context.Context) error {
+			_ = createSideEffect(ctx)
+			return nil
+		})
+		if writeErr != nil {
+			return writeErr
+		}
+		return errSimulated
+	default:
+		return write(createSideEffect)
+	}
+}
+
+func (index *unreliableIndex) CreateIcon(ctx context.Context, iconName string, iconfile domain.IconfileDescriptor, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	return index.write(ctx, createSideEffect, func(sideEffect func(ctx context.Context) error) error {
+		return index.Index.CreateIcon(ctx, iconName, iconfile, modifiedBy, sideEffect)
+	})
+}
+
+func (index *unreliableIndex) AddTag(ctx context.Context, iconName string, tag string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	return index.write(ctx, createSideEffect, func(sideEffect func(ctx context.Context) error) error {
+		return index.Index.AddTag(ctx, iconName, tag, modifiedBy, sideEffect)
+	})
+}
+
+func (index *unreliableIndex) SetIconDescription(ctx context.Context, iconName string, description string, modifiedBy string, createSideEffect func(ctx context.Context) error) error {
+	return index.write(ctx, createSideEffect, func(sideEffect func(ctx context.Context) error) error {
+		return index.Index.SetIconDescription(ctx, iconName, description, modifiedBy, sideEffect)
+	})
+}
+
+// unavailableBlobstore fails every write while down, or the writes of metadata only while metadataDown
+type unavailableBlobstore struct {
+	*memory_blobstore.Blobstore
+	down         bool
+	metadataDown bool
+}
+
+func (blobstore *unavailableBlobstore) AddIconfile(ctx context.Context, iconName string, iconfile domain.Iconfile, modifiedBy string) error {
+	if blobstore.down {
+		return errSimulated
+	}
+	return blobstore.Blobstore.AddIconfile(ctx, iconName, iconfile, modifiedBy)
+}
+
+func (blobstore *unavailableBlobstore) UpdateIconMetadata(ctx context.Context, iconName string, metadata domain.IconMetadata, modifiedBy string) error {
+	if blobstore.down || blobstore.metadataDown {
+		return errSimulated
+	}
+	return blobstore.Blobstore.UpdateIconMetadata(ctx, iconName, metadata, modifiedBy)
+}
+
+// unavailableAuditLog fails to record entries while down
+type unavailableAuditLog struct {
+	*memory_index.Index
+	down bool
+}
+
+func (auditLog *unavailableAuditLog) RecordAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	if auditLog.down {
+		return errSimulated
+	}
+	return auditLog.Index.RecordAuditEntry(ctx, entry)
+}
+
+type outboxTestSuite struct {
+	suite.Suite
+	ctx       context.Context
+	index     *unreliableIndex
+	blobstore *unavailableBlobstore
+	combo     *repositories.RepoCombo
+	user      authr.UserInfo
+}
+
+func TestOutboxTestSuite(t *testing.T) {
+	suite.Run(t, &outboxTestSuite{ctx: context.Background()})
+}
+
+func (s *outboxTestSuite) BeforeTest(suiteName string, testName string) {
+	s.index = &unreliableIndex{Index: memory_index.NewIndex()}
+	s.blobstore = &unavailableBlobstore{Blobstore: memory_blobstore.NewBlobstore()}
+	s.combo = &repositories.RepoCombo{Index: s.index, Blobstore: s.blobstore, Outbox: s.index.Index}
+	s.user = authr.UserInfo{UserId: authn.LocalDomain.CreateUserID("ux")}
+}
+
+func (s *outboxTestSuite) pendingEntries() []domain.OutboxEntry {
+	entries, err := s.index.GetOutboxEntries(s.ctx, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	return entries
+}
+
+func (s *outboxTestSuite) settle() domain.OutboxReport {
+	report, err := s.combo.RetryPendingChanges(s.ctx, time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	return report
+}
+
+func (s *outboxTestSuite) TestSuccessfulChangeLeavesNoEntry() {
+	icon := test_commons.TestData[0]
+	s.NoError(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user))
+	s.NoError(s.combo.AddTag(s.ctx, icon.Name, "money", s.user))
+
+	s.Empty(s.pendingEntries())
+}
+
+func (s *outboxTestSuite) TestMetadataIsWrittenFromTheIndex() {
+	icon := test_commons.TestData[0]
+	s.NoError(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user))
+	indexed, describeErr := s.index.DescribeIcon(s.ctx, icon.Name)
+	s.Require().NoError(describeErr)
+
+	metadata, getErr := s.blobstore.GetIconMetadata(s.ctx, icon.Name)
+	s.NoError(getErr)
+	s.Equal(indexed.Metadata(), metadata)
+
+	s.NoError(s.combo.AddTag(s.ctx, icon.Name, "money", s.user))
+	s.NoError(s.combo.DeleteIconfile(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor, s.user))
+	_, getErr = s.blobstore.GetIconMetadata(s.ctx, icon.Name)
+	s.ErrorIs(getErr, domain.ErrIconMetadataNotFound)
+}
+
+func (s *outboxTestSuite) TestChangeFailingBeforeTheBlobstoreLeavesNoEntry() {
+	icon := test_commons.TestData[0]
+	s.NoError(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user))
+
+	// The index refuses the change before the blobstore is written to
+	s.Error(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user))
+
+	s.Empty(s.pendingEntries())
+}
+
+func (s *outboxTestSuite) TestIconfileStoredButNotIndexedIsDeleted() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	s.index.failure = failIndexAfterSideEffect
+	s.ErrorIs(s.combo.CreateIcon(s.ctx, icon.Name, iconfile, s.user), errSimulated)
+	s.Equal(1, len(s.pendingEntries()))
+	_, getErr := s.blobstore.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(getErr)
+
+	s.Equal(domain.OutboxReport{Settled: 1}, s.settle())
+
+	_, getErr = s.blobstore.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.ErrorIs(getErr, domain.ErrIconfileNotFound)
+	_, describeErr := s.index.DescribeIcon(s.ctx, icon.Name)
+	s.ErrorIs(describeErr, domain.ErrIconNotFound)
+	s.Empty(s.pendingEntries())
+}
+
+func (s *outboxTestSuite) TestChangeFailsIfItsAuditEntryFailsToBeRecorded() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	auditLog := &unavailableAuditLog{Index: s.index.Index, down: true}
+	s.combo.Audit = auditLog
+	s.ErrorIs(s.combo.CreateIcon(s.ctx, icon.Name, iconfile, s.user), errSimulated)
+
+	_, describeErr := s.index.DescribeIcon(s.ctx, icon.Name)
+	s.ErrorIs(describeErr, domain.ErrIconNotFound)
+	s.Equal(domain.OutboxReport{Settled: 1}, s.settle())
+	_, getErr := s.blobstore.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.ErrorIs(getErr, domain.ErrIconfileNotFound)
+
+	auditLog.down = false
+	s.NoError(s.combo.CreateIcon(s.ctx, icon.Name, iconfile, s.user))
+	page, queryErr := s.combo.GetAuditEntries(s.ctx, domain.AuditQuery{IconName: icon.Name})
+	s.NoError(queryErr)
+	s.Require().Len(page.Entries, 1)
+	s.Equal(domain.AuditActionCreateIcon, page.Entries[0].Action)
+	s.Require().NotNil(page.Entries[0].After)
+	s.Equal([]domain.IconfileDescriptor{iconfile.IconfileDescriptor}, page.Entries[0].After.Iconfiles)
+}
+
+func (s *outboxTestSuite) TestIconfileIndexedButNotStoredIsWrittenFromTheEntry() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	s.index.failure = failAfterIndexCommitted
+	s.blobstore.down = true
+	s.ErrorIs(s.combo.CreateIcon(s.ctx, icon.Name, iconfile, s.user), errSimulated)
+	s.blobstore.down = false
+
+	s.Equal(domain.OutboxReport{Settled: 1}, s.settle())
+
+	content, getErr := s.blobstore.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(getErr)
+	s.Equal(iconfile.Content, content)
+	iconDesc, describeErr := s.index.DescribeIcon(s.ctx, icon.Name)
+	s.NoError(describeErr)
+	s.Equal([]domain.IconfileDescriptor{iconfile.IconfileDescriptor}, iconDesc.Iconfiles)
+	s.Empty(s.pendingEntries())
+}
+
+func (s *outboxTestSuite) TestMetadataIsSyncedWithTheIndex() {
+	icon := test_commons.TestData[0]
+	s.NoError(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user))
+	s.index.failure = failAfterIndexCommitted
+	s.blobstore.down = true
+	s.ErrorIs(s.combo.AddTag(s.ctx, icon.Name, "money", s.user), errSimulated)
+	s.blobstore.down = false
+
+	s.Equal(domain.OutboxReport{Settled: 1}, s.settle())
+
+	metadata, getErr := s.blobstore.GetIconMetadata(s.ctx, icon.Name)
+	s.NoError(getErr)
+	s.Equal([]string{"money"}, metadata.Tags)
+}
+
+func (s *outboxTestSuite) TestDescriptionIsSyncedWithTheIndex() {
+	icon := test_commons.TestData[0]
+	s.NoError(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user))
+	s.index.failure = failAfterIndexCommitted
+	s.blobstore.down = true
+	s.ErrorIs(s.combo.SetIconDescription(s.ctx, icon.Name, "Money sign", s.user), errSimulated)
+	s.blobstore.down = false
+
+	s.Equal(domain.OutboxReport{Settled: 1}, s.settle())
+
+	metadata, getErr := s.blobstore.GetIconMetadata(s.ctx, icon.Name)
+	s.NoError(getErr)
+	s.Equal("Money sign", metadata.Description)
+}
+
+func (s *outboxTestSuite) TestChangesetFailingHalfwayIsUndone() {
+	icon := test_commons.TestData[0]
+	s.NoError(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user))
+	metadataBefore, getErr := s.blobstore.GetIconMetadata(s.ctx, icon.Name)
+	s.Require().NoError(getErr)
+
+	// The iconfiles are deleted and added before the metadata fails to be written
+	s.blobstore.metadataDown = true
+	_, changesetErr := s.combo.ApplyChangeset(s.ctx, []domain.ChangesetOperation{
+		{Action: domain.AuditActionAddIconfile, IconName: icon.Name, Iconfile: icon.Iconfiles[1]},
+		{Action: domain.AuditActionDeleteIconfile, IconName: icon.Name, Iconfile: domain.Iconfile{IconfileDescriptor: icon.Iconfiles[0].IconfileDescriptor}},
+	}, s.user)
+	s.ErrorIs(changesetErr, errSimulated)
+	s.blobstore.metadataDown = false
+
+	content, getErr := s.blobstore.GetIconfile(s.ctx, icon.Name, icon.Iconfiles[0].IconfileDescriptor)
+	s.NoError(getErr)
+	s.Equal(icon.Iconfiles[0].Content, content)
+	_, getErr = s.blobstore.GetIconfile(s.ctx, icon.Name, icon.Iconfiles[1].IconfileDescriptor)
+	s.ErrorIs(getErr, domain.ErrIconfileNotFound)
+	metadata, getErr := s.blobstore.GetIconMetadata(s.ctx, icon.Name)
+	s.NoError(getErr)
+	s.Equal(metadataBefore, metadata)
+}
+
+func (s *outboxTestSuite) TestIconfileAddedIsRemovedIfTheMetadataFailsToBeWritten() {
+	icon := test_commons.TestData[0]
+	s.blobstore.metadataDown = true
+	s.ErrorIs(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user), errSimulated)
+
+	stored, listErr := s.blobstore.GetIconfiles(s.ctx)
+	s.NoError(listErr)
+	s.Empty(stored)
+	_, getErr := s.blobstore.GetIconMetadata(s.ctx, icon.Name)
+	s.ErrorIs(getErr, domain.ErrIconMetadataNotFound)
+}
+
+func (s *outboxTestSuite) TestFailedAttemptIsRecordedAndRetried() {
+	icon := test_commons.TestData[0]
+	s.index.failure = failAfterIndexCommitted
+	s.blobstore.down = true
+	s.ErrorIs(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user), errSimulated)
+
+	s.Equal(domain.OutboxReport{Failed: 1}, s.settle())
+	entries := s.pendingEntries()
+	s.Equal(1, len(entries))
+	s.Equal(1, entries[0].Attempts)
+	s.Contains(entries[0].LastError, errSimulated.Error())
+
+	s.blobstore.down = false
+	s.Equal(domain.OutboxReport{Settled: 1}, s.settle())
+	s.Empty(s.pendingEntries())
+}
+
+func (s *outboxTestSuite) TestEntryIsHeldUntilTheDeadlineOfTheWrite() {
+	icon := test_commons.TestData[0]
+	iconfile := icon.Iconfiles[0]
+	ctx, cancel := context.WithTimeout(s.ctx, time.Minute)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	s.index.failure = failIndexAfterSideEffect
+	s.ErrorIs(s.combo.CreateIcon(ctx, icon.Name, iconfile, s.user), errSimulated)
+
+	entries := s.pendingEntries()
+	s.Equal(1, len(entries))
+	s.True(deadline.Equal(entries[0].HeldUntil))
+
+	// The write may still be in progress: the iconfile is not to be deleted from under it
+	report, err := s.combo.RetryPendingChanges(s.ctx, time.Now())
+	s.NoError(err)
+	s.Equal(domain.OutboxReport{}, report)
+	_, getErr := s.blobstore.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.NoError(getErr)
+
+	report, err = s.combo.RetryPendingChanges(s.ctx, deadline.Add(time.Second))
+	s.NoError(err)
+	s.Equal(domain.OutboxReport{Settled: 1}, report)
+	_, getErr = s.blobstore.GetIconfile(s.ctx, icon.Name, iconfile.IconfileDescriptor)
+	s.ErrorIs(getErr, domain.ErrIconfileNotFound)
+}
+
+func (s *outboxTestSuite) TestWritesWithoutDeadlineAreGivenOne() {
+	icon := test_commons.TestData[0]
+	s.index.failure = failIndexAfterSideEffect
+	s.ErrorIs(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user), errSimulated)
+
+	entries := s.pendingEntries()
+	s.Equal(1, len(entries))
+	s.True(entries[0].HeldUntil.After(entries[0].RecordedAt))
+	s.True(entries[0].HeldUntil.Before(time.Now().Add(time.Hour)))
+}
+
+func (s *outboxTestSuite) TestRecentEntriesAreLeftAlone() {
+	icon := test_commons.TestData[0]
+	s.index.failure = failIndexAfterSideEffect
+	s.ErrorIs(s.combo.CreateIcon(s.ctx, icon.Name, icon.Iconfiles[0], s.user), errSimulated)
+
+	report, err := s.combo.RetryPendingChanges(s.ctx, time.Now().Add(-time.Minute))
+	s.NoError(err)
+	s.Equal(domain.OutboxReport{}, report)
+	s.Equal(1, len(s.pendingEntries()))
+}
@@ -1,6 +1,7 @@
 package sequential_tests
 
 import (
+	"context"
 	"iconrepo/internal/repositories/blobstore/git"
 	blobstore_tests "iconrepo/test/repositories/blobstore"
 	server_test "iconrepo/test/server"
@@ -20,11 +21,14 @@ type gitTests struct {
 
 func TestGitTestSuite(t *testing.T) {
 	for _, repoController := range blobstore_tests.BlobstoreProvidersToTest() {
-		suite.Run(t, &gitTests{BlobstoreTestSuite: blobstore_tests.BlobstoreTestSuite{RepoController: repoController, TestSequenceId: "simulated_git_failuer"}})
+		suite.Run(t, &gitTests{BlobstoreTestSuite: blobstore_tests.BlobstoreTestSuite{RepoController: repoController, TestSequenceId: "simulated_git_failuer", Ctx: context.Background()}})
 	}
 }
 
 func (s *gitTests) TestRemainsConsistentAfterAddingIconfileFails() {
+	if !s.RepoController.HasCommits() {
+		s.T().Skip("no git commit to fail")
+	}
 	icon := test_commons.TestData[0]
 	iconfile1 := icon.Iconfiles[0]
 	iconfile2 := icon.Iconfiles[1]
@@ -60,6 +64,9 @@ func TestIconCreateTestSuite(t *testing.T) {
 }
 
 func (s *iconCreateTests) TestRollbackToLastConsistentStateOnError() {
+	if !s.TestBlobstoreController.HasCommits() {
+		s.T().Skip("no git commit to fail")
+	}
 	dataIn, dataOut := testdata.Get()
 	moreDataIn, _ := testdata.GetMore()
 
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/test/testdata"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type auditTestSuite struct {
+	IconTestSuite
+}
+
+func TestAuditTestSuite(t *testing.T) {
+	t.Parallel()
+	for _, iconSuite := range IconTestSuites("api_audit") {
+		suite.Run(t, &auditTestSuite{IconTestSuite: iconSuite})
+	}
+}
+
+func (s *auditTestSuite) TestChangesAreAudited() {
+	dataIn, _ := testdata.Get()
+	iconIn := dataIn[0]
+	tag := "Ahoj"
+
+	session := s.mustLoginAsAdmin()
+	session.MustAddTestData(dataIn)
+
+	statusCode, err := session.addTag(iconIn.Name, tag)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+
+	statusCode, err = session.removeTag(iconIn.Name, tag)
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	statusCode, page, err := session.getAuditEntries(url.Values{"icon": {iconIn.Name}}.Encode())
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.Equal(len(iconIn.Iconfiles)+2, len(page.Entries))
+
+	removeTagEntry := page.Entries[0]
+	s.Equal(domain.AuditActionRemoveTag, removeTagEntry.Action)
+	s.Equal(tag, removeTagEntry.Tag)
+	s.Equal(testdata.DefaultCredentials.Username, removeTagEntry.Actor)
+	s.NotEmpty(removeTagEntry.RequestID)
+	s.Equal([]string{tag}, removeTagEntry.Before.Tags)
+	s.Empty(removeTagEntry.After.Tags)
+
+	s.Equal(domain.AuditActionAddTag, page.Entries[1].Action)
+
+	createEntry := page.Entries[len(page.Entries)-1]
+	s.Equal(domain.AuditActionCreateIcon, createEntry.Action)
+	s.Nil(createEntry.Before)
+	s.Equal(iconIn.Iconfiles[0].IconfileDescriptor, *createEntry.Iconfile)
+}
+
+func (s *auditTestSuite) TestDeletionIsAudited() {
+	dataIn, _ := testdata.Get()
+	iconIn := dataIn[0]
+
+	session := s.mustLoginAsAdmin()
+	session.MustAddTestData(dataIn)
+
+	statusCode, err := session.deleteIcon(iconIn.Name)
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	statusCode, page, err := session.getAuditEntries(url.Values{"icon": {iconIn.Name}, "limit": {"1"}}.Encode())
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.Equal(1, len(page.Entries))
+	s.NotEmpty(page.NextCursor)
+	s.Equal(domain.AuditActionDeleteIcon, page.Entries[0].Action)
+	s.Equal(iconIn.Name, page.Entries[0].Before.Name)
+	s.Nil(page.Entries[0].After)
+}
+
+func (s *auditTestSuite) TestRejectsInvalidQuery() {
+	session := s.mustLoginAsAdmin()
+
+	statusCode, _, err := session.getAuditEntries(url.Values{"since": {"yesterday"}}.Encode())
+	s.NoError(err)
+	s.Equal(http.StatusBadRequest, statusCode)
+}
+
+func (s *auditTestSuite) TestRequiresApproverOrAdminPermission() {
+	dataIn, _ := testdata.Get()
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	session.mustSetAuthorization(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	statusCode, _, err := session.getAuditEntries("")
+	s.NoError(err)
+	s.Equal(http.StatusForbidden, statusCode)
+
+	for _, group := range []authr.GroupID{authr.ICON_APPROVER, authr.REPO_ADMIN} {
+		session.mustSetAuthorization(authr.GetPermissionsForGroup(group))
+		statusCode, page, err := session.getAuditEntries("")
+		s.NoError(err)
+		s.Equal(http.StatusOK, statusCode)
+		s.NotEmpty(page.Entries)
+	}
+}
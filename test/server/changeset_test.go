@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/httpadapter"
+	"iconrepo/test/testdata"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type changesetTestSuite struct {
+	IconTestSuite
+}
+
+func TestChangesetTestSuite(t *testing.T) {
+	t.Parallel()
+	for _, iconSuite := range IconTestSuites("api_changeset") {
+		suite.Run(t, &changesetTestSuite{IconTestSuite: iconSuite})
+	}
+}
+
+func createIconsChangeset(icons []domain.Icon) []httpadapter.ChangesetOperationDTO {
+	ops := []httpadapter.ChangesetOperationDTO{}
+	for _, icon := range icons {
+		ops = append(ops, httpadapter.ChangesetOperationDTO{Op: string(domain.AuditActionCreateIcon), IconName: icon.Name, Content: icon.Iconfiles[0].Content})
+		for _, iconfile := range icon.Iconfiles[1:] {
+			ops = append(ops, httpadapter.ChangesetOperationDTO{Op: string(domain.AuditActionAddIconfile), IconName: icon.Name, Content: iconfile.Content})
+		}
+	}
+	return ops
+}
+
+func (s *changesetTestSuite) TestCreatesIconsWithIconfilesAndTags() {
+	dataIn, dataOut := testdata.Get()
+	tag := "Ahoj"
+
+	session := s.Client.MustLoginSetAllPerms()
+
+	ops := createIconsChangeset(dataIn)
+	ops = append(ops, httpadapter.ChangesetOperationDTO{Op: string(domain.AuditActionAddTag), IconName: dataIn[0].Name, Tag: tag})
+	statusCode, respIcons, err := session.applyChangeset(ops)
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+
+	dataOut[0].Tags = []string{tag}
+	s.AssertResponseIconSetsEqual(dataOut, respIcons)
+	s.AssertResponseIconSetsEqual(dataOut, session.mustDescribeAllIcons())
+	s.AssertEndState()
+}
+
+func (s *changesetTestSuite) TestChangesExistingIcons() {
+	dataIn, dataOut := testdata.Get()
+	moreDataIn, _ := testdata.GetMore()
+
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	iconName := dataIn[0].Name
+	newIconfile := moreDataIn[0].Iconfiles[1]
+	ops := []httpadapter.ChangesetOperationDTO{
+		{Op: string(domain.AuditActionDeleteIcon), IconName: dataIn[1].Name},
+		{Op: string(domain.AuditActionAddIconfile), IconName: iconName, Content: newIconfile.Content},
+		{Op: string(domain.AuditActionAddTag), IconName: iconName, Tag: "Ahoj"},
+	}
+	statusCode, respIcons, err := session.applyChangeset(ops)
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+
+	expected := dataOut[:1]
+	expected[0].Paths = append(expected[0].Paths, s.createIconfilePaths(iconName, newIconfile.IconfileDescriptor))
+	expected[0].Tags = []string{"Ahoj"}
+	s.AssertResponseIconSetsEqual(expected, respIcons)
+	s.AssertResponseIconSetsEqual(expected, session.mustDescribeAllIcons())
+	s.AssertEndState()
+}
+
+func (s *changesetTestSuite) TestChangesNothingWhenAnOperationFails() {
+	dataIn, dataOut := testdata.Get()
+	moreDataIn, _ := testdata.GetMore()
+
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	ops := createIconsChangeset(moreDataIn)
+	ops = append(ops, httpadapter.ChangesetOperationDTO{Op: string(domain.AuditActionAddTag), IconName: dataIn[0].Name, Tag: "Ahoj"})
+	ops = append(ops, httpadapter.ChangesetOperationDTO{Op: string(domain.AuditActionAddIconfile), IconName: "no-such-icon", Content: moreDataIn[0].Iconfiles[0].Content})
+	statusCode, _, err := session.applyChangeset(ops)
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, statusCode)
+
+	s.AssertResponseIconSetsEqual(dataOut, session.mustDescribeAllIcons())
+	s.AssertEndState()
+}
+
+func (s *changesetTestSuite) TestFailsWithConflictOnExistingIcon() {
+	dataIn, dataOut := testdata.Get()
+
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	statusCode, _, err := session.applyChangeset(createIconsChangeset(dataIn[:1]))
+	s.NoError(err)
+	s.Equal(http.StatusConflict, statusCode)
+
+	s.AssertResponseIconSetsEqual(dataOut, session.mustDescribeAllIcons())
+	s.AssertEndState()
+}
+
+func (s *changesetTestSuite) TestFailsWithoutPermissionForAnyOperation() {
+	dataIn, dataOut := testdata.Get()
+
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+	session.mustSetAllPermsExcept([]authr.PermissionID{authr.REMOVE_TAG})
+
+	ops := []httpadapter.ChangesetOperationDTO{
+		{Op: string(domain.AuditActionAddTag), IconName: dataIn[0].Name, Tag: "Ahoj"},
+		{Op: string(domain.AuditActionRemoveTag), IconName: dataIn[0].Name, Tag: "Ahoj"},
+	}
+	statusCode, _, err := session.applyChangeset(ops)
+	s.NoError(err)
+	s.Equal(http.StatusForbidden, statusCode)
+
+	s.AssertResponseIconSetsEqual(dataOut, session.mustDescribeAllIcons())
+}
+
+func (s *changesetTestSuite) TestRejectsUnknownOperation() {
+	dataIn, dataOut := testdata.Get()
+
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	statusCode, _, err := session.applyChangeset([]httpadapter.ChangesetOperationDTO{{Op: "renameIcon", IconName: dataIn[0].Name}})
+	s.NoError(err)
+	s.Equal(http.StatusBadRequest, statusCode)
+
+	s.AssertResponseIconSetsEqual(dataOut, session.mustDescribeAllIcons())
+}
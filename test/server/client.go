@@ -20,6 +20,11 @@ import (
 var errUnexpecteHTTPStatus = errors.New("unexpected HTTP status")
 var errJSONUnmarshal = errors.New("failed to unmarshal JSON")
 
+// isErrorResponseWithoutJSON tells whether the request failed only because the response to it, an error response, has no JSON body
+func isErrorResponseWithoutJSON(resp testResponse, err error) bool {
+	return errors.Is(err, errJSONUnmarshal) && resp.statusCode != http.StatusOK
+}
+
 var authenticationBackdoorPath = "/backdoor/authentication"
 
 type apiTestClient struct {
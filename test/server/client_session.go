@@ -8,6 +8,7 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"iconrepo/internal/app/domain"
@@ -173,6 +174,30 @@ func (session *apiTestSession) mustDescribeAllIcons() []httpadapter.IconDTO {
 	return respIcons
 }
 
+// getIconCatalog lists the icons changed since the revision given, sending ifNoneMatch unless it is empty
+func (session *apiTestSession) getIconCatalog(since string, ifNoneMatch string) (testResponse, httpadapter.IconCatalogDTO, error) {
+	request := &testRequest{
+		path:          "/icon?" + url.Values{"since": {since}}.Encode(),
+		jar:           session.cjar,
+		respBodyProto: &httpadapter.IconCatalogDTO{},
+	}
+	if len(ifNoneMatch) > 0 {
+		request.headers = map[string]string{"If-None-Match": ifNoneMatch}
+	}
+	resp, err := session.get(request)
+	if err != nil && !isErrorResponseWithoutJSON(resp, err) {
+		return resp, httpadapter.IconCatalogDTO{}, fmt.Errorf("GET %s failed: %w", request.path, err)
+	}
+	if resp.statusCode != 200 {
+		return resp, httpadapter.IconCatalogDTO{}, nil
+	}
+	catalog, ok := resp.body.(*httpadapter.IconCatalogDTO)
+	if !ok {
+		return resp, httpadapter.IconCatalogDTO{}, fmt.Errorf("failed to cast %T as httpadapter.IconCatalogDTO", resp.body)
+	}
+	return resp, *catalog, nil
+}
+
 func (session *apiTestSession) describeIcon(iconName string) (int, httpadapter.IconDTO, error) {
 	resp, err := session.get(&testRequest{
 		path:          fmt.Sprintf("/icon/%s", iconName),
@@ -348,3 +373,153 @@ func (session *apiTestSession) removeTag(iconName string, tag string) (int, erro
 
 	return resp.statusCode, err
 }
+
+func (session *apiTestSession) setIconDescription(iconName string, description string) (int, error) {
+	resp, err := session.sendRequest("PUT", &testRequest{
+		path: fmt.Sprintf("/icon/%s/description", iconName),
+		jar:  session.cjar,
+		json: true,
+		body: httpadapter.DescriptionRequestData{Description: description},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.statusCode, err
+}
+
+func (session *apiTestSession) getTags() (int, []string, error) {
+	resp, err := session.get(&testRequest{
+		path:          "/tag",
+		jar:           session.cjar,
+		respBodyProto: &[]string{},
+	})
+	if err != nil && !isErrorResponseWithoutJSON(resp, err) {
+		return resp.statusCode, []string{}, fmt.Errorf("GET /tag failed: %w", err)
+	}
+	if resp.statusCode != 200 {
+		return resp.statusCode, []string{}, nil
+	}
+	tags, ok := resp.body.(*[]string)
+	if !ok {
+		return resp.statusCode, []string{}, fmt.Errorf("failed to cast %T as []string", resp.body)
+	}
+	return resp.statusCode, *tags, nil
+}
+
+func (session *apiTestSession) getIconsWithTag(tag string) (int, []httpadapter.IconDTO, error) {
+	resp, err := session.get(&testRequest{
+		path:          fmt.Sprintf("/tag/%s/icon", tag),
+		jar:           session.cjar,
+		respBodyProto: &[]httpadapter.IconDTO{},
+	})
+	if err != nil && !isErrorResponseWithoutJSON(resp, err) {
+		return resp.statusCode, []httpadapter.IconDTO{}, fmt.Errorf("GET /tag/%s/icon failed: %w", tag, err)
+	}
+	if resp.statusCode != 200 {
+		return resp.statusCode, []httpadapter.IconDTO{}, nil
+	}
+	icons, ok := resp.body.(*[]httpadapter.IconDTO)
+	if !ok {
+		return resp.statusCode, []httpadapter.IconDTO{}, fmt.Errorf("failed to cast %T as []httpadapter.IconDTO", resp.body)
+	}
+	return resp.statusCode, *icons, nil
+}
+
+func (session *apiTestSession) getAuditEntries(query string) (int, domain.AuditPage, error) {
+	resp, err := session.get(&testRequest{
+		path:          "/audit?" + query,
+		jar:           session.cjar,
+		respBodyProto: &domain.AuditPage{},
+	})
+	if err != nil && !isErrorResponseWithoutJSON(resp, err) {
+		return resp.statusCode, domain.AuditPage{}, fmt.Errorf("GET /audit?%s failed: %w", query, err)
+	}
+	if resp.statusCode != 200 {
+		return resp.statusCode, domain.AuditPage{}, nil
+	}
+	page, ok := resp.body.(*domain.AuditPage)
+	if !ok {
+		return resp.statusCode, domain.AuditPage{}, fmt.Errorf("failed to cast %T as domain.AuditPage", resp.body)
+	}
+	return resp.statusCode, *page, nil
+}
+
+func (session *apiTestSession) updateReviewStatus(iconName string, iconfileDescriptor domain.IconfileDescriptor, status string, comment string) (int, error) {
+	resp, err := session.sendRequest("PUT", &testRequest{
+		path: fmt.Sprintf("%s/review-status", getFilePath(iconName, iconfileDescriptor)),
+		jar:  session.cjar,
+		json: true,
+		body: httpadapter.ReviewStatusRequestData{Status: status, Comment: comment},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.statusCode, err
+}
+
+func (session *apiTestSession) checkConsistency(repair bool) (int, domain.ConsistencyReport, error) {
+	method := "GET"
+	path := "/admin/consistency"
+	if repair {
+		method = "POST"
+		path = "/admin/consistency/repair"
+	}
+	resp, err := session.sendRequest(method, &testRequest{
+		path:          path,
+		jar:           session.cjar,
+		respBodyProto: &domain.ConsistencyReport{},
+	})
+	if err != nil && !isErrorResponseWithoutJSON(resp, err) {
+		return resp.statusCode, domain.ConsistencyReport{}, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	if resp.statusCode != 200 {
+		return resp.statusCode, domain.ConsistencyReport{}, nil
+	}
+	report, ok := resp.body.(*domain.ConsistencyReport)
+	if !ok {
+		return resp.statusCode, domain.ConsistencyReport{}, fmt.Errorf("failed to cast %T as domain.ConsistencyReport", resp.body)
+	}
+	return resp.statusCode, *report, nil
+}
+
+func (session *apiTestSession) reindex() (int, domain.ReindexReport, error) {
+	resp, err := session.sendRequest("POST", &testRequest{
+		path:          "/admin/reindex",
+		jar:           session.cjar,
+		respBodyProto: &domain.ReindexReport{},
+	})
+	if err != nil && !isErrorResponseWithoutJSON(resp, err) {
+		return resp.statusCode, domain.ReindexReport{}, fmt.Errorf("POST /admin/reindex failed: %w", err)
+	}
+	if resp.statusCode != 200 {
+		return resp.statusCode, domain.ReindexReport{}, nil
+	}
+	report, ok := resp.body.(*domain.ReindexReport)
+	if !ok {
+		return resp.statusCode, domain.ReindexReport{}, fmt.Errorf("failed to cast %T as domain.ReindexReport", resp.body)
+	}
+	return resp.statusCode, *report, nil
+}
+
+func (session *apiTestSession) applyChangeset(ops []httpadapter.ChangesetOperationDTO) (int, []httpadapter.IconDTO, error) {
+	resp, err := session.sendRequest("POST", &testRequest{
+		path:          "/changeset",
+		jar:           session.cjar,
+		json:          true,
+		body:          httpadapter.ChangesetRequestData{Operations: ops},
+		respBodyProto: &[]httpadapter.IconDTO{},
+	})
+	if err != nil && !isErrorResponseWithoutJSON(resp, err) {
+		return resp.statusCode, nil, fmt.Errorf("POST /changeset failed: %w", err)
+	}
+	if resp.statusCode != 200 {
+		return resp.statusCode, nil, nil
+	}
+	icons, ok := resp.body.(*[]httpadapter.IconDTO)
+	if !ok {
+		return resp.statusCode, nil, fmt.Errorf("failed to cast %T as []httpadapter.IconDTO", resp.body)
+	}
+	return resp.statusCode, *icons, nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/test/test_commons"
+	"iconrepo/test/testdata"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type consistencyTestSuite struct {
+	IconTestSuite
+}
+
+func TestConsistencyTestSuite(t *testing.T) {
+	t.Parallel()
+	for _, iconSuite := range IconTestSuites("api_consistency") {
+		suite.Run(t, &consistencyTestSuite{IconTestSuite: iconSuite})
+	}
+}
+
+func (s *consistencyTestSuite) TestRequiresAdminPermission() {
+	session := s.Client.MustLoginSetAllPerms()
+	statusCode, _, err := session.checkConsistency(false)
+	s.NoError(err)
+	s.Equal(http.StatusForbidden, statusCode)
+}
+
+func (s *consistencyTestSuite) TestConsistentRepository() {
+	dataIn, _ := testdata.Get()
+	session := s.mustLoginAsAdmin()
+	session.MustAddTestData(dataIn)
+
+	statusCode, report, err := session.checkConsistency(false)
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.True(report.IsConsistent())
+	s.Empty(report.UnrecognizedFiles)
+}
+
+func (s *consistencyTestSuite) TestReportsAndRepairsInconsistencies() {
+	dataIn, _ := testdata.Get()
+	session := s.mustLoginAsAdmin()
+	session.MustAddTestData(dataIn)
+
+	// A file committed behind the index' back
+	unindexedIcon := test_commons.TestData[1]
+	unindexedIconfile := unindexedIcon.Iconfiles[0]
+	s.NoError(s.TestBlobstoreController.AddIconfile(s.Ctx, unindexedIcon.Name, unindexedIconfile, unindexedIcon.ModifiedBy))
+
+	// An index entry without content
+	danglingIconfile := domain.IconfileDescriptor{Format: "png", Size: "1024px"}
+	s.NoError(s.indexingController.AddIconfileToIcon(s.Ctx, dataIn[0].Name, danglingIconfile, "ux", nil))
+
+	statusCode, report, err := session.checkConsistency(false)
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.False(report.Repaired)
+	s.Equal([]domain.IconfileRef{{IconName: unindexedIcon.Name, Iconfile: unindexedIconfile.IconfileDescriptor}}, report.MissingFromIndex)
+	s.Equal([]domain.IconfileRef{{IconName: dataIn[0].Name, Iconfile: danglingIconfile}}, report.MissingFromBlobstore)
+
+	statusCode, report, err = session.checkConsistency(true)
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.True(report.Repaired)
+
+	statusCode, report, err = session.checkConsistency(false)
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.True(report.IsConsistent())
+
+	reindexed, describeErr := s.indexingController.DescribeIcon(s.Ctx, unindexedIcon.Name)
+	s.NoError(describeErr)
+	s.Equal([]domain.IconfileDescriptor{unindexedIconfile.IconfileDescriptor}, reindexed.Iconfiles)
+
+	repaired, describeErr := s.indexingController.DescribeIcon(s.Ctx, dataIn[0].Name)
+	s.NoError(describeErr)
+	s.Equal(len(dataIn[0].Iconfiles), len(repaired.Iconfiles))
+}
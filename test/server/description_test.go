@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/test/testdata"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type descriptionTestSuite struct {
+	IconTestSuite
+}
+
+func TestDescriptionTestSuite(t *testing.T) {
+	t.Parallel()
+	for _, iconSuite := range IconTestSuites("api_description") {
+		suite.Run(t, &descriptionTestSuite{IconTestSuite: iconSuite})
+	}
+}
+
+func (s *descriptionTestSuite) TestSettingFailsWithoutPermission() {
+	dataIn, _ := testdata.Get()
+	iconIn := dataIn[0]
+
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	session.mustSetAllPermsExcept([]authr.PermissionID{authr.UPDATE_ICON})
+
+	statusCode, err := session.setIconDescription(iconIn.Name, "a shopping cart")
+	s.NoError(err)
+	s.Equal(http.StatusForbidden, statusCode)
+
+	_, icon, err := session.describeIcon(iconIn.Name)
+	s.NoError(err)
+	s.Empty(icon.Description)
+}
+
+func (s *descriptionTestSuite) TestDescriptionIsKeptInTheIconMetadata() {
+	dataIn, _ := testdata.Get()
+	iconIn := dataIn[0]
+
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	statusCode, err := session.setIconDescription(iconIn.Name, "a shopping cart")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	_, icon, err := session.describeIcon(iconIn.Name)
+	s.NoError(err)
+	s.Equal("a shopping cart", icon.Description)
+	metadata, err := s.TestBlobstoreController.GetIconMetadata(s.Ctx, iconIn.Name)
+	s.NoError(err)
+	s.Equal("a shopping cart", metadata.Description)
+
+	statusCode, err = session.setIconDescription(iconIn.Name, "")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	_, icon, err = session.describeIcon(iconIn.Name)
+	s.NoError(err)
+	s.Empty(icon.Description)
+}
+
+func (s *descriptionTestSuite) TestSettingTheDescriptionOfMissingIcon() {
+	session := s.Client.MustLoginSetAllPerms()
+
+	statusCode, err := session.setIconDescription("no-such-icon", "a shopping cart")
+	s.NoError(err)
+	s.Equal(http.StatusNotFound, statusCode)
+}
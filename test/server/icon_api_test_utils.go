@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
 	"iconrepo/internal/httpadapter"
 	"iconrepo/internal/repositories/blobstore/git"
 	blobstore_tests "iconrepo/test/repositories/blobstore"
@@ -118,3 +119,10 @@ func sortResponseIconPaths(respIcon httpadapter.IconDTO) {
 		return strings.Compare(respIcon.Tags[i], respIcon.Tags[j]) < 0
 	})
 }
+
+func (s *IconTestSuite) mustLoginAsAdmin() *apiTestSession {
+	session := s.Client.mustLogin()
+	permissions := append(authr.GetPermissionsForGroup(authr.ICON_EDITOR), authr.GetPermissionsForGroup(authr.REPO_ADMIN)...)
+	session.mustSetAuthorization(permissions)
+	return session
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"iconrepo/test/testdata"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type iconCatalogTestSuite struct {
+	IconTestSuite
+}
+
+func TestIconCatalogTestSuite(t *testing.T) {
+	t.Parallel()
+	for _, iconSuite := range IconTestSuites("api_iconcatalog") {
+		suite.Run(t, &iconCatalogTestSuite{IconTestSuite: iconSuite})
+	}
+}
+
+func etagOf(resp testResponse) string {
+	return http.Header(resp.headers).Get("ETag")
+}
+
+func (s *iconCatalogTestSuite) TestIconListIsNotSentAgainUntilChanged() {
+	dataIn, _ := testdata.Get()
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	resp, err := session.get(&testRequest{path: "/icon", respBodyProto: &[]interface{}{}})
+	s.NoError(err)
+	s.Equal(http.StatusOK, resp.statusCode)
+	etag := etagOf(resp)
+	s.NotEmpty(etag)
+
+	resp, err = session.get(&testRequest{path: "/icon", headers: map[string]string{"If-None-Match": etag}})
+	s.NoError(err)
+	s.Equal(http.StatusNotModified, resp.statusCode)
+	s.Equal(etag, etagOf(resp))
+
+	statusCode, err := session.addTag(dataIn[0].Name, "new-tag")
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+
+	resp, err = session.get(&testRequest{path: "/icon", headers: map[string]string{"If-None-Match": etag}, respBodyProto: &[]interface{}{}})
+	s.NoError(err)
+	s.Equal(http.StatusOK, resp.statusCode)
+	s.NotEqual(etag, etagOf(resp))
+}
+
+func (s *iconCatalogTestSuite) TestChangesSinceRevision() {
+	dataIn, dataOut := testdata.Get()
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	resp, full, err := session.getIconCatalog("", "")
+	s.NoError(err)
+	s.Equal(http.StatusOK, resp.statusCode)
+	s.True(full.Full)
+	s.NotEmpty(full.Revision)
+	s.AssertResponseIconSetsEqual(dataOut, full.Icons)
+	s.Empty(full.Deleted)
+
+	statusCode, err := session.addTag(dataIn[0].Name, "new-tag")
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+	statusCode, err = session.deleteIcon(dataIn[1].Name)
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	resp, delta, err := session.getIconCatalog(full.Revision, "")
+	s.NoError(err)
+	s.Equal(http.StatusOK, resp.statusCode)
+	s.False(delta.Full)
+	s.NotEqual(full.Revision, delta.Revision)
+	s.Equal(1, len(delta.Icons))
+	s.Equal(dataIn[0].Name, delta.Icons[0].Name)
+	s.Contains(delta.Icons[0].Tags, "new-tag")
+	s.Equal([]string{dataIn[1].Name}, delta.Deleted)
+
+	resp, upToDate, err := session.getIconCatalog(delta.Revision, "")
+	s.NoError(err)
+	s.Equal(http.StatusOK, resp.statusCode)
+	s.False(upToDate.Full)
+	s.Equal(delta.Revision, upToDate.Revision)
+	s.Empty(upToDate.Icons)
+	s.Empty(upToDate.Deleted)
+
+	resp, _, err = session.getIconCatalog(delta.Revision, etagOf(resp))
+	s.NoError(err)
+	s.Equal(http.StatusNotModified, resp.statusCode)
+
+	s.AssertEndState()
+}
+
+func (s *iconCatalogTestSuite) TestUnknownRevisionGetsAllIcons() {
+	dataIn, dataOut := testdata.Get()
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+
+	resp, catalog, err := session.getIconCatalog("no-such-revision.12", "")
+	s.NoError(err)
+	s.Equal(http.StatusOK, resp.statusCode)
+	s.True(catalog.Full)
+	s.AssertResponseIconSetsEqual(dataOut, catalog.Icons)
+}
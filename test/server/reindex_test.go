@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/test/testdata"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type reindexTestSuite struct {
+	IconTestSuite
+}
+
+func TestReindexTestSuite(t *testing.T) {
+	t.Parallel()
+	for _, iconSuite := range IconTestSuites("api_reindex") {
+		suite.Run(t, &reindexTestSuite{IconTestSuite: iconSuite})
+	}
+}
+
+func (s *reindexTestSuite) TestRequiresAdminPermission() {
+	session := s.Client.MustLoginSetAllPerms()
+	statusCode, _, err := session.reindex()
+	s.NoError(err)
+	s.Equal(http.StatusForbidden, statusCode)
+}
+
+func (s *reindexTestSuite) TestRefusesNonEmptyIndex() {
+	dataIn, _ := testdata.Get()
+	session := s.mustLoginAsAdmin()
+	session.MustAddTestData(dataIn)
+
+	statusCode, _, err := session.reindex()
+	s.NoError(err)
+	s.Equal(http.StatusConflict, statusCode)
+}
+
+func (s *reindexTestSuite) TestRebuildsLostIndex() {
+	dataIn, _ := testdata.Get()
+	session := s.mustLoginAsAdmin()
+	session.MustAddTestData(dataIn)
+	indexedBefore := map[string][]domain.IconfileDescriptor{}
+	for _, icon := range dataIn {
+		indexed, describeErr := s.indexingController.DescribeIcon(s.Ctx, icon.Name)
+		s.Require().NoError(describeErr)
+		indexedBefore[icon.Name] = indexed.Iconfiles
+	}
+
+	// The index is lost, the blobstore survives
+	s.NoError(s.indexingController.ResetRepo(s.Ctx, &s.config))
+
+	statusCode, report, err := session.reindex()
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.Equal(len(dataIn), report.Icons)
+	s.Empty(report.UnrecognizedFiles)
+
+	iconfileCount := 0
+	for _, icon := range dataIn {
+		iconfileCount += len(icon.Iconfiles)
+		reindexed, describeErr := s.indexingController.DescribeIcon(s.Ctx, icon.Name)
+		s.NoError(describeErr)
+		s.ElementsMatch(indexedBefore[icon.Name], reindexed.Iconfiles)
+	}
+	s.Equal(iconfileCount, report.Iconfiles)
+
+	statusCode, consistency, err := session.checkConsistency(false)
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.True(consistency.IsConsistent())
+}
+
+func (s *reindexTestSuite) TestRestoresTags() {
+	dataIn, _ := testdata.Get()
+	session := s.mustLoginAsAdmin()
+	session.MustAddTestData(dataIn)
+
+	iconName := dataIn[0].Name
+	for _, tag := range []string{"used-in-marvinjs", "some other tag", "to be removed"} {
+		statusCode, err := session.addTag(iconName, tag)
+		s.NoError(err)
+		s.Equal(http.StatusCreated, statusCode)
+	}
+	statusCode, err := session.removeTag(iconName, "to be removed")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	s.NoError(s.indexingController.ResetRepo(s.Ctx, &s.config))
+
+	statusCode, report, err := session.reindex()
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.Equal(2, report.Tags)
+	s.Empty(report.UnrecognizedFiles)
+
+	reindexed, describeErr := s.indexingController.DescribeIcon(s.Ctx, iconName)
+	s.NoError(describeErr)
+	s.ElementsMatch([]string{"used-in-marvinjs", "some other tag"}, reindexed.Tags)
+}
+
+func (s *reindexTestSuite) TestRestoresDescription() {
+	dataIn, _ := testdata.Get()
+	session := s.mustLoginAsAdmin()
+	session.MustAddTestData(dataIn)
+
+	iconName := dataIn[0].Name
+	statusCode, err := session.setIconDescription(iconName, "a shopping cart")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	s.NoError(s.indexingController.ResetRepo(s.Ctx, &s.config))
+
+	statusCode, _, err = session.reindex()
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+
+	reindexed, describeErr := s.indexingController.DescribeIcon(s.Ctx, iconName)
+	s.NoError(describeErr)
+	s.Equal("a shopping cart", reindexed.Description)
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"iconrepo/internal/app/domain"
+	"iconrepo/internal/app/security/authr"
+	"iconrepo/test/testdata"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type reviewTestSuite struct {
+	IconTestSuite
+}
+
+func TestReviewTestSuite(t *testing.T) {
+	t.Parallel()
+	for _, iconSuite := range IconTestSuites("api_review") {
+		suite.Run(t, &reviewTestSuite{IconTestSuite: iconSuite})
+	}
+}
+
+func (s *reviewTestSuite) BeforeTest(suiteName string, testName string) {
+	s.config.EnableReviewWorkflow = true
+	s.IconTestSuite.BeforeTest(suiteName, testName)
+}
+
+func (s *reviewTestSuite) TestDraftIsPublishedAfterApproval() {
+	dataIn, _ := testdata.Get()
+	iconIn := dataIn[0]
+	iconfile := iconIn.Iconfiles[0]
+
+	session := s.Client.MustLoginSetAllPerms()
+	statusCode, created, err := session.CreateIcon(iconIn.Name, iconfile.Content)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+	s.Equal(domain.ReviewStatusDraft, created.Paths[0].ReviewStatus)
+
+	session.mustSetAuthorization([]authr.PermissionID{})
+	s.Empty(session.mustDescribeAllIcons())
+	statusCode, _, err = session.describeIcon(iconIn.Name)
+	s.ErrorIs(err, errJSONUnmarshal)
+	s.Equal(http.StatusNotFound, statusCode)
+
+	session.mustSetAuthorization([]authr.PermissionID{authr.APPROVE_ICON})
+	statusCode, err = session.updateReviewStatus(iconIn.Name, iconfile.IconfileDescriptor, "published", "")
+	s.NoError(err)
+	s.Equal(http.StatusConflict, statusCode)
+
+	session.mustSetAuthorization(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	statusCode, err = session.updateReviewStatus(iconIn.Name, iconfile.IconfileDescriptor, "in-review", "")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	statusCode, err = session.updateReviewStatus(iconIn.Name, iconfile.IconfileDescriptor, "published", "")
+	s.NoError(err)
+	s.Equal(http.StatusForbidden, statusCode)
+
+	session.mustSetAuthorization([]authr.PermissionID{authr.APPROVE_ICON})
+	statusCode, err = session.updateReviewStatus(iconIn.Name, iconfile.IconfileDescriptor, "published", "looks good")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	session.mustSetAuthorization([]authr.PermissionID{})
+	icons := session.mustDescribeAllIcons()
+	s.Equal(1, len(icons))
+	s.Equal(domain.ReviewStatusPublished, icons[0].Paths[0].ReviewStatus)
+	s.getCheckIconfile(session, iconIn.Name, iconfile)
+
+	session.mustSetAuthorization([]authr.PermissionID{authr.APPROVE_ICON})
+	statusCode, page, err := session.getAuditEntries(url.Values{"icon": {iconIn.Name}}.Encode())
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.Equal(domain.AuditActionPublish, page.Entries[0].Action)
+	s.Equal("looks good", page.Entries[0].Comment)
+}
+
+func (s *reviewTestSuite) TestRejectedIconfileStaysInvisible() {
+	dataIn, _ := testdata.Get()
+	iconIn := dataIn[0]
+	iconfile := iconIn.Iconfiles[0]
+
+	session := s.Client.MustLoginSetAllPerms()
+	statusCode, _, err := session.CreateIcon(iconIn.Name, iconfile.Content)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+	statusCode, err = session.updateReviewStatus(iconIn.Name, iconfile.IconfileDescriptor, "in-review", "")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	session.mustSetAuthorization([]authr.PermissionID{authr.APPROVE_ICON})
+	statusCode, err = session.updateReviewStatus(iconIn.Name, iconfile.IconfileDescriptor, "rejected", "wrong colour")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	icons := session.mustDescribeAllIcons()
+	s.Equal(domain.ReviewStatusRejected, icons[0].Paths[0].ReviewStatus)
+
+	session.mustSetAuthorization([]authr.PermissionID{})
+	s.Empty(session.mustDescribeAllIcons())
+	resp, getErr := session.get(&testRequest{
+		path: getFilePath(iconIn.Name, iconfile.IconfileDescriptor),
+		jar:  session.cjar,
+	})
+	s.NoError(getErr)
+	s.Equal(http.StatusNotFound, resp.statusCode)
+}
+
+func (s *reviewTestSuite) TestTagsOfUnpublishedIconsAreHiddenFromConsumers() {
+	dataIn, _ := testdata.Get()
+	draftIcon := dataIn[0]
+	publishedIcon := dataIn[1]
+
+	session := s.Client.MustLoginSetAllPerms()
+	for _, icon := range []domain.Icon{draftIcon, publishedIcon} {
+		statusCode, _, err := session.CreateIcon(icon.Name, icon.Iconfiles[0].Content)
+		s.NoError(err)
+		s.Equal(http.StatusCreated, statusCode)
+	}
+	statusCode, err := session.addTag(draftIcon.Name, "draft-only")
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+	statusCode, err = session.addTag(publishedIcon.Name, "published")
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+	statusCode, err = session.updateReviewStatus(publishedIcon.Name, publishedIcon.Iconfiles[0].IconfileDescriptor, "in-review", "")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+	session.mustSetAuthorization([]authr.PermissionID{authr.APPROVE_ICON})
+	statusCode, err = session.updateReviewStatus(publishedIcon.Name, publishedIcon.Iconfiles[0].IconfileDescriptor, "published", "")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	statusCode, tags, err := session.getTags()
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.ElementsMatch([]string{"draft-only", "published"}, tags)
+
+	session.mustSetAuthorization([]authr.PermissionID{})
+	statusCode, tags, err = session.getTags()
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.Equal([]string{"published"}, tags)
+}
+
+func (s *reviewTestSuite) TestIconCatalogOfConsumersHasOnlyPublishedIconfiles() {
+	dataIn, _ := testdata.Get()
+	published := dataIn[0]
+	draft := dataIn[1]
+
+	session := s.Client.MustLoginSetAllPerms()
+	statusCode, _, err := session.CreateIcon(published.Name, published.Iconfiles[0].Content)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+	statusCode, err = session.updateReviewStatus(published.Name, published.Iconfiles[0].IconfileDescriptor, "in-review", "")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+	session.mustSetAuthorization([]authr.PermissionID{authr.APPROVE_ICON})
+	statusCode, err = session.updateReviewStatus(published.Name, published.Iconfiles[0].IconfileDescriptor, "published", "")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+	editorResp, _, err := session.getIconCatalog("", "")
+	s.NoError(err)
+
+	session.mustSetAuthorization([]authr.PermissionID{})
+	resp, catalog, err := session.getIconCatalog("", "")
+	s.NoError(err)
+	s.Equal(http.StatusOK, resp.statusCode)
+	s.Equal(1, len(catalog.Icons))
+	s.Equal(published.Name, catalog.Icons[0].Name)
+	s.NotEqual(etagOf(editorResp), etagOf(resp))
+
+	session.mustSetAuthorization(authr.GetPermissionsForGroup(authr.ICON_EDITOR))
+	statusCode, _, err = session.CreateIcon(draft.Name, draft.Iconfiles[0].Content)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+
+	session.mustSetAuthorization([]authr.PermissionID{})
+	resp, delta, err := session.getIconCatalog(catalog.Revision, "")
+	s.NoError(err)
+	s.Equal(http.StatusOK, resp.statusCode)
+	s.False(delta.Full)
+	s.Empty(delta.Icons)
+	s.Equal([]string{draft.Name}, delta.Deleted)
+}
+
+func (s *reviewTestSuite) TestReindexKeepsTheReviewStatus() {
+	dataIn, _ := testdata.Get()
+	iconIn := dataIn[0]
+	inReview := iconIn.Iconfiles[0]
+	draft := iconIn.Iconfiles[1]
+
+	session := s.mustLoginAsAdmin()
+	statusCode, _, err := session.CreateIcon(iconIn.Name, inReview.Content)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+	statusCode, _, err = session.addIconfile(iconIn.Name, draft)
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	statusCode, err = session.updateReviewStatus(iconIn.Name, inReview.IconfileDescriptor, "in-review", "")
+	s.NoError(err)
+	s.Equal(http.StatusNoContent, statusCode)
+
+	s.NoError(s.indexingController.ResetRepo(s.Ctx, &s.config))
+
+	statusCode, _, err = session.reindex()
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+
+	reindexed, describeErr := s.indexingController.DescribeIcon(s.Ctx, iconIn.Name)
+	s.NoError(describeErr)
+	reindexedInReview, findErr := reindexed.FindIconfile(inReview.IconfileDescriptor)
+	s.NoError(findErr)
+	s.Equal(domain.ReviewStatusInReview, reindexedInReview.ReviewStatus)
+	reindexedDraft, findErr := reindexed.FindIconfile(draft.IconfileDescriptor)
+	s.NoError(findErr)
+	s.Equal(domain.ReviewStatusDraft, reindexedDraft.ReviewStatus)
+
+	session.mustSetAuthorization([]authr.PermissionID{})
+	s.Empty(session.mustDescribeAllIcons())
+}
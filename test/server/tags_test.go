@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"iconrepo/internal/app/security/authr"
+	"iconrepo/internal/httpadapter"
 	"iconrepo/test/testdata"
 
 	"github.com/stretchr/testify/suite"
@@ -102,3 +103,26 @@ func (s *tagsTestSuite) TestDeletingSucceedssWithRequiredPermission() {
 	respIcons := session.mustDescribeAllIcons()
 	s.AssertResponseIconSetsEqual(dataOut, respIcons)
 }
+
+func (s *tagsTestSuite) TestIconsWithTagAreListed() {
+	dataIn, dataOut := testdata.Get()
+	tag := "Ahoj"
+
+	session := s.Client.MustLoginSetAllPerms()
+	session.MustAddTestData(dataIn)
+	statusCode, err := session.addTag(dataIn[0].Name, tag)
+	s.NoError(err)
+	s.Equal(http.StatusCreated, statusCode)
+
+	statusCode, respIcons, err := session.getIconsWithTag(tag)
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	iconOut := dataOut[0]
+	iconOut.Tags = []string{tag}
+	s.AssertResponseIconSetsEqual([]httpadapter.IconDTO{iconOut}, respIcons)
+
+	statusCode, respIcons, err = session.getIconsWithTag("no-such-tag")
+	s.NoError(err)
+	s.Equal(http.StatusOK, statusCode)
+	s.Empty(respIcons)
+}
@@ -78,11 +78,9 @@ func (s *ApiTestSuite) SetupSuite() {
 		s.config.DynamodbURL = os.Getenv("DYNAMODB_URL")
 	}
 
-	if len(os.Getenv("LOCAL_GIT_ONLY")) > 0 {
+	if len(os.Getenv("LOCAL_GIT_ONLY")) > 0 || len(os.Getenv("MEMORY_ONLY")) > 0 {
 		s.config.GitlabNamespacePath = ""
-	}
-
-	if len(os.Getenv("LOCAL_GIT_ONLY")) == 0 {
+	} else {
 		var apiTokenErr error
 		s.config.GitlabAccessToken, apiTokenErr = git_tests.GitTestGitlabAPIToken()
 		if apiTokenErr != nil {